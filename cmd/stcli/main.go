@@ -142,6 +142,7 @@ func main() {
 		showCommand,
 		operationCommand,
 		errorsCommand,
+		searchCommand,
 	}
 
 	tty := isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
@@ -8,6 +8,7 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/urfave/cli"
 )
@@ -43,9 +44,32 @@ var operationCommand = cli.Command{
 			ArgsUsage: "[folder id]",
 			Action:    expects(1, foldersOverride),
 		},
+		{
+			Name:      "folder-fetch",
+			Usage:     "Pull a file or directory in an index only folder",
+			ArgsUsage: "[folder id] [path]",
+			Action:    expects(2, folderFileOperation("db/fetch")),
+		},
+		{
+			Name:      "folder-evict",
+			Usage:     "Remove the local copy of a fetched file or directory in an index only folder",
+			ArgsUsage: "[folder id] [path]",
+			Action:    expects(2, folderFileOperation("db/evict")),
+		},
 	},
 }
 
+func folderFileOperation(endpoint string) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		client := c.App.Metadata["client"].(*APIClient)
+		query := url.Values{}
+		query.Set("folder", c.Args()[0])
+		query.Set("file", c.Args()[1])
+		_, err := client.Post(endpoint+"?"+query.Encode(), "")
+		return err
+	}
+}
+
 func foldersOverride(c *cli.Context) error {
 	client := c.App.Metadata["client"].(*APIClient)
 	cfg, err := getConfig(client)
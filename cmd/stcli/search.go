@@ -0,0 +1,80 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/urfave/cli"
+)
+
+var searchCommand = cli.Command{
+	Name:      "search",
+	HideHelp:  true,
+	Usage:     "Search for files in a folder",
+	ArgsUsage: "[folder id] [glob pattern]",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "regex", Usage: "Regular expression matched against the full path"},
+		cli.StringFlag{Name: "type", Usage: "Comma separated file types (file, directory, symlink)"},
+		cli.Int64Flag{Name: "min-size", Usage: "Minimum size in bytes"},
+		cli.Int64Flag{Name: "max-size", Usage: "Maximum size in bytes"},
+		cli.StringFlag{Name: "modified-after", Usage: "Modified at or after this time (RFC 3339)"},
+		cli.StringFlag{Name: "modified-before", Usage: "Modified before this time (RFC 3339)"},
+		cli.StringFlag{Name: "modified-by", Usage: "Last modified by this device (full or short ID)"},
+		cli.StringFlag{Name: "deleted", Usage: "Select only deleted (true) or existing (false) files"},
+		cli.StringFlag{Name: "invalid", Usage: "Select only invalid (true) or valid (false) files"},
+		cli.BoolFlag{Name: "needed", Usage: "Select only files needed by this device"},
+		cli.IntFlag{Name: "page", Value: 1, Usage: "Page of results to show"},
+		cli.IntFlag{Name: "perpage", Value: 100, Usage: "Number of results per page"},
+	},
+	Action: search,
+}
+
+func search(c *cli.Context) error {
+	if c.NArg() < 1 || c.NArg() > 2 {
+		return errors.New("expected a folder ID and an optional pattern")
+	}
+	client := c.App.Metadata["client"].(*APIClient)
+
+	values := url.Values{}
+	values.Set("folder", c.Args().Get(0))
+	if c.NArg() > 1 {
+		values.Set("pattern", c.Args().Get(1))
+	}
+	for flag, param := range map[string]string{
+		"regex":           "regex",
+		"type":            "type",
+		"modified-after":  "modifiedafter",
+		"modified-before": "modifiedbefore",
+		"modified-by":     "modifiedby",
+		"deleted":         "deleted",
+		"invalid":         "invalid",
+	} {
+		if val := c.String(flag); val != "" {
+			values.Set(param, val)
+		}
+	}
+	if val := c.Int64("min-size"); val > 0 {
+		values.Set("minsize", strconv.FormatInt(val, 10))
+	}
+	if val := c.Int64("max-size"); val > 0 {
+		values.Set("maxsize", strconv.FormatInt(val, 10))
+	}
+	if c.Bool("needed") {
+		values.Set("needed", "true")
+	}
+	values.Set("page", strconv.Itoa(c.Int("page")))
+	values.Set("perpage", strconv.Itoa(c.Int("perpage")))
+
+	response, err := client.Get("db/search?" + values.Encode())
+	if err != nil {
+		return err
+	}
+	return prettyPrintResponse(c, response)
+}
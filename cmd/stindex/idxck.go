@@ -53,6 +53,7 @@ func idxck(ldb backend.Backend) (success bool) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer it.Release()
 	for it.Next() {
 		key := it.Key()
 		switch key[0] {
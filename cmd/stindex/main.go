@@ -17,11 +17,12 @@ import (
 )
 
 func main() {
-	var mode string
+	var mode, to string
 	log.SetFlags(0)
 	log.SetOutput(os.Stdout)
 
-	flag.StringVar(&mode, "mode", "dump", "Mode of operation: dump, dumpsize, idxck")
+	flag.StringVar(&mode, "mode", "dump", "Mode of operation: dump, dumpsize, idxck, migrate")
+	flag.StringVar(&to, "to", "", "Database type to migrate to: leveldb, badger, bolt (for -mode migrate, with Syncthing stopped)")
 
 	flag.Parse()
 
@@ -34,6 +35,8 @@ func main() {
 	var err error
 	if looksLikeBadger(path) {
 		ldb, err = backend.OpenBadger(path)
+	} else if looksLikeBolt(path) {
+		ldb, err = backend.OpenBolt(path)
 	} else {
 		ldb, err = backend.OpenLevelDBRO(path)
 	}
@@ -52,6 +55,10 @@ func main() {
 		}
 	case "account":
 		account(ldb)
+	case "migrate":
+		if err := migrate(ldb, path, to); err != nil {
+			log.Fatal(err)
+		}
 	default:
 		fmt.Println("Unknown mode")
 	}
@@ -61,3 +68,9 @@ func looksLikeBadger(path string) bool {
 	_, err := os.Stat(filepath.Join(path, "KEYREGISTRY"))
 	return err == nil
 }
+
+func looksLikeBolt(path string) bool {
+	// LevelDB and Badger databases are directories.
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
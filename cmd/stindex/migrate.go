@@ -0,0 +1,147 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/locations"
+)
+
+type backendType struct {
+	open func(path string) (backend.Backend, error)
+	name string // the file or directory name Syncthing expects
+	env  string // the environment variable selecting it, if any
+}
+
+var backendTypes = map[string]backendType{
+	"leveldb": {backend.OpenLevelDBAuto, locations.LevelDBDir, ""},
+	"badger":  {backend.OpenBadger, locations.BadgerDir, "USE_BADGER"},
+	"bolt":    {backend.OpenBolt, locations.BoltDBFile, "USE_BOLT"},
+}
+
+// migrate copies the database at srcPath into a new database of the given
+// type next to it. The copy is made under a temporary name and verified.
+// The source database is then renamed out of the way and the copy renamed
+// into place, moving the source back if that fails. Syncthing uses the
+// database that's there, unless told otherwise by the environment.
+func migrate(src backend.Backend, srcPath, to string) error {
+	bt, ok := backendTypes[to]
+	if !ok {
+		return fmt.Errorf("unknown database type %q", to)
+	}
+	srcPath = filepath.Clean(srcPath)
+	dstPath := filepath.Join(filepath.Dir(srcPath), bt.name)
+	if dstPath == srcPath {
+		return fmt.Errorf("%s is already a %s database", srcPath, to)
+	}
+	if _, err := os.Lstat(dstPath); err == nil {
+		return fmt.Errorf("%s already exists", dstPath)
+	}
+
+	tmpPath := dstPath + ".tmp"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	dst, err := bt.open(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Copying database to", tmpPath)
+	if err := backend.CopyBackend(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("copying: %w", err)
+	}
+
+	// Verify what's actually on disk.
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if dst, err = bt.open(tmpPath); err != nil {
+		return err
+	}
+	log.Println("Verifying copy")
+	keys, err := compareBackends(src, dst)
+	if err == nil && !idxck(dst) {
+		err = errors.New("index check failed")
+	}
+	dst.Close()
+	if err != nil {
+		return fmt.Errorf("verifying: %w (the copy is left in %s)", err, tmpPath)
+	}
+
+	src.Close()
+	oldPath := srcPath + ".migrated." + time.Now().Format("20060102150405")
+	if err := os.Rename(srcPath, oldPath); err != nil {
+		return fmt.Errorf("moving aside the old database: %w (the copy is left in %s)", err, tmpPath)
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		if rbErr := os.Rename(oldPath, srcPath); rbErr != nil {
+			return fmt.Errorf("moving the copy into place: %w; moving back the old database: %v (it is in %s)", err, rbErr, oldPath)
+		}
+		return fmt.Errorf("moving the copy into place: %w (the copy is left in %s)", err, tmpPath)
+	}
+
+	log.Printf("Migrated %d keys to %s, the old database was moved to %s", keys, dstPath, oldPath)
+	var envs []string
+	for name, other := range backendTypes {
+		if name != to && other.env != "" {
+			envs = append(envs, other.env)
+		}
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		log.Printf("Make sure %s isn't set in Syncthing's environment, or it won't use the new database", env)
+	}
+	return nil
+}
+
+// compareBackends returns the number of keys in the backends, or an error if
+// they don't contain exactly the same keys and values.
+func compareBackends(a, b backend.Backend) (int, error) {
+	ait, err := a.NewPrefixIterator(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer ait.Release()
+	bit, err := b.NewPrefixIterator(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer bit.Release()
+
+	keys := 0
+	for {
+		aok, bok := ait.Next(), bit.Next()
+		if !aok || !bok {
+			if aok || bok {
+				return keys, errors.New("number of keys differ")
+			}
+			break
+		}
+		if !bytes.Equal(ait.Key(), bit.Key()) {
+			return keys, fmt.Errorf("key mismatch: %x != %x", ait.Key(), bit.Key())
+		}
+		if !bytes.Equal(ait.Value(), bit.Value()) {
+			return keys, fmt.Errorf("value mismatch for key %x", ait.Key())
+		}
+		keys++
+	}
+	if err := ait.Error(); err != nil {
+		return keys, err
+	}
+	return keys, bit.Error()
+}
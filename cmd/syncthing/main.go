@@ -7,6 +7,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"flag"
@@ -165,6 +166,8 @@ type RuntimeOptions struct {
 	confDir          string
 	dataDir          string
 	resetDatabase    bool
+	exportDatabase   string
+	importDatabase   string
 	showVersion      bool
 	showPaths        bool
 	showDeviceId     bool
@@ -239,6 +242,8 @@ func parseCommandLineOptions() RuntimeOptions {
 	flag.BoolVar(&options.noRestart, "no-restart", options.noRestart, "Do not restart Syncthing when exiting due to API/GUI command, upgrade, or crash")
 	flag.BoolVar(&options.resetDatabase, "reset-database", false, "Reset the database, forcing a full rescan and resync")
 	flag.BoolVar(&options.ResetDeltaIdxs, "reset-deltas", false, "Reset delta index IDs, forcing a full index exchange")
+	flag.StringVar(&options.exportDatabase, "export-database", "", "Export the database to the given file (\"-\" for stdout), then exit")
+	flag.StringVar(&options.importDatabase, "import-database", "", "Import the database from the given file (\"-\" for stdin), then exit")
 	flag.BoolVar(&options.doUpgrade, "upgrade", false, "Perform upgrade")
 	flag.BoolVar(&options.doUpgradeCheck, "upgrade-check", false, "Check for available upgrade")
 	flag.BoolVar(&options.showVersion, "version", false, "Show version")
@@ -434,6 +439,23 @@ func main() {
 		return
 	}
 
+	if options.exportDatabase != "" {
+		if err := exportDB(options.exportDatabase); err != nil {
+			l.Warnln("Exporting database:", err)
+			os.Exit(syncthing.ExitError.AsInt())
+		}
+		return
+	}
+
+	if options.importDatabase != "" {
+		if err := importDB(options.importDatabase); err != nil {
+			l.Warnln("Importing database:", err)
+			os.Exit(syncthing.ExitError.AsInt())
+		}
+		l.Infoln("Successfully imported database.")
+		return
+	}
+
 	if innerProcess {
 		syncthingMain(options)
 	} else {
@@ -795,6 +817,78 @@ func resetDB() error {
 	return os.RemoveAll(locations.Get(locations.Database))
 }
 
+// openDB opens the database for offline use, bringing it up to date with
+// the current schema.
+func openDB() (*db.Lowlevel, error) {
+	backend, err := syncthing.OpenDBBackend(locations.Get(locations.Database), config.TuningAuto)
+	if err != nil {
+		return nil, err
+	}
+	ldb := db.NewLowlevel(backend)
+	if err := db.UpdateSchema(ldb); err != nil {
+		ldb.Close()
+		return nil, err
+	}
+	return ldb, nil
+}
+
+func exportDB(path string) error {
+	ldb, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+
+	fd := os.Stdout
+	if path != "-" {
+		if fd, err = os.Create(path); err != nil {
+			return err
+		}
+	}
+	w := bufio.NewWriter(fd)
+	err = ldb.Export(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if path != "-" {
+		if closeErr := fd.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func importDB(path string) error {
+	ldb, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+
+	var fd *os.File
+	if path == "-" {
+		// The import is read twice, to validate it before changing
+		// anything, so stdin is copied to a file first.
+		if fd, err = ioutil.TempFile("", "syncthing-import-"); err != nil {
+			return err
+		}
+		defer os.Remove(fd.Name())
+		defer fd.Close()
+		if _, err := io.Copy(fd, os.Stdin); err != nil {
+			return err
+		}
+		if _, err := fd.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	} else {
+		if fd, err = os.Open(path); err != nil {
+			return err
+		}
+		defer fd.Close()
+	}
+	return ldb.Import(fd)
+}
+
 func ensureDir(dir string, mode fs.FileMode) error {
 	fs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
 	err := fs.MkdirAll(".", mode)
@@ -46,6 +46,7 @@ angular.module('syncthing.core')
         $scope.neededFolder = '';
         $scope.failed = {};
         $scope.localChanged = {};
+        $scope.search = {};
         $scope.scanProgress = {};
         $scope.themes = [];
         $scope.globalChangeEvents = {};
@@ -63,6 +64,7 @@ angular.module('syncthing.core')
             sharedDevices: {},
             selectedDevices: {},
             unrelatedDevices: {},
+            encryptionPasswords: {},
             type: "sendreceive",
             rescanIntervalS: 3600,
             fsWatcherDelayS: 10,
@@ -207,7 +209,7 @@ angular.module('syncthing.core')
 
                 // If a folder finished scanning, then refresh folder stats
                 // to update last scan time.
-                if (data.from === 'scanning' && data.to === 'idle') {
+                if (data.from === 'scanning' && (data.to === 'idle' || data.to === 'scheduled')) {
                     refreshFolderStats();
                 }
             }
@@ -818,7 +820,7 @@ angular.module('syncthing.core')
             if (status === 'idle' || status === 'localadditions') {
                 return 'success';
             }
-            if (status == 'paused') {
+            if (status == 'paused' || status == 'scheduled') {
                 return 'default';
             }
             if (status === 'syncing' || status === 'sync-preparing' || status === 'scanning') {
@@ -1722,11 +1724,13 @@ angular.module('syncthing.core')
             var devMap = deviceMap($scope.devices)
             $scope.currentFolder.sharedDevices = [];
             $scope.currentFolder.selectedDevices = {};
+            $scope.currentFolder.encryptionPasswords = {};
             $scope.currentFolder.devices.forEach(function (n) {
                 if (n.deviceID !== $scope.myID) {
                     $scope.currentFolder.sharedDevices.push(devMap[n.deviceID]);
                 }
                 $scope.currentFolder.selectedDevices[n.deviceID] = true;
+                $scope.currentFolder.encryptionPasswords[n.deviceID] = n.encryptionPassword;
             });
             $scope.currentFolder.unrelatedDevices = $scope.devices.filter(function (n) {
                 return n.deviceID !== $scope.myID
@@ -1836,8 +1840,10 @@ angular.module('syncthing.core')
             var folderCfg = angular.copy($scope.currentFolder);
             folderCfg.selectedDevices[$scope.myID] = true;
             var newDevices = [];
+            var encryptionPasswords = folderCfg.encryptionPasswords || {};
             folderCfg.devices.forEach(function (dev) {
                 if (folderCfg.selectedDevices[dev.deviceID] === true) {
+                    dev.encryptionPassword = encryptionPasswords[dev.deviceID] || "";
                     newDevices.push(dev);
                     delete folderCfg.selectedDevices[dev.deviceID];
                 };
@@ -1845,7 +1851,8 @@ angular.module('syncthing.core')
             for (var deviceID in folderCfg.selectedDevices) {
                 if (folderCfg.selectedDevices[deviceID] === true) {
                     newDevices.push({
-                        deviceID: deviceID
+                        deviceID: deviceID,
+                        encryptionPassword: encryptionPasswords[deviceID] || ""
                     });
                 }
             }
@@ -1853,6 +1860,7 @@ angular.module('syncthing.core')
             delete folderCfg.sharedDevices;
             delete folderCfg.selectedDevices;
             delete folderCfg.unrelatedDevices;
+            delete folderCfg.encryptionPasswords;
 
             if (folderCfg.fileVersioningSelector === "trashcan") {
                 folderCfg.versioning = {
@@ -2292,6 +2300,76 @@ angular.module('syncthing.core')
             });
         };
 
+        $scope.showSearch = function (folder) {
+            $scope.search = {
+                folder: folder,
+                query: {
+                    regexMode: false,
+                    pattern: '',
+                    type: '',
+                    deleted: '',
+                    needed: false,
+                },
+                page: 1,
+                perpage: 25,
+            };
+            $('#search').modal().one('hidden.bs.modal', function () {
+                $scope.search = {};
+            });
+        };
+
+        $scope.refreshSearch = function (page) {
+            var search = $scope.search;
+            if (!search.folder) {
+                return;
+            }
+            var q = search.query;
+            var url = urlbase + '/db/search?folder=' + encodeURIComponent(search.folder);
+            if (q.pattern) {
+                url += (q.regexMode ? '&regex=' : '&pattern=') + encodeURIComponent(q.pattern);
+            }
+            if (q.type) {
+                url += '&type=' + q.type;
+            }
+            if (q.minSize) {
+                url += '&minsize=' + Math.round(q.minSize * 1024);
+            }
+            if (q.maxSize) {
+                url += '&maxsize=' + Math.round(q.maxSize * 1024);
+            }
+            if (q.modifiedAfter) {
+                url += '&modifiedafter=' + encodeURIComponent(new Date(q.modifiedAfter).toISOString());
+            }
+            if (q.modifiedBefore) {
+                url += '&modifiedbefore=' + encodeURIComponent(new Date(q.modifiedBefore).toISOString());
+            }
+            if (q.modifiedBy) {
+                url += '&modifiedby=' + encodeURIComponent(q.modifiedBy);
+            }
+            if (q.deleted) {
+                url += '&deleted=' + q.deleted;
+            }
+            if (q.needed) {
+                url += '&needed=true';
+            }
+            url += '&page=' + page + '&perpage=' + search.perpage;
+            search.searching = true;
+            search.error = undefined;
+            $http.get(url).success(function (data) {
+                search.results = data;
+                search.page = page;
+                search.searching = false;
+            }).error(function (err, status) {
+                search.searching = false;
+                if (status === 400) {
+                    search.results = undefined;
+                    search.error = err;
+                    return;
+                }
+                $scope.emitHTTPError(err);
+            });
+        };
+
         $scope.revert = function (folder) {
             $http.post(urlbase + "/db/revert?folder=" + encodeURIComponent(folder));
         };
@@ -7,6 +7,7 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -53,6 +54,7 @@ import (
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/ur"
 	"github.com/syncthing/syncthing/lib/util"
+	"github.com/syncthing/syncthing/lib/webhooks"
 )
 
 // matches a bcrypt hash and not too much else
@@ -79,6 +81,7 @@ type service struct {
 	discoverer           discover.CachingMux
 	connectionsService   connections.Service
 	fss                  model.FolderSummaryService
+	webhooks             webhooks.Service
 	urService            *ur.Service
 	systemConfigMut      sync.Mutex // serializes posts to /rest/system/config
 	contr                Controller
@@ -106,7 +109,7 @@ type Service interface {
 	WaitForStart() error
 }
 
-func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonName string, m model.Model, defaultSub, diskSub events.BufferedSubscription, evLogger events.Logger, discoverer discover.CachingMux, connectionsService connections.Service, urService *ur.Service, fss model.FolderSummaryService, errors, systemLog logger.Recorder, contr Controller, noUpgrade bool) Service {
+func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonName string, m model.Model, defaultSub, diskSub events.BufferedSubscription, evLogger events.Logger, discoverer discover.CachingMux, connectionsService connections.Service, urService *ur.Service, fss model.FolderSummaryService, webhooks webhooks.Service, errors, systemLog logger.Recorder, contr Controller, noUpgrade bool) Service {
 	s := &service{
 		id:      id,
 		cfg:     cfg,
@@ -121,6 +124,7 @@ func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonNam
 		discoverer:           discoverer,
 		connectionsService:   connectionsService,
 		fss:                  fss,
+		webhooks:             webhooks,
 		urService:            urService,
 		systemConfigMut:      sync.NewMutex(),
 		guiErrors:            errors,
@@ -245,13 +249,17 @@ func (s *service) serve(ctx context.Context) {
 	// The GET handlers
 	getRestMux := http.NewServeMux()
 	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)              // device folder
+	getRestMux.HandleFunc("/rest/db/conflicts", s.getDBConflicts)                // folder
 	getRestMux.HandleFunc("/rest/db/file", s.getDBFile)                          // folder file
+	getRestMux.HandleFunc("/rest/db/history", s.getDBHistory)                    // folder file
 	getRestMux.HandleFunc("/rest/db/ignores", s.getDBIgnores)                    // folder
 	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                          // folder [perpage] [page]
 	getRestMux.HandleFunc("/rest/db/remoteneed", s.getDBRemoteNeed)              // device folder [perpage] [page]
 	getRestMux.HandleFunc("/rest/db/localchanged", s.getDBLocalChanged)          // folder
 	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                      // folder
 	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                      // folder [prefix] [dirsonly] [levels]
+	getRestMux.HandleFunc("/rest/db/export", s.getDBExport)                      // -
+	getRestMux.HandleFunc("/rest/db/search", s.getDBSearch)                      // folder [pattern] [regex] [type] [minsize] [maxsize] [modifiedafter] [modifiedbefore] [modifiedby] [deleted] [invalid] [needed] [perpage] [page]
 	getRestMux.HandleFunc("/rest/folder/versions", s.getFolderVersions)          // folder
 	getRestMux.HandleFunc("/rest/folder/errors", s.getFolderErrors)              // folder
 	getRestMux.HandleFunc("/rest/folder/pullerrors", s.getFolderErrors)          // folder (deprecated)
@@ -283,7 +291,11 @@ func (s *service) serve(ctx context.Context) {
 	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                    // folder
 	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                  // folder
 	postRestMux.HandleFunc("/rest/db/revert", s.postDBRevert)                      // folder
+	postRestMux.HandleFunc("/rest/db/fetch", s.postDBFetch)                        // folder file
+	postRestMux.HandleFunc("/rest/db/evict", s.postDBEvict)                        // folder file
+	postRestMux.HandleFunc("/rest/db/conflicts/resolve", s.postDBConflictsResolve) // folder file winner
 	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                          // folder [sub...] [delay]
+	postRestMux.HandleFunc("/rest/db/import", s.postDBImport)                      // <body>
 	postRestMux.HandleFunc("/rest/folder/versions", s.postFolderVersionsRestore)   // folder <body>
 	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)              // <body>
 	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                // <body>
@@ -702,6 +714,233 @@ func (s *service) postDBRevert(w http.ResponseWriter, r *http.Request) {
 	go s.model.Revert(folder)
 }
 
+func (s *service) postDBFetch(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if err := s.model.Fetch(qs.Get("folder"), qs.Get("file")); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (s *service) postDBEvict(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if err := s.model.Evict(qs.Get("folder"), qs.Get("file")); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (s *service) getDBConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := s.model.Conflicts(r.URL.Query().Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, conflicts)
+}
+
+// postDBConflictsResolve keeps the conflict copy given by file, or the
+// original it conflicts with, depending on whether winner is "conflict" or
+// "original".
+func (s *service) postDBConflictsResolve(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	var keepConflict bool
+	switch qs.Get("winner") {
+	case "conflict":
+		keepConflict = true
+	case "original":
+	default:
+		http.Error(w, `winner must be "conflict" or "original"`, http.StatusBadRequest)
+		return
+	}
+	if err := s.model.ResolveConflict(qs.Get("folder"), qs.Get("file"), keepConflict); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (s *service) getDBExport(w http.ResponseWriter, r *http.Request) {
+	filename := fmt.Sprintf("syncthing-database-%s-%s.jsonl", s.id.Short().String(), time.Now().Format("2006-01-02T150405"))
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+
+	// The response has already started by the time anything can go wrong.
+	// A failed export lacks the end marker, which makes the import fail.
+	bw := bufio.NewWriter(w)
+	if err := s.model.ExportDatabase(bw); err != nil {
+		l.Warnln("Exporting database:", err)
+		return
+	}
+	bw.Flush()
+}
+
+// postDBImport stages the database export in the body, to be imported when
+// restarting. Importing while running would replace the data under the
+// running folders.
+func (s *service) postDBImport(w http.ResponseWriter, r *http.Request) {
+	if err := stageDBImport(locations.Get(locations.DatabaseImport), r.Body); err != nil {
+		l.Warnln("Staging database import:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.flushResponse(`{"ok": "restarting"}`, w)
+	go s.contr.Restart()
+}
+
+// stageDBImport writes the export to path, once it has been validated in
+// full.
+func stageDBImport(path string, r io.Reader) error {
+	tmp := path + ".tmp"
+	fd, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	_, err = io.Copy(fd, r)
+	if err == nil {
+		_, err = fd.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = db.ValidateExport(fd)
+	}
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *service) getDBSearch(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	folder := qs.Get("folder")
+	query, err := s.searchQuery(qs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, perpage := getPagingParams(qs)
+
+	snap, err := s.model.DBSnapshot(folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer snap.Release()
+
+	// The results are written as they are found, so that we never need
+	// to keep more than one of them in memory.
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	skip := (page - 1) * perpage
+	n := 0
+	more := false
+	err = snap.Search(query, func(f db.FileInfoTruncated) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if n == perpage {
+			more = true
+			return false
+		}
+		if n == 0 {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprintf(bw, `{"page":%d,"perpage":%d,"files":[`, page, perpage)
+		} else {
+			bw.WriteString(",")
+		}
+		enc.Encode(jsonFileInfoTrunc(f))
+		n++
+		return true
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n == 0 {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(bw, `{"page":%d,"perpage":%d,"files":[`, page, perpage)
+	}
+	fmt.Fprintf(bw, "],\"more\":%v}\n", more)
+	bw.Flush()
+}
+
+// searchQuery returns the search query described by the request parameters
+func (s *service) searchQuery(qs url.Values) (db.SearchQuery, error) {
+	var query db.SearchQuery
+	var err error
+
+	query.Pattern = qs.Get("pattern")
+	if regex := qs.Get("regex"); regex != "" {
+		if query.Regexp, err = regexp.Compile(regex); err != nil {
+			return query, err
+		}
+	}
+
+	if types := qs.Get("type"); types != "" {
+		for _, name := range strings.Split(types, ",") {
+			t, ok := protocol.FileInfoType_value[strings.ToUpper(strings.TrimSpace(name))]
+			if !ok {
+				return query, fmt.Errorf("unknown file type %q", name)
+			}
+			query.Types = append(query.Types, protocol.FileInfoType(t))
+		}
+	}
+
+	for param, dst := range map[string]*int64{"minsize": &query.MinSize, "maxsize": &query.MaxSize} {
+		if val := qs.Get(param); val != "" {
+			if *dst, err = strconv.ParseInt(val, 10, 64); err != nil {
+				return query, fmt.Errorf("%s: %w", param, err)
+			}
+		}
+	}
+
+	for param, dst := range map[string]*time.Time{"modifiedafter": &query.ModifiedAfter, "modifiedbefore": &query.ModifiedBefore} {
+		if val := qs.Get(param); val != "" {
+			if *dst, err = time.Parse(time.RFC3339, val); err != nil {
+				return query, fmt.Errorf("%s: %w", param, err)
+			}
+		}
+	}
+
+	if by := qs.Get("modifiedby"); by != "" {
+		// Either a full device ID, or the short form we show for the
+		// modifiedBy attribute of files.
+		if id, err := protocol.DeviceIDFromString(by); err == nil {
+			query.ModifiedBy = id.Short()
+		} else {
+			for id := range s.cfg.Devices() {
+				if id.Short().String() == strings.ToUpper(by) {
+					query.ModifiedBy = id.Short()
+					break
+				}
+			}
+			if query.ModifiedBy == 0 {
+				return query, fmt.Errorf("unknown device %q", by)
+			}
+		}
+	}
+
+	for param, dst := range map[string]**bool{"deleted": &query.Deleted, "invalid": &query.Invalid} {
+		if val := qs.Get(param); val != "" {
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return query, fmt.Errorf("%s: %w", param, err)
+			}
+			*dst = &b
+		}
+	}
+
+	if val := qs.Get("needed"); val != "" {
+		if query.Needed, err = strconv.ParseBool(val); err != nil {
+			return query, fmt.Errorf("needed: %w", err)
+		}
+	}
+
+	return query, nil
+}
+
 func getPagingParams(qs url.Values) (int, int) {
 	page, err := strconv.Atoi(qs.Get("page"))
 	if err != nil || page < 1 {
@@ -825,6 +1064,48 @@ func (s *service) getDBFile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *service) getDBHistory(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+	file := qs.Get("file")
+
+	snap, err := s.model.DBSnapshot(folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer snap.Release()
+
+	entries := snap.FileHistory(file)
+	history := make([]map[string]interface{}, len(entries))
+	for i, e := range entries {
+		device, err := protocol.DeviceIDFromBytes(e.Device)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if device == protocol.LocalDeviceID {
+			device = s.id
+		}
+		history[i] = map[string]interface{}{
+			"recorded":   e.RecordedAt(),
+			"device":     device,
+			"type":       e.Type.String(),
+			"size":       e.Size,
+			"deleted":    e.Deleted,
+			"modified":   e.ModTime(),
+			"modifiedBy": e.ModifiedBy.String(),
+			"version":    jsonVersionVector(e.Version),
+		}
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"folder":  folder,
+		"file":    file,
+		"history": history,
+	})
+}
+
 func (s *service) getSystemConfig(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, s.cfg.RawCopy())
 }
@@ -952,6 +1233,7 @@ func (s *service) getSystemStatus(w http.ResponseWriter, r *http.Request) {
 	res["startTime"] = ur.StartTime
 	res["guiAddressOverridden"] = s.cfg.GUI().IsOverridden()
 	res["guiAddressUsed"] = s.listenerAddr.String()
+	res["webhookStatus"] = s.webhooks.Status()
 
 	sendJSON(w, res)
 }
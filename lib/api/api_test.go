@@ -34,6 +34,7 @@ import (
 	"github.com/syncthing/syncthing/lib/sync"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/ur"
+	"github.com/syncthing/syncthing/lib/webhooks"
 	"github.com/thejerf/suture"
 )
 
@@ -108,7 +109,7 @@ func TestStopAfterBrokenConfig(t *testing.T) {
 	}
 	w := config.Wrap("/dev/null", cfg, events.NoopLogger)
 
-	srv := New(protocol.LocalDeviceID, w, "", "syncthing", nil, nil, nil, events.NoopLogger, nil, nil, nil, nil, nil, nil, nil, false).(*service)
+	srv := New(protocol.LocalDeviceID, w, "", "syncthing", nil, nil, nil, events.NoopLogger, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
 	defer os.Remove(token)
 	srv.started = make(chan string)
 
@@ -287,6 +288,19 @@ func TestAPIServiceRequests(t *testing.T) {
 			Type:   "application/json",
 			Prefix: "null",
 		},
+		{
+			URL:  "/rest/db/export",
+			Code: 200,
+			Type: "application/x-ndjson",
+		},
+		{
+			URL:  "/rest/db/search?folder=default&regex=(",
+			Code: 400,
+		},
+		{
+			URL:  "/rest/db/search?folder=default&type=file,nonsense",
+			Code: 400,
+		},
 
 		// /rest/stats
 		{
@@ -533,7 +547,7 @@ func startHTTP(cfg *mockedConfig) (string, *suture.Supervisor, error) {
 
 	// Instantiate the API service
 	urService := ur.New(cfg, m, connections, false)
-	svc := New(protocol.LocalDeviceID, cfg, assetDir, "syncthing", m, eventSub, diskEventSub, events.NoopLogger, discoverer, connections, urService, &mockedFolderSummaryService{}, errorLog, systemLog, nil, false).(*service)
+	svc := New(protocol.LocalDeviceID, cfg, assetDir, "syncthing", m, eventSub, diskEventSub, events.NoopLogger, discoverer, connections, urService, &mockedFolderSummaryService{}, webhooks.New(cfg, events.NoopLogger, ""), errorLog, systemLog, nil, false).(*service)
 	defer os.Remove(token)
 	svc.started = addrChan
 
@@ -1032,7 +1046,7 @@ func TestEventMasks(t *testing.T) {
 	cfg := new(mockedConfig)
 	defSub := new(mockedEventSub)
 	diskSub := new(mockedEventSub)
-	svc := New(protocol.LocalDeviceID, cfg, "", "syncthing", nil, defSub, diskSub, events.NoopLogger, nil, nil, nil, nil, nil, nil, nil, false).(*service)
+	svc := New(protocol.LocalDeviceID, cfg, "", "syncthing", nil, defSub, diskSub, events.NoopLogger, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
 	defer os.Remove(token)
 
 	if mask := svc.getEventMask(""); mask != DefaultEventMask {
@@ -1186,6 +1200,36 @@ func equalStrings(a, b []string) bool {
 	return true
 }
 
+func TestStageDBImport(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "index-import.jsonl")
+
+	// A truncated export isn't staged.
+	if err := stageDBImport(path, strings.NewReader(`{"type":"header","version":1}`+"\n")); err == nil {
+		t.Error("expected an error staging a truncated export")
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Error("truncated export was staged")
+	}
+
+	export := `{"type":"header","version":1}` + "\n" + `{"type":"end"}` + "\n"
+	if err := stageDBImport(path, strings.NewReader(export)); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := ioutil.ReadFile(path); err != nil || string(bs) != export {
+		t.Errorf("staged export is %q (%v)", bs, err)
+	}
+	if files, _ := ioutil.ReadDir(tmpDir); len(files) != 1 {
+		t.Errorf("expected only the staged export, got %d files", len(files))
+	}
+}
+
 // runningInContainer returns true if we are inside Docker or LXC. It might
 // be prone to false negatives if things change in the future, but likely
 // not false positives.
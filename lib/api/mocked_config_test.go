@@ -98,6 +98,10 @@ func (c *mockedConfig) FolderList() []config.FolderConfiguration {
 	return nil
 }
 
+func (c *mockedConfig) FolderPasswords(device protocol.DeviceID) map[string]string {
+	return nil
+}
+
 func (c *mockedConfig) SetFolder(fld config.FolderConfiguration) (config.Waiter, error) {
 	return noopWaiter{}, nil
 }
@@ -7,6 +7,7 @@
 package api
 
 import (
+	"io"
 	"net"
 	"time"
 
@@ -32,6 +33,22 @@ func (m *mockedModel) Override(folder string) {}
 
 func (m *mockedModel) Revert(folder string) {}
 
+func (m *mockedModel) Fetch(folder, file string) error {
+	return nil
+}
+
+func (m *mockedModel) Evict(folder, file string) error {
+	return nil
+}
+
+func (m *mockedModel) Conflicts(folder string) ([]model.Conflict, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) ResolveConflict(folder, name string, keepConflict bool) error {
+	return nil
+}
+
 func (m *mockedModel) NeedFolderFiles(folder string, page, perpage int) ([]db.FileInfoTruncated, []db.FileInfoTruncated, []db.FileInfoTruncated) {
 	return nil, nil, nil
 }
@@ -63,6 +80,10 @@ func (m *mockedModel) CurrentGlobalFile(folder string, file string) (protocol.Fi
 func (m *mockedModel) ResetFolder(folder string) {
 }
 
+func (m *mockedModel) ExportDatabase(w io.Writer) error {
+	return nil
+}
+
 func (m *mockedModel) Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) []model.Availability {
 	return nil
 }
@@ -124,6 +145,10 @@ func (m *mockedModel) WatchError(folder string) error {
 	return nil
 }
 
+func (m *mockedModel) QuotaBlocked(folder string) db.Counts {
+	return db.Counts{}
+}
+
 func (m *mockedModel) Serve() {}
 func (m *mockedModel) Stop()  {}
 
@@ -135,7 +160,7 @@ func (m *mockedModel) IndexUpdate(deviceID protocol.DeviceID, folder string, fil
 	return nil
 }
 
-func (m *mockedModel) Request(deviceID protocol.DeviceID, folder, name string, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (protocol.RequestResponse, error) {
+func (m *mockedModel) Request(deviceID protocol.DeviceID, folder, name string, blockNo, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (protocol.RequestResponse, error) {
 	return nil, nil
 }
 
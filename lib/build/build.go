@@ -45,6 +45,7 @@ var (
 		"STNORESTART",
 		"STNOUPGRADE",
 		"USE_BADGER",
+		"USE_BOLT",
 	}
 )
 
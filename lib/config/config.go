@@ -186,15 +186,16 @@ func ReadJSON(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 }
 
 type Configuration struct {
-	Version        int                   `xml:"version,attr" json:"version"`
-	Folders        []FolderConfiguration `xml:"folder" json:"folders"`
-	Devices        []DeviceConfiguration `xml:"device" json:"devices"`
-	GUI            GUIConfiguration      `xml:"gui" json:"gui"`
-	LDAP           LDAPConfiguration     `xml:"ldap" json:"ldap"`
-	Options        OptionsConfiguration  `xml:"options" json:"options"`
-	IgnoredDevices []ObservedDevice      `xml:"remoteIgnoredDevice" json:"remoteIgnoredDevices"`
-	PendingDevices []ObservedDevice      `xml:"pendingDevice" json:"pendingDevices"`
-	XMLName        xml.Name              `xml:"configuration" json:"-"`
+	Version        int                    `xml:"version,attr" json:"version"`
+	Folders        []FolderConfiguration  `xml:"folder" json:"folders"`
+	Devices        []DeviceConfiguration  `xml:"device" json:"devices"`
+	GUI            GUIConfiguration       `xml:"gui" json:"gui"`
+	LDAP           LDAPConfiguration      `xml:"ldap" json:"ldap"`
+	Options        OptionsConfiguration   `xml:"options" json:"options"`
+	IgnoredDevices []ObservedDevice       `xml:"remoteIgnoredDevice" json:"remoteIgnoredDevices"`
+	PendingDevices []ObservedDevice       `xml:"pendingDevice" json:"pendingDevices"`
+	Webhooks       []WebhookConfiguration `xml:"webhook" json:"webhooks"`
+	XMLName        xml.Name               `xml:"configuration" json:"-"`
 
 	MyID            protocol.DeviceID `xml:"-" json:"-"` // Provided by the instantiator.
 	OriginalVersion int               `xml:"-" json:"-"` // The version we read from disk, before any conversion
@@ -225,6 +226,11 @@ func (cfg Configuration) Copy() Configuration {
 	newCfg.PendingDevices = make([]ObservedDevice, len(cfg.PendingDevices))
 	copy(newCfg.PendingDevices, cfg.PendingDevices)
 
+	newCfg.Webhooks = append([]WebhookConfiguration(nil), cfg.Webhooks...)
+	for i := range newCfg.Webhooks {
+		newCfg.Webhooks[i] = cfg.Webhooks[i].Copy()
+	}
+
 	return newCfg
 }
 
@@ -308,6 +314,8 @@ func (cfg *Configuration) clean() error {
 		existingFolders[folder.ID] = folder
 	}
 
+	cfg.Webhooks = ensureWebhookIDs(cfg.Webhooks)
+
 	cfg.Options.RawListenAddresses = util.UniqueTrimmedStrings(cfg.Options.RawListenAddresses)
 	cfg.Options.RawGlobalAnnServers = util.UniqueTrimmedStrings(cfg.Options.RawGlobalAnnServers)
 
@@ -405,6 +405,45 @@ func TestVersioningConfig(t *testing.T) {
 	}
 }
 
+func TestXattrFilter(t *testing.T) {
+	cfg, err := load("testdata/xattrfilter.xml", device4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fcfg := cfg.Folders()["test"]
+	if !fcfg.SyncXattrs {
+		t.Error("SyncXattrs should be set")
+	}
+
+	cases := []struct {
+		name   string
+		permit bool
+	}{
+		{"user.foo", true},
+		{"user.foo.bar", true},
+		{"user", true},
+		{"username", false},
+		{"user.secret", false},
+		{"user.secret.key", false},
+		{"user.secrets", true},
+		{"system.posix_acl_access", true},
+		{"system.posix_acl_default", false},
+		{"security.selinux", false},
+	}
+	for _, tc := range cases {
+		if res := fcfg.XattrFilter.Permit(tc.name); res != tc.permit {
+			t.Errorf("Permit(%q) => %v, expected %v", tc.name, res, tc.permit)
+		}
+	}
+
+	// An empty allow list permits everything that isn't denied.
+	filter := XattrFilter{Deny: []string{"security."}}
+	if !filter.Permit("trusted.foo") || filter.Permit("security.selinux") {
+		t.Error("unexpected result with empty allow list")
+	}
+}
+
 func TestIssue1262(t *testing.T) {
 	if runtime.GOOS != "windows" {
 		t.Skipf("path gets converted to absolute as part of the filesystem initialization on linux")
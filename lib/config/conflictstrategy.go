@@ -0,0 +1,53 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// A ConflictStrategy decides which of two conflicting versions of a file
+// wins. Ties, and conflicts the strategy can't decide, keep both.
+type ConflictStrategy int
+
+const (
+	ConflictStrategyKeepBoth ConflictStrategy = iota // default: the remote version wins, the local one is kept as a conflict copy
+	ConflictStrategyNewest                           // the version with the newest modification time wins
+	ConflictStrategyDevice                           // the version last modified by a given device wins
+	ConflictStrategyLargest                          // the largest version wins
+)
+
+func (s ConflictStrategy) String() string {
+	switch s {
+	case ConflictStrategyKeepBoth:
+		return "keepBoth"
+	case ConflictStrategyNewest:
+		return "newest"
+	case ConflictStrategyDevice:
+		return "device"
+	case ConflictStrategyLargest:
+		return "largest"
+	default:
+		return "unknown"
+	}
+}
+
+func (s ConflictStrategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ConflictStrategy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "keepBoth":
+		*s = ConflictStrategyKeepBoth
+	case "newest":
+		*s = ConflictStrategyNewest
+	case "device":
+		*s = ConflictStrategyDevice
+	case "largest":
+		*s = ConflictStrategyLargest
+	default:
+		*s = ConflictStrategyKeepBoth
+	}
+	return nil
+}
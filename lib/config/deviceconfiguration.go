@@ -18,6 +18,7 @@ type DeviceConfiguration struct {
 	Name                     string               `xml:"name,attr,omitempty" json:"name"`
 	Addresses                []string             `xml:"address,omitempty" json:"addresses" default:"dynamic"`
 	Compression              protocol.Compression `xml:"compression,attr" json:"compression"`
+	CompressionLevel         int                  `xml:"compressionLevel,attr,omitempty" json:"compressionLevel"` // zstd level, 0: default
 	CertName                 string               `xml:"certName,attr,omitempty" json:"certName"`
 	Introducer               bool                 `xml:"introducer,attr" json:"introducer"`
 	SkipIntroductionRemovals bool                 `xml:"skipIntroductionRemovals,attr" json:"skipIntroductionRemovals"`
@@ -30,6 +31,8 @@ type DeviceConfiguration struct {
 	IgnoredFolders           []ObservedFolder     `xml:"ignoredFolder" json:"ignoredFolders"`
 	PendingFolders           []ObservedFolder     `xml:"pendingFolder" json:"pendingFolders"`
 	MaxRequestKiB            int                  `xml:"maxRequestKiB" json:"maxRequestKiB"`
+	ScheduleWindows          []string             `xml:"scheduleWindow" json:"scheduleWindows"`
+	NumConnections           int                  `xml:"numConnections,omitempty" json:"numConnections"` // 0 or 1: a single connection
 }
 
 func NewDeviceConfiguration(id protocol.DeviceID, name string) DeviceConfiguration {
@@ -54,6 +57,7 @@ func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
 	copy(c.IgnoredFolders, cfg.IgnoredFolders)
 	c.PendingFolders = make([]ObservedFolder, len(cfg.PendingFolders))
 	copy(c.PendingFolders, cfg.PendingFolders)
+	c.ScheduleWindows = append([]string(nil), cfg.ScheduleWindows...)
 	return c
 }
 
@@ -64,6 +68,7 @@ func (cfg *DeviceConfiguration) prepare(sharedFolders []string) {
 	if len(cfg.AllowedNetworks) == 0 {
 		cfg.AllowedNetworks = []string{}
 	}
+	cfg.ScheduleWindows = validScheduleWindows(cfg.ScheduleWindows, "device "+cfg.DeviceID.String())
 
 	ignoredFolders := deduplicateObservedFoldersToMap(cfg.IgnoredFolders)
 	pendingFolders := deduplicateObservedFoldersToMap(cfg.PendingFolders)
@@ -81,6 +86,12 @@ func (cfg *DeviceConfiguration) prepare(sharedFolders []string) {
 	cfg.PendingFolders = sortedObservedFolderSlice(pendingFolders)
 }
 
+// Schedule returns the windows in which to connect to the device.
+func (cfg DeviceConfiguration) Schedule() Schedule {
+	s, _ := ParseSchedule(cfg.ScheduleWindows)
+	return s
+}
+
 func (cfg *DeviceConfiguration) IgnoredFolder(folder string) bool {
 	for _, ignoredFolder := range cfg.IgnoredFolders {
 		if ignoredFolder.ID == folder {
@@ -26,7 +26,9 @@ var (
 	ErrMarkerMissing    = errors.New("folder marker missing (this indicates potential data loss, search docs/forum to get information about how to proceed)")
 )
 
-const DefaultMarkerName = ".stfolder"
+const (
+	DefaultMarkerName = ".stfolder"
+)
 
 type FolderConfiguration struct {
 	ID                      string                      `xml:"id,attr" json:"id"`
@@ -60,6 +62,25 @@ type FolderConfiguration struct {
 	MaxConcurrentWrites     int                         `xml:"maxConcurrentWrites" json:"maxConcurrentWrites" default:"2"`
 	DisableFsync            bool                        `xml:"disableFsync" json:"disableFsync"`
 	BlockPullOrder          BlockPullOrder              `xml:"blockPullOrder" json:"blockPullOrder"`
+	SyncXattrs              bool                        `xml:"syncXattrs" json:"syncXattrs"`
+	XattrFilter             XattrFilter                 `xml:"xattrFilter" json:"xattrFilter"`
+	FSWatcherPoll           bool                        `xml:"fsWatcherPoll" json:"fsWatcherPoll"`                   // Find changes by polling instead of using native filesystem notifications.
+	FSWatcherPollIntervalS  int                         `xml:"fsWatcherPollIntervalS" json:"fsWatcherPollIntervalS"` // Zero means the default of 10 seconds.
+	FSWatcherPollMaxStats   int                         `xml:"fsWatcherPollMaxStats" json:"fsWatcherPollMaxStats"`   // Items to stat per interval when polling. Zero means the default of 1000.
+	CopyRangeMethod         fs.CopyRangeMethod          `xml:"copyRangeMethod" json:"copyRangeMethod"`
+	MaxFolderSize           Size                        `xml:"maxFolderSize" json:"maxFolderSize"`                // Zero means unlimited.
+	FileHistoryEntries      int                         `xml:"fileHistoryEntries" json:"fileHistoryEntries"`      // Changes to remember per file. Zero, the default, disables.
+	FileHistoryMaxAgeS      int                         `xml:"fileHistoryMaxAgeS" json:"fileHistoryMaxAgeS"`      // Zero means no age limit.
+	SelectedPaths           []string                    `xml:"selectedPath" json:"selectedPaths" restart:"false"` // Paths to pull for selective sync, or fetched in index only folders. Empty means everything, except in index only folders.
+	ScheduleWindows         []string                    `xml:"scheduleWindow" json:"scheduleWindows"`             // When to scan and pull, such as "mon-fri 18:00-08:00". Empty means always.
+	MaxSendKbps             int                         `xml:"maxSendKbps" json:"maxSendKbps" restart:"false"`    // Zero means unlimited.
+	MaxRecvKbps             int                         `xml:"maxRecvKbps" json:"maxRecvKbps" restart:"false"`    // Zero means unlimited.
+	Priority                int                         `xml:"priority" json:"priority" restart:"false"`          // Share of the bandwidth relative to other folders, when they contend for it. Zero means the default of 1.
+	ConflictStrategy        ConflictStrategy            `xml:"conflictStrategy" json:"conflictStrategy"`
+	ConflictWinner          protocol.DeviceID           `xml:"conflictWinner" json:"conflictWinner"` // The device whose versions win with the device conflict strategy.
+	MergeCommand            string                      `xml:"mergeCommand" json:"mergeCommand"`     // Merges conflicting changes, given %BASE%, %LOCAL% and %REMOTE%, leaving the result in %LOCAL%.
+	MergePatterns           []string                    `xml:"mergePattern" json:"mergePatterns"`    // Files to merge with the merge command, such as "*.txt".
+	Hooks                   []FolderHookConfiguration   `xml:"hook" json:"hooks"`                    // Commands to run on events in the folder.
 
 	cachedFilesystem    fs.Filesystem
 	cachedModTimeWindow time.Duration
@@ -70,8 +91,9 @@ type FolderConfiguration struct {
 }
 
 type FolderDeviceConfiguration struct {
-	DeviceID     protocol.DeviceID `xml:"id,attr" json:"deviceID"`
-	IntroducedBy protocol.DeviceID `xml:"introducedBy,attr" json:"introducedBy"`
+	DeviceID           protocol.DeviceID `xml:"id,attr" json:"deviceID"`
+	IntroducedBy       protocol.DeviceID `xml:"introducedBy,attr" json:"introducedBy"`
+	EncryptionPassword string            `xml:"encryptionPassword" json:"encryptionPassword"` // The device only gets encrypted data if set
 }
 
 func NewFolderConfiguration(myID protocol.DeviceID, id, label string, fsType fs.FilesystemType, path string) FolderConfiguration {
@@ -94,6 +116,11 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 	c.Devices = make([]FolderDeviceConfiguration, len(f.Devices))
 	copy(c.Devices, f.Devices)
 	c.Versioning = f.Versioning.Copy()
+	c.XattrFilter = f.XattrFilter.Copy()
+	c.SelectedPaths = append([]string(nil), f.SelectedPaths...)
+	c.ScheduleWindows = append([]string(nil), f.ScheduleWindows...)
+	c.MergePatterns = append([]string(nil), f.MergePatterns...)
+	c.Hooks = append([]FolderHookConfiguration(nil), f.Hooks...)
 	return c
 }
 
@@ -193,6 +220,12 @@ func (f *FolderConfiguration) CreateRoot() (err error) {
 	return err
 }
 
+// Schedule returns the windows in which to scan and pull.
+func (f FolderConfiguration) Schedule() Schedule {
+	s, _ := ParseSchedule(f.ScheduleWindows)
+	return s
+}
+
 func (f FolderConfiguration) Description() string {
 	if f.Label == "" {
 		return f.ID
@@ -234,6 +267,8 @@ func (f *FolderConfiguration) prepare() {
 		f.MarkerName = DefaultMarkerName
 	}
 
+	f.ScheduleWindows = validScheduleWindows(f.ScheduleWindows, "folder "+f.Description())
+
 	switch {
 	case f.RawModTimeWindowS > 0:
 		f.cachedModTimeWindow = time.Duration(f.RawModTimeWindowS) * time.Second
@@ -278,6 +313,48 @@ func (f *FolderConfiguration) SharedWith(device protocol.DeviceID) bool {
 	return false
 }
 
+// Device returns the configuration for the given device in this folder.
+func (f *FolderConfiguration) Device(device protocol.DeviceID) (FolderDeviceConfiguration, bool) {
+	for _, dev := range f.Devices {
+		if dev.DeviceID == device {
+			return dev, true
+		}
+	}
+	return FolderDeviceConfiguration{}, false
+}
+
+// MaxFolderSizeBytes returns the maximum size of the folder in bytes, or zero
+// if it's unlimited. A percentage is relative to the total size of the
+// filesystem.
+func (f *FolderConfiguration) MaxFolderSizeBytes() int64 {
+	val := f.MaxFolderSize.BaseValue()
+	if val <= 0 {
+		return 0
+	}
+	if f.MaxFolderSize.Percentage() {
+		usage, err := f.Filesystem().Usage(".")
+		if err != nil {
+			return 0
+		}
+		val = val / 100 * float64(usage.Total)
+	}
+	return int64(val)
+}
+
+// FileHistoryRetention returns the number of changes to remember for each
+// file and for how long, where zero entries means that no history is kept.
+func (f FolderConfiguration) FileHistoryRetention() (int, time.Duration) {
+	entries := f.FileHistoryEntries
+	if entries <= 0 {
+		return 0, 0
+	}
+	maxAge := time.Duration(f.FileHistoryMaxAgeS) * time.Second
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return entries, maxAge
+}
+
 func (f *FolderConfiguration) CheckAvailableSpace(req int64) error {
 	val := f.MinDiskFree.BaseValue()
 	if val <= 0 {
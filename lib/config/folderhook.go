@@ -0,0 +1,73 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "time"
+
+// A FolderHookConfiguration is a command to run on events in a folder. It
+// gets the details of the event in environment variables.
+type FolderHookConfiguration struct {
+	Event     HookEvent `xml:"event,attr" json:"event"`
+	Command   string    `xml:"command" json:"command"`
+	TimeoutS  int       `xml:"timeoutS" json:"timeoutS"`   // Zero means the default of 60 seconds.
+	DebounceS int       `xml:"debounceS" json:"debounceS"` // Run once the events have stopped for this long. Zero means once per event.
+}
+
+func (h FolderHookConfiguration) Timeout() time.Duration {
+	if h.TimeoutS > 0 {
+		return time.Duration(h.TimeoutS) * time.Second
+	}
+	return time.Minute
+}
+
+func (h FolderHookConfiguration) Debounce() time.Duration {
+	if h.DebounceS > 0 {
+		return time.Duration(h.DebounceS) * time.Second
+	}
+	return 0
+}
+
+// A HookEvent is what a hook runs on.
+type HookEvent int
+
+const (
+	HookEventNone                HookEvent = iota // never runs
+	HookEventItemFinished                         // an item was synced, or failed to
+	HookEventFolderSynced                         // the folder finished syncing and is in sync
+	HookEventLocalChangeDetected                  // an item was changed locally
+)
+
+func (e HookEvent) String() string {
+	switch e {
+	case HookEventItemFinished:
+		return "itemFinished"
+	case HookEventFolderSynced:
+		return "folderSynced"
+	case HookEventLocalChangeDetected:
+		return "localChangeDetected"
+	default:
+		return "none"
+	}
+}
+
+func (e HookEvent) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *HookEvent) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "itemFinished":
+		*e = HookEventItemFinished
+	case "folderSynced":
+		*e = HookEventFolderSynced
+	case "localChangeDetected":
+		*e = HookEventLocalChangeDetected
+	default:
+		*e = HookEventNone
+	}
+	return nil
+}
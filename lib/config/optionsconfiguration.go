@@ -62,6 +62,7 @@ type OptionsConfiguration struct {
 	RawStunServers          []string `xml:"stunServer" json:"stunServers" default:"default"`
 	DatabaseTuning          Tuning   `xml:"databaseTuning" json:"databaseTuning" restart:"true"`
 	RawMaxCIRequestKiB      int      `xml:"maxConcurrentIncomingRequestKiB" json:"maxConcurrentIncomingRequestKiB"`
+	RawMaxConcurrentHooks   int      `xml:"maxConcurrentHooks" json:"maxConcurrentHooks"` // Zero means the default of 4, negative unlimited.
 
 	DeprecatedUPnPEnabled        bool     `xml:"upnpEnabled,omitempty" json:"-"`
 	DeprecatedUPnPLeaseM         int      `xml:"upnpLeaseMinutes,omitempty" json:"-"`
@@ -201,6 +202,17 @@ func (opts OptionsConfiguration) MaxConcurrentIncomingRequestKiB() int {
 	return opts.RawMaxCIRequestKiB
 }
 
+func (opts OptionsConfiguration) MaxConcurrentHooks() int {
+	switch {
+	case opts.RawMaxConcurrentHooks > 0:
+		return opts.RawMaxConcurrentHooks
+	case opts.RawMaxConcurrentHooks < 0:
+		// Unlimited, which in limiter land is spelled zero
+		return 0
+	}
+	return 4
+}
+
 func (opts OptionsConfiguration) ShouldAutoUpgrade() bool {
 	return opts.AutoUpgradeIntervalH > 0
 }
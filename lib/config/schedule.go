@@ -0,0 +1,180 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule is a set of weekly recurring windows in local time. It is open
+// while the time is within any of the windows, and always open if it has
+// none.
+type Schedule []ScheduleWindow
+
+type ScheduleWindow struct {
+	days       [7]bool // Indexed by time.Weekday, the days the window starts on.
+	start, end int     // Minutes since midnight. An end at or before the start is on the next day.
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseSchedule parses windows of the form "[days] HH:MM-HH:MM". The days
+// are "*" or a comma separated list of days and day ranges, such as
+// "mon-fri,sun", and default to every day. A window ending at or before
+// its start ends on the next day, so "mon-fri 18:00-08:00" covers the
+// nights after work days.
+func ParseSchedule(windows []string) (Schedule, error) {
+	s := make(Schedule, 0, len(windows))
+	for _, w := range windows {
+		sw, err := parseScheduleWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, sw)
+	}
+	return s, nil
+}
+
+func parseScheduleWindow(s string) (ScheduleWindow, error) {
+	var w ScheduleWindow
+	fields := strings.Fields(strings.ToLower(s))
+	switch len(fields) {
+	case 1:
+		fields = []string{"*", fields[0]}
+	case 2:
+	default:
+		return w, fmt.Errorf("schedule window %q: expected days and times", s)
+	}
+
+	if fields[0] == "*" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	} else {
+		for _, days := range strings.Split(fields[0], ",") {
+			from, to := days, days
+			if i := strings.IndexByte(days, '-'); i >= 0 {
+				from, to = days[:i], days[i+1:]
+			}
+			first, ok := weekdays[from]
+			if !ok {
+				return w, fmt.Errorf("schedule window %q: unknown day %q", s, from)
+			}
+			last, ok := weekdays[to]
+			if !ok {
+				return w, fmt.Errorf("schedule window %q: unknown day %q", s, to)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return w, fmt.Errorf("schedule window %q: expected a time range", s)
+	}
+	var err error
+	if w.start, err = parseMinutes(times[0]); err != nil || w.start == 24*60 {
+		return w, fmt.Errorf("schedule window %q: invalid start time %q", s, times[0])
+	}
+	if w.end, err = parseMinutes(times[1]); err != nil {
+		return w, fmt.Errorf("schedule window %q: invalid end time %q", s, times[1])
+	}
+	return w, nil
+}
+
+// parseMinutes parses "HH:MM" into minutes since midnight, allowing 24:00.
+func parseMinutes(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// Open returns whether the schedule allows activity at the given time.
+func (s Schedule) Open(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, w := range s {
+		if w.open(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w ScheduleWindow) open(t time.Time) bool {
+	day := t.Weekday()
+	min := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[day] && min >= w.start && min < w.end
+	}
+	return w.days[day] && min >= w.start || w.days[(day+6)%7] && min < w.end
+}
+
+// Next returns the next time after t at which the schedule opens or closes,
+// or the zero time if it never does.
+func (s Schedule) Next(t time.Time) time.Time {
+	if len(s) == 0 {
+		return time.Time{}
+	}
+	open := s.Open(t)
+	next := t.Truncate(time.Minute)
+	// A week and a bit covers all windows, plus any DST shift.
+	for i := 0; i < 8*24*60; i++ {
+		next = next.Add(time.Minute)
+		if s.Open(next) != open {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// validScheduleWindows returns the windows that parse, warning about the
+// others.
+func validScheduleWindows(windows []string, owner string) []string {
+	if len(windows) == 0 {
+		return windows
+	}
+	valid := make([]string, 0, len(windows))
+	for _, w := range windows {
+		if _, err := parseScheduleWindow(w); err != nil {
+			l.Warnf("Ignoring invalid schedule for %v: %v", owner, err)
+			continue
+		}
+		valid = append(valid, w)
+	}
+	return valid
+}
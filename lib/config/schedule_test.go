@@ -0,0 +1,100 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	valid := []string{
+		"mon-fri 18:00-08:00",
+		"sat,sun 00:00-24:00",
+		"* 12:00-13:00",
+		"22:00-06:00",
+		"fri-mon 09:30-10:00",
+		"Tue 00:00-00:00",
+	}
+	for _, w := range valid {
+		if _, err := parseScheduleWindow(w); err != nil {
+			t.Errorf("%q: unexpected error %v", w, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"mon",
+		"mon 18:00",
+		"mon 18-20",
+		"foo 18:00-20:00",
+		"mon-foo 18:00-20:00",
+		"mon 25:00-08:00",
+		"mon 24:00-08:00",
+		"mon 18:60-20:00",
+		"mon fri 18:00-20:00",
+	}
+	for _, w := range invalid {
+		if _, err := parseScheduleWindow(w); err == nil {
+			t.Errorf("%q: expected an error", w)
+		}
+	}
+}
+
+func TestScheduleOpen(t *testing.T) {
+	s, err := ParseSchedule([]string{"mon-fri 18:00-08:00", "sat,sun 00:00-24:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2020-08-03 is a Monday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2020, 8, 3+day, hour, min, 0, 0, time.Local)
+	}
+	cases := []struct {
+		t    time.Time
+		open bool
+	}{
+		{at(0, 7, 59), false}, // No window starts on Sunday night.
+		{at(0, 12, 0), false},
+		{at(0, 18, 0), true},
+		{at(1, 7, 59), true},
+		{at(1, 8, 0), false},
+		{at(4, 17, 59), false},
+		{at(4, 18, 0), true},
+		{at(5, 12, 0), true},
+		{at(6, 23, 59), true},
+	}
+	for _, tc := range cases {
+		if open := s.Open(tc.t); open != tc.open {
+			t.Errorf("%v: open %v, expected %v", tc.t, open, tc.open)
+		}
+	}
+
+	if next := s.Next(at(0, 12, 34)); !next.Equal(at(0, 18, 0)) {
+		t.Errorf("next change at %v, expected %v", next, at(0, 18, 0))
+	}
+	if next := s.Next(at(0, 18, 0)); !next.Equal(at(1, 8, 0)) {
+		t.Errorf("next change at %v, expected %v", next, at(1, 8, 0))
+	}
+	if next := s.Next(at(4, 20, 0)); !next.Equal(at(7, 0, 0)) {
+		t.Errorf("next change at %v, expected %v", next, at(7, 0, 0))
+	}
+
+	var always Schedule
+	if !always.Open(at(0, 0, 0)) || !always.Next(at(0, 0, 0)).IsZero() {
+		t.Error("empty schedule should always be open")
+	}
+}
+
+func TestScheduleWindowsPrepare(t *testing.T) {
+	f := FolderConfiguration{ScheduleWindows: []string{"mon 18:00-20:00", "invalid"}}
+	f.prepare()
+	if len(f.ScheduleWindows) != 1 || f.ScheduleWindows[0] != "mon 18:00-20:00" {
+		t.Errorf("expected the invalid window to be dropped, got %v", f.ScheduleWindows)
+	}
+}
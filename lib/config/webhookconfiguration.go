@@ -0,0 +1,81 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A WebhookConfiguration is a URL that events are posted to, in batches.
+type WebhookConfiguration struct {
+	ID             string              `xml:"id,attr" json:"id"` // Empty means the URL.
+	URL            string              `xml:"url" json:"url"`
+	Secret         string              `xml:"secret" json:"secret"`                 // Signs the events with HMAC-SHA256. Empty means unsigned.
+	Events         []string            `xml:"event" json:"events"`                  // Event types, such as "ItemFinished". Empty means the default of the REST API.
+	Folders        []string            `xml:"folder" json:"folders"`                // Only events about these folders. Empty means all.
+	Devices        []protocol.DeviceID `xml:"device" json:"devices"`                // Only events about these devices. Empty means all.
+	MaxBatchSize   int                 `xml:"maxBatchSize" json:"maxBatchSize"`     // Zero means the default of 100 events.
+	BatchIntervalS int                 `xml:"batchIntervalS" json:"batchIntervalS"` // Zero means the default of 10 seconds.
+	MaxSpoolSize   int                 `xml:"maxSpoolSize" json:"maxSpoolSize"`     // Undelivered events to keep, dropping the oldest. Zero means the default of 10000.
+}
+
+func (w WebhookConfiguration) Copy() WebhookConfiguration {
+	c := w
+	c.Events = append([]string(nil), w.Events...)
+	c.Folders = append([]string(nil), w.Folders...)
+	c.Devices = append([]protocol.DeviceID(nil), w.Devices...)
+	return c
+}
+
+func (w WebhookConfiguration) BatchSize() int {
+	if w.MaxBatchSize > 0 {
+		return w.MaxBatchSize
+	}
+	return 100
+}
+
+func (w WebhookConfiguration) BatchInterval() time.Duration {
+	if w.BatchIntervalS > 0 {
+		return time.Duration(w.BatchIntervalS) * time.Second
+	}
+	return 10 * time.Second
+}
+
+func (w WebhookConfiguration) SpoolSize() int {
+	if w.MaxSpoolSize > 0 {
+		return w.MaxSpoolSize
+	}
+	return 10000
+}
+
+// ensureWebhookIDs fills in missing webhook IDs and drops webhooks without
+// a URL or with a duplicate ID.
+func ensureWebhookIDs(webhooks []WebhookConfiguration) []WebhookConfiguration {
+	if len(webhooks) == 0 {
+		return webhooks
+	}
+	seen := make(map[string]struct{}, len(webhooks))
+	res := make([]WebhookConfiguration, 0, len(webhooks))
+	for _, w := range webhooks {
+		if w.URL == "" {
+			l.Warnf("Dropping webhook %q without a URL", w.ID)
+			continue
+		}
+		if w.ID == "" {
+			w.ID = w.URL
+		}
+		if _, ok := seen[w.ID]; ok {
+			l.Warnf("Dropping webhook with duplicate ID %q", w.ID)
+			continue
+		}
+		seen[w.ID] = struct{}{}
+		res = append(res, w)
+	}
+	return res
+}
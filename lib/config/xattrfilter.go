@@ -0,0 +1,44 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "strings"
+
+// XattrFilter selects the extended attributes to sync by namespace. An
+// entry matches an attribute of the same name and all attributes below it,
+// i.e. "user" matches "user.foo" and "system.posix_acl_access" matches
+// exactly that. An empty allow list allows everything; the deny list takes
+// precedence over the allow list.
+type XattrFilter struct {
+	Allow []string `xml:"allow" json:"allow"`
+	Deny  []string `xml:"deny" json:"deny"`
+}
+
+func (f XattrFilter) Copy() XattrFilter {
+	cp := f
+	cp.Allow = append([]string(nil), f.Allow...)
+	cp.Deny = append([]string(nil), f.Deny...)
+	return cp
+}
+
+// Permit returns true if the attribute with the given name is to be synced.
+func (f XattrFilter) Permit(name string) bool {
+	if len(f.Allow) > 0 && !xattrNamespaceMatch(f.Allow, name) {
+		return false
+	}
+	return !xattrNamespaceMatch(f.Deny, name)
+}
+
+func xattrNamespaceMatch(entries []string, name string) bool {
+	for _, entry := range entries {
+		entry = strings.TrimSuffix(entry, ".")
+		if name == entry || strings.HasPrefix(name, entry+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// MultiConnection is a Connection made up of several underlying
+// connections to the same device.
+type MultiConnection interface {
+	Connection
+	// Connections returns the underlying connections, the primary first.
+	Connections() []Connection
+}
+
+// A multiConn spreads requests over several connections to a device, to
+// get past the throughput limit of a single TCP connection. Everything
+// else, in particular the index data which needs to stay in order, goes
+// over the primary connection. Both devices send their cluster config on
+// each connection, and only the one on the primary is passed to the model,
+// so it doesn't matter if the devices disagree on which one is the primary.
+// Only the device that dialed the primary dials the other connections, so
+// that the devices don't both dial the missing ones at the same time.
+type multiConn struct {
+	Connection // the primary
+	want       int
+	dial       bool // whether we dialed the primary, and so dial the others
+	next       uint32
+
+	mut          sync.Mutex
+	secondaries  []Connection
+	cc           *protocol.ClusterConfig
+	capabilities protocol.Capabilities
+}
+
+var (
+	errPrimaryClosed     = errors.New("primary connection closed")
+	errSurplusConnection = errors.New("surplus connection")
+)
+
+// maxConnections limits the number of connections a device can ask us to
+// keep to it.
+const maxConnections = 16
+
+func newMultiConn(want int, dial bool) *multiConn {
+	return &multiConn{
+		want: want,
+		dial: dial,
+		mut:  sync.NewMutex(),
+	}
+}
+
+// wantedConnections returns the number of connections to keep to the
+// device, the larger of what we and the device want, or one if either side
+// doesn't support multiple connections.
+func wantedConnections(cfg config.DeviceConfiguration, hello protocol.HelloResult) int {
+	if !(protocol.LocalCapabilities() & hello.Capabilities).Has(protocol.CapabilityMultipleConnections) {
+		return 1
+	}
+	want := cfg.NumConnections
+	if remote := int(hello.NumConnections); remote > want {
+		want = remote
+	}
+	if want > maxConnections {
+		return maxConnections
+	}
+	if want < 1 {
+		return 1
+	}
+	return want
+}
+
+// missingConnections returns how many more connections we should dial for
+// the connection.
+func missingConnections(conn Connection) int {
+	mc, ok := conn.(*multiConn)
+	if !ok || !mc.dial || mc.Closed() {
+		return 0
+	}
+	if n := mc.want - len(mc.Connections()); n > 0 {
+		return n
+	}
+	return 0
+}
+
+func (c *multiConn) Connections() []Connection {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return append([]Connection{c.Connection}, c.secondaries...)
+}
+
+// addSecondary starts the connection, sending it the cluster config if we
+// have one already. Connections beyond the wanted number, as when both
+// devices dialed, are closed instead and false is returned.
+func (c *multiConn) addSecondary(conn Connection) bool {
+	c.mut.Lock()
+	if c.Connection.Closed() {
+		// The primary closed while the connection was being set up.
+		c.mut.Unlock()
+		conn.Close(errPrimaryClosed)
+		return false
+	}
+	if 1+len(c.secondaries) >= c.want {
+		c.mut.Unlock()
+		conn.Close(errSurplusConnection)
+		return false
+	}
+	c.secondaries = append(c.secondaries, conn)
+	conn.SetCapabilities(c.capabilities)
+	cc := c.cc
+	c.mut.Unlock()
+
+	conn.Start()
+	if cc != nil {
+		conn.ClusterConfig(*cc)
+	}
+	return true
+}
+
+// removeSecondary removes the secondary connection with the given protocol
+// connection, returning it.
+func (c *multiConn) removeSecondary(conn protocol.Connection) (Connection, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for i, sec := range c.secondaries {
+		if sec == conn || protocolConn(sec) == conn {
+			c.secondaries = append(c.secondaries[:i:i], c.secondaries[i+1:]...)
+			return sec, true
+		}
+	}
+	return nil, false
+}
+
+func (c *multiConn) closeSecondaries(err error) {
+	c.mut.Lock()
+	secondaries := c.secondaries
+	c.secondaries = nil
+	c.mut.Unlock()
+
+	for _, sec := range secondaries {
+		sec.Close(err)
+	}
+}
+
+func (c *multiConn) Close(err error) {
+	c.closeSecondaries(err)
+	c.Connection.Close(err)
+}
+
+func (c *multiConn) ClusterConfig(config protocol.ClusterConfig) {
+	c.mut.Lock()
+	c.cc = &config
+	secondaries := c.secondaries
+	c.mut.Unlock()
+
+	c.Connection.ClusterConfig(config)
+	for _, sec := range secondaries {
+		sec.ClusterConfig(config)
+	}
+}
+
+func (c *multiConn) SetCapabilities(caps protocol.Capabilities) {
+	c.mut.Lock()
+	c.capabilities = caps
+	c.mut.Unlock()
+	c.Connection.SetCapabilities(caps)
+}
+
+// Request sends the request on the connections in turn.
+func (c *multiConn) Request(ctx context.Context, folder string, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
+	conns := c.Connections()
+	conn := conns[int(atomic.AddUint32(&c.next, 1)%uint32(len(conns)))]
+	return conn.Request(ctx, folder, name, blockNo, offset, size, hash, weakHash, fromTemporary)
+}
+
+// Statistics returns the totals of all connections.
+func (c *multiConn) Statistics() protocol.Statistics {
+	stats := c.Connection.Statistics()
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for _, sec := range c.secondaries {
+		secStats := sec.Statistics()
+		stats.InBytesTotal += secStats.InBytesTotal
+		stats.OutBytesTotal += secStats.OutBytesTotal
+	}
+	return stats
+}
+
+// primaryModel is the protocol.Model of the primary connection, closing the
+// secondaries with it.
+type primaryModel struct {
+	protocol.Model
+	conn *multiConn
+}
+
+func (m primaryModel) Closed(conn protocol.Connection, err error) {
+	m.conn.closeSecondaries(err)
+	m.Model.Closed(conn, err)
+}
+
+// secondaryModel is the protocol.Model of a secondary connection. Its
+// cluster config was already handled on the primary, and closing it only
+// removes it from the multiConn.
+type secondaryModel struct {
+	protocol.Model
+	conn *multiConn
+}
+
+func (m secondaryModel) ClusterConfig(protocol.DeviceID, protocol.ClusterConfig) error {
+	return nil
+}
+
+func (m secondaryModel) Closed(conn protocol.Connection, err error) {
+	l.Debugf("Secondary connection %v to %v closed: %v", conn.Name(), conn.ID(), err)
+	if sec, ok := m.conn.removeSecondary(conn); ok {
+		// Closes the underlying connection as well.
+		sec.Close(err)
+	}
+}
+
+func protocolConn(conn Connection) protocol.Connection {
+	if cc, ok := conn.(completeConn); ok {
+		return cc.Connection
+	}
+	return conn
+}
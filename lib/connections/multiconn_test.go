@@ -0,0 +1,215 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestWantedConnections(t *testing.T) {
+	multi := protocol.HelloResult{Capabilities: protocol.CapabilityMultipleConnections}
+	cases := []struct {
+		local int
+		hello protocol.HelloResult
+		want  int
+	}{
+		{0, protocol.HelloResult{}, 1},
+		{4, protocol.HelloResult{NumConnections: 4}, 1},
+		{0, multi, 1},
+		{4, multi, 4},
+		{-1, multi, 1},
+		{100, multi, maxConnections},
+		{2, protocol.HelloResult{Capabilities: protocol.CapabilityMultipleConnections, NumConnections: 3}, 3},
+		{3, protocol.HelloResult{Capabilities: protocol.CapabilityMultipleConnections, NumConnections: 2}, 3},
+	}
+	for _, tc := range cases {
+		if got := wantedConnections(config.DeviceConfiguration{NumConnections: tc.local}, tc.hello); got != tc.want {
+			t.Errorf("wantedConnections(%d, %+v) = %d, expected %d", tc.local, tc.hello, got, tc.want)
+		}
+	}
+}
+
+func TestMultiConn(t *testing.T) {
+	primary := newFakeConn()
+	mc := newMultiConn(3, true)
+	mc.Connection = primary
+
+	if n := missingConnections(mc); n != 2 {
+		t.Fatalf("expected two missing connections, got %d", n)
+	}
+	if n := missingConnections(primary); n != 0 {
+		t.Fatalf("expected no missing connections for a single connection, got %d", n)
+	}
+
+	mc.SetCapabilities(protocol.CapabilityMultipleConnections)
+	mc.ClusterConfig(protocol.ClusterConfig{})
+
+	// A secondary gets the capabilities and cluster config when added.
+	secondary := newFakeConn()
+	mc.addSecondary(secondary)
+	if !secondary.started || secondary.capabilities != protocol.CapabilityMultipleConnections || secondary.clusterConfigs != 1 {
+		t.Errorf("secondary not set up: %+v", secondary)
+	}
+	if n := missingConnections(mc); n != 1 {
+		t.Errorf("expected one missing connection, got %d", n)
+	}
+
+	// Requests are spread over both connections.
+	for i := 0; i < 10; i++ {
+		if _, err := mc.Request(context.Background(), "folder", "file", 0, 0, 0, nil, 0, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if primary.requests != 5 || secondary.requests != 5 {
+		t.Errorf("expected 5 requests on each connection, got %d and %d", primary.requests, secondary.requests)
+	}
+
+	// Cluster configs go over all connections.
+	mc.ClusterConfig(protocol.ClusterConfig{})
+	if primary.clusterConfigs != 2 || secondary.clusterConfigs != 2 {
+		t.Errorf("expected cluster configs on both connections, got %d and %d", primary.clusterConfigs, secondary.clusterConfigs)
+	}
+
+	stats := mc.Statistics()
+	if stats.InBytesTotal != 2 || stats.OutBytesTotal != 4 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+
+	// A closed secondary is removed, without closing the primary.
+	m := secondaryModel{nil, mc}
+	m.Closed(secondary, errors.New("test"))
+	if !secondary.closed || primary.closed {
+		t.Error("expected only the secondary to be closed")
+	}
+	if conns := mc.Connections(); len(conns) != 1 || conns[0] != primary {
+		t.Errorf("expected only the primary left, got %v", conns)
+	}
+
+	// Closing closes all connections.
+	secondary = newFakeConn()
+	mc.addSecondary(secondary)
+	mc.Close(errors.New("test"))
+	if !secondary.closed || !primary.closed {
+		t.Error("expected all connections to be closed")
+	}
+	if n := missingConnections(mc); n != 0 {
+		t.Errorf("expected no missing connections when closed, got %d", n)
+	}
+
+	// Secondaries added after the primary closed are closed right away.
+	secondary = newFakeConn()
+	mc.addSecondary(secondary)
+	if !secondary.closed || secondary.started {
+		t.Error("expected the secondary to be closed")
+	}
+}
+
+func TestMultiConnBothDialed(t *testing.T) {
+	// Both devices dialing at once gives twice the wanted secondaries,
+	// arriving concurrently. The surplus ones are closed, leaving the rest
+	// and the primary up.
+	primary := newFakeConn()
+	mc := newMultiConn(3, true)
+	mc.Connection = primary
+
+	secondaries := make([]*fakeConn, 4)
+	var wg sync.WaitGroup
+	for i := range secondaries {
+		secondaries[i] = newFakeConn()
+		wg.Add(1)
+		go func(conn *fakeConn) {
+			defer wg.Done()
+			mc.addSecondary(conn)
+		}(secondaries[i])
+	}
+	wg.Wait()
+
+	if primary.closed {
+		t.Error("expected the primary to stay open")
+	}
+	if conns := mc.Connections(); len(conns) != 3 {
+		t.Errorf("expected three connections, got %d", len(conns))
+	}
+	started, closed := 0, 0
+	for _, conn := range secondaries {
+		if conn.started {
+			started++
+		}
+		if conn.closed {
+			closed++
+		}
+	}
+	if started != 2 || closed != 2 {
+		t.Errorf("expected two started and two closed secondaries, got %d and %d", started, closed)
+	}
+	if n := missingConnections(mc); n != 0 {
+		t.Errorf("expected no missing connections, got %d", n)
+	}
+
+	// Only the device that dialed the primary dials the others.
+	accepted := newMultiConn(3, false)
+	accepted.Connection = newFakeConn()
+	if n := missingConnections(accepted); n != 0 {
+		t.Errorf("expected no connections to dial for an accepted primary, got %d", n)
+	}
+}
+
+type fakeConn struct {
+	Connection
+	started        bool
+	closed         bool
+	capabilities   protocol.Capabilities
+	clusterConfigs int
+	requests       int
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{}
+}
+
+func (c *fakeConn) Start() {
+	c.started = true
+}
+
+func (c *fakeConn) Close(error) {
+	c.closed = true
+}
+
+func (c *fakeConn) Closed() bool {
+	return c.closed
+}
+
+func (c *fakeConn) SetCapabilities(caps protocol.Capabilities) {
+	c.capabilities = caps
+}
+
+func (c *fakeConn) ClusterConfig(protocol.ClusterConfig) {
+	c.clusterConfigs++
+}
+
+func (c *fakeConn) Request(context.Context, string, string, int, int64, int, []byte, uint32, bool) ([]byte, error) {
+	c.requests++
+	return nil, nil
+}
+
+func (c *fakeConn) Statistics() protocol.Statistics {
+	return protocol.Statistics{InBytesTotal: 1, OutBytesTotal: 2}
+}
+
+func (c *fakeConn) ID() protocol.DeviceID {
+	return protocol.LocalDeviceID
+}
+
+func (c *fakeConn) Name() string {
+	return "fake"
+}
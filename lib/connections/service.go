@@ -41,8 +41,9 @@ var (
 )
 
 var (
-	errDisabled   = errors.New("disabled by configuration")
-	errDeprecated = errors.New("deprecated protocol")
+	errDisabled        = errors.New("disabled by configuration")
+	errDeprecated      = errors.New("deprecated protocol")
+	errOutsideSchedule = errors.New("outside of the device's schedule")
 )
 
 const (
@@ -124,6 +125,8 @@ type service struct {
 	limiter              *limiter
 	natService           *nat.Service
 	evLogger             events.Logger
+	connectNow           chan struct{}
+	scheduleChanged      chan struct{}
 
 	listenersMut       sync.RWMutex
 	listeners          map[string]genericListener
@@ -152,6 +155,8 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 		limiter:              newLimiter(cfg),
 		natService:           nat.NewService(myID, cfg),
 		evLogger:             evLogger,
+		connectNow:           make(chan struct{}, 1),
+		scheduleChanged:      make(chan struct{}, 1),
 
 		listenersMut:   sync.NewRWMutex(),
 		listeners:      make(map[string]genericListener),
@@ -182,10 +187,12 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 	// (handled in configuration changing) to handle incoming connections,
 	// one routine to periodically attempt outgoing connections, one routine to
 	// the common handling regardless of whether the connection was
-	// incoming or outgoing.
+	// incoming or outgoing, and one routine to connect and disconnect at the
+	// boundaries of the devices' schedules.
 
 	service.Add(util.AsService(service.connect, fmt.Sprintf("%s/connect", service)))
 	service.Add(util.AsService(service.handle, fmt.Sprintf("%s/handle", service)))
+	service.Add(util.AsService(service.schedule, fmt.Sprintf("%s/schedule", service)))
 	service.Add(service.listenerSupervisor)
 	service.Add(service.natService)
 
@@ -276,8 +283,16 @@ func (s *service) handle(ctx context.Context) {
 		// not a relay connection, we should drop that, and prefer this one.
 		ct, connected := s.model.Connection(remoteID)
 
+		// A connection with the same priority as the existing one is added
+		// to it, if we keep several connections to the device. Surplus
+		// ones are closed when added, rather than replacing all of them.
+		existing, multi := ct.(*multiConn)
+		secondary := connected && ct.Priority() == c.priority && multi && !existing.Closed()
+
 		// Lower priority is better, just like nice etc.
-		if connected && (ct.Priority() > c.priority || time.Since(ct.Statistics().StartedAt) > minConnectionReplaceAge) {
+		if secondary {
+			l.Debugf("Adding connection to %s (existing: %s new: %s)", remoteID, ct, c)
+		} else if connected && (ct.Priority() > c.priority || time.Since(ct.Statistics().StartedAt) > minConnectionReplaceAge) {
 			l.Debugf("Switching connections %s (existing: %s new: %s)", remoteID, ct, c)
 		} else if connected {
 			// We should not already be connected to the other party. TODO: This
@@ -298,6 +313,12 @@ func (s *service) handle(ctx context.Context) {
 			continue
 		}
 
+		if !deviceCfg.Schedule().Open(time.Now()) {
+			l.Infof("Rejecting connection from %s at %s: %v", remoteID, c, errOutsideSchedule)
+			c.Close()
+			continue
+		}
+
 		// Verify the name on the certificate. By default we set it to
 		// "syncthing" when generating, but the user may have replaced
 		// the certificate and used another name.
@@ -320,12 +341,45 @@ func (s *service) handle(ctx context.Context) {
 		isLAN := s.isLAN(c.RemoteAddr())
 		rd, wr := s.limiter.getLimiters(remoteID, c, isLAN)
 
-		protoConn := protocol.NewConnection(remoteID, rd, wr, s.model, c.String(), deviceCfg.Compression)
-		modelConn := completeConn{c, protoConn}
+		// Compressed messages use the best compression the other side
+		// can decompress, with LZ4 for those not telling us.
+		msgCompression := protocol.NegotiateMessageCompression(hello.Compressions)
+
+		if secondary {
+			protoConn := protocol.NewConnection(remoteID, rd, wr, secondaryModel{s.model, existing}, c.String(), deviceCfg.Compression, msgCompression, deviceCfg.CompressionLevel, s.cfg.FolderPasswords(remoteID))
+
+			if existing.addSecondary(completeConn{c, protoConn}) {
+				l.Infof("Established additional secure connection to %s at %s", remoteID, c)
+			} else {
+				l.Debugf("Closed surplus connection to %s at %s", remoteID, c)
+			}
+			continue
+		}
+
+		var receiver protocol.Model = s.model
+		var mc *multiConn
+		if want := wantedConnections(deviceCfg, hello); want > 1 {
+			mc = newMultiConn(want, c.connType.dialed())
+			receiver = primaryModel{s.model, mc}
+		}
+
+		protoConn := protocol.NewConnection(remoteID, rd, wr, receiver, c.String(), deviceCfg.Compression, msgCompression, deviceCfg.CompressionLevel, s.cfg.FolderPasswords(remoteID))
+		var modelConn Connection = completeConn{c, protoConn}
+		if mc != nil {
+			mc.Connection = modelConn
+			modelConn = mc
+		}
 
 		l.Infof("Established secure connection to %s at %s", remoteID, c)
 
 		s.model.AddConnection(modelConn, hello)
+		if mc != nil {
+			// Have the connect loop dial the additional connections.
+			select {
+			case s.connectNow <- struct{}{}:
+			default:
+			}
+		}
 		continue
 	}
 }
@@ -374,9 +428,17 @@ func (s *service) connect(ctx context.Context) {
 				continue
 			}
 
+			if !deviceCfg.Schedule().Open(now) {
+				continue
+			}
+
 			ct, connected := s.model.Connection(deviceID)
+			missing := 0
+			if connected {
+				missing = missingConnections(ct)
+			}
 
-			if connected && ct.Priority() == bestDialerPrio {
+			if connected && ct.Priority() == bestDialerPrio && missing == 0 {
 				// Things are already as good as they can get.
 				continue
 			}
@@ -405,7 +467,7 @@ func (s *service) connect(ctx context.Context) {
 				nextDialKey := deviceID.String() + "/" + addr
 				seen = append(seen, nextDialKey)
 				nextDialAt, ok := nextDial[nextDialKey]
-				if ok && initialRampup >= sleep && nextDialAt.After(now) {
+				if ok && missing == 0 && initialRampup >= sleep && nextDialAt.After(now) {
 					l.Debugf("Not dialing %s via %v as sleep is %v, next dial is at %s and current time is %s", deviceID, addr, sleep, nextDialAt, now)
 					continue
 				}
@@ -448,7 +510,7 @@ func (s *service) connect(ctx context.Context) {
 
 				priority := dialerFactory.Priority()
 
-				if connected && priority >= ct.Priority() {
+				if connected && priority > ct.Priority() || connected && priority == ct.Priority() && missing == 0 {
 					l.Debugf("Not dialing using %s as priority is less than current connection (%d >= %d)", dialerFactory, dialerFactory.Priority(), ct.Priority())
 					continue
 				}
@@ -474,9 +536,17 @@ func (s *service) connect(ctx context.Context) {
 				})
 			}
 
-			conn, ok := s.dialParallel(ctx, deviceCfg.DeviceID, dialTargets)
-			if ok {
+			// Dial as many connections as are missing, or one to get
+			// connected or a better connection.
+			for i := 0; i == 0 || i < missing; i++ {
+				conn, ok := s.dialParallel(ctx, deviceCfg.DeviceID, dialTargets)
+				if !ok {
+					break
+				}
 				s.conns <- conn
+				if connected && conn.priority < ct.Priority() {
+					break
+				}
 			}
 		}
 
@@ -492,6 +562,55 @@ func (s *service) connect(ctx context.Context) {
 
 		select {
 		case <-time.After(sleep):
+		case <-s.connectNow:
+			l.Debugln("Device schedule opened, reconnecting")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// schedule closes the connections to devices whose schedule closed, and has
+// the connect loop reconnect when one opens.
+func (s *service) schedule(ctx context.Context) {
+	open := make(map[protocol.DeviceID]bool)
+	for {
+		now := time.Now()
+		var next time.Time
+		opened := false
+		for id, deviceCfg := range s.cfg.Devices() {
+			schedule := deviceCfg.Schedule()
+			if t := schedule.Next(now); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+			isOpen := schedule.Open(now)
+			if wasOpen, ok := open[id]; ok && isOpen && !wasOpen {
+				opened = true
+			}
+			open[id] = isOpen
+			if isOpen {
+				continue
+			}
+			if conn, ok := s.model.Connection(id); ok {
+				l.Infof("Disconnecting from %s at %s: %v", id, conn, errOutsideSchedule)
+				conn.Close(errOutsideSchedule)
+			}
+		}
+
+		if opened {
+			select {
+			case s.connectNow <- struct{}{}:
+			default:
+			}
+		}
+
+		var timeout <-chan time.Time
+		if !next.IsZero() {
+			timeout = time.After(time.Until(next))
+		}
+		select {
+		case <-timeout:
+		case <-s.scheduleChanged:
 		case <-ctx.Done():
 			return
 		}
@@ -652,6 +771,11 @@ func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 	}
 	s.listenersMut.Unlock()
 
+	select {
+	case s.scheduleChanged <- struct{}{}:
+	default:
+	}
+
 	return true
 }
 
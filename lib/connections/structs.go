@@ -71,6 +71,8 @@ const (
 	connTypeTCPServer
 	connTypeQUICClient
 	connTypeQUICServer
+	connTypeWSClient
+	connTypeWSServer
 )
 
 func (t connType) String() string {
@@ -87,6 +89,10 @@ func (t connType) String() string {
 		return "quic-client"
 	case connTypeQUICServer:
 		return "quic-server"
+	case connTypeWSClient:
+		return "ws-client"
+	case connTypeWSServer:
+		return "ws-server"
 	default:
 		return "unknown-type"
 	}
@@ -100,11 +106,24 @@ func (t connType) Transport() string {
 		return "tcp"
 	case connTypeQUICClient, connTypeQUICServer:
 		return "quic"
+	case connTypeWSClient, connTypeWSServer:
+		return "ws"
 	default:
 		return "unknown"
 	}
 }
 
+// dialed returns whether we dialed the connection, as opposed to accepting
+// it.
+func (t connType) dialed() bool {
+	switch t {
+	case connTypeRelayClient, connTypeTCPClient, connTypeQUICClient, connTypeWSClient:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c internalConn) Close() {
 	// *tls.Conn.Close() does more than it says on the tin. Specifically, it
 	// sends a TLS alert message, which might block forever if the
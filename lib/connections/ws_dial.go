@@ -0,0 +1,126 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func init() {
+	factory := &wsDialerFactory{}
+	for _, scheme := range []string{"ws", "wss"} {
+		dialers[scheme] = factory
+	}
+}
+
+type wsDialer struct {
+	commonDialer
+}
+
+func (d *wsDialer) Dial(ctx context.Context, _ protocol.DeviceID, uri *url.URL) (internalConn, error) {
+	uri = fixupPort(uri, wsDefaultPort(uri.Scheme))
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	// Goes through the proxy, if one is configured.
+	conn, err := dialer.DialContext(timeoutCtx, "tcp", uri.Host)
+	if err != nil {
+		return internalConn{}, err
+	}
+
+	err = dialer.SetTCPOptions(conn)
+	if err != nil {
+		l.Debugln("Dial (BEP/ws): setting tcp options:", err)
+	}
+
+	err = dialer.SetTrafficClass(conn, d.trafficClass)
+	if err != nil {
+		l.Debugln("Dial (BEP/ws): setting traffic class:", err)
+	}
+
+	ws, err := wsClientHandshake(timeoutCtx, conn, uri)
+	if err != nil {
+		conn.Close()
+		return internalConn{}, err
+	}
+
+	tc := tls.Client(ws, d.tlsCfg)
+	err = tlsTimedHandshake(tc)
+	if err != nil {
+		tc.Close()
+		return internalConn{}, err
+	}
+
+	return internalConn{tc, connTypeWSClient, wsPriority}, nil
+}
+
+// wsClientHandshake sets up the websocket on the connection, wrapped in TLS
+// for the wss scheme.
+func wsClientHandshake(ctx context.Context, conn net.Conn, uri *url.URL) (*wsConn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	rwc := conn
+	origin := "http://" + uri.Host
+	if uri.Scheme == "wss" {
+		tc := tls.Client(conn, wsTLSConfig(nil, uri.Hostname()))
+		if err := tc.Handshake(); err != nil {
+			return nil, err
+		}
+		rwc = tc
+		origin = "https://" + uri.Host
+	}
+
+	wsCfg, err := websocket.NewConfig(uri.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := websocket.NewClient(wsCfg, rwc)
+	if err != nil {
+		return nil, err
+	}
+	return newWSConn(ws, conn.LocalAddr(), conn.RemoteAddr()), nil
+}
+
+type wsDialerFactory struct{}
+
+func (wsDialerFactory) New(opts config.OptionsConfiguration, tlsCfg *tls.Config) genericDialer {
+	return &wsDialer{commonDialer{
+		trafficClass:      opts.TrafficClass,
+		reconnectInterval: time.Duration(opts.ReconnectIntervalS) * time.Second,
+		tlsCfg:            tlsCfg,
+	}}
+}
+
+func (wsDialerFactory) Priority() int {
+	return wsPriority
+}
+
+func (wsDialerFactory) AlwaysWAN() bool {
+	return false
+}
+
+func (wsDialerFactory) Valid(_ config.Configuration) error {
+	// Always valid
+	return nil
+}
+
+func (wsDialerFactory) String() string {
+	return "WebSocket Dialer"
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/nat"
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+func init() {
+	factory := &wsListenerFactory{}
+	for _, scheme := range []string{"ws", "wss"} {
+		listeners[scheme] = factory
+	}
+}
+
+type wsListener struct {
+	util.ServiceWithError
+	onAddressesChangedNotifier
+
+	uri     *url.URL
+	cfg     config.Wrapper
+	tlsCfg  *tls.Config
+	conns   chan internalConn
+	factory listenerFactory
+}
+
+func (t *wsListener) serve(ctx context.Context) error {
+	listener, err := net.Listen("tcp", t.uri.Host)
+	if err != nil {
+		l.Infoln("Listen (BEP/ws):", err)
+		return err
+	}
+	if t.uri.Scheme == "wss" {
+		listener = tls.NewListener(listener, wsTLSConfig(t.tlsCfg.Certificates, ""))
+	}
+	t.notifyAddressesChanged(t)
+	defer t.clearAddresses(t)
+
+	l.Infof("WebSocket listener (%v) starting", listener.Addr())
+	defer l.Infof("WebSocket listener (%v) shutting down", listener.Addr())
+
+	srv := &http.Server{
+		Handler:           t.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Failed handshakes and the like are not interesting.
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Established connections are not affected, as their handlers
+		// keep running until the connection is closed.
+		srv.Close()
+		<-serveErr
+		return nil
+	case err := <-serveErr:
+		l.Infoln("Listen (BEP/ws):", err)
+		return err
+	}
+}
+
+func (t *wsListener) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		local, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		remote, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		websocket.Server{
+			Handler: func(ws *websocket.Conn) {
+				t.handle(newWSConn(ws, local, remote))
+			},
+		}.ServeHTTP(w, r)
+	})
+}
+
+// handle runs for as long as the websocket is in use, as it's closed when
+// the handler returns.
+func (t *wsListener) handle(conn *wsConn) {
+	l.Debugln("Listen (BEP/ws): connect from", conn.RemoteAddr())
+
+	tc := tls.Server(conn, t.tlsCfg)
+	if err := tlsTimedHandshake(tc); err != nil {
+		l.Infoln("Listen (BEP/ws): TLS handshake:", err)
+		tc.Close()
+		return
+	}
+
+	t.conns <- internalConn{tc, connTypeWSServer, wsPriority}
+	<-conn.closed
+}
+
+func (t *wsListener) URI() *url.URL {
+	return t.uri
+}
+
+func (t *wsListener) WANAddresses() []*url.URL {
+	return t.LANAddresses()
+}
+
+func (t *wsListener) LANAddresses() []*url.URL {
+	return []*url.URL{t.uri}
+}
+
+func (t *wsListener) String() string {
+	return t.uri.String()
+}
+
+func (t *wsListener) Factory() listenerFactory {
+	return t.factory
+}
+
+func (t *wsListener) NATType() string {
+	return "unknown"
+}
+
+type wsListenerFactory struct{}
+
+func (f *wsListenerFactory) New(uri *url.URL, cfg config.Wrapper, tlsCfg *tls.Config, conns chan internalConn, natService *nat.Service) genericListener {
+	l := &wsListener{
+		uri:     fixupPort(uri, wsDefaultPort(uri.Scheme)),
+		cfg:     cfg,
+		tlsCfg:  tlsCfg,
+		conns:   conns,
+		factory: f,
+	}
+	l.ServiceWithError = util.AsServiceWithError(l.serve, l.String())
+	return l
+}
+
+func (wsListenerFactory) Valid(_ config.Configuration) error {
+	// Always valid
+	return nil
+}
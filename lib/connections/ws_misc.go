@@ -0,0 +1,75 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+const wsPriority = 150
+
+// wsDefaultPort returns the default port for the ws or wss scheme.
+func wsDefaultPort(scheme string) int {
+	if scheme == "wss" {
+		return 443
+	}
+	return 80
+}
+
+// wsTLSConfig returns the configuration for the TLS session wrapping the
+// websocket with the wss scheme. The devices are authenticated by the BEP
+// TLS session carried inside the websocket, not by this one, which may well
+// be terminated by a reverse proxy with a certificate of its own.
+func wsTLSConfig(certs []tls.Certificate, serverName string) *tls.Config {
+	return &tls.Config{
+		Certificates:       certs,
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"http/1.1"},
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// A wsConn is a websocket carrying binary frames, with the addresses of the
+// underlying connection. The websocket package gives the websocket URLs
+// instead.
+type wsConn struct {
+	*websocket.Conn
+	local, remote net.Addr
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newWSConn(ws *websocket.Conn, local, remote net.Addr) *wsConn {
+	ws.PayloadType = websocket.BinaryFrame
+	return &wsConn{
+		Conn:   ws,
+		local:  local,
+		remote: remote,
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestWebSocketConnection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tlsCfgs := make([]*tls.Config, 2)
+	for i := range tlsCfgs {
+		cert, err := tlsutil.NewCertificate(filepath.Join(dir, "cert"), filepath.Join(dir, "key"), "syncthing", 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tlsCfgs[i] = tlsutil.SecureDefault()
+		tlsCfgs[i].Certificates = []tls.Certificate{cert}
+		tlsCfgs[i].ClientAuth = tls.RequestClientCert
+		tlsCfgs[i].InsecureSkipVerify = true
+	}
+	clientID := protocol.NewDeviceID(tlsCfgs[0].Certificates[0].Certificate[0])
+	serverID := protocol.NewDeviceID(tlsCfgs[1].Certificates[0].Certificate[0])
+
+	for _, scheme := range []string{"ws", "wss"} {
+		t.Run(scheme, func(t *testing.T) {
+			listener := &wsListener{
+				tlsCfg: tlsCfgs[1],
+				conns:  make(chan internalConn, 1),
+			}
+			srv := httptest.NewUnstartedServer(listener.handler())
+			if scheme == "wss" {
+				srv.StartTLS()
+			} else {
+				srv.Start()
+			}
+			defer srv.Close()
+
+			uri, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uri.Scheme = scheme
+
+			dialer := wsDialerFactory{}.New(config.OptionsConfiguration{}, tlsCfgs[0])
+			client, err := dialer.Dial(context.Background(), serverID, uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			server := <-listener.conns
+			defer server.Close()
+
+			if client.Type() != "ws-client" || server.Type() != "ws-server" {
+				t.Errorf("unexpected types %v and %v", client.Type(), server.Type())
+			}
+			if client.RemoteAddr().String() != uri.Host || server.LocalAddr().String() != uri.Host {
+				t.Errorf("unexpected addresses %v and %v, expected %v", client.RemoteAddr(), server.LocalAddr(), uri.Host)
+			}
+
+			// The devices are authenticated by the TLS session inside the
+			// websocket.
+			if id := protocol.NewDeviceID(client.ConnectionState().PeerCertificates[0].Raw); id != serverID {
+				t.Errorf("client connected to %v, expected %v", id, serverID)
+			}
+			if id := protocol.NewDeviceID(server.ConnectionState().PeerCertificates[0].Raw); id != clientID {
+				t.Errorf("server connected to %v, expected %v", id, clientID)
+			}
+
+			data := make([]byte, 128<<10)
+			for i := range data {
+				data[i] = byte(i)
+			}
+			go client.Write(data)
+			buf := make([]byte, len(data))
+			if _, err := io.ReadFull(server, buf); err != nil {
+				t.Fatal(err)
+			}
+			for i := range data {
+				if buf[i] != data[i] {
+					t.Fatalf("data differs at %d", i)
+				}
+			}
+		})
+	}
+}
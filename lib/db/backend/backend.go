@@ -8,6 +8,7 @@ package backend
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -123,7 +124,17 @@ const (
 )
 
 func Open(path string, tuning Tuning) (Backend, error) {
-	if os.Getenv("USE_BADGER") != "" {
+	// The type is selected by the environment, or by the name of an
+	// existing database of that type.
+	if os.Getenv("USE_BOLT") != "" || filepath.Base(path) == locations.BoltDBFile {
+		l.Warnln("Using experimental bolt db")
+		if err := maybeCopyDatabase(path, strings.Replace(path, locations.BoltDBFile, locations.LevelDBDir, 1), OpenBolt, OpenLevelDBRO); err != nil {
+			return nil, err
+		}
+		return OpenBolt(path)
+	}
+
+	if os.Getenv("USE_BADGER") != "" || filepath.Base(path) == locations.BadgerDir {
 		l.Warnln("Using experimental badger db")
 		if err := maybeCopyDatabase(path, strings.Replace(path, locations.BadgerDir, locations.LevelDBDir, 1), OpenBadger, OpenLevelDBRO); err != nil {
 			return nil, err
@@ -134,10 +145,16 @@ func Open(path string, tuning Tuning) (Backend, error) {
 	if err := maybeCopyDatabase(path, strings.Replace(path, locations.LevelDBDir, locations.BadgerDir, 1), OpenLevelDBAuto, OpenBadger); err != nil {
 		return nil, err
 	}
+	if err := maybeCopyDatabase(path, strings.Replace(path, locations.LevelDBDir, locations.BoltDBFile, 1), OpenLevelDBAuto, OpenBolt); err != nil {
+		return nil, err
+	}
 	return OpenLevelDB(path, tuning)
 }
 
 func OpenMemory() Backend {
+	if os.Getenv("USE_BOLT") != "" {
+		return OpenBoltMemory()
+	}
 	if os.Getenv("USE_BADGER") != "" {
 		return OpenBadgerMemory()
 	}
@@ -253,7 +270,7 @@ func maybeCopyDatabase(toPath, fromPath string, toOpen, fromOpen opener) error {
 	defer toDB.Close()
 
 	l.Infoln("Copying database for format conversion...")
-	if err := copyBackend(toDB, fromDB); err != nil {
+	if err := CopyBackend(toDB, fromDB); err != nil {
 		return err
 	}
 
@@ -263,7 +280,9 @@ func maybeCopyDatabase(toPath, fromPath string, toOpen, fromOpen opener) error {
 	return nil
 }
 
-func copyBackend(to, from Backend) error {
+// CopyBackend copies all keys and values from one backend to another, in a
+// write transaction that is checkpointed along the way.
+func CopyBackend(to, from Backend) error {
 	srcIt, err := from.NewPrefixIterator(nil)
 	if err != nil {
 		return err
@@ -280,8 +299,11 @@ func copyBackend(to, from Backend) error {
 		if err := dstTx.Put(srcIt.Key(), srcIt.Value()); err != nil {
 			return err
 		}
+		if err := dstTx.Checkpoint(); err != nil {
+			return err
+		}
 	}
-	if srcIt.Error() != nil {
+	if err := srcIt.Error(); err != nil {
 		return err
 	}
 	srcIt.Release()
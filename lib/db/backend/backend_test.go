@@ -6,7 +6,15 @@
 
 package backend
 
-import "testing"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/locations"
+)
 
 // testBackendBehavior is the generic test suite that must be fulfilled by
 // every backend implementation. It should be called by each implementation
@@ -74,3 +82,79 @@ func testIteratorClosedDB(t *testing.T, open func() Backend) {
 		t.Error("Next: IsClosed(err) == false:", err)
 	}
 }
+
+func TestCopyBackend(t *testing.T) {
+	from := OpenLevelDBMemory()
+	defer from.Close()
+	to := OpenBoltMemory()
+	defer to.Close()
+
+	// Enough data to make the transaction checkpoint a few times.
+	val := make([]byte, 1000)
+	for i := 0; i < 1000; i++ {
+		if err := from.Put([]byte(fmt.Sprintf("key%04d", i)), val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CopyBackend(to, from); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := to.NewPrefixIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Release()
+	n := 0
+	for it.Next() {
+		if exp := fmt.Sprintf("key%04d", n); string(it.Key()) != exp {
+			t.Fatalf("got key %q, expected %q", it.Key(), exp)
+		}
+		if len(it.Value()) != len(val) {
+			t.Fatalf("got value of length %d for %q", len(it.Value()), it.Key())
+		}
+		n++
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1000 {
+		t.Errorf("copied %d keys, expected 1000", n)
+	}
+}
+
+func TestOpenByName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A database migrated to bolt is opened as such, and not converted
+	// back to LevelDB, without anything in the environment.
+	path := filepath.Join(dir, locations.BoltDBFile)
+	db, err := OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put([]byte("a"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Open(path, TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, ok := db.(*boltBackend); !ok {
+		t.Errorf("opened %T, expected a bolt database", db)
+	}
+	if v, err := db.Get([]byte("a")); err != nil || string(v) != "a" {
+		t.Errorf("got %q (%v), expected the migrated value", v, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, locations.LevelDBDir)); !os.IsNotExist(err) {
+		t.Error("expected no LevelDB database")
+	}
+}
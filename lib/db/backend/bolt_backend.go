@@ -0,0 +1,697 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// All our data lives in a single bucket.
+var boltBucket = []byte("syncthing")
+
+// The number of entries an iterator reads per bbolt transaction.
+const boltIteratorChunk = 128
+
+func OpenBolt(path string) (Backend, error) {
+	return openBolt(path, false)
+}
+
+func OpenBoltMemory() Backend {
+	// There is no in-memory mode, so we use a temporary file that is
+	// removed on close instead.
+	dir, err := ioutil.TempDir("", "syncthing-bolt-")
+	if err != nil {
+		panic(err)
+	}
+	backend, err := openBolt(filepath.Join(dir, "index.bolt"), true)
+	if err != nil {
+		// Opening a new database should never be able to fail, and this
+		// is anyway used just by tests.
+		panic(err)
+	}
+	return backend
+}
+
+func openBolt(path string, temporary bool) (Backend, error) {
+	opts := &bbolt.Options{
+		Timeout:        10 * time.Second,
+		FreelistType:   bbolt.FreelistMapType,
+		NoFreelistSync: true,
+	}
+	bdb, err := bbolt.Open(path, 0600, opts)
+	if err != nil {
+		return nil, wrapBoltErr(err)
+	}
+	var gen uint64
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		gen = uint64(tx.ID())
+		return err
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, wrapBoltErr(err)
+	}
+	return &boltBackend{
+		bdb:       bdb,
+		versions:  newBoltVersions(gen),
+		closeWG:   &closeWaitGroup{},
+		temporary: temporary,
+	}, nil
+}
+
+// boltBackend implements Backend on top of a bbolt database.
+//
+// No bbolt read transaction is kept open between calls. A write that needs
+// to grow the memory mapping of the database file waits for all of them to
+// finish, which would deadlock whenever the writing goroutine, or one it
+// waits for, holds a snapshot. Snapshots instead read the latest data and
+// use the previous values kept in versions for the keys changed since they
+// were taken.
+type boltBackend struct {
+	bdb       *bbolt.DB
+	versions  *boltVersions
+	closeWG   *closeWaitGroup
+	temporary bool
+}
+
+func (b *boltBackend) NewReadTransaction() (ReadTransaction, error) {
+	return b.newSnapshot()
+}
+
+func (b *boltBackend) newSnapshot() (*boltSnapshot, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	return &boltSnapshot{
+		b:   b,
+		gen: b.versions.open(),
+		rel: rel,
+	}, nil
+}
+
+func (b *boltBackend) NewWriteTransaction() (WriteTransaction, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := b.newSnapshot()
+	if err != nil {
+		rel.Release()
+		return nil, err // already wrapped
+	}
+	return &boltTransaction{
+		boltSnapshot: snap,
+		rel:          rel,
+	}, nil
+}
+
+func (b *boltBackend) Close() error {
+	b.closeWG.CloseWait()
+	err := b.bdb.Close()
+	if b.temporary {
+		os.RemoveAll(filepath.Dir(b.bdb.Path()))
+	}
+	return wrapBoltErr(err)
+}
+
+func (b *boltBackend) Get(key []byte) ([]byte, error) {
+	if err := b.closeWG.Add(1); err != nil {
+		return nil, err
+	}
+	defer b.closeWG.Done()
+
+	val, _, err := boltGet(b.bdb, key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, errNotFound{}
+	}
+	return val, nil
+}
+
+func (b *boltBackend) NewPrefixIterator(prefix []byte) (Iterator, error) {
+	snap, err := b.newSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	it := snap.newIterator(prefix, nil, nil)
+	it.releaseFn = snap.Release
+	return it, nil
+}
+
+func (b *boltBackend) NewRangeIterator(first, last []byte) (Iterator, error) {
+	snap, err := b.newSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	it := snap.newIterator(commonPrefix(first, last), first, last)
+	it.releaseFn = snap.Release
+	return it, nil
+}
+
+func (b *boltBackend) Put(key, val []byte) error {
+	if err := b.closeWG.Add(1); err != nil {
+		return err
+	}
+	defer b.closeWG.Done()
+
+	return b.update([]boltOp{{key: key, val: val}})
+}
+
+func (b *boltBackend) Delete(key []byte) error {
+	if err := b.closeWG.Add(1); err != nil {
+		return err
+	}
+	defer b.closeWG.Done()
+
+	return b.update([]boltOp{{key: key, delete: true}})
+}
+
+func (b *boltBackend) Compact() error {
+	// Freed pages are reused by later writes, and there is nothing to
+	// compact as such.
+	return nil
+}
+
+// update applies the operations in a bbolt read-write transaction,
+// recording the previous values for the snapshots.
+func (b *boltBackend) update(ops []boltOp) error {
+	var gen uint64
+	err := b.bdb.Update(func(tx *bbolt.Tx) error {
+		gen = uint64(tx.ID())
+		bkt := tx.Bucket(boltBucket)
+		prev := make([]boltVersion, 0, len(ops))
+		seen := make(map[string]struct{}, len(ops))
+		for _, op := range ops {
+			if _, ok := seen[string(op.key)]; !ok {
+				seen[string(op.key)] = struct{}{}
+				prev = append(prev, boltVersion{
+					key: copyBytes(op.key),
+					val: copyBytes(bkt.Get(op.key)),
+				})
+			}
+			var err error
+			if op.delete {
+				err = bkt.Delete(op.key)
+			} else {
+				err = bkt.Put(op.key, op.val)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		// Snapshots may see the changes as soon as they are committed.
+		b.versions.add(gen, prev)
+		return nil
+	})
+	if err != nil {
+		return wrapBoltErr(err)
+	}
+	b.versions.committed(gen)
+	return nil
+}
+
+// boltSnapshot implements backend.ReadTransaction
+type boltSnapshot struct {
+	b        *boltBackend
+	gen      uint64
+	rel      *releaser
+	released bool
+}
+
+func (s *boltSnapshot) Get(key []byte) ([]byte, error) {
+	val, cur, err := boltGet(s.b.bdb, key)
+	if err != nil {
+		return nil, err
+	}
+	val = s.b.versions.value(key, val, s.gen, cur)
+	if val == nil {
+		return nil, errNotFound{}
+	}
+	return val, nil
+}
+
+func (s *boltSnapshot) NewPrefixIterator(prefix []byte) (Iterator, error) {
+	return s.newIterator(prefix, nil, nil), nil
+}
+
+func (s *boltSnapshot) NewRangeIterator(first, last []byte) (Iterator, error) {
+	return s.newIterator(commonPrefix(first, last), first, last), nil
+}
+
+// newIterator returns an iterator over the snapshot, which keeps the
+// versions it needs even if the snapshot is released first.
+func (s *boltSnapshot) newIterator(prefix, first, last []byte) *boltIterator {
+	s.b.versions.retain(s.gen)
+	return &boltIterator{
+		b:      s.b,
+		gen:    s.gen,
+		prefix: prefix,
+		first:  first,
+		last:   last,
+	}
+}
+
+func (s *boltSnapshot) Release() {
+	if s.released {
+		return
+	}
+	s.released = true
+	s.b.versions.close(s.gen)
+	s.rel.Release()
+}
+
+type boltOp struct {
+	key    []byte
+	val    []byte
+	delete bool
+}
+
+// boltTransaction implements backend.WriteTransaction by collecting the
+// writes in memory and applying them in a bbolt read-write transaction
+// when flushing, much like the leveldb batch. This keeps the writes
+// invisible until flushed, and the time spent holding the database's
+// single writer lock short. Once flushed, reads see the latest data again,
+// so that the previous values don't need to be kept for the whole
+// transaction.
+type boltTransaction struct {
+	*boltSnapshot
+	ops  []boltOp
+	size int
+	rel  *releaser
+}
+
+func (t *boltTransaction) Delete(key []byte) error {
+	t.ops = append(t.ops, boltOp{key: copyBytes(key), delete: true})
+	t.size += len(key)
+	return t.checkFlush(dbFlushBatchMax)
+}
+
+func (t *boltTransaction) Put(key, val []byte) error {
+	t.ops = append(t.ops, boltOp{key: copyBytes(key), val: copyBytes(val)})
+	t.size += len(key) + len(val)
+	return t.checkFlush(dbFlushBatchMax)
+}
+
+func (t *boltTransaction) Checkpoint(preFlush ...func() error) error {
+	return t.checkFlush(dbFlushBatchMin, preFlush...)
+}
+
+func (t *boltTransaction) Commit() error {
+	err := t.flush()
+	t.boltSnapshot.Release()
+	t.rel.Release()
+	return err
+}
+
+func (t *boltTransaction) Release() {
+	t.boltSnapshot.Release()
+	t.rel.Release()
+}
+
+// checkFlush flushes and resets the pending writes if their size exceeds
+// the given size.
+func (t *boltTransaction) checkFlush(size int, preFlush ...func() error) error {
+	if t.size < size {
+		return nil
+	}
+	for _, hook := range preFlush {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return t.flush()
+}
+
+func (t *boltTransaction) flush() error {
+	if len(t.ops) == 0 {
+		return nil
+	}
+	if err := t.b.update(t.ops); err != nil {
+		return err
+	}
+	t.ops = t.ops[:0]
+	t.size = 0
+	if !t.released {
+		t.b.versions.close(t.gen)
+		t.gen = t.b.versions.open()
+	}
+	return nil
+}
+
+// boltVersions keeps the values keys had before being changed, for as long
+// as snapshots taken before the change are open. Generations are the IDs of
+// the bbolt transactions: a snapshot of generation gen sees the changes up
+// to and including transaction gen.
+type boltVersions struct {
+	mut       sync.Mutex
+	gen       uint64         // last committed transaction
+	snapshots map[uint64]int // number of open snapshots per generation
+	versions  []*boltVersion // in commit order
+	dropped   int            // number of versions removed from the front
+	byKey     map[string][]*boltVersion
+}
+
+type boltVersion struct {
+	gen uint64 // the transaction that changed the value
+	key []byte
+	val []byte // nil if the key didn't exist
+}
+
+func newBoltVersions(gen uint64) *boltVersions {
+	return &boltVersions{
+		gen:       gen,
+		snapshots: make(map[uint64]int),
+		byKey:     make(map[string][]*boltVersion),
+	}
+}
+
+// open returns the current generation, to be closed when the snapshot is
+// released.
+func (v *boltVersions) open() uint64 {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	v.snapshots[v.gen]++
+	return v.gen
+}
+
+// retain keeps the versions for an already open generation, to be closed
+// again.
+func (v *boltVersions) retain(gen uint64) {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	v.snapshots[gen]++
+}
+
+func (v *boltVersions) close(gen uint64) {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if v.snapshots[gen]--; v.snapshots[gen] <= 0 {
+		delete(v.snapshots, gen)
+	}
+	v.gc()
+}
+
+// add records the previous values of the keys changed in transaction gen.
+// It must be called before the transaction is committed. The versions of a
+// transaction that then fails to commit remain, which is harmless, as they
+// hold the values that are still current.
+func (v *boltVersions) add(gen uint64, prev []boltVersion) {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	for i := range prev {
+		ver := &prev[i]
+		ver.gen = gen
+		v.versions = append(v.versions, ver)
+		v.byKey[string(ver.key)] = append(v.byKey[string(ver.key)], ver)
+	}
+}
+
+func (v *boltVersions) committed(gen uint64) {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if gen > v.gen {
+		v.gen = gen
+	}
+	v.gc()
+}
+
+// gc drops the versions of changes no open snapshot predates.
+func (v *boltVersions) gc() {
+	keep := v.gen
+	for gen := range v.snapshots {
+		if gen < keep {
+			keep = gen
+		}
+	}
+	n := 0
+	for ; n < len(v.versions) && v.versions[n].gen <= keep; n++ {
+		// Versions of a key are in commit order as well.
+		key := string(v.versions[n].key)
+		if rest := v.byKey[key][1:]; len(rest) > 0 {
+			v.byKey[key] = rest
+		} else {
+			delete(v.byKey, key)
+		}
+		v.versions[n] = nil
+	}
+	v.versions = v.versions[n:]
+	v.dropped += n
+}
+
+// value returns the value of the key in the snapshot of generation gen,
+// given the value val read in transaction cur.
+func (v *boltVersions) value(key, val []byte, gen, cur uint64) []byte {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	return v.valueLocked(key, val, gen, cur)
+}
+
+func (v *boltVersions) valueLocked(key, val []byte, gen, cur uint64) []byte {
+	for _, ver := range v.byKey[string(key)] {
+		if ver.gen <= gen {
+			continue
+		}
+		if ver.gen <= cur {
+			// The first change after the snapshot was taken has the value
+			// the snapshot saw.
+			return copyBytes(ver.val)
+		}
+		break
+	}
+	return val
+}
+
+type boltKeyValue struct {
+	key []byte
+	val []byte
+}
+
+// boltIterator reads chunks of entries in short read transactions,
+// continuing after the last key read in the next one. Keys changed since
+// the snapshot was taken are merged in from the versions.
+type boltIterator struct {
+	b         *boltBackend
+	gen       uint64
+	prefix    []byte
+	first     []byte
+	last      []byte
+	pos       []byte   // last key read from the database
+	changed   [][]byte // sorted keys after pos changed since gen
+	seen      int      // position in the versions up to which changed is collected
+	chunk     []boltKeyValue
+	key       []byte
+	val       []byte
+	exhausted bool // nothing more to read after the current chunk
+	done      bool
+	err       error
+	released  bool
+	releaseFn func()
+}
+
+func (i *boltIterator) Next() bool {
+	if i.done {
+		return false
+	}
+	for len(i.chunk) == 0 && !i.exhausted {
+		if err := i.readChunk(); err != nil {
+			i.err = wrapBoltErr(err)
+			break
+		}
+	}
+	if len(i.chunk) == 0 {
+		i.done = true
+		i.key, i.val = nil, nil
+		return false
+	}
+	i.key, i.val = i.chunk[0].key, i.chunk[0].val
+	i.chunk = i.chunk[1:]
+	return true
+}
+
+func (i *boltIterator) readChunk() error {
+	var cur uint64
+	kvs := make([]boltKeyValue, 0, boltIteratorChunk)
+	err := i.b.bdb.View(func(tx *bbolt.Tx) error {
+		cur = uint64(tx.ID())
+		cursor := tx.Bucket(boltBucket).Cursor()
+		var k, v []byte
+		switch {
+		case i.pos != nil:
+			// Continue after the last key, which may have been deleted in
+			// the meantime.
+			k, v = cursor.Seek(i.pos)
+			if k != nil && bytes.Equal(k, i.pos) {
+				k, v = cursor.Next()
+			}
+		case i.first != nil:
+			// Range iterator
+			k, v = cursor.Seek(i.first)
+		default:
+			// Prefix iterator
+			k, v = cursor.Seek(i.prefix)
+		}
+		for ; len(kvs) < boltIteratorChunk; k, v = cursor.Next() {
+			if !i.inRange(k) {
+				i.exhausted = true
+				return nil
+			}
+			// The data is only valid during the transaction, and must
+			// not be modified.
+			kvs = append(kvs, boltKeyValue{copyBytes(k), copyBytes(v)})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	i.mergeVersions(kvs, cur)
+	if !i.exhausted {
+		i.pos = kvs[len(kvs)-1].key
+	}
+	return nil
+}
+
+// mergeVersions sets the chunk to the entries of the snapshot, from those
+// read in transaction cur and the keys changed since the snapshot.
+func (i *boltIterator) mergeVersions(kvs []boltKeyValue, cur uint64) {
+	v := i.b.versions
+	v.mut.Lock()
+	defer v.mut.Unlock()
+
+	n := i.seen - v.dropped
+	if n < 0 {
+		// Dropped versions are of changes before the snapshot.
+		n = 0
+	}
+	added := false
+	for ; n < len(v.versions) && v.versions[n].gen <= cur; n++ {
+		ver := v.versions[n]
+		if ver.gen > i.gen && i.inRange(ver.key) && (i.pos == nil || bytes.Compare(ver.key, i.pos) > 0) {
+			i.changed = append(i.changed, ver.key)
+			added = true
+		}
+	}
+	i.seen = n + v.dropped
+	if added {
+		sort.Slice(i.changed, func(a, b int) bool {
+			return bytes.Compare(i.changed[a], i.changed[b]) < 0
+		})
+		uniq := i.changed[:0]
+		for _, key := range i.changed {
+			if len(uniq) == 0 || !bytes.Equal(uniq[len(uniq)-1], key) {
+				uniq = append(uniq, key)
+			}
+		}
+		i.changed = uniq
+	}
+
+	// Keys beyond the last one read are handled with the next chunk.
+	n = len(i.changed)
+	if !i.exhausted {
+		end := kvs[len(kvs)-1].key
+		n = sort.Search(len(i.changed), func(j int) bool {
+			return bytes.Compare(i.changed[j], end) > 0
+		})
+	}
+	changed := i.changed[:n]
+	i.changed = i.changed[n:]
+
+	i.chunk = make([]boltKeyValue, 0, len(kvs)+len(changed))
+	for len(kvs) > 0 || len(changed) > 0 {
+		var kv boltKeyValue
+		switch {
+		case len(changed) == 0 || len(kvs) > 0 && bytes.Compare(kvs[0].key, changed[0]) < 0:
+			kv, kvs = kvs[0], kvs[1:]
+		case len(kvs) > 0 && bytes.Equal(kvs[0].key, changed[0]):
+			kv, kvs, changed = kvs[0], kvs[1:], changed[1:]
+		default:
+			// Doesn't exist anymore.
+			kv, changed = boltKeyValue{key: copyBytes(changed[0])}, changed[1:]
+		}
+		if kv.val = v.valueLocked(kv.key, kv.val, i.gen, cur); kv.val != nil {
+			i.chunk = append(i.chunk, kv)
+		}
+	}
+}
+
+func (i *boltIterator) inRange(key []byte) bool {
+	// The range excludes last, like the leveldb one does.
+	return key != nil && bytes.HasPrefix(key, i.prefix) &&
+		(i.first == nil || bytes.Compare(key, i.first) >= 0) &&
+		(i.last == nil || bytes.Compare(key, i.last) < 0)
+}
+
+func (i *boltIterator) Key() []byte {
+	return i.key
+}
+
+func (i *boltIterator) Value() []byte {
+	return i.val
+}
+
+func (i *boltIterator) Error() error {
+	return i.err
+}
+
+func (i *boltIterator) Release() {
+	if i.released {
+		return
+	}
+	i.released = true
+	i.done = true
+	i.chunk = nil
+	i.changed = nil
+	i.b.versions.close(i.gen)
+	if i.releaseFn != nil {
+		i.releaseFn()
+	}
+}
+
+// wrapBoltErr wraps errors so that the backend package can recognize them
+func wrapBoltErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == bbolt.ErrDatabaseNotOpen || err == bbolt.ErrTxClosed {
+		return errClosed{}
+	}
+	return err
+}
+
+// boltGet returns the current value of the key, or nil if it doesn't exist,
+// and the transaction it was read in.
+func boltGet(bdb *bbolt.DB, key []byte) ([]byte, uint64, error) {
+	var val []byte
+	var cur uint64
+	err := bdb.View(func(tx *bbolt.Tx) error {
+		cur = uint64(tx.ID())
+		val = copyBytes(tx.Bucket(boltBucket).Get(key))
+		return nil
+	})
+	return val, cur, wrapBoltErr(err)
+}
+
+func copyBytes(bs []byte) []byte {
+	if bs == nil {
+		return nil
+	}
+	c := make([]byte, len(bs))
+	copy(c, bs)
+	return c
+}
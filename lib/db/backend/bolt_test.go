@@ -0,0 +1,163 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBoltBackendBehavior(t *testing.T) {
+	testBackendBehavior(t, OpenBoltMemory)
+}
+
+// TestBoltTransactionGrowth writes enough, in a transaction and directly,
+// with an iterator open, to require the database to be remapped. That
+// deadlocks if a bbolt read transaction is kept open while writing.
+func TestBoltTransactionGrowth(t *testing.T) {
+	db := OpenBoltMemory()
+
+	const numKeys = 1000
+	for i := 0; i < numKeys; i++ {
+		if err := db.Put([]byte(fmt.Sprintf("a%04d", i)), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- func() error {
+			tx, err := db.NewWriteTransaction()
+			if err != nil {
+				return err
+			}
+			defer tx.Release()
+
+			it, err := tx.NewPrefixIterator([]byte("a"))
+			if err != nil {
+				return err
+			}
+			defer it.Release()
+
+			val := make([]byte, 16<<10)
+			seen := 0
+			for it.Next() {
+				if exp := fmt.Sprintf("a%04d", seen); string(it.Key()) != exp {
+					return fmt.Errorf("got key %s, expected %s", it.Key(), exp)
+				}
+				seen++
+				if err := tx.Put([]byte(fmt.Sprintf("b%04d", seen)), val); err != nil {
+					return err
+				}
+				if err := db.Put([]byte(fmt.Sprintf("c%04d", seen)), val); err != nil {
+					return err
+				}
+			}
+			if seen != numKeys {
+				return fmt.Errorf("iterated over %d keys, expected %d", seen, numKeys)
+			}
+
+			// Flushed writes are visible in the transaction.
+			if _, err := tx.Get([]byte("b0001")); err != nil {
+				return err
+			}
+			return tx.Commit()
+		}()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Minute):
+		// Closing the database would wait for the transaction.
+		t.Fatal("timed out, probably deadlocked")
+	}
+	defer db.Close()
+
+	if _, err := db.Get([]byte(fmt.Sprintf("b%04d", numKeys))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBoltSnapshotVersions checks that snapshots keep seeing the data as it
+// was when they were taken, over more than one chunk of an iterator.
+func TestBoltSnapshotVersions(t *testing.T) {
+	db := OpenBoltMemory()
+	defer db.Close()
+
+	const numKeys = 3 * boltIteratorChunk
+	for i := 0; i < numKeys; i += 2 {
+		if err := db.Put([]byte(fmt.Sprintf("a%04d", i)), []byte("old")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, err := db.NewReadTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+	it, err := snap.NewPrefixIterator([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Release()
+
+	// Change every key, existing or not, while iterating, once within the
+	// first chunk and once after. The changes stay invisible to the
+	// snapshot.
+	seen := 0
+	for it.Next() {
+		if exp := fmt.Sprintf("a%04d", 2*seen); string(it.Key()) != exp || string(it.Value()) != "old" {
+			t.Fatalf("got %s=%s, expected %s=old", it.Key(), it.Value(), exp)
+		}
+		seen++
+		if seen != 1 && seen != boltIteratorChunk+1 {
+			continue
+		}
+		tx, err := db.NewWriteTransaction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < numKeys; i++ {
+			key := []byte(fmt.Sprintf("a%04d", i))
+			if i%4 == 0 {
+				err = tx.Delete(key)
+			} else {
+				err = tx.Put(key, []byte(fmt.Sprint("new", seen)))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if seen != numKeys/2 {
+		t.Errorf("iterated over %d keys, expected %d", seen, numKeys/2)
+	}
+
+	if val, err := snap.Get([]byte("a0000")); err != nil || string(val) != "old" {
+		t.Errorf("got %s, %v from snapshot, expected old", val, err)
+	}
+	if _, err := snap.Get([]byte("a0001")); !IsNotFound(err) {
+		t.Errorf("got %v from snapshot, expected not found", err)
+	}
+	if _, err := db.Get([]byte("a0000")); !IsNotFound(err) {
+		t.Errorf("got %v, expected not found", err)
+	}
+	if val, err := db.Get([]byte("a0001")); err != nil || string(val) != fmt.Sprint("new", boltIteratorChunk+1) {
+		t.Errorf("got %s, %v, expected new%d", val, err, boltIteratorChunk+1)
+	}
+}
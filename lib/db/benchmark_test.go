@@ -329,6 +329,30 @@ func BenchmarkGlobalTruncated(b *testing.B) {
 	b.ReportAllocs()
 }
 
+// BenchmarkNeedCountRestricted counts the need of an index only folder,
+// which selects what it pulls.
+func BenchmarkNeedCountRestricted(b *testing.B) {
+	ldb, benchS := getBenchFileSet()
+	defer ldb.Close()
+
+	paths := make([]string, len(secondHalf))
+	for i, f := range secondHalf {
+		paths[i] = f.Name
+	}
+	benchS.SetSelection(db.NewRestrictedSelection(paths))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snap := benchS.Snapshot()
+		if need := snap.NeedSize(protocol.LocalDeviceID); int(need.Files) != len(secondHalf) {
+			b.Fatalf("wrong need %v", need)
+		}
+		snap.Release()
+	}
+
+	b.ReportAllocs()
+}
+
 func BenchmarkNeedCount(b *testing.B) {
 	ldb, benchS := getBenchFileSet()
 	defer ldb.Close()
@@ -0,0 +1,433 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The export is a stream of JSON objects, one per line, starting with a
+// header and ending with an end marker. Everything that isn't derived from
+// other data is included; the derived data is rebuilt on import.
+const (
+	exportVersion = 1
+
+	exportTypeHeader          = "header"
+	exportTypeMeta            = "meta"
+	exportTypeFile            = "file"
+	exportTypeMtime           = "mtime"
+	exportTypeFileHistory     = "fileHistory"
+	exportTypeIndexID         = "indexID"
+	exportTypeDeviceStatistic = "deviceStatistic"
+	exportTypeFolderStatistic = "folderStatistic"
+	exportTypeEnd             = "end"
+
+	// Files are imported in batches of at most this many
+	importBatchFiles = 1000
+)
+
+var errExportTruncated = errors.New("unexpected end of export")
+
+type exportRecord struct {
+	Type    string             `json:"type"`
+	Version int                `json:"version,omitempty"`
+	Folder  string             `json:"folder,omitempty"`
+	Device  *protocol.DeviceID `json:"device,omitempty"`
+	File    *protocol.FileInfo `json:"file,omitempty"`
+	IndexID protocol.IndexID   `json:"indexID,omitempty,string"`
+	Counts  *CountsSet         `json:"counts,omitempty"`
+	Key     string             `json:"key,omitempty"`
+	Value   []byte             `json:"value,omitempty"`
+}
+
+// Export writes the contents of the database to w, in a format that is
+// independent of the database backend and layout. The folder metadata is
+// included for reference, but is recalculated on import.
+func (db *Lowlevel) Export(w io.Writer) error {
+	t, err := db.newReadOnlyTransaction()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&exportRecord{Type: exportTypeHeader, Version: exportVersion}); err != nil {
+		return err
+	}
+	for _, folder := range db.ListFolders() {
+		if err := t.exportFolder(enc, folder); err != nil {
+			return err
+		}
+	}
+	if err := db.exportIndexIDs(t, enc); err != nil {
+		return err
+	}
+	if err := exportStatistics(t, enc, KeyTypeDeviceStatistic, exportTypeDeviceStatistic); err != nil {
+		return err
+	}
+	if err := exportStatistics(t, enc, KeyTypeFolderStatistic, exportTypeFolderStatistic); err != nil {
+		return err
+	}
+	return enc.Encode(&exportRecord{Type: exportTypeEnd})
+}
+
+func (t readOnlyTransaction) exportFolder(enc *json.Encoder, folder string) error {
+	key, err := t.keyer.GenerateFolderMetaKey(nil, []byte(folder))
+	if err != nil {
+		return err
+	}
+	if bs, err := t.Get(key); err == nil {
+		var counts CountsSet
+		if err := counts.Unmarshal(bs); err != nil {
+			return err
+		}
+		if err := enc.Encode(&exportRecord{Type: exportTypeMeta, Folder: folder, Counts: &counts}); err != nil {
+			return err
+		}
+	}
+
+	if err := t.exportFiles(enc, folder); err != nil {
+		return err
+	}
+	if err := t.exportMtimes(enc, folder); err != nil {
+		return err
+	}
+	return t.exportFileHistory(enc, folder)
+}
+
+func (t readOnlyTransaction) exportFiles(enc *json.Encoder, folder string) error {
+	key, err := t.keyer.GenerateDeviceFileKey(nil, []byte(folder), nil, nil)
+	if err != nil {
+		return err
+	}
+	dbi, err := t.NewPrefixIterator(key.WithoutNameAndDevice())
+	if err != nil {
+		return err
+	}
+	defer dbi.Release()
+	for dbi.Next() {
+		device, ok := t.keyer.DeviceFromDeviceFileKey(dbi.Key())
+		if !ok {
+			continue
+		}
+		devID, err := protocol.DeviceIDFromBytes(device)
+		if err != nil {
+			return err
+		}
+		intf, err := t.unmarshalTrunc(dbi.Value(), false)
+		if err != nil {
+			return err
+		}
+		f := intf.(protocol.FileInfo)
+		// The version has been filled in, and the hash is a detail of the
+		// database layout.
+		f.VersionHash = nil
+		if err := enc.Encode(&exportRecord{Type: exportTypeFile, Folder: folder, Device: &devID, File: &f}); err != nil {
+			return err
+		}
+	}
+	return dbi.Error()
+}
+
+func (t readOnlyTransaction) exportMtimes(enc *json.Encoder, folder string) error {
+	key, err := t.keyer.GenerateMtimesKey(nil, []byte(folder))
+	if err != nil {
+		return err
+	}
+	dbi, err := t.NewPrefixIterator(key)
+	if err != nil {
+		return err
+	}
+	defer dbi.Release()
+	for dbi.Next() {
+		name := string(dbi.Key()[len(key):])
+		if err := enc.Encode(&exportRecord{Type: exportTypeMtime, Folder: folder, Key: name, Value: dbi.Value()}); err != nil {
+			return err
+		}
+	}
+	return dbi.Error()
+}
+
+func (t readOnlyTransaction) exportFileHistory(enc *json.Encoder, folder string) error {
+	key, err := t.keyer.GenerateFileHistoryKey(nil, []byte(folder), nil)
+	if err != nil {
+		return err
+	}
+	dbi, err := t.NewPrefixIterator(key)
+	if err != nil {
+		return err
+	}
+	defer dbi.Release()
+	for dbi.Next() {
+		name := string(t.keyer.NameFromFileHistoryKey(dbi.Key()))
+		if err := enc.Encode(&exportRecord{Type: exportTypeFileHistory, Folder: folder, Key: name, Value: dbi.Value()}); err != nil {
+			return err
+		}
+	}
+	return dbi.Error()
+}
+
+func (db *Lowlevel) exportIndexIDs(t readOnlyTransaction, enc *json.Encoder) error {
+	dbi, err := t.NewPrefixIterator([]byte{KeyTypeIndexID})
+	if err != nil {
+		return err
+	}
+	defer dbi.Release()
+	for dbi.Next() {
+		key := dbi.Key()
+		if len(key) != keyPrefixLen+keyDeviceLen+keyFolderLen {
+			continue
+		}
+		device, ok := db.deviceIdx.Val(binary.BigEndian.Uint32(key[keyPrefixLen:]))
+		if !ok {
+			continue
+		}
+		folder, ok := db.folderIdx.Val(binary.BigEndian.Uint32(key[keyPrefixLen+keyDeviceLen:]))
+		if !ok {
+			continue
+		}
+		devID, err := protocol.DeviceIDFromBytes(device)
+		if err != nil {
+			return err
+		}
+		var id protocol.IndexID
+		if err := id.Unmarshal(dbi.Value()); err != nil {
+			return err
+		}
+		if err := enc.Encode(&exportRecord{Type: exportTypeIndexID, Folder: string(folder), Device: &devID, IndexID: id}); err != nil {
+			return err
+		}
+	}
+	return dbi.Error()
+}
+
+// exportStatistics exports the statistics namespaces of the given key type.
+// The key is everything after the key type, i.e. the device or folder ID
+// followed by the name of the statistic.
+func exportStatistics(t readOnlyTransaction, enc *json.Encoder, keyType byte, recordType string) error {
+	dbi, err := t.NewPrefixIterator([]byte{keyType})
+	if err != nil {
+		return err
+	}
+	defer dbi.Release()
+	for dbi.Next() {
+		key := string(dbi.Key()[keyPrefixLen:])
+		if err := enc.Encode(&exportRecord{Type: recordType, Key: key, Value: dbi.Value()}); err != nil {
+			return err
+		}
+	}
+	return dbi.Error()
+}
+
+// Import reads an export as written by Export into the database. Folders
+// that are part of the export replace any existing data for the same
+// folder. The global version lists, needed files, block maps and folder
+// metadata are rebuilt from the imported files, while sequence numbers are
+// retained. The imported folders must not be in use while importing.
+//
+// The whole export is validated before anything is changed, so that a
+// malformed or truncated export leaves the database as it was.
+func (db *Lowlevel) Import(r io.ReadSeeker) error {
+	if err := ValidateExport(r); err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	imp := &importer{
+		db:    db,
+		metas: make(map[string]*metadataTracker),
+	}
+	if err := readExport(r, imp.handle); err != nil {
+		return err
+	}
+	return imp.finish()
+}
+
+// ValidateExport reads an export as written by Export, returning an error
+// if it isn't complete or can't be imported.
+func ValidateExport(r io.Reader) error {
+	return readExport(r, func(exportRecord) error { return nil })
+}
+
+// readExport calls fn for each record of the export, after checking it,
+// excluding the header and end marker.
+func readExport(r io.Reader, fn func(exportRecord) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var hdr exportRecord
+	if err := dec.Decode(&hdr); err == io.EOF {
+		return errExportTruncated
+	} else if err != nil {
+		return err
+	}
+	if hdr.Type != exportTypeHeader {
+		return errors.New("not a database export")
+	}
+	if hdr.Version > exportVersion {
+		return fmt.Errorf("unsupported export version %d", hdr.Version)
+	}
+
+	for {
+		var rec exportRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return errExportTruncated
+		} else if err != nil {
+			return err
+		}
+		if rec.Type == exportTypeEnd {
+			return nil
+		}
+		if err := rec.check(); err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// check returns an error if the record lacks something needed to import it.
+func (rec *exportRecord) check() error {
+	switch rec.Type {
+	case exportTypeFile:
+		if rec.File == nil || rec.Device == nil {
+			return errors.New("incomplete file record")
+		}
+	case exportTypeIndexID:
+		if rec.Device == nil {
+			return errors.New("incomplete index ID record")
+		}
+	case exportTypeMtime, exportTypeFileHistory:
+	default:
+		return nil
+	}
+	if rec.Folder == "" {
+		return errors.New("missing folder in record")
+	}
+	return nil
+}
+
+type importer struct {
+	db     *Lowlevel
+	metas  map[string]*metadataTracker
+	folder string
+	device protocol.DeviceID
+	files  []protocol.FileInfo
+}
+
+func (i *importer) handle(rec exportRecord) error {
+	switch rec.Type {
+	case exportTypeMeta:
+		// Recalculated when done.
+		return nil
+
+	case exportTypeFile:
+		if rec.Folder != i.folder || *rec.Device != i.device || len(i.files) >= importBatchFiles {
+			if err := i.flushFiles(); err != nil {
+				return err
+			}
+		}
+		if _, err := i.meta(rec.Folder); err != nil {
+			return err
+		}
+		i.folder = rec.Folder
+		i.device = *rec.Device
+		i.files = append(i.files, *rec.File)
+		return nil
+
+	case exportTypeMtime:
+		if _, err := i.meta(rec.Folder); err != nil {
+			return err
+		}
+		key, err := i.db.keyer.GenerateMtimesKey(nil, []byte(rec.Folder))
+		if err != nil {
+			return err
+		}
+		return i.db.Put(append(key, rec.Key...), rec.Value)
+
+	case exportTypeFileHistory:
+		if _, err := i.meta(rec.Folder); err != nil {
+			return err
+		}
+		key, err := i.db.keyer.GenerateFileHistoryKey(nil, []byte(rec.Folder), []byte(rec.Key))
+		if err != nil {
+			return err
+		}
+		return i.db.Put(key, rec.Value)
+
+	case exportTypeIndexID:
+		if _, err := i.meta(rec.Folder); err != nil {
+			return err
+		}
+		return i.db.setIndexID(rec.Device[:], []byte(rec.Folder), rec.IndexID)
+
+	case exportTypeDeviceStatistic:
+		return i.db.Put(append([]byte{KeyTypeDeviceStatistic}, rec.Key...), rec.Value)
+
+	case exportTypeFolderStatistic:
+		return i.db.Put(append([]byte{KeyTypeFolderStatistic}, rec.Key...), rec.Value)
+
+	default:
+		// Might have been added in a later version, without being
+		// essential.
+		l.Debugf("Skipping unknown record type %q in database import", rec.Type)
+		return nil
+	}
+}
+
+// meta returns the metadata tracker for the given folder, clearing out
+// existing data for the folder the first time it's seen.
+func (i *importer) meta(folder string) (*metadataTracker, error) {
+	if meta, ok := i.metas[folder]; ok {
+		return meta, nil
+	}
+	if err := i.db.dropFolderAll([]byte(folder)); err != nil {
+		return nil, err
+	}
+	meta := newMetadataTracker()
+	i.metas[folder] = meta
+	return meta, nil
+}
+
+func (i *importer) flushFiles() error {
+	if len(i.files) == 0 {
+		return nil
+	}
+	meta := i.metas[i.folder]
+	var err error
+	if i.device == protocol.LocalDeviceID {
+		err = i.db.updateLocalFiles([]byte(i.folder), i.files, meta, true, fileHistoryRetention{})
+	} else {
+		err = i.db.updateRemoteFiles([]byte(i.folder), i.device[:], i.files, meta, fileHistoryRetention{})
+	}
+	i.files = i.files[:0]
+	return err
+}
+
+func (i *importer) finish() error {
+	if err := i.flushFiles(); err != nil {
+		return err
+	}
+	for folder := range i.metas {
+		i.db.gcMut.RLock()
+		_, err := i.db.recalcMeta(folder)
+		i.db.gcMut.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
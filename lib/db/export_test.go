@@ -0,0 +1,225 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d4l3k/messagediff"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestExportImport(t *testing.T) {
+	const folder = "test"
+
+	ldb := NewLowlevel(backend.OpenMemory())
+	defer ldb.Close()
+	s := NewFileSet(folder, fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb)
+	s.SetFileHistoryRetention(10, 0)
+
+	// A long version vector, to get it indirected.
+	var longVersion protocol.Vector
+	for i := 1; i <= versionIndirectionCutoff+1; i++ {
+		longVersion = longVersion.Update(protocol.ShortID(i))
+	}
+
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{}.Update(myID), Blocks: genBlocks(1), Size: 1},
+		{Name: "b", Version: protocol.Vector{}.Update(myID), Blocks: genBlocks(5), Size: 10},
+		{Name: "c", Version: longVersion, Type: protocol.FileInfoTypeDirectory},
+	})
+	// Leave a gap in the local sequence numbers.
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{}.Update(myID).Update(myID), Blocks: genBlocks(2), Size: 1},
+	})
+	s.Update(remoteDevice0, []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{}.Update(myID), Blocks: genBlocks(1), Size: 1, Sequence: 1},
+		{Name: "d", Version: protocol.Vector{}.Update(42), Blocks: genBlocks(3), Size: 3, Sequence: 2},
+		{Name: "e", Version: protocol.Vector{}.Update(42), Deleted: true, Sequence: 3},
+	})
+	s.IndexID(protocol.LocalDeviceID) // generates one
+	s.SetIndexID(remoteDevice0, 5678)
+
+	mtimes, err := ldb.keyer.GenerateMtimesKey(nil, []byte(folder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ldb.Put(append(mtimes, "a"...), []byte("mtime")); err != nil {
+		t.Fatal(err)
+	}
+	lastSeen := time.Unix(1234567890, 0)
+	if err := NewDeviceStatisticsNamespace(ldb, remoteDevice0.String()).PutTime("lastSeen", lastSeen); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFolderStatisticsNamespace(ldb, folder).PutString("lastFile", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	var exp bytes.Buffer
+	if err := ldb.Export(&exp); err != nil {
+		t.Fatal(err)
+	}
+
+	// Import into a database with stale data for the folder, which should
+	// be replaced.
+
+	ldb2 := NewLowlevel(backend.OpenMemory())
+	defer ldb2.Close()
+	stale := NewFileSet(folder, fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb2)
+	stale.Update(remoteDevice1, []protocol.FileInfo{
+		{Name: "stale", Version: protocol.Vector{}.Update(43), Blocks: genBlocks(1), Size: 1, Sequence: 1},
+	})
+
+	if err := ldb2.Import(bytes.NewReader(exp.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	s2 := NewFileSet(folder, fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb2)
+
+	snap := s.Snapshot()
+	defer snap.Release()
+	snap2 := s2.Snapshot()
+	defer snap2.Release()
+
+	for _, dev := range []protocol.DeviceID{protocol.LocalDeviceID, remoteDevice0, remoteDevice1} {
+		if seq, seq2 := snap.Sequence(dev), snap2.Sequence(dev); seq != seq2 {
+			t.Errorf("sequence for %v: %d != %d", dev, seq2, seq)
+		}
+		if diff, equal := messagediff.PrettyDiff(snapHave(snap, dev), snapHave(snap2, dev)); !equal {
+			t.Errorf("files for %v differ:\n%s", dev, diff)
+		}
+		if diff, equal := messagediff.PrettyDiff(snapNeed(snap, dev), snapNeed(snap2, dev)); !equal {
+			t.Errorf("needed files for %v differ:\n%s", dev, diff)
+		}
+		if diff, equal := messagediff.PrettyDiff(snap.NeedSize(dev), snap2.NeedSize(dev)); !equal {
+			t.Errorf("need size for %v differs:\n%s", dev, diff)
+		}
+		if id, id2 := s.IndexID(dev), s2.IndexID(dev); id != id2 {
+			t.Errorf("index ID for %v: %v != %v", dev, id2, id)
+		}
+	}
+	if diff, equal := messagediff.PrettyDiff(snap.GlobalSize(), snap2.GlobalSize()); !equal {
+		t.Errorf("global size differs:\n%s", diff)
+	}
+	if diff, equal := messagediff.PrettyDiff(snap.LocalSize(), snap2.LocalSize()); !equal {
+		t.Errorf("local size differs:\n%s", diff)
+	}
+	if diff, equal := messagediff.PrettyDiff(snap.FileHistory("a"), snap2.FileHistory("a")); !equal || len(snap2.FileHistory("a")) != 2 {
+		t.Errorf("file history differs:\n%s", diff)
+	}
+	if _, ok := snap2.GetGlobal("stale"); ok {
+		t.Error("stale file should be gone")
+	}
+
+	// The block map was rebuilt.
+	found := false
+	NewBlockFinder(ldb2).Iterate([]string{folder}, genBlocks(5)[4].Hash, func(folder, file string, index int32) bool {
+		found = file == "b" && index == 4
+		return true
+	})
+	if !found {
+		t.Error("block not found in imported block map")
+	}
+
+	mtimes, err = ldb2.keyer.GenerateMtimesKey(nil, []byte(folder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := ldb2.Get(append(mtimes, "a"...)); err != nil || string(bs) != "mtime" {
+		t.Errorf("mtime not imported: %q, %v", bs, err)
+	}
+	if ts, ok, err := NewDeviceStatisticsNamespace(ldb2, remoteDevice0.String()).Time("lastSeen"); err != nil || !ok || !ts.Equal(lastSeen) {
+		t.Errorf("device statistic not imported: %v, %v, %v", ts, ok, err)
+	}
+	if v, ok, err := NewFolderStatisticsNamespace(ldb2, folder).String("lastFile"); err != nil || !ok || v != "a" {
+		t.Errorf("folder statistic not imported: %v, %v, %v", v, ok, err)
+	}
+
+	// Exporting again gives the same result, except for the metadata.
+	var exp2 bytes.Buffer
+	if err := ldb2.Export(&exp2); err != nil {
+		t.Fatal(err)
+	}
+	if diff, equal := messagediff.PrettyDiff(exportLinesWithoutMeta(exp.String()), exportLinesWithoutMeta(exp2.String())); !equal {
+		t.Errorf("exports differ:\n%s", diff)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	ldb := NewLowlevel(backend.OpenMemory())
+	defer ldb.Close()
+
+	folder := "test"
+	s := NewFileSet(folder, fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb)
+	existing := protocol.FileInfo{Name: "existing", Version: protocol.Vector{}.Update(42), Blocks: genBlocks(1), Size: 1, Sequence: 1}
+	s.Update(remoteDevice0, []protocol.FileInfo{existing})
+
+	file := `{"type":"file","folder":"test","device":"` + remoteDevice1.String() + `","file":{"name":"new"}}` + "\n"
+	cases := map[string]string{
+		"empty":         "",
+		"no header":     `{"type":"end"}` + "\n",
+		"version":       `{"type":"header","version":1000}` + "\n",
+		"truncated":     `{"type":"header","version":1}` + "\n",
+		"garbage":       `{"type":"header","version":1}` + "\n" + "garbage\n",
+		"truncated end": `{"type":"header","version":1}` + "\n" + file,
+		"garbage end":   `{"type":"header","version":1}` + "\n" + file + "garbage\n",
+		"no device":     `{"type":"header","version":1}` + "\n" + `{"type":"file","folder":"test","file":{"name":"new"}}` + "\n" + `{"type":"end"}` + "\n",
+		"no folder":     `{"type":"header","version":1}` + "\n" + `{"type":"mtime","key":"a"}` + "\n" + `{"type":"end"}` + "\n",
+	}
+	for name, data := range cases {
+		if err := ValidateExport(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+		if err := ldb.Import(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// The folder's data was left alone by the failed imports.
+	snap := s.Snapshot()
+	defer snap.Release()
+	if f, ok := snap.Get(remoteDevice0, existing.Name); !ok || !f.IsEquivalent(existing, 0) {
+		t.Errorf("existing file changed to %v", f)
+	}
+	if _, ok := snap.Get(remoteDevice1, "new"); ok {
+		t.Error("file from a failed import was added")
+	}
+}
+
+func snapHave(snap *Snapshot, dev protocol.DeviceID) []protocol.FileInfo {
+	var fs []protocol.FileInfo
+	snap.WithHave(dev, func(fi protocol.FileIntf) bool {
+		fs = append(fs, fi.(protocol.FileInfo))
+		return true
+	})
+	return fs
+}
+
+func snapNeed(snap *Snapshot, dev protocol.DeviceID) []protocol.FileInfo {
+	var fs []protocol.FileInfo
+	snap.WithNeed(dev, func(fi protocol.FileIntf) bool {
+		fs = append(fs, fi.(protocol.FileInfo))
+		return true
+	})
+	return fs
+}
+
+func exportLinesWithoutMeta(exp string) []string {
+	var lines []string
+	for _, line := range strings.Split(exp, "\n") {
+		if !strings.HasPrefix(line, `{"type":"meta"`) {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
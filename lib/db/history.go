@@ -0,0 +1,169 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// fileHistoryRetention limits the history kept for each file. The zero
+// value keeps no history at all.
+type fileHistoryRetention struct {
+	entries int           // at most this many entries per file
+	maxAge  time.Duration // zero means no age limit
+}
+
+func (r fileHistoryRetention) enabled() bool {
+	return r.entries > 0
+}
+
+// RecordedAt returns the time the change was recorded.
+func (e FileHistoryEntry) RecordedAt() time.Time {
+	return time.Unix(0, e.Time)
+}
+
+// ModTime returns the modification time of the file in this version.
+func (e FileHistoryEntry) ModTime() time.Time {
+	return time.Unix(e.ModifiedS, int64(e.ModifiedNs))
+}
+
+// prune removes the entries that are beyond the retention limits, as of
+// the given time.
+func (h *FileHistory) prune(r fileHistoryRetention, now time.Time) {
+	if r.maxAge > 0 {
+		cutoff := now.Add(-r.maxAge).UnixNano()
+		i := 0
+		for i < len(h.Entries) && h.Entries[i].Time < cutoff {
+			i++
+		}
+		h.Entries = h.Entries[i:]
+	}
+	if len(h.Entries) > r.entries {
+		h.Entries = h.Entries[len(h.Entries)-r.entries:]
+	}
+}
+
+func (t readOnlyTransaction) getFileHistory(keyBuf, folder, name []byte) ([]byte, FileHistory, error) {
+	var hist FileHistory
+	keyBuf, err := t.keyer.GenerateFileHistoryKey(keyBuf, folder, name)
+	if err != nil {
+		return nil, hist, err
+	}
+	bs, err := t.Get(keyBuf)
+	if backend.IsNotFound(err) {
+		return keyBuf, hist, nil
+	} else if err != nil {
+		return nil, hist, err
+	}
+	if err := hist.Unmarshal(bs); err != nil {
+		return nil, hist, err
+	}
+	return keyBuf, hist, nil
+}
+
+// addFileHistory records global as the new global version of the file,
+// received from the given device.
+func (t readWriteTransaction) addFileHistory(keyBuf, folder, device []byte, global protocol.FileIntf, retention fileHistoryRetention) ([]byte, error) {
+	if !retention.enabled() || global.IsInvalid() {
+		return keyBuf, nil
+	}
+
+	keyBuf, hist, err := t.getFileHistory(keyBuf, folder, []byte(global.FileName()))
+	if err != nil {
+		return nil, err
+	}
+
+	// The global version changes without the version itself changing when
+	// the global file goes from invalid to valid. That isn't a change to the
+	// file as such.
+	if n := len(hist.Entries); n > 0 && hist.Entries[n-1].Version.Equal(global.FileVersion()) {
+		return keyBuf, nil
+	}
+
+	now := time.Now()
+	modTime := global.ModTime()
+	hist.Entries = append(hist.Entries, FileHistoryEntry{
+		Time:       now.UnixNano(),
+		Device:     device,
+		Type:       global.FileType(),
+		Size:       global.FileSize(),
+		Version:    global.FileVersion(),
+		Deleted:    global.IsDeleted(),
+		ModifiedBy: global.FileModifiedBy(),
+		ModifiedS:  modTime.Unix(),
+		ModifiedNs: int32(modTime.Nanosecond()),
+	})
+	hist.prune(retention, now)
+
+	l.Debugf("adding file history; folder=%q file=%q version=%v", folder, global.FileName(), global.FileVersion())
+	return keyBuf, t.Put(keyBuf, mustMarshal(&hist))
+}
+
+func (db *Lowlevel) dropFileHistory(folder []byte) error {
+	key, err := db.keyer.GenerateFileHistoryKey(nil, folder, nil)
+	if err != nil {
+		return err
+	}
+	return db.dropPrefix(key)
+}
+
+// dropDeviceFileHistory removes the entries received from the given device
+// from the history of all files in the folder.
+func (db *Lowlevel) dropDeviceFileHistory(folder, device []byte) error {
+	t, err := db.newReadWriteTransaction()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	key, err := db.keyer.GenerateFileHistoryKey(nil, folder, nil)
+	if err != nil {
+		return err
+	}
+	dbi, err := t.NewPrefixIterator(key)
+	if err != nil {
+		return err
+	}
+	defer dbi.Release()
+
+	for dbi.Next() {
+		var hist FileHistory
+		if err := hist.Unmarshal(dbi.Value()); err != nil {
+			return err
+		}
+		kept := hist.Entries[:0]
+		for _, e := range hist.Entries {
+			if !bytes.Equal(e.Device, device) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == len(hist.Entries) {
+			continue
+		}
+		hist.Entries = kept
+		if len(kept) == 0 {
+			err = t.Delete(dbi.Key())
+		} else {
+			err = t.Put(dbi.Key(), mustMarshal(&hist))
+		}
+		if err != nil {
+			return err
+		}
+		if err := t.Checkpoint(); err != nil {
+			return err
+		}
+	}
+	dbi.Release()
+	if err := dbi.Error(); err != nil {
+		return err
+	}
+	return t.Commit()
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFileHistory(t *testing.T) {
+	ldb := NewLowlevel(backend.OpenMemory())
+	defer ldb.Close()
+	s := NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb)
+
+	// Nothing is recorded until enabled.
+	v1 := protocol.Vector{}.Update(myID)
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{{Name: "a", Version: v1, Size: 1}})
+	if hist := fileHistory(s, "a"); len(hist) != 0 {
+		t.Fatalf("got history without retention: %v", hist)
+	}
+
+	s.SetFileHistoryRetention(3, 0)
+
+	v2 := v1.Update(myID)
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{{Name: "a", Version: v2, Size: 2, ModifiedBy: myID}})
+
+	// An older version from a remote doesn't change the global version.
+	s.Update(remoteDevice0, []protocol.FileInfo{{Name: "a", Version: v1, Size: 1, Sequence: 1}})
+	if hist := fileHistory(s, "a"); len(hist) != 1 {
+		t.Fatalf("expected one entry, got %v", hist)
+	}
+
+	// A newer one does.
+	v3 := v2.Update(remoteDevice0.Short())
+	s.Update(remoteDevice0, []protocol.FileInfo{{Name: "a", Version: v3, Deleted: true, ModifiedBy: remoteDevice0.Short(), Sequence: 2}})
+
+	hist := fileHistory(s, "a")
+	if len(hist) != 2 {
+		t.Fatalf("expected two entries, got %v", hist)
+	}
+	if !hist[0].Version.Equal(v2) || hist[0].Size != 2 || hist[0].Deleted || hist[0].ModifiedBy != myID {
+		t.Errorf("unexpected first entry %v", hist[0])
+	}
+	if dev, _ := protocol.DeviceIDFromBytes(hist[0].Device); dev != protocol.LocalDeviceID {
+		t.Errorf("first entry from %v, expected local device", dev)
+	}
+	if !hist[1].Version.Equal(v3) || !hist[1].Deleted || hist[1].ModifiedBy != remoteDevice0.Short() {
+		t.Errorf("unexpected second entry %v", hist[1])
+	}
+	if dev, _ := protocol.DeviceIDFromBytes(hist[1].Device); dev != remoteDevice0 {
+		t.Errorf("second entry from %v, expected %v", dev, remoteDevice0)
+	}
+	if hist[1].RecordedAt().Before(hist[0].RecordedAt()) {
+		t.Errorf("entries out of order: %v", hist)
+	}
+
+	// Only the latest entries are retained.
+	v := v3
+	for i := 0; i < 5; i++ {
+		v = v.Update(myID)
+		s.Update(protocol.LocalDeviceID, []protocol.FileInfo{{Name: "a", Version: v, Size: int64(i)}})
+	}
+	hist = fileHistory(s, "a")
+	if len(hist) != 3 {
+		t.Fatalf("expected three entries, got %v", hist)
+	}
+	if !hist[2].Version.Equal(v) || hist[2].Size != 4 {
+		t.Errorf("unexpected last entry %v", hist[2])
+	}
+
+	// Dropping a device's history leaves the other entries.
+	s.Update(remoteDevice1, []protocol.FileInfo{{Name: "b", Version: v1.Update(remoteDevice1.Short()), Size: 1, Sequence: 1}})
+	v = v.Update(remoteDevice0.Short())
+	s.Update(remoteDevice0, []protocol.FileInfo{{Name: "a", Version: v, Size: 5, Sequence: 3}})
+	s.DropFileHistory(remoteDevice1)
+	if hist := fileHistory(s, "b"); len(hist) != 0 {
+		t.Errorf("history remains after dropping device: %v", hist)
+	}
+	s.DropFileHistory(remoteDevice0)
+	hist = fileHistory(s, "a")
+	if len(hist) != 2 {
+		t.Fatalf("expected two remaining entries, got %v", hist)
+	}
+	for _, e := range hist {
+		if dev, _ := protocol.DeviceIDFromBytes(e.Device); dev != protocol.LocalDeviceID {
+			t.Errorf("entry from %v remains after dropping it", dev)
+		}
+	}
+
+	// Dropping the folder drops the history.
+	DropFolder(ldb, "test")
+	s = NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb)
+	if hist := fileHistory(s, "a"); len(hist) != 0 {
+		t.Errorf("history remains after dropping folder: %v", hist)
+	}
+}
+
+func TestFileHistoryPrune(t *testing.T) {
+	now := time.Now()
+	hist := FileHistory{}
+	for i := 5; i > 0; i-- {
+		hist.Entries = append(hist.Entries, FileHistoryEntry{Time: now.Add(-time.Duration(i) * time.Hour).UnixNano()})
+	}
+
+	hist.prune(fileHistoryRetention{entries: 4, maxAge: 150 * time.Minute}, now)
+	if len(hist.Entries) != 2 {
+		t.Errorf("expected two remaining entries, got %d", len(hist.Entries))
+	}
+	hist.prune(fileHistoryRetention{entries: 1}, now)
+	if len(hist.Entries) != 1 || hist.Entries[0].Time != now.Add(-time.Hour).UnixNano() {
+		t.Errorf("expected the last entry to remain, got %v", hist.Entries)
+	}
+}
+
+func fileHistory(s *FileSet, file string) []FileHistoryEntry {
+	snap := s.Snapshot()
+	defer snap.Release()
+	return snap.FileHistory(file)
+}
@@ -68,6 +68,9 @@ const (
 
 	// KeyTypeVersion <version hash> = Vector
 	KeyTypeVersion = 15
+
+	// KeyTypeFileHistory <int32 folder ID> <file name> = FileHistory
+	KeyTypeFileHistory = 16
 )
 
 type keyer interface {
@@ -100,6 +103,10 @@ type keyer interface {
 	// Mtimes
 	GenerateMtimesKey(key, folder []byte) (mtimesKey, error)
 
+	// File history
+	GenerateFileHistoryKey(key, folder, name []byte) (fileHistoryKey, error)
+	NameFromFileHistoryKey(key []byte) []byte
+
 	// Folder metadata
 	GenerateFolderMetaKey(key, folder []byte) (folderMetaKey, error)
 
@@ -302,6 +309,28 @@ func (k defaultKeyer) GenerateMtimesKey(key, folder []byte) (mtimesKey, error) {
 	return key, nil
 }
 
+type fileHistoryKey []byte
+
+func (k fileHistoryKey) WithoutName() []byte {
+	return k[:keyPrefixLen+keyFolderLen]
+}
+
+func (k defaultKeyer) GenerateFileHistoryKey(key, folder, name []byte) (fileHistoryKey, error) {
+	folderID, err := k.folderIdx.ID(folder)
+	if err != nil {
+		return nil, err
+	}
+	key = resize(key, keyPrefixLen+keyFolderLen+len(name))
+	key[0] = KeyTypeFileHistory
+	binary.BigEndian.PutUint32(key[keyPrefixLen:], folderID)
+	copy(key[keyPrefixLen+keyFolderLen:], name)
+	return key, nil
+}
+
+func (k defaultKeyer) NameFromFileHistoryKey(key []byte) []byte {
+	return key[keyPrefixLen+keyFolderLen:]
+}
+
 type folderMetaKey []byte
 
 func (k defaultKeyer) GenerateFolderMetaKey(key, folder []byte) (folderMetaKey, error) {
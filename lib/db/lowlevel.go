@@ -109,8 +109,8 @@ func (db *Lowlevel) ListFolders() []string {
 }
 
 // updateRemoteFiles adds a list of fileinfos to the database and updates the
-// global versionlist and metadata.
-func (db *Lowlevel) updateRemoteFiles(folder, device []byte, fs []protocol.FileInfo, meta *metadataTracker) error {
+// global versionlist, metadata and file history.
+func (db *Lowlevel) updateRemoteFiles(folder, device []byte, fs []protocol.FileInfo, meta *metadataTracker, history fileHistoryRetention) error {
 	db.gcMut.RLock()
 	defer db.gcMut.RUnlock()
 
@@ -154,10 +154,16 @@ func (db *Lowlevel) updateRemoteFiles(folder, device []byte, fs []protocol.FileI
 		if err != nil {
 			return err
 		}
-		keyBuf, _, err = t.updateGlobal(gk, keyBuf, folder, device, f, meta)
+		var global protocol.FileIntf
+		keyBuf, global, err = t.updateGlobal(gk, keyBuf, folder, device, f, meta)
 		if err != nil {
 			return err
 		}
+		if global != nil {
+			if keyBuf, err = t.addFileHistory(keyBuf, folder, device, global, history); err != nil {
+				return err
+			}
+		}
 
 		if err := t.Checkpoint(func() error {
 			return meta.toDB(t, folder)
@@ -174,8 +180,9 @@ func (db *Lowlevel) updateRemoteFiles(folder, device []byte, fs []protocol.FileI
 }
 
 // updateLocalFiles adds fileinfos to the db, and updates the global versionlist,
-// metadata, sequence and blockmap buckets.
-func (db *Lowlevel) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta *metadataTracker) error {
+// metadata, file history, sequence and blockmap buckets. New sequence numbers
+// are assigned unless keepSequence is set.
+func (db *Lowlevel) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta *metadataTracker, keepSequence bool, history fileHistoryRetention) error {
 	db.gcMut.RLock()
 	defer db.gcMut.RUnlock()
 
@@ -235,7 +242,9 @@ func (db *Lowlevel) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta
 			l.Debugf("removing sequence; folder=%q sequence=%v %v", folder, ef.SequenceNo(), ef.FileName())
 		}
 
-		f.Sequence = meta.nextLocalSeq()
+		if !keepSequence {
+			f.Sequence = meta.nextLocalSeq()
+		}
 
 		if ok {
 			meta.removeFile(protocol.LocalDeviceID, ef)
@@ -251,10 +260,16 @@ func (db *Lowlevel) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta
 		if err != nil {
 			return err
 		}
-		keyBuf, _, err = t.updateGlobal(gk, keyBuf, folder, protocol.LocalDeviceID[:], f, meta)
+		var global protocol.FileIntf
+		keyBuf, global, err = t.updateGlobal(gk, keyBuf, folder, protocol.LocalDeviceID[:], f, meta)
 		if err != nil {
 			return err
 		}
+		if global != nil {
+			if keyBuf, err = t.addFileHistory(keyBuf, folder, protocol.LocalDeviceID[:], global, history); err != nil {
+				return err
+			}
+		}
 
 		keyBuf, err = db.keyer.GenerateSequenceKey(keyBuf, folder, f.Sequence)
 		if err != nil {
@@ -567,6 +582,23 @@ func (db *Lowlevel) dropFolderMeta(folder []byte) error {
 	return db.dropPrefix(key)
 }
 
+// dropFolderAll clears out all information related to the given folder.
+func (db *Lowlevel) dropFolderAll(folder []byte) error {
+	droppers := []func([]byte) error{
+		db.dropFolder,
+		db.dropMtimes,
+		db.dropFileHistory,
+		db.dropFolderMeta,
+		db.folderIdx.Delete,
+	}
+	for _, drop := range droppers {
+		if err := drop(folder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *Lowlevel) dropPrefix(prefix []byte) error {
 	t, err := db.newReadWriteTransaction()
 	if err != nil {
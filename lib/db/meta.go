@@ -215,19 +215,7 @@ func (m *metadataTracker) updateSeqLocked(dev protocol.DeviceID, f protocol.File
 }
 
 func (m *metadataTracker) addFileLocked(dev protocol.DeviceID, flag uint32, f protocol.FileIntf) {
-	cp := m.countsPtr(dev, flag)
-
-	switch {
-	case f.IsDeleted():
-		cp.Deleted++
-	case f.IsDirectory() && !f.IsSymlink():
-		cp.Directories++
-	case f.IsSymlink():
-		cp.Symlinks++
-	default:
-		cp.Files++
-	}
-	cp.Bytes += f.FileSize()
+	m.countsPtr(dev, flag).addFile(f)
 }
 
 // removeFile removes a file from the counts
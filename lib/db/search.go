@@ -0,0 +1,149 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A SearchQuery selects files from the global list of a folder. The zero
+// value selects everything.
+type SearchQuery struct {
+	// Pattern is a glob pattern using forward slashes as path separator,
+	// matched against the full path if it contains a slash and against the
+	// file name otherwise.
+	Pattern string
+	// Regexp is matched against the full path, using forward slashes.
+	Regexp *regexp.Regexp
+	// Types are the file types to select, or nil for all of them.
+	Types   []protocol.FileInfoType
+	MinSize int64
+	MaxSize int64 // zero means no limit
+	// The modification time must be within [ModifiedAfter, ModifiedBefore),
+	// for those that aren't zero.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	ModifiedBy     protocol.ShortID // zero means any device
+	Deleted        *bool            // nil means either
+	Invalid        *bool            // nil means either
+	// Needed selects only files that are needed by the local device.
+	Needed bool
+}
+
+// Search calls fn for each file matching the query, in the order of their
+// names, until fn returns false. An error is returned only for invalid
+// queries, before fn is called.
+func (s *Snapshot) Search(q SearchQuery, fn func(FileInfoTruncated) bool) error {
+	match, err := q.matcher()
+	if err != nil {
+		return err
+	}
+
+	// The directory part of the glob pattern before any special characters
+	// is constant, so only that directory needs to be looked at.
+	prefix := q.literalPrefix()
+
+	iter := func(f protocol.FileIntf) bool {
+		ft := f.(FileInfoTruncated)
+		if prefix != "" && !strings.HasPrefix(ft.Name, prefix+string(filepath.Separator)) {
+			return true
+		}
+		if !match(ft) {
+			return true
+		}
+		return fn(ft)
+	}
+
+	switch {
+	case q.Needed:
+		s.WithNeedTruncated(protocol.LocalDeviceID, iter)
+	case prefix != "":
+		s.WithPrefixedGlobalTruncated(prefix, iter)
+	default:
+		s.WithGlobalTruncated(iter)
+	}
+	return nil
+}
+
+func (q SearchQuery) matcher() (func(FileInfoTruncated) bool, error) {
+	var pattern glob.Glob
+	var basename bool
+	if q.Pattern != "" {
+		var err error
+		pattern, err = glob.Compile(q.Pattern, '/')
+		if err != nil {
+			return nil, err
+		}
+		basename = !strings.Contains(q.Pattern, "/")
+	}
+
+	return func(f FileInfoTruncated) bool {
+		name := filepath.ToSlash(f.Name)
+		if pattern != nil {
+			if basename && !pattern.Match(path.Base(name)) {
+				return false
+			} else if !basename && !pattern.Match(name) {
+				return false
+			}
+		}
+		if q.Regexp != nil && !q.Regexp.MatchString(name) {
+			return false
+		}
+		if len(q.Types) > 0 {
+			found := false
+			for _, t := range q.Types {
+				if f.Type == t {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		if f.Size < q.MinSize || (q.MaxSize > 0 && f.Size > q.MaxSize) {
+			return false
+		}
+		if !q.ModifiedAfter.IsZero() && f.ModTime().Before(q.ModifiedAfter) {
+			return false
+		}
+		if !q.ModifiedBefore.IsZero() && !f.ModTime().Before(q.ModifiedBefore) {
+			return false
+		}
+		if q.ModifiedBy != 0 && f.ModifiedBy != q.ModifiedBy {
+			return false
+		}
+		if q.Deleted != nil && f.IsDeleted() != *q.Deleted {
+			return false
+		}
+		if q.Invalid != nil && f.IsInvalid() != *q.Invalid {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// literalPrefix returns the leading directories of the pattern that don't
+// contain any special characters, as a native path.
+func (q SearchQuery) literalPrefix() string {
+	literal := q.Pattern
+	if special := strings.IndexAny(literal, "*?[]{}\\"); special >= 0 {
+		literal = literal[:special]
+	}
+	idx := strings.LastIndexByte(literal, '/')
+	if idx <= 0 {
+		return ""
+	}
+	return filepath.FromSlash(literal[:idx])
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSearch(t *testing.T) {
+	ldb := NewLowlevel(backend.OpenMemory())
+	defer ldb.Close()
+	s := NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb)
+
+	t0 := time.Unix(1500000000, 0)
+	local := []protocol.FileInfo{
+		{Name: "a.txt", Size: 10, ModifiedS: t0.Unix(), ModifiedBy: 1, Version: protocol.Vector{}.Update(1)},
+		{Name: "dir", Type: protocol.FileInfoTypeDirectory, ModifiedS: t0.Unix(), ModifiedBy: 1, Version: protocol.Vector{}.Update(1)},
+		{Name: filepath.Join("dir", "b.jpg"), Size: 1000, ModifiedS: t0.Add(time.Hour).Unix(), ModifiedBy: 2, Version: protocol.Vector{}.Update(2)},
+		{Name: filepath.Join("dir", "sub", "c.jpg"), Size: 100, ModifiedS: t0.Add(2 * time.Hour).Unix(), ModifiedBy: 2, Version: protocol.Vector{}.Update(2)},
+		{Name: "d.jpg", Deleted: true, ModifiedS: t0.Unix(), ModifiedBy: 1, Version: protocol.Vector{}.Update(1)},
+	}
+	s.Update(protocol.LocalDeviceID, local)
+	s.Update(remoteDevice0, []protocol.FileInfo{
+		{Name: "e.jpg", Size: 5, ModifiedS: t0.Unix(), ModifiedBy: 3, Version: protocol.Vector{}.Update(3), Sequence: 1},
+	})
+
+	yes, no := true, false
+	cases := []struct {
+		query    SearchQuery
+		expected []string
+	}{
+		{SearchQuery{}, []string{"a.txt", "d.jpg", "dir", "dir/b.jpg", "dir/sub/c.jpg", "e.jpg"}},
+		{SearchQuery{Pattern: "*.jpg"}, []string{"d.jpg", "dir/b.jpg", "dir/sub/c.jpg", "e.jpg"}},
+		{SearchQuery{Pattern: "dir/*.jpg"}, []string{"dir/b.jpg"}},
+		{SearchQuery{Pattern: "dir/**.jpg"}, []string{"dir/b.jpg", "dir/sub/c.jpg"}},
+		{SearchQuery{Pattern: "dir/sub/c.jpg"}, []string{"dir/sub/c.jpg"}},
+		{SearchQuery{Regexp: regexp.MustCompile(`^dir/.*\.jpg$`)}, []string{"dir/b.jpg", "dir/sub/c.jpg"}},
+		{SearchQuery{Types: []protocol.FileInfoType{protocol.FileInfoTypeDirectory}}, []string{"dir"}},
+		{SearchQuery{MinSize: 10, MaxSize: 100}, []string{"a.txt", "dir/sub/c.jpg"}},
+		{SearchQuery{ModifiedAfter: t0.Add(time.Hour), ModifiedBefore: t0.Add(2 * time.Hour)}, []string{"dir/b.jpg"}},
+		{SearchQuery{ModifiedBy: 2}, []string{"dir/b.jpg", "dir/sub/c.jpg"}},
+		{SearchQuery{Pattern: "*.jpg", Deleted: &no}, []string{"dir/b.jpg", "dir/sub/c.jpg", "e.jpg"}},
+		{SearchQuery{Deleted: &yes}, []string{"d.jpg"}},
+		{SearchQuery{Invalid: &yes}, nil},
+		{SearchQuery{Needed: true}, []string{"e.jpg"}},
+		{SearchQuery{Needed: true, Pattern: "dir/*"}, nil},
+	}
+
+	snap := s.Snapshot()
+	defer snap.Release()
+	for _, tc := range cases {
+		var names []string
+		err := snap.Search(tc.query, func(f FileInfoTruncated) bool {
+			names = append(names, filepath.ToSlash(f.Name))
+			return true
+		})
+		if err != nil {
+			t.Errorf("%+v: %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("%+v: got %v, expected %v", tc.query, names, tc.expected)
+		}
+	}
+
+	// Stopping early
+	n := 0
+	if err := snap.Search(SearchQuery{}, func(FileInfoTruncated) bool {
+		n++
+		return n < 2
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d calls, expected 2", n)
+	}
+
+	if err := snap.Search(SearchQuery{Pattern: "[a"}, func(FileInfoTruncated) bool {
+		t.Error("unexpected call for invalid query")
+		return false
+	}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
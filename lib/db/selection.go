@@ -0,0 +1,199 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A Selection is the set of paths that are pulled in a folder using
+// selective sync. A file is selected if it is one of the paths, is inside
+// one of them, or is a parent directory of one of them. Files that aren't
+// selected are still part of the global state, but the local device
+// doesn't need them. The zero value selects everything.
+type Selection struct {
+	paths      []string // wire format, sorted, none inside another
+	restricted bool     // only paths are selected, even if there are none
+}
+
+// NewSelection returns the selection of the given paths, which are
+// relative to the folder root.
+func NewSelection(paths []string) Selection {
+	var clean []string
+	for _, p := range paths {
+		p = strings.TrimPrefix(path.Clean("/"+osutil.NormalizedFilename(p)), "/")
+		if p == "" {
+			// The root selects everything.
+			return Selection{}
+		}
+		clean = append(clean, p)
+	}
+	sort.Strings(clean)
+
+	sel := Selection{restricted: len(clean) > 0}
+outer:
+	for _, p := range clean {
+		for _, existing := range sel.paths {
+			if p == existing || strings.HasPrefix(p, existing+"/") {
+				continue outer
+			}
+		}
+		sel.paths = append(sel.paths, p)
+	}
+	return sel
+}
+
+// NewRestrictedSelection is like NewSelection, except that nothing is
+// selected when there are no paths.
+func NewRestrictedSelection(paths []string) Selection {
+	sel := NewSelection(paths)
+	if len(paths) == 0 {
+		sel.restricted = true
+	}
+	return sel
+}
+
+// All returns true if everything is selected.
+func (s Selection) All() bool {
+	return !s.restricted
+}
+
+// Paths returns the selected paths in wire format, or nil if everything is
+// selected.
+func (s Selection) Paths() []string {
+	return append([]string(nil), s.paths...)
+}
+
+// Selected returns true if the file with the given native name is selected.
+// The folder root is always selected.
+func (s Selection) Selected(name string) bool {
+	if s.All() || name == "." || name == "" {
+		return true
+	}
+	return s.selected(osutil.NormalizedFilename(name))
+}
+
+func (s Selection) selected(name string) bool {
+	for _, p := range s.paths {
+		if name == p || strings.HasPrefix(name, p+"/") || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// withNeedLocalSelected is like withNeedLocal, but only iterates the
+// selected files. Parent directories are iterated before their children.
+func (t *readOnlyTransaction) withNeedLocalSelected(folder []byte, sel Selection, truncate bool, fn Iterator) error {
+	var keyBuf []byte
+	var err error
+	seen := make(map[string]struct{})
+	for _, p := range sel.paths {
+		// The parent directories and the path itself, which are looked up
+		// individually.
+		parts := strings.Split(p, "/")
+		for i := range parts {
+			name := strings.Join(parts[:i+1], "/")
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+
+			keyBuf, err = t.keyer.GenerateNeedFileKey(keyBuf, folder, []byte(name))
+			if err != nil {
+				return err
+			}
+			if _, err := t.Get(keyBuf); backend.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			var f protocol.FileIntf
+			var ok bool
+			keyBuf, f, ok, err = t.getGlobal(keyBuf, folder, []byte(name), truncate)
+			if err != nil {
+				return err
+			}
+			if ok && !fn(f) {
+				return nil
+			}
+		}
+
+		// Everything inside it
+		stop := false
+		err := t.withNeedLocalPrefix(folder, []byte(p+"/"), truncate, func(f protocol.FileIntf) bool {
+			if !fn(f) {
+				stop = true
+				return false
+			}
+			return true
+		})
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
+// needSizeCache keeps the local need size of a restricted selection, which
+// unlike the need tracked in the metadata has to be counted, until the file
+// set or the selection changes.
+type needSizeCache struct {
+	mut       sync.Mutex
+	gen       uint64 // incremented on each change
+	cachedGen uint64
+	cached    bool
+	counts    Counts
+}
+
+func newNeedSizeCache() *needSizeCache {
+	return &needSizeCache{mut: sync.NewMutex()}
+}
+
+// changed is called after each change to the file set or the selection,
+// once it's visible to new snapshots.
+func (c *needSizeCache) changed() {
+	c.mut.Lock()
+	c.gen++
+	c.cached = false
+	c.mut.Unlock()
+}
+
+func (c *needSizeCache) generation() uint64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.gen
+}
+
+func (c *needSizeCache) get(gen uint64) (Counts, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !c.cached || c.cachedGen != gen {
+		return Counts{}, false
+	}
+	return c.counts, true
+}
+
+// set caches the counts of a snapshot taken at gen, unless something
+// changed since.
+func (c *needSizeCache) set(gen uint64, counts Counts) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if gen != c.gen {
+		return
+	}
+	c.cachedGen = gen
+	c.cached = true
+	c.counts = counts
+}
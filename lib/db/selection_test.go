@@ -0,0 +1,123 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSelectionSelected(t *testing.T) {
+	if sel := db.NewSelection(nil); !sel.All() {
+		t.Error("empty selection should select everything")
+	}
+	if sel := db.NewSelection([]string{"a", "/"}); !sel.All() {
+		t.Error("selecting the root should select everything")
+	}
+
+	if sel := db.NewRestrictedSelection(nil); sel.All() || sel.Selected("a") || !sel.Selected(".") {
+		t.Error("empty restricted selection should select only the root")
+	}
+
+	sel := db.NewSelection([]string{"a/b/", "a/b/c", "/d", "e/../f"})
+	if paths := sel.Paths(); len(paths) != 3 || paths[0] != "a/b" || paths[1] != "d" || paths[2] != "f" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	cases := []struct {
+		name     string
+		selected bool
+	}{
+		{".", true},
+		{"a", true},
+		{"a/b", true},
+		{"a/b/c/d", true},
+		{"a/bc", false},
+		{"a/c", false},
+		{"d", true},
+		{"dd", false},
+		{"e", false},
+		{"f/g", true},
+	}
+	for _, tc := range cases {
+		if sel.Selected(filepath.FromSlash(tc.name)) != tc.selected {
+			t.Errorf("Selected(%q) != %v", tc.name, tc.selected)
+		}
+	}
+}
+
+func TestSelectionNeed(t *testing.T) {
+	ldb := db.NewLowlevel(backend.OpenMemory())
+	defer ldb.Close()
+	s := db.NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeFake, ""), ldb)
+
+	v := protocol.Vector{}.Update(remoteDevice0.Short())
+	remote := fileList{
+		protocol.FileInfo{Name: "a", Version: v, Type: protocol.FileInfoTypeDirectory},
+		protocol.FileInfo{Name: "a/b", Version: v, Type: protocol.FileInfoTypeDirectory},
+		protocol.FileInfo{Name: "a/b/c", Version: v, Size: 10},
+		protocol.FileInfo{Name: "a/b/d", Version: v, Size: 20},
+		protocol.FileInfo{Name: "a/e", Version: v, Size: 40},
+		protocol.FileInfo{Name: "f", Version: v, Size: 80},
+	}
+	setSequence(0, remote)
+	s.Update(remoteDevice0, remote)
+
+	if need := needList(s, protocol.LocalDeviceID); len(need) != len(remote) {
+		t.Fatalf("expected to need everything, got %v", fileList(need))
+	}
+
+	s.SetSelection(db.NewRestrictedSelection(nil))
+	if need := needList(s, protocol.LocalDeviceID); len(need) != 0 {
+		t.Fatalf("expected to need nothing, got %v", fileList(need))
+	}
+
+	s.SetSelection(db.NewSelection([]string{filepath.FromSlash("a/b")}))
+
+	need := needList(s, protocol.LocalDeviceID)
+	expected := []string{"a", filepath.FromSlash("a/b"), filepath.FromSlash("a/b/c"), filepath.FromSlash("a/b/d")}
+	if len(need) != len(expected) {
+		t.Fatalf("expected to need %v, got %v", expected, fileList(need))
+	}
+	for i, f := range need {
+		if f.Name != expected[i] {
+			t.Errorf("need[%d] is %q, expected %q", i, f.Name, expected[i])
+		}
+	}
+
+	snap := s.Snapshot()
+	defer snap.Release()
+	size := snap.NeedSize(protocol.LocalDeviceID)
+	if size.Files != 2 || size.Directories != 2 || size.Bytes != 30+2*protocol.SyntheticDirectorySize {
+		t.Errorf("unexpected need size %v", size)
+	}
+
+	// The unselected files are still in the global state.
+	if gs := snap.GlobalSize(); gs.Files != 4 {
+		t.Errorf("expected four global files, got %v", gs)
+	}
+
+	// The need size is counted again after changes.
+	more := fileList{protocol.FileInfo{Name: filepath.FromSlash("a/b/g"), Version: v, Size: 100, Sequence: 7}}
+	s.Update(remoteDevice0, more)
+	snap2 := s.Snapshot()
+	defer snap2.Release()
+	if size := snap2.NeedSize(protocol.LocalDeviceID); size.Files != 3 || size.Bytes != 130+2*protocol.SyntheticDirectorySize {
+		t.Errorf("unexpected need size %v after an update", size)
+	}
+	s.SetSelection(db.NewSelection([]string{"f"}))
+	snap3 := s.Snapshot()
+	defer snap3.Release()
+	if size := snap3.NeedSize(protocol.LocalDeviceID); size.Files != 1 || size.Bytes != 80 {
+		t.Errorf("unexpected need size %v after changing the selection", size)
+	}
+}
@@ -13,6 +13,8 @@
 package db
 
 import (
+	"time"
+
 	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
@@ -27,6 +29,12 @@ type FileSet struct {
 	meta   *metadataTracker
 
 	updateMutex sync.Mutex // protects database updates and the corresponding metadata changes
+	history     fileHistoryRetention
+
+	selection    Selection
+	selectionMut sync.Mutex
+
+	needSize *needSizeCache
 }
 
 // The Iterator is called with either a protocol.FileInfo or a
@@ -36,11 +44,13 @@ type Iterator func(f protocol.FileIntf) bool
 
 func NewFileSet(folder string, fs fs.Filesystem, db *Lowlevel) *FileSet {
 	return &FileSet{
-		folder:      folder,
-		fs:          fs,
-		db:          db,
-		meta:        db.loadMetadataTracker(folder),
-		updateMutex: sync.NewMutex(),
+		folder:       folder,
+		fs:           fs,
+		db:           db,
+		meta:         db.loadMetadataTracker(folder),
+		updateMutex:  sync.NewMutex(),
+		selectionMut: sync.NewMutex(),
+		needSize:     newNeedSizeCache(),
 	}
 }
 
@@ -49,6 +59,7 @@ func (s *FileSet) Drop(device protocol.DeviceID) {
 
 	s.updateMutex.Lock()
 	defer s.updateMutex.Unlock()
+	defer s.needSize.changed()
 
 	if err := s.db.dropDeviceFolder(device[:], []byte(s.folder), s.meta); backend.IsClosed(err) {
 		return
@@ -107,35 +118,76 @@ func (s *FileSet) Update(device protocol.DeviceID, fs []protocol.FileInfo) {
 
 	s.updateMutex.Lock()
 	defer s.updateMutex.Unlock()
+	defer s.needSize.changed()
 
 	if device == protocol.LocalDeviceID {
 		// For the local device we have a bunch of metadata to track.
-		if err := s.db.updateLocalFiles([]byte(s.folder), fs, s.meta); err != nil && !backend.IsClosed(err) {
+		if err := s.db.updateLocalFiles([]byte(s.folder), fs, s.meta, false, s.history); err != nil && !backend.IsClosed(err) {
 			panic(err)
 		}
 		return
 	}
 	// Easy case, just update the files and we're done.
-	if err := s.db.updateRemoteFiles([]byte(s.folder), device[:], fs, s.meta); err != nil && !backend.IsClosed(err) {
+	if err := s.db.updateRemoteFiles([]byte(s.folder), device[:], fs, s.meta, s.history); err != nil && !backend.IsClosed(err) {
 		panic(err)
 	}
 }
 
+// DropFileHistory removes the changes received from the given device from
+// the history of the files, as when the folder is no longer shared with it.
+func (s *FileSet) DropFileHistory(device protocol.DeviceID) {
+	l.Debugf("%s DropFileHistory(%v)", s.folder, device)
+
+	if err := s.db.dropDeviceFileHistory([]byte(s.folder), device[:]); err != nil && !backend.IsClosed(err) {
+		panic(err)
+	}
+}
+
+// SetFileHistoryRetention sets the number of changes to the global version
+// to remember for each file, and optionally for how long. History is not
+// recorded when entries is zero.
+func (s *FileSet) SetFileHistoryRetention(entries int, maxAge time.Duration) {
+	s.updateMutex.Lock()
+	s.history = fileHistoryRetention{entries: entries, maxAge: maxAge}
+	s.updateMutex.Unlock()
+}
+
+// SetSelection sets the files that the local device needs, for selective
+// sync. It affects the snapshots taken afterwards.
+func (s *FileSet) SetSelection(sel Selection) {
+	s.selectionMut.Lock()
+	s.selection = sel
+	s.selectionMut.Unlock()
+	s.needSize.changed()
+}
+
 type Snapshot struct {
-	folder string
-	t      readOnlyTransaction
-	meta   *countsMap
+	folder    string
+	t         readOnlyTransaction
+	meta      *countsMap
+	selection Selection
+	needSize  *needSizeCache
+	gen       uint64 // of needSize when the snapshot was taken
 }
 
 func (s *FileSet) Snapshot() *Snapshot {
+	// The generation is taken before the transaction, so that it is never
+	// newer than the data read.
+	gen := s.needSize.generation()
 	t, err := s.db.newReadOnlyTransaction()
 	if err != nil {
 		panic(err)
 	}
+	s.selectionMut.Lock()
+	sel := s.selection
+	s.selectionMut.Unlock()
 	return &Snapshot{
-		folder: s.folder,
-		t:      t,
-		meta:   s.meta.Snapshot(),
+		folder:    s.folder,
+		t:         t,
+		meta:      s.meta.Snapshot(),
+		selection: sel,
+		needSize:  s.needSize,
+		gen:       gen,
 	}
 }
 
@@ -143,20 +195,34 @@ func (s *Snapshot) Release() {
 	s.t.close()
 }
 
+// Selection returns the selective sync selection the snapshot was taken
+// with.
+func (s *Snapshot) Selection() Selection {
+	return s.selection
+}
+
 func (s *Snapshot) WithNeed(device protocol.DeviceID, fn Iterator) {
 	l.Debugf("%s WithNeed(%v)", s.folder, device)
-	if err := s.t.withNeed([]byte(s.folder), device[:], false, nativeFileIterator(fn)); err != nil && !backend.IsClosed(err) {
+	if err := s.withNeed(device, false, nativeFileIterator(fn)); err != nil && !backend.IsClosed(err) {
 		panic(err)
 	}
 }
 
 func (s *Snapshot) WithNeedTruncated(device protocol.DeviceID, fn Iterator) {
 	l.Debugf("%s WithNeedTruncated(%v)", s.folder, device)
-	if err := s.t.withNeed([]byte(s.folder), device[:], true, nativeFileIterator(fn)); err != nil && !backend.IsClosed(err) {
+	if err := s.withNeed(device, true, nativeFileIterator(fn)); err != nil && !backend.IsClosed(err) {
 		panic(err)
 	}
 }
 
+// withNeed takes the selection into account for the local device.
+func (s *Snapshot) withNeed(device protocol.DeviceID, truncate bool, fn Iterator) error {
+	if device == protocol.LocalDeviceID && !s.selection.All() {
+		return s.t.withNeedLocalSelected([]byte(s.folder), s.selection, truncate, fn)
+	}
+	return s.t.withNeed([]byte(s.folder), device[:], truncate, fn)
+}
+
 func (s *Snapshot) WithHave(device protocol.DeviceID, fn Iterator) {
 	l.Debugf("%s WithHave(%v)", s.folder, device)
 	if err := s.t.withHave([]byte(s.folder), device[:], nil, false, nativeFileIterator(fn)); err != nil && !backend.IsClosed(err) {
@@ -251,6 +317,18 @@ func (s *Snapshot) GetGlobalTruncated(file string) (FileInfoTruncated, bool) {
 	return f, true
 }
 
+// FileHistory returns the recorded changes to the global version of the
+// file, oldest first.
+func (s *Snapshot) FileHistory(file string) []FileHistoryEntry {
+	_, hist, err := s.t.getFileHistory(nil, []byte(s.folder), []byte(osutil.NormalizedFilename(file)))
+	if backend.IsClosed(err) {
+		return nil
+	} else if err != nil {
+		panic(err)
+	}
+	return hist.Entries
+}
+
 func (s *Snapshot) Availability(file string) []protocol.DeviceID {
 	av, err := s.t.availability([]byte(s.folder), []byte(osutil.NormalizedFilename(file)))
 	if backend.IsClosed(err) {
@@ -294,6 +372,25 @@ func (s *Snapshot) GlobalSize() Counts {
 }
 
 func (s *Snapshot) NeedSize(device protocol.DeviceID) Counts {
+	if device == protocol.LocalDeviceID && !s.selection.All() {
+		// The tracked counts include the files that aren't selected, so
+		// we need to count the selected ones, once per change to the set.
+		if need, ok := s.needSize.get(s.gen); ok {
+			return need
+		}
+		need := Counts{DeviceID: device[:], LocalFlags: needFlag}
+		if err := s.withNeed(device, true, func(f protocol.FileIntf) bool {
+			need.addFile(f)
+			return true
+		}); err != nil {
+			if !backend.IsClosed(err) {
+				panic(err)
+			}
+			return need
+		}
+		s.needSize.set(s.gen, need)
+		return need
+	}
 	return s.meta.Counts(device, needFlag)
 }
 
@@ -403,6 +500,7 @@ func (s *FileSet) RepairSequence() (int, error) {
 	s.updateAndGCMutexLock() // Ensures consistent locking order
 	defer s.updateMutex.Unlock()
 	defer s.db.gcMut.RUnlock()
+	defer s.needSize.changed()
 	return s.db.repairSequenceGCLocked(s.folder, s.meta)
 }
 
@@ -414,18 +512,8 @@ func (s *FileSet) updateAndGCMutexLock() {
 // DropFolder clears out all information related to the given folder from the
 // database.
 func DropFolder(db *Lowlevel, folder string) {
-	droppers := []func([]byte) error{
-		db.dropFolder,
-		db.dropMtimes,
-		db.dropFolderMeta,
-		db.folderIdx.Delete,
-	}
-	for _, drop := range droppers {
-		if err := drop([]byte(folder)); backend.IsClosed(err) {
-			return
-		} else if err != nil {
-			panic(err)
-		}
+	if err := db.dropFolderAll([]byte(folder)); err != nil && !backend.IsClosed(err) {
+		panic(err)
 	}
 }
 
@@ -57,6 +57,10 @@ func (f FileInfoTruncated) IsReceiveOnlyChanged() bool {
 	return f.LocalFlags&protocol.FlagLocalReceiveOnly != 0
 }
 
+func (f FileInfoTruncated) IsUnselected() bool {
+	return f.LocalFlags&protocol.FlagLocalUnselected != 0
+}
+
 func (f FileInfoTruncated) IsDirectory() bool {
 	return f.Type == protocol.FileInfoTypeDirectory
 }
@@ -182,6 +186,21 @@ func (c Counts) Add(other Counts) Counts {
 	}
 }
 
+// addFile adds the given file to the counts.
+func (c *Counts) addFile(f protocol.FileIntf) {
+	switch {
+	case f.IsDeleted():
+		c.Deleted++
+	case f.IsDirectory() && !f.IsSymlink():
+		c.Directories++
+	case f.IsSymlink():
+		c.Symlinks++
+	default:
+		c.Files++
+	}
+	c.Bytes += f.FileSize()
+}
+
 func (c Counts) TotalItems() int32 {
 	return c.Files + c.Directories + c.Symlinks + c.Deleted
 }
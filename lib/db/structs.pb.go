@@ -394,6 +394,89 @@ func (m *VersionListDeprecated) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_VersionListDeprecated proto.InternalMessageInfo
 
+// FileHistory holds the most recent global versions of a file, oldest first
+type FileHistory struct {
+	Entries []FileHistoryEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries"`
+}
+
+func (m *FileHistory) Reset()         { *m = FileHistory{} }
+func (m *FileHistory) String() string { return proto.CompactTextString(m) }
+func (*FileHistory) ProtoMessage()    {}
+func (*FileHistory) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e774e8f5f348d14d, []int{9}
+}
+func (m *FileHistory) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FileHistory) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FileHistory.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *FileHistory) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FileHistory.Merge(m, src)
+}
+func (m *FileHistory) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *FileHistory) XXX_DiscardUnknown() {
+	xxx_messageInfo_FileHistory.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FileHistory proto.InternalMessageInfo
+
+type FileHistoryEntry struct {
+	Time       int64                                               `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	Device     []byte                                              `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	Type       protocol.FileInfoType                               `protobuf:"varint,3,opt,name=type,proto3,enum=protocol.FileInfoType" json:"type,omitempty"`
+	Size       int64                                               `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Version    protocol.Vector                                     `protobuf:"bytes,5,opt,name=version,proto3" json:"version"`
+	Deleted    bool                                                `protobuf:"varint,6,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	ModifiedBy github_com_syncthing_syncthing_lib_protocol.ShortID `protobuf:"varint,7,opt,name=modified_by,json=modifiedBy,proto3,customtype=github.com/syncthing/syncthing/lib/protocol.ShortID" json:"modified_by"`
+	ModifiedS  int64                                               `protobuf:"varint,8,opt,name=modified_s,json=modifiedS,proto3" json:"modified_s,omitempty"`
+	ModifiedNs int32                                               `protobuf:"varint,9,opt,name=modified_ns,json=modifiedNs,proto3" json:"modified_ns,omitempty"`
+}
+
+func (m *FileHistoryEntry) Reset()         { *m = FileHistoryEntry{} }
+func (m *FileHistoryEntry) String() string { return proto.CompactTextString(m) }
+func (*FileHistoryEntry) ProtoMessage()    {}
+func (*FileHistoryEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e774e8f5f348d14d, []int{10}
+}
+func (m *FileHistoryEntry) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FileHistoryEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FileHistoryEntry.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *FileHistoryEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FileHistoryEntry.Merge(m, src)
+}
+func (m *FileHistoryEntry) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *FileHistoryEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_FileHistoryEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FileHistoryEntry proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterType((*FileVersion)(nil), "db.FileVersion")
 	proto.RegisterType((*VersionList)(nil), "db.VersionList")
@@ -404,66 +487,74 @@ func init() {
 	proto.RegisterType((*CountsSet)(nil), "db.CountsSet")
 	proto.RegisterType((*FileVersionDeprecated)(nil), "db.FileVersionDeprecated")
 	proto.RegisterType((*VersionListDeprecated)(nil), "db.VersionListDeprecated")
+	proto.RegisterType((*FileHistory)(nil), "db.FileHistory")
+	proto.RegisterType((*FileHistoryEntry)(nil), "db.FileHistoryEntry")
 }
 
 func init() { proto.RegisterFile("structs.proto", fileDescriptor_e774e8f5f348d14d) }
 
 var fileDescriptor_e774e8f5f348d14d = []byte{
-	// 861 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0x4f, 0x8f, 0xdb, 0x54,
-	0x10, 0x8f, 0x9b, 0xff, 0xe3, 0x64, 0xdb, 0xbe, 0x76, 0x57, 0x66, 0x25, 0x1c, 0xcb, 0x08, 0x61,
-	0x71, 0x48, 0x60, 0x7b, 0xa3, 0x12, 0x42, 0x61, 0x55, 0x11, 0x09, 0x51, 0xf4, 0xb6, 0xf4, 0x80,
-	0x2a, 0x45, 0xb6, 0xf3, 0x92, 0x3c, 0xd5, 0xf1, 0x0b, 0x7e, 0xce, 0xae, 0xdc, 0x4f, 0xc1, 0x05,
-	0x89, 0x03, 0x87, 0x5e, 0xf8, 0x2e, 0x7b, 0xec, 0x11, 0x71, 0x88, 0x20, 0xcb, 0x01, 0xbe, 0x05,
-	0x7a, 0xf3, 0x6c, 0xc7, 0x1b, 0x0e, 0xb4, 0xb7, 0x99, 0xdf, 0xcc, 0xf3, 0xcc, 0xfc, 0xe6, 0xe7,
-	0x81, 0xbe, 0x4c, 0x93, 0x4d, 0x98, 0xca, 0xe1, 0x3a, 0x11, 0xa9, 0x20, 0x77, 0x66, 0xc1, 0xe9,
-	0x07, 0x09, 0x5b, 0x0b, 0x39, 0x42, 0x20, 0xd8, 0xcc, 0x47, 0x0b, 0xb1, 0x10, 0xe8, 0xa0, 0xa5,
-	0x13, 0x4f, 0x4f, 0x22, 0x1e, 0xe8, 0x94, 0x50, 0x44, 0xa3, 0x80, 0xad, 0x35, 0xee, 0xfe, 0x62,
-	0x80, 0xf9, 0x84, 0x47, 0xec, 0x39, 0x4b, 0x24, 0x17, 0x31, 0xf9, 0x04, 0xda, 0x97, 0xda, 0xb4,
-	0x0c, 0xc7, 0xf0, 0xcc, 0xb3, 0x7b, 0xc3, 0xe2, 0xd5, 0xf0, 0x39, 0x0b, 0x53, 0x91, 0x8c, 0x1b,
-	0xd7, 0xdb, 0x41, 0x8d, 0x16, 0x69, 0xc4, 0x82, 0xf6, 0x8c, 0x45, 0x2c, 0x65, 0x33, 0xeb, 0x8e,
-	0x63, 0x78, 0x1d, 0x5a, 0xb8, 0x3a, 0x72, 0xc9, 0x43, 0x26, 0xad, 0xba, 0x53, 0xf7, 0x7a, 0xb4,
-	0x70, 0xc9, 0x47, 0x70, 0x97, 0xc7, 0x97, 0x7e, 0xc4, 0x67, 0xd3, 0x22, 0xa3, 0x81, 0x19, 0x47,
-	0x39, 0x7c, 0xae, 0x51, 0xf7, 0x3b, 0x30, 0xf3, 0xce, 0xbe, 0xe6, 0x32, 0x25, 0x5f, 0x40, 0x27,
-	0x2f, 0x2b, 0x2d, 0xc3, 0xa9, 0x7b, 0xe6, 0xd9, 0xdd, 0xe1, 0x2c, 0x18, 0x56, 0x06, 0x18, 0x3f,
-	0x50, 0xdd, 0xed, 0xb6, 0x03, 0x93, 0xfa, 0x57, 0x39, 0x26, 0x69, 0xf9, 0xea, 0xb3, 0xc6, 0xcf,
-	0xaf, 0x07, 0x35, 0xf7, 0xd7, 0x26, 0xdc, 0x57, 0x8f, 0x26, 0xf1, 0x5c, 0x3c, 0x4b, 0x36, 0x71,
-	0xe8, 0xab, 0x7e, 0x09, 0x34, 0x62, 0x7f, 0xc5, 0x70, 0xf0, 0x2e, 0x45, 0x5b, 0x61, 0x92, 0xbf,
-	0x62, 0x56, 0xdd, 0x31, 0xbc, 0x3a, 0x45, 0x9b, 0xbc, 0x0f, 0xb0, 0x12, 0x33, 0x3e, 0xe7, 0x6c,
-	0x36, 0x95, 0x56, 0x13, 0x23, 0xdd, 0x02, 0xb9, 0x20, 0x2f, 0xc0, 0x2c, 0xc3, 0x41, 0x66, 0xf5,
-	0x1c, 0xc3, 0x6b, 0x8c, 0x1f, 0xab, 0xb6, 0x7e, 0xdf, 0x0e, 0x1e, 0x2d, 0x78, 0xba, 0xdc, 0x04,
-	0xc3, 0x50, 0xac, 0x46, 0x32, 0x8b, 0xc3, 0x74, 0xc9, 0xe3, 0x45, 0xc5, 0xaa, 0xae, 0x69, 0x78,
-	0xb1, 0x14, 0x49, 0x3a, 0x39, 0xa7, 0x65, 0xb9, 0x71, 0x56, 0x5d, 0x50, 0xf7, 0xed, 0x16, 0x74,
-	0x0a, 0x1d, 0xc9, 0x7e, 0xd8, 0xb0, 0x38, 0x64, 0x16, 0x60, 0xb3, 0xa5, 0x4f, 0x3e, 0x84, 0x23,
-	0x99, 0xad, 0x22, 0x1e, 0xbf, 0x9c, 0xa6, 0x7e, 0xb2, 0x60, 0xa9, 0x75, 0x1f, 0x87, 0xef, 0xe7,
-	0xe8, 0x33, 0x04, 0xc9, 0x00, 0xcc, 0x20, 0x12, 0xe1, 0x4b, 0x39, 0x5d, 0xfa, 0x72, 0x69, 0x11,
-	0xc7, 0xf0, 0x7a, 0x14, 0x34, 0xf4, 0x95, 0x2f, 0x97, 0xe4, 0x63, 0x68, 0xa4, 0xd9, 0x9a, 0xa1,
-	0x02, 0x8e, 0xce, 0x4e, 0xf6, 0x2d, 0x95, 0x2c, 0x67, 0x6b, 0x46, 0x31, 0x87, 0x38, 0x60, 0xae,
-	0x59, 0xb2, 0xe2, 0x52, 0xef, 0xb1, 0xe1, 0x18, 0x5e, 0x9f, 0x56, 0x21, 0x55, 0xae, 0x64, 0x30,
-	0x96, 0x96, 0xe9, 0x18, 0x5e, 0x73, 0x4f, 0xc2, 0x37, 0x92, 0x8c, 0x40, 0x17, 0x9f, 0xe2, 0x6e,
-	0xfa, 0x2a, 0x3e, 0xbe, 0xb7, 0xdb, 0x0e, 0x7a, 0xd4, 0xbf, 0x1a, 0xab, 0xc0, 0x05, 0x7f, 0xc5,
-	0x68, 0x37, 0x28, 0x4c, 0x55, 0x33, 0x12, 0xa1, 0x1f, 0x4d, 0xe7, 0x91, 0xbf, 0x90, 0xd6, 0xdf,
-	0x6d, 0x2c, 0x0a, 0x88, 0x3d, 0x51, 0x10, 0x71, 0xa1, 0x97, 0x13, 0xa6, 0x67, 0xfc, 0xa7, 0x8d,
-	0x43, 0x9a, 0x39, 0x88, 0x53, 0x56, 0xa4, 0xde, 0xba, 0x2d, 0x75, 0x0f, 0xda, 0xb9, 0x72, 0x2d,
-	0xf5, 0xae, 0x33, 0x3e, 0xda, 0x6d, 0x07, 0x40, 0xfd, 0xab, 0x89, 0x46, 0x69, 0x11, 0x56, 0x8c,
-	0xc7, 0x62, 0x5a, 0x25, 0xa0, 0x83, 0x9f, 0xea, 0xc7, 0xe2, 0xdb, 0x3d, 0x98, 0xeb, 0xf4, 0x73,
-	0xe8, 0xe2, 0x38, 0x28, 0xfe, 0x4f, 0xa1, 0x85, 0x4e, 0x21, 0xfd, 0x07, 0x7b, 0x96, 0x11, 0x57,
-	0x34, 0xe7, 0xbb, 0xcf, 0x13, 0xdd, 0x17, 0x70, 0x3c, 0x89, 0x67, 0x3c, 0x61, 0x61, 0x9a, 0xcf,
-	0xc0, 0xe4, 0xd3, 0x38, 0xca, 0xfe, 0x7f, 0xa1, 0x6f, 0x41, 0x87, 0xfb, 0x97, 0x01, 0xad, 0x2f,
-	0xc5, 0x26, 0x4e, 0x25, 0x79, 0x08, 0xcd, 0x39, 0x8f, 0x98, 0xc4, 0x7f, 0xa7, 0x49, 0xb5, 0xa3,
-	0x58, 0xd7, 0xc5, 0x45, 0xc2, 0x99, 0x44, 0x71, 0x34, 0x69, 0x15, 0x42, 0x6d, 0x6a, 0xa5, 0x49,
-	0xfc, 0xc5, 0x9a, 0xb4, 0xf4, 0xab, 0x6c, 0x37, 0x30, 0x54, 0xb2, 0xfd, 0x10, 0x9a, 0x41, 0x96,
-	0xb2, 0xe2, 0xdf, 0xd3, 0xce, 0x2d, 0x9d, 0xb7, 0x0e, 0x74, 0x7e, 0x0a, 0x1d, 0x7d, 0x68, 0x26,
-	0xe7, 0xa8, 0xf0, 0x1e, 0x2d, 0x7d, 0x62, 0x43, 0x45, 0x07, 0x48, 0xc5, 0x2d, 0x65, 0xb8, 0x4f,
-	0xa1, 0xab, 0xa7, 0xbc, 0x60, 0x29, 0xf1, 0xa0, 0x15, 0xa2, 0x93, 0x2f, 0x01, 0xd4, 0xfd, 0xd1,
-	0xe1, 0x82, 0x7b, 0x1d, 0x57, 0xed, 0x87, 0x09, 0xf3, 0x8b, 0xbb, 0x58, 0xa7, 0x85, 0xeb, 0xfe,
-	0x64, 0xc0, 0x71, 0xe5, 0x64, 0x9d, 0xb3, 0x75, 0xc2, 0xf4, 0x05, 0x7a, 0xf7, 0xeb, 0x7b, 0x02,
-	0x2d, 0x3d, 0x08, 0x16, 0xe9, 0xd1, 0xdc, 0x53, 0xd5, 0x0b, 0x41, 0xd6, 0xb5, 0x54, 0x0b, 0x01,
-	0x1e, 0xd0, 0xba, 0x17, 0xb1, 0xfb, 0x3d, 0x1c, 0x57, 0x8e, 0x6d, 0xa5, 0xad, 0xc7, 0xff, 0x39,
-	0xbb, 0xef, 0x1d, 0x9c, 0xdd, 0x7d, 0x72, 0xde, 0xe0, 0xc1, 0xc5, 0x1d, 0x3b, 0xd7, 0x7f, 0xda,
-	0xb5, 0xeb, 0x9d, 0x6d, 0xbc, 0xd9, 0xd9, 0xc6, 0x1f, 0x3b, 0xbb, 0xf6, 0xe3, 0x8d, 0x5d, 0x7b,
-	0x7d, 0x63, 0x1b, 0x6f, 0x6e, 0xec, 0xda, 0x6f, 0x37, 0x76, 0x2d, 0x68, 0xe1, 0xa4, 0x8f, 0xfe,
-	0x0d, 0x00, 0x00, 0xff, 0xff, 0xb9, 0xe1, 0xbd, 0x08, 0xe2, 0x06, 0x00, 0x00,
+	// 947 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0x4d, 0x8f, 0xdb, 0xc4,
+	0x1b, 0x8f, 0x37, 0xef, 0x8f, 0xb3, 0xdb, 0xed, 0xb4, 0xbb, 0xf2, 0x7f, 0xa5, 0x7f, 0x62, 0x19,
+	0x21, 0x2c, 0x0e, 0x09, 0x6c, 0x39, 0x51, 0x09, 0xa1, 0x74, 0xa9, 0x1a, 0x09, 0x51, 0x34, 0x5b,
+	0x7a, 0x40, 0x95, 0x22, 0xdb, 0x99, 0x24, 0xa3, 0x3a, 0x76, 0xf0, 0x4c, 0x76, 0xe5, 0x7e, 0x0a,
+	0x2e, 0x48, 0x1c, 0x38, 0xf4, 0xc2, 0x77, 0xd9, 0x63, 0x8f, 0x88, 0x43, 0x04, 0x59, 0x0e, 0xe5,
+	0x5b, 0xa0, 0x79, 0x4b, 0xbc, 0x46, 0x88, 0xad, 0xc4, 0x6d, 0x9e, 0xdf, 0xf3, 0x8c, 0x9f, 0xb7,
+	0x9f, 0x7f, 0x03, 0xfb, 0x8c, 0x67, 0xab, 0x88, 0xb3, 0xfe, 0x32, 0x4b, 0x79, 0x8a, 0xf6, 0x26,
+	0xe1, 0xc9, 0x7b, 0x19, 0x59, 0xa6, 0x6c, 0x20, 0x81, 0x70, 0x35, 0x1d, 0xcc, 0xd2, 0x59, 0x2a,
+	0x0d, 0x79, 0x52, 0x81, 0x27, 0xc7, 0x31, 0x0d, 0x55, 0x48, 0x94, 0xc6, 0x83, 0x90, 0x2c, 0x15,
+	0xee, 0xfd, 0x64, 0x81, 0xfd, 0x98, 0xc6, 0xe4, 0x39, 0xc9, 0x18, 0x4d, 0x13, 0xf4, 0x11, 0x34,
+	0x2f, 0xd4, 0xd1, 0xb1, 0x5c, 0xcb, 0xb7, 0x4f, 0x0f, 0xfb, 0xe6, 0x56, 0xff, 0x39, 0x89, 0x78,
+	0x9a, 0x0d, 0x6b, 0x57, 0xeb, 0x5e, 0x05, 0x9b, 0x30, 0xe4, 0x40, 0x73, 0x42, 0x62, 0xc2, 0xc9,
+	0xc4, 0xd9, 0x73, 0x2d, 0xbf, 0x85, 0x8d, 0xa9, 0x3c, 0x17, 0x34, 0x22, 0xcc, 0xa9, 0xba, 0x55,
+	0xbf, 0x83, 0x8d, 0x89, 0x3e, 0x80, 0x3b, 0x34, 0xb9, 0x08, 0x62, 0x3a, 0x19, 0x9b, 0x88, 0x9a,
+	0x8c, 0x38, 0xd0, 0xf0, 0x99, 0x42, 0xbd, 0x6f, 0xc0, 0xd6, 0x95, 0x7d, 0x49, 0x19, 0x47, 0x9f,
+	0x43, 0x4b, 0xa7, 0x65, 0x8e, 0xe5, 0x56, 0x7d, 0xfb, 0xf4, 0x4e, 0x7f, 0x12, 0xf6, 0x0b, 0x0d,
+	0x0c, 0xef, 0x89, 0xea, 0x36, 0xeb, 0x9e, 0x8d, 0x83, 0x4b, 0x8d, 0x31, 0xbc, 0xbd, 0xf5, 0x69,
+	0xed, 0xc7, 0xd7, 0xbd, 0x8a, 0xf7, 0x73, 0x1d, 0xee, 0x8a, 0x4b, 0xa3, 0x64, 0x9a, 0x3e, 0xcb,
+	0x56, 0x49, 0x14, 0x88, 0x7a, 0x11, 0xd4, 0x92, 0x60, 0x41, 0x64, 0xe3, 0x6d, 0x2c, 0xcf, 0x02,
+	0x63, 0xf4, 0x15, 0x71, 0xaa, 0xae, 0xe5, 0x57, 0xb1, 0x3c, 0xa3, 0xff, 0x03, 0x2c, 0xd2, 0x09,
+	0x9d, 0x52, 0x32, 0x19, 0x33, 0xa7, 0x2e, 0x3d, 0x6d, 0x83, 0x9c, 0xa3, 0x17, 0x60, 0x6f, 0xdd,
+	0x61, 0xee, 0x74, 0x5c, 0xcb, 0xaf, 0x0d, 0x1f, 0x8a, 0xb2, 0x7e, 0x5d, 0xf7, 0x1e, 0xcc, 0x28,
+	0x9f, 0xaf, 0xc2, 0x7e, 0x94, 0x2e, 0x06, 0x2c, 0x4f, 0x22, 0x3e, 0xa7, 0xc9, 0xac, 0x70, 0x2a,
+	0xae, 0xa9, 0x7f, 0x3e, 0x4f, 0x33, 0x3e, 0x3a, 0xc3, 0xdb, 0x74, 0xc3, 0xbc, 0xb8, 0xa0, 0xf6,
+	0xed, 0x16, 0x74, 0x02, 0x2d, 0x46, 0xbe, 0x5b, 0x91, 0x24, 0x22, 0x0e, 0xc8, 0x62, 0xb7, 0x36,
+	0x7a, 0x1f, 0x0e, 0x58, 0xbe, 0x88, 0x69, 0xf2, 0x72, 0xcc, 0x83, 0x6c, 0x46, 0xb8, 0x73, 0x57,
+	0x36, 0xbf, 0xaf, 0xd1, 0x67, 0x12, 0x44, 0x3d, 0xb0, 0xc3, 0x38, 0x8d, 0x5e, 0xb2, 0xf1, 0x3c,
+	0x60, 0x73, 0x07, 0xb9, 0x96, 0xdf, 0xc1, 0xa0, 0xa0, 0x27, 0x01, 0x9b, 0xa3, 0x0f, 0xa1, 0xc6,
+	0xf3, 0x25, 0x91, 0x0c, 0x38, 0x38, 0x3d, 0xde, 0x95, 0xb4, 0x9d, 0x72, 0xbe, 0x24, 0x58, 0xc6,
+	0x20, 0x17, 0xec, 0x25, 0xc9, 0x16, 0x94, 0xa9, 0x3d, 0xd6, 0x5c, 0xcb, 0xdf, 0xc7, 0x45, 0x48,
+	0xa4, 0xdb, 0x4e, 0x30, 0x61, 0x8e, 0xed, 0x5a, 0x7e, 0x7d, 0x37, 0x84, 0xaf, 0x18, 0x1a, 0x80,
+	0x4a, 0x3e, 0x96, 0xbb, 0xd9, 0x17, 0xfe, 0xe1, 0xe1, 0x66, 0xdd, 0xeb, 0xe0, 0xe0, 0x72, 0x28,
+	0x1c, 0xe7, 0xf4, 0x15, 0xc1, 0xed, 0xd0, 0x1c, 0x45, 0xce, 0x38, 0x8d, 0x82, 0x78, 0x3c, 0x8d,
+	0x83, 0x19, 0x73, 0xde, 0x36, 0x65, 0x52, 0x90, 0xd8, 0x63, 0x01, 0x21, 0x0f, 0x3a, 0x7a, 0x60,
+	0xaa, 0xc7, 0x3f, 0x9b, 0xb2, 0x49, 0x5b, 0x83, 0xb2, 0xcb, 0x02, 0xd5, 0x1b, 0x37, 0xa9, 0xee,
+	0x43, 0x53, 0x33, 0xd7, 0x11, 0xf7, 0x5a, 0xc3, 0x83, 0xcd, 0xba, 0x07, 0x38, 0xb8, 0x1c, 0x29,
+	0x14, 0x1b, 0xb7, 0x98, 0x78, 0x92, 0x8e, 0x8b, 0x03, 0x68, 0xc9, 0x4f, 0xed, 0x27, 0xe9, 0xd7,
+	0x3b, 0x50, 0xf3, 0xf4, 0x33, 0x68, 0xcb, 0x76, 0x24, 0xf9, 0x3f, 0x86, 0x86, 0x34, 0x0c, 0xf5,
+	0xef, 0xed, 0xa6, 0x2c, 0x71, 0x31, 0x66, 0xbd, 0x7b, 0x1d, 0xe8, 0xbd, 0x80, 0xa3, 0x51, 0x32,
+	0xa1, 0x19, 0x89, 0xb8, 0xee, 0x81, 0xb0, 0xa7, 0x49, 0x9c, 0xff, 0xfb, 0x42, 0x6f, 0x31, 0x0e,
+	0xef, 0x0f, 0x0b, 0x1a, 0x8f, 0xd2, 0x55, 0xc2, 0x19, 0xba, 0x0f, 0xf5, 0x29, 0x8d, 0x09, 0x93,
+	0xff, 0x4e, 0x1d, 0x2b, 0x43, 0x4c, 0x5d, 0x25, 0x4f, 0x33, 0x4a, 0x98, 0x24, 0x47, 0x1d, 0x17,
+	0x21, 0xc9, 0x4d, 0xc5, 0x34, 0x26, 0x7f, 0xb1, 0x3a, 0xde, 0xda, 0xc5, 0x69, 0xd7, 0xa4, 0xcb,
+	0x98, 0x22, 0x5b, 0x98, 0x73, 0x62, 0xfe, 0x3d, 0x65, 0xdc, 0xe0, 0x79, 0xa3, 0xc4, 0xf3, 0x13,
+	0x68, 0x29, 0xa1, 0x19, 0x9d, 0x49, 0x86, 0x77, 0xf0, 0xd6, 0x46, 0x5d, 0x28, 0xf0, 0xc0, 0x41,
+	0x65, 0x66, 0x78, 0x4f, 0xa1, 0xad, 0xba, 0x3c, 0x27, 0x1c, 0xf9, 0xd0, 0x88, 0xa4, 0xa1, 0x97,
+	0x00, 0x42, 0x7f, 0x94, 0xdb, 0xcc, 0x5e, 0xf9, 0x45, 0xf9, 0x51, 0x46, 0x02, 0xa3, 0x8b, 0x55,
+	0x6c, 0x4c, 0xef, 0x07, 0x0b, 0x8e, 0x0a, 0x92, 0x75, 0x46, 0x96, 0x19, 0x51, 0x0a, 0xf4, 0xee,
+	0xea, 0x7b, 0x0c, 0x0d, 0xd5, 0x88, 0x4c, 0xd2, 0xc1, 0xda, 0x12, 0xd9, 0x0d, 0x21, 0xab, 0x8a,
+	0xaa, 0xda, 0x2c, 0x8f, 0x75, 0x47, 0x62, 0xef, 0x5b, 0x38, 0x2a, 0x88, 0x6d, 0xa1, 0xac, 0x87,
+	0x7f, 0x93, 0xdd, 0xff, 0x95, 0x64, 0x77, 0x17, 0xac, 0x0b, 0x2c, 0x2b, 0xee, 0x23, 0xf5, 0xcc,
+	0x3c, 0xa1, 0x8c, 0xa7, 0x59, 0x8e, 0x3e, 0x81, 0x26, 0x49, 0xb8, 0x64, 0x85, 0xfa, 0xe0, 0x7d,
+	0xf3, 0x41, 0x1d, 0xf1, 0x45, 0xc2, 0xb3, 0xdc, 0x34, 0xab, 0x43, 0xbd, 0xb7, 0x7b, 0x70, 0x58,
+	0x8e, 0x11, 0x0a, 0xcd, 0xa9, 0x56, 0xed, 0x2a, 0x96, 0xe7, 0x7f, 0x9c, 0x8a, 0x91, 0xa9, 0xea,
+	0x2d, 0x64, 0xca, 0x28, 0x7f, 0xad, 0xa0, 0xfc, 0x85, 0xfd, 0xd4, 0xdf, 0xf9, 0x75, 0x2c, 0x49,
+	0x46, 0xe9, 0x99, 0x68, 0xfe, 0xb7, 0xcf, 0xc4, 0xcd, 0x37, 0xaa, 0x55, 0x7e, 0xa3, 0x4a, 0x0a,
+	0xdb, 0x2e, 0x2b, 0xec, 0xd0, 0xbd, 0xfa, 0xbd, 0x5b, 0xb9, 0xda, 0x74, 0xad, 0x37, 0x9b, 0xae,
+	0xf5, 0xdb, 0xa6, 0x5b, 0xf9, 0xfe, 0xba, 0x5b, 0x79, 0x7d, 0xdd, 0xb5, 0xde, 0x5c, 0x77, 0x2b,
+	0xbf, 0x5c, 0x77, 0x2b, 0x61, 0x43, 0xe6, 0x7f, 0xf0, 0xd7, 0x00, 0x48, 0xa1, 0xbe, 0x0c, 0x92,
+	0x08, 0x00, 0x00,
 }
 
 func (m *FileVersion) Marshal() (dAtA []byte, err error) {
@@ -994,6 +1085,123 @@ func (m *VersionListDeprecated) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *FileHistory) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FileHistory) MarshalTo(dAtA []byte) (int, error) {
+	size := m.ProtoSize()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *FileHistory) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Entries) > 0 {
+		for iNdEx := len(m.Entries) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Entries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintStructs(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *FileHistoryEntry) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FileHistoryEntry) MarshalTo(dAtA []byte) (int, error) {
+	size := m.ProtoSize()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *FileHistoryEntry) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.ModifiedNs != 0 {
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedNs))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.ModifiedS != 0 {
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedS))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.ModifiedBy != 0 {
+		i = encodeVarintStructs(dAtA, i, uint64(m.ModifiedBy))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.Deleted {
+		i--
+		if m.Deleted {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
+	}
+	{
+		size, err := m.Version.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintStructs(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x2a
+	if m.Size != 0 {
+		i = encodeVarintStructs(dAtA, i, uint64(m.Size))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Type != 0 {
+		i = encodeVarintStructs(dAtA, i, uint64(m.Type))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Device) > 0 {
+		i -= len(m.Device)
+		copy(dAtA[i:], m.Device)
+		i = encodeVarintStructs(dAtA, i, uint64(len(m.Device)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Time != 0 {
+		i = encodeVarintStructs(dAtA, i, uint64(m.Time))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintStructs(dAtA []byte, offset int, v uint64) int {
 	offset -= sovStructs(v)
 	base := offset
@@ -1229,6 +1437,57 @@ func (m *VersionListDeprecated) ProtoSize() (n int) {
 	return n
 }
 
+func (m *FileHistory) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Entries) > 0 {
+		for _, e := range m.Entries {
+			l = e.ProtoSize()
+			n += 1 + l + sovStructs(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *FileHistoryEntry) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Time != 0 {
+		n += 1 + sovStructs(uint64(m.Time))
+	}
+	l = len(m.Device)
+	if l > 0 {
+		n += 1 + l + sovStructs(uint64(l))
+	}
+	if m.Type != 0 {
+		n += 1 + sovStructs(uint64(m.Type))
+	}
+	if m.Size != 0 {
+		n += 1 + sovStructs(uint64(m.Size))
+	}
+	l = m.Version.ProtoSize()
+	n += 1 + l + sovStructs(uint64(l))
+	if m.Deleted {
+		n += 2
+	}
+	if m.ModifiedBy != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedBy))
+	}
+	if m.ModifiedS != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedS))
+	}
+	if m.ModifiedNs != 0 {
+		n += 1 + sovStructs(uint64(m.ModifiedNs))
+	}
+	return n
+}
+
 func sovStructs(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -2722,6 +2981,347 @@ func (m *VersionListDeprecated) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *FileHistory) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStructs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FileHistory: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FileHistory: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Entries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Entries = append(m.Entries, FileHistoryEntry{})
+			if err := m.Entries[len(m.Entries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStructs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FileHistoryEntry) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStructs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FileHistoryEntry: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FileHistoryEntry: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Time", wireType)
+			}
+			m.Time = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Time |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Device", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Device = append(m.Device[:0], dAtA[iNdEx:postIndex]...)
+			if m.Device == nil {
+				m.Device = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= protocol.FileInfoType(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			}
+			m.Size = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStructs
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Version.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Deleted", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Deleted = bool(v != 0)
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedBy", wireType)
+			}
+			m.ModifiedBy = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedBy |= github_com_syncthing_syncthing_lib_protocol.ShortID(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedS", wireType)
+			}
+			m.ModifiedS = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedS |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedNs", wireType)
+			}
+			m.ModifiedNs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStructs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedNs |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStructs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthStructs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipStructs(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -480,11 +480,15 @@ func (t *readOnlyTransaction) withNeed(folder, device []byte, truncate bool, fn
 }
 
 func (t *readOnlyTransaction) withNeedLocal(folder []byte, truncate bool, fn Iterator) error {
-	key, err := t.keyer.GenerateNeedFileKey(nil, folder, nil)
+	return t.withNeedLocalPrefix(folder, nil, truncate, fn)
+}
+
+func (t *readOnlyTransaction) withNeedLocalPrefix(folder, prefix []byte, truncate bool, fn Iterator) error {
+	key, err := t.keyer.GenerateNeedFileKey(nil, folder, prefix)
 	if err != nil {
 		return err
 	}
-	dbi, err := t.NewPrefixIterator(key.WithoutName())
+	dbi, err := t.NewPrefixIterator(key)
 	if err != nil {
 		return err
 	}
@@ -597,30 +601,31 @@ func (t readWriteTransaction) putFile(fkey []byte, fi protocol.FileInfo, truncat
 
 // updateGlobal adds this device+version to the version list for the given
 // file. If the device is already present in the list, the version is updated.
-// If the file does not have an entry in the global list, it is created.
-func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, file protocol.FileInfo, meta *metadataTracker) ([]byte, bool, error) {
+// If the file does not have an entry in the global list, it is created. The
+// new global file is returned if the global version changed, otherwise nil.
+func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, file protocol.FileInfo, meta *metadataTracker) ([]byte, protocol.FileIntf, error) {
 	deviceID, err := protocol.DeviceIDFromBytes(device)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
 
 	l.Debugf("update global; folder=%q device=%v file=%q version=%v invalid=%v", folder, deviceID, file.Name, file.Version, file.IsInvalid())
 
 	fl, err := t.getGlobalVersionsByKey(gk)
 	if err != nil && !backend.IsNotFound(err) {
-		return nil, false, err
+		return nil, nil, err
 	}
 
 	globalFV, oldGlobalFV, removedFV, haveOldGlobal, haveRemoved, globalChanged, err := fl.update(folder, device, file, t.readOnlyTransaction)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
 
 	name := []byte(file.Name)
 
 	l.Debugf(`new global for "%v" after update: %v`, file.Name, fl)
 	if err := t.Put(gk, mustMarshal(&fl)); err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
 
 	// Only load those from db if actually needed
@@ -639,25 +644,25 @@ func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, fi
 	needNow := need(globalFV, true, file.Version)
 	if needBefore {
 		if keyBuf, oldGlobal, err = t.getGlobalFromFileVersion(keyBuf, folder, name, true, oldGlobalFV); err != nil {
-			return nil, false, err
+			return nil, nil, err
 		}
 		gotOldGlobal = true
 		meta.removeNeeded(deviceID, oldGlobal)
 		if !needNow && bytes.Equal(device, protocol.LocalDeviceID[:]) {
 			if keyBuf, err = t.updateLocalNeed(keyBuf, folder, name, false); err != nil {
-				return nil, false, err
+				return nil, nil, err
 			}
 		}
 	}
 	if needNow {
 		if keyBuf, global, err = t.updateGlobalGetGlobal(keyBuf, folder, name, file, globalFV); err != nil {
-			return nil, false, err
+			return nil, nil, err
 		}
 		gotGlobal = true
 		meta.addNeeded(deviceID, global)
 		if !needBefore && bytes.Equal(device, protocol.LocalDeviceID[:]) {
 			if keyBuf, err = t.updateLocalNeed(keyBuf, folder, name, true); err != nil {
-				return nil, false, err
+				return nil, nil, err
 			}
 		}
 	}
@@ -667,14 +672,14 @@ func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, fi
 	if !globalChanged {
 		// Neither the global state nor the needs of any devices, except
 		// the one updated, changed.
-		return keyBuf, true, nil
+		return keyBuf, nil, nil
 	}
 
 	// Remove the old global from the global size counter
 	if haveOldGlobal {
 		if !gotOldGlobal {
 			if keyBuf, oldGlobal, err = t.getGlobalFromFileVersion(keyBuf, folder, name, true, oldGlobalFV); err != nil {
-				return nil, false, err
+				return nil, nil, err
 			}
 			gotOldGlobal = true
 		}
@@ -685,7 +690,7 @@ func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, fi
 	// Add the new global to the global size counter
 	if !gotGlobal {
 		if keyBuf, global, err = t.updateGlobalGetGlobal(keyBuf, folder, name, file, globalFV); err != nil {
-			return nil, false, err
+			return nil, nil, err
 		}
 		gotGlobal = true
 	}
@@ -703,7 +708,7 @@ func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, fi
 			meta.removeNeeded(protocol.LocalDeviceID, oldGlobal)
 			if !needNow {
 				if keyBuf, err = t.updateLocalNeed(keyBuf, folder, name, false); err != nil {
-					return nil, false, err
+					return nil, nil, err
 				}
 			}
 		}
@@ -711,7 +716,7 @@ func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, fi
 			meta.addNeeded(protocol.LocalDeviceID, global)
 			if !needBefore {
 				if keyBuf, err = t.updateLocalNeed(keyBuf, folder, name, true); err != nil {
-					return nil, false, err
+					return nil, nil, err
 				}
 			}
 		}
@@ -731,7 +736,7 @@ func (t readWriteTransaction) updateGlobal(gk, keyBuf, folder, device []byte, fi
 		}
 	}
 
-	return keyBuf, true, nil
+	return keyBuf, global, nil
 }
 
 func (t readWriteTransaction) updateGlobalGetGlobal(keyBuf, folder, name []byte, file protocol.FileInfo, fv FileVersion) ([]byte, protocol.FileIntf, error) {
@@ -0,0 +1,146 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// httpProxyDialerFunction returns a dialer tunneling connections through an
+// HTTP proxy using the CONNECT method, for "http" and "https" proxy URLs.
+func httpProxyDialerFunction(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var auth string
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password))
+	}
+
+	return &httpProxyDialer{
+		host:    host,
+		tls:     u.Scheme == "https",
+		auth:    auth,
+		forward: forward,
+	}, nil
+}
+
+type httpProxyDialer struct {
+	host    string
+	tls     bool
+	auth    string
+	forward proxy.Dialer
+}
+
+func (d *httpProxyDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *httpProxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("network %s not supported by HTTP proxy", network)
+	}
+
+	var conn net.Conn
+	var err error
+	if fwd, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = fwd.DialContext(ctx, "tcp", d.host)
+	} else {
+		conn, err = d.forward.Dial("tcp", d.host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Abort the exchange with the proxy if the context is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if d.tls {
+		host, _, _ := net.SplitHostPort(d.host)
+		tc := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.auth != "" {
+		req.Header.Set("Proxy-Authorization", d.auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The body of a successful response is the tunnel, so it's not read or
+	// closed.
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("HTTP proxy: %s", resp.Status)
+	}
+
+	if ctx.Err() != nil {
+		// The connection may have been closed above.
+		conn.Close()
+		return nil, ctx.Err()
+	}
+
+	if br.Buffered() > 0 {
+		// The proxy already sent data from the other end.
+		return &bufferedConn{conn, br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn that reads from the buffered reader first.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.br.Read(p)
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"golang.org/x/net/proxy"
+)
+
+func TestHTTPProxyDialer(t *testing.T) {
+	// The target echoes what it gets.
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxyListener.Close()
+	go serveConnectProxy(t, proxyListener, "Basic dXNlcjpwYXNz")
+
+	u, err := url.Parse("http://user:pass@" + proxyListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := httpProxyDialerFunction(u, proxy.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := d.(proxy.ContextDialer).DialContext(context.Background(), "tcp", target.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("got %q, expected hello", buf)
+	}
+
+	// Without the credentials the proxy refuses.
+	u.User = nil
+	d, err = httpProxyDialerFunction(u, proxy.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Dial("tcp", target.Addr().String()); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func serveConnectProxy(t *testing.T, l net.Listener, auth string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if req.Method != http.MethodConnect {
+				t.Errorf("unexpected method %v", req.Method)
+				return
+			}
+			if req.Header.Get("Proxy-Authorization") != auth {
+				io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+				return
+			}
+			target, err := net.Dial("tcp", req.Host)
+			if err != nil {
+				io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+				return
+			}
+			defer target.Close()
+			io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			go io.Copy(target, conn)
+			io.Copy(conn, target)
+		}()
+	}
+}
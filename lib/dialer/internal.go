@@ -22,6 +22,11 @@ var (
 
 func init() {
 	proxy.RegisterDialerType("socks", socksDialerFunction)
+	// HTTP proxies in ALL_PROXY are used by tunneling through them with
+	// CONNECT. Before, as an unknown scheme, they were ignored and we
+	// connected directly.
+	proxy.RegisterDialerType("http", httpProxyDialerFunction)
+	proxy.RegisterDialerType("https", httpProxyDialerFunction)
 
 	if proxyDialer := proxy.FromEnvironment(); proxyDialer != proxy.Direct {
 		http.DefaultTransport = &http.Transport{
@@ -0,0 +1,15 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+func (f basicFile) CopyRangeFrom(method CopyRangeMethod, src File, srcOffset, dstOffset, size int64) error {
+	srcFile, ok := src.(basicFile)
+	if !ok {
+		return ErrCopyRangeNotSupported
+	}
+	return copyRangeOptimised(method, srcFile, f, srcOffset, dstOffset, size)
+}
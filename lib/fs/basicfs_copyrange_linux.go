@@ -0,0 +1,86 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package fs
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// From linux/fs.h
+const ficloneRange = 0x4020940d
+
+type fileCloneRange struct {
+	srcFd     int64
+	srcOffset uint64
+	srcLength uint64
+	dstOffset uint64
+}
+
+func copyRangeOptimised(method CopyRangeMethod, src, dst basicFile, srcOffset, dstOffset, size int64) error {
+	switch method {
+	case CopyRangeMethodIoctl:
+		return copyRangeIoctl(src, dst, srcOffset, dstOffset, size)
+	case CopyRangeMethodCopyFileRange:
+		return copyRangeCopyFileRange(src, dst, srcOffset, dstOffset, size)
+	case CopyRangeMethodAllWithFallback:
+		if err := copyRangeIoctl(src, dst, srcOffset, dstOffset, size); err == nil {
+			return nil
+		}
+		return copyRangeCopyFileRange(src, dst, srcOffset, dstOffset, size)
+	default:
+		return ErrCopyRangeNotSupported
+	}
+}
+
+// copyRangeIoctl makes the destination range share storage with the source
+// range, on filesystems that support reflinks such as Btrfs and XFS. The
+// offsets and size need to be aligned to the filesystem block size, except
+// for a range that ends at the end of the source file.
+func copyRangeIoctl(src, dst basicFile, srcOffset, dstOffset, size int64) error {
+	args := fileCloneRange{
+		srcFd:     int64(src.Fd()),
+		srcOffset: uint64(srcOffset),
+		srcLength: uint64(size),
+		dstOffset: uint64(dstOffset),
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, dst.Fd(), ficloneRange, uintptr(unsafe.Pointer(&args)))
+	switch errno {
+	case 0:
+		return nil
+	case unix.EOPNOTSUPP, unix.EXDEV, unix.EINVAL, unix.ENOTTY:
+		return ErrCopyRangeNotSupported
+	default:
+		return &os.PathError{Op: "ficlonerange", Path: dst.Name(), Err: errno}
+	}
+}
+
+// copyRangeCopyFileRange lets the kernel copy the data, which saves a round
+// trip through userspace and on some filesystems shares the storage like a
+// reflink.
+func copyRangeCopyFileRange(src, dst basicFile, srcOffset, dstOffset, size int64) error {
+	for size > 0 {
+		n, err := unix.CopyFileRange(int(src.Fd()), &srcOffset, int(dst.Fd()), &dstOffset, int(size), 0)
+		switch {
+		case err == syscall.ENOSYS, err == unix.EXDEV, err == unix.EOPNOTSUPP, err == unix.EINVAL:
+			return ErrCopyRangeNotSupported
+		case err != nil:
+			return &os.PathError{Op: "copy_file_range", Path: dst.Name(), Err: err}
+		case n == 0:
+			return io.ErrUnexpectedEOF
+		}
+		// The offsets have been advanced by the call.
+		size -= int64(n)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !linux
+
+package fs
+
+func copyRangeOptimised(method CopyRangeMethod, src, dst basicFile, srcOffset, dstOffset, size int64) error {
+	return ErrCopyRangeNotSupported
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux darwin freebsd netbsd
+
+package fs
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/xattr"
+)
+
+func (f *BasicFilesystem) GetXattr(name, attr string) ([]byte, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return nil, err
+	}
+	value, err := xattr.LGet(name, attr)
+	return value, xattrError(err)
+}
+
+func (f *BasicFilesystem) SetXattr(name, attr string, value []byte) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	return xattrError(xattr.LSet(name, attr, value))
+}
+
+func (f *BasicFilesystem) ListXattr(name string) ([]string, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := xattr.LList(name)
+	return attrs, xattrError(err)
+}
+
+func (f *BasicFilesystem) RemoveXattr(name, attr string) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	return xattrError(xattr.LRemove(name, attr))
+}
+
+// xattrError translates the error returned when the underlying filesystem
+// (as opposed to the operating system) doesn't support extended attributes
+// into ErrXattrsNotSupported, and other errors into *os.PathError so that
+// IsNotExist and friends work as expected.
+func xattrError(err error) error {
+	xerr, ok := err.(*xattr.Error)
+	if !ok {
+		return err
+	}
+	if xerr.Err == syscall.ENOTSUP || xerr.Err == syscall.EOPNOTSUPP {
+		return ErrXattrsNotSupported
+	}
+	op := xerr.Op
+	if xerr.Name != "" {
+		op += " " + xerr.Name
+	}
+	return &os.PathError{Op: op, Path: xerr.Path, Err: xerr.Err}
+}
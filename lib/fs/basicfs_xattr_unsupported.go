@@ -0,0 +1,25 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !linux,!darwin,!freebsd,!netbsd
+
+package fs
+
+func (f *BasicFilesystem) GetXattr(name, attr string) ([]byte, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (f *BasicFilesystem) SetXattr(name, attr string, value []byte) error {
+	return ErrXattrsNotSupported
+}
+
+func (f *BasicFilesystem) ListXattr(name string) ([]string, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (f *BasicFilesystem) RemoveXattr(name, attr string) error {
+	return ErrXattrsNotSupported
+}
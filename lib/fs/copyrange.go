@@ -0,0 +1,110 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"errors"
+	"io"
+)
+
+type CopyRangeMethod int
+
+const (
+	CopyRangeMethodStandard CopyRangeMethod = iota // default is standard
+	CopyRangeMethodIoctl
+	CopyRangeMethodCopyFileRange
+	CopyRangeMethodAllWithFallback
+)
+
+func (o CopyRangeMethod) String() string {
+	switch o {
+	case CopyRangeMethodStandard:
+		return "standard"
+	case CopyRangeMethodIoctl:
+		return "ioctl"
+	case CopyRangeMethodCopyFileRange:
+		return "copy_file_range"
+	case CopyRangeMethodAllWithFallback:
+		return "all"
+	default:
+		return "unknown"
+	}
+}
+
+func (o CopyRangeMethod) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+func (o *CopyRangeMethod) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "standard":
+		*o = CopyRangeMethodStandard
+	case "ioctl":
+		*o = CopyRangeMethodIoctl
+	case "copy_file_range":
+		*o = CopyRangeMethodCopyFileRange
+	case "all":
+		*o = CopyRangeMethodAllWithFallback
+	default:
+		*o = CopyRangeMethodStandard
+	}
+	return nil
+}
+
+var ErrCopyRangeNotSupported = errors.New("copy range method not supported")
+
+// The CopyRanger interface is optionally implemented by files that can copy
+// data from another file of the same filesystem without it passing through
+// our buffers, for example by sharing the underlying storage. Files that
+// don't implement it are limited to the standard method.
+type CopyRanger interface {
+	// CopyRangeFrom copies size bytes at srcOffset in src to dstOffset in
+	// the receiver, or returns ErrCopyRangeNotSupported if the method
+	// can't be used for this particular copy.
+	CopyRangeFrom(method CopyRangeMethod, src File, srcOffset, dstOffset, size int64) error
+}
+
+// CopyRange copies size bytes at srcOffset in src to dstOffset in dst using
+// the given method. The standard method reads and writes the data and works
+// for all files, the others return ErrCopyRangeNotSupported unless dst is a
+// CopyRanger that can perform the copy.
+func CopyRange(method CopyRangeMethod, src, dst File, srcOffset, dstOffset, size int64) error {
+	if method == CopyRangeMethodStandard {
+		return copyRangeStandard(src, dst, srcOffset, dstOffset, size)
+	}
+	cr, ok := dst.(CopyRanger)
+	if !ok {
+		return ErrCopyRangeNotSupported
+	}
+	return cr.CopyRangeFrom(method, src, srcOffset, dstOffset, size)
+}
+
+func copyRangeStandard(src, dst File, srcOffset, dstOffset, size int64) error {
+	bufSize := int64(4 << 20)
+	if size < bufSize {
+		bufSize = size
+	}
+	buf := make([]byte, bufSize)
+	for size > 0 {
+		if int64(len(buf)) > size {
+			buf = buf[:size]
+		}
+		n, err := src.ReadAt(buf, srcOffset)
+		if err == io.EOF && n < len(buf) {
+			return io.ErrUnexpectedEOF
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+		if _, err := dst.WriteAt(buf[:n], dstOffset); err != nil {
+			return err
+		}
+		srcOffset += int64(n)
+		dstOffset += int64(n)
+		size -= int64(n)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestCopyRange(t *testing.T) {
+	const blockSize = 128 << 10
+
+	dir, err := ioutil.TempDir("", "syncthing-copyrange-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 4*blockSize+100)
+	rand.Read(data)
+
+	cases := []struct {
+		fs     Filesystem
+		method CopyRangeMethod
+	}{
+		{newFakeFilesystem("/TestCopyRange?content=true"), CopyRangeMethodStandard},
+		{NewFilesystem(FilesystemTypeBasic, dir), CopyRangeMethodStandard},
+		{NewFilesystem(FilesystemTypeBasic, dir), CopyRangeMethodIoctl},
+		{NewFilesystem(FilesystemTypeBasic, dir), CopyRangeMethodCopyFileRange},
+		{NewFilesystem(FilesystemTypeBasic, dir), CopyRangeMethodAllWithFallback},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.fs.Type().String()+"-"+tc.method.String(), func(t *testing.T) {
+			src, err := tc.fs.Create("src")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+			if _, err := src.Write(data); err != nil {
+				t.Fatal(err)
+			}
+
+			dst, err := tc.fs.Create("dst")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+			if _, err := dst.Write(make([]byte, len(data))); err != nil {
+				t.Fatal(err)
+			}
+
+			// Copy the blocks in reverse order, the last one being short.
+			expected := make([]byte, len(data))
+			for i := 0; i < 5; i++ {
+				srcOffset := int64(i * blockSize)
+				dstOffset := int64((4 - i) * blockSize)
+				size := int64(blockSize)
+				if i == 4 {
+					size = int64(len(data)) - srcOffset
+				}
+				err := CopyRange(tc.method, src, dst, srcOffset, dstOffset, size)
+				if err == ErrCopyRangeNotSupported {
+					t.Skip(err)
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				copy(expected[dstOffset:], data[srcOffset:srcOffset+size])
+			}
+
+			actual := make([]byte, len(data))
+			if _, err := dst.ReadAt(actual, 0); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Error("copied data differs")
+			}
+		})
+	}
+}
+
+func TestCopyRangeNotSupported(t *testing.T) {
+	fs := newFakeFilesystem("/TestCopyRangeNotSupported")
+	src, err := fs.Create("src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := fs.Create("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if err := CopyRange(CopyRangeMethodAllWithFallback, src, dst, 0, 0, 1); err != ErrCopyRangeNotSupported {
+		t.Errorf("expected ErrCopyRangeNotSupported, got %v", err)
+	}
+}
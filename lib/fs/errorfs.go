@@ -49,3 +49,7 @@ func (fs *errorFilesystem) SameFile(fi1, fi2 FileInfo) bool              { retur
 func (fs *errorFilesystem) Watch(path string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
 	return nil, nil, fs.err
 }
+func (fs *errorFilesystem) GetXattr(name, attr string) ([]byte, error)     { return nil, fs.err }
+func (fs *errorFilesystem) SetXattr(name, attr string, value []byte) error { return fs.err }
+func (fs *errorFilesystem) ListXattr(name string) ([]string, error)        { return nil, fs.err }
+func (fs *errorFilesystem) RemoveXattr(name, attr string) error            { return fs.err }
@@ -17,6 +17,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -153,6 +154,7 @@ type fakeEntry struct {
 	mtime     time.Time
 	children  map[string]*fakeEntry
 	content   []byte
+	xattrs    map[string][]byte
 }
 
 func (fs *fakefs) entryForName(name string) *fakeEntry {
@@ -596,6 +598,65 @@ func (fs *fakefs) SameFile(fi1, fi2 FileInfo) bool {
 	return ok && fi1.ModTime().Equal(fi2.ModTime()) && fi1.Mode() == fi2.Mode() && fi1.IsDir() == fi2.IsDir() && fi1.IsRegular() == fi2.IsRegular() && fi1.IsSymlink() == fi2.IsSymlink() && fi1.Owner() == fi2.Owner() && fi1.Group() == fi2.Group()
 }
 
+var errFakeNoXattr = errors.New("no such attribute")
+
+func (fs *fakefs) GetXattr(name, attr string) ([]byte, error) {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	entry := fs.entryForName(name)
+	if entry == nil {
+		return nil, os.ErrNotExist
+	}
+	value, ok := entry.xattrs[attr]
+	if !ok {
+		return nil, errFakeNoXattr
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (fs *fakefs) SetXattr(name, attr string, value []byte) error {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	entry := fs.entryForName(name)
+	if entry == nil {
+		return os.ErrNotExist
+	}
+	if entry.xattrs == nil {
+		entry.xattrs = make(map[string][]byte)
+	}
+	entry.xattrs[attr] = append([]byte(nil), value...)
+	return nil
+}
+
+func (fs *fakefs) ListXattr(name string) ([]string, error) {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	entry := fs.entryForName(name)
+	if entry == nil {
+		return nil, os.ErrNotExist
+	}
+	attrs := make([]string, 0, len(entry.xattrs))
+	for attr := range entry.xattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	return attrs, nil
+}
+
+func (fs *fakefs) RemoveXattr(name, attr string) error {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+	entry := fs.entryForName(name)
+	if entry == nil {
+		return os.ErrNotExist
+	}
+	if _, ok := entry.xattrs[attr]; !ok {
+		return errFakeNoXattr
+	}
+	delete(entry.xattrs, attr)
+	return nil
+}
+
 // fakeFile is the representation of an open file. We don't care if it's
 // opened for reading or writing, it's all good.
 type fakeFile struct {
@@ -48,6 +48,13 @@ type Filesystem interface {
 	Type() FilesystemType
 	URI() string
 	SameFile(fi1, fi2 FileInfo) bool
+	// Extended attributes are accessed without following a symlink at the
+	// end of the path. Filesystems without support for them return
+	// ErrXattrsNotSupported.
+	GetXattr(name, attr string) ([]byte, error)
+	SetXattr(name, attr string, value []byte) error
+	ListXattr(name string) ([]string, error)
+	RemoveXattr(name, attr string) error
 }
 
 // The File interface abstracts access to a regular file, being a somewhat
@@ -137,6 +144,8 @@ func (evType EventType) String() string {
 
 var ErrWatchNotSupported = errors.New("watching is not supported")
 
+var ErrXattrsNotSupported = errors.New("extended attributes are not supported")
+
 // Equivalents from os package.
 
 const ModePerm = FileMode(os.ModePerm)
@@ -179,6 +188,28 @@ func NewFilesystem(fsType FilesystemType, uri string) Filesystem {
 		fs = newBasicFilesystem(uri)
 	case FilesystemTypeFake:
 		fs = newFakeFilesystem(uri)
+	case FilesystemTypeSFTP:
+		var err error
+		fs, err = newSFTPFilesystem(uri)
+		if err != nil {
+			l.Debugln("Invalid sftp filesystem", uri, err)
+			fs = &errorFilesystem{
+				fsType: fsType,
+				uri:    uri,
+				err:    err,
+			}
+		}
+	case FilesystemTypeS3:
+		var err error
+		fs, err = newS3Filesystem(uri)
+		if err != nil {
+			l.Debugln("Invalid s3 filesystem", uri, err)
+			fs = &errorFilesystem{
+				fsType: fsType,
+				uri:    uri,
+				err:    err,
+			}
+		}
 	default:
 		l.Debugln("Unknown filesystem", fsType, uri)
 		fs = &errorFilesystem{
@@ -163,3 +163,27 @@ func (fs *logFilesystem) Usage(name string) (Usage, error) {
 	l.Debugln(getCaller(), fs.Type(), fs.URI(), "Usage", name, usage, err)
 	return usage, err
 }
+
+func (fs *logFilesystem) GetXattr(name, attr string) ([]byte, error) {
+	value, err := fs.Filesystem.GetXattr(name, attr)
+	l.Debugln(getCaller(), fs.Type(), fs.URI(), "GetXattr", name, attr, len(value), err)
+	return value, err
+}
+
+func (fs *logFilesystem) SetXattr(name, attr string, value []byte) error {
+	err := fs.Filesystem.SetXattr(name, attr, value)
+	l.Debugln(getCaller(), fs.Type(), fs.URI(), "SetXattr", name, attr, len(value), err)
+	return err
+}
+
+func (fs *logFilesystem) ListXattr(name string) ([]string, error) {
+	attrs, err := fs.Filesystem.ListXattr(name)
+	l.Debugln(getCaller(), fs.Type(), fs.URI(), "ListXattr", name, attrs, err)
+	return attrs, err
+}
+
+func (fs *logFilesystem) RemoveXattr(name, attr string) error {
+	err := fs.Filesystem.RemoveXattr(name, attr)
+	l.Debugln(getCaller(), fs.Type(), fs.URI(), "RemoveXattr", name, attr, err)
+	return err
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The pollWatchFilesystem replaces the Watch method of the wrapped
+// filesystem with one that finds changes by periodically comparing the
+// directory tree to a snapshot of it. It's meant for filesystems where
+// notifications aren't available, such as network and FUSE mounts.
+type pollWatchFilesystem struct {
+	Filesystem
+	interval time.Duration
+	budget   int
+}
+
+// NewPollWatchFilesystem returns a filesystem that watches for changes by
+// polling every interval. Each time at most budget items are stat:ed, so a
+// full pass over a large tree may span several intervals.
+func NewPollWatchFilesystem(fs Filesystem, interval time.Duration, budget int) Filesystem {
+	if budget < 1 {
+		budget = 1
+	}
+	return &pollWatchFilesystem{
+		Filesystem: fs,
+		interval:   interval,
+		budget:     budget,
+	}
+}
+
+func (f *pollWatchFilesystem) Watch(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
+	info, err := f.Lstat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil, errors.New("not a directory")
+	}
+
+	w := &pollWatcher{
+		fs:          f.Filesystem,
+		root:        filepath.Clean(name),
+		ignore:      ignore,
+		ignorePerms: ignorePerms,
+		budget:      f.budget,
+		dirs:        make(map[string]map[string]pollEntry),
+		initial:     true,
+	}
+	w.queue = []string{w.root}
+
+	outChan := make(chan Event)
+	errChan := make(chan error)
+	go w.loop(ctx, f.interval, outChan, errChan)
+
+	return outChan, errChan, nil
+}
+
+// A pollEntry is the state of an item as of the last time we checked.
+type pollEntry struct {
+	size    int64
+	modTime time.Time
+	mode    FileMode
+	isDir   bool
+}
+
+func newPollEntry(info FileInfo) pollEntry {
+	return pollEntry{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		mode:    info.Mode(),
+		isDir:   info.IsDir(),
+	}
+}
+
+func (e pollEntry) changed(other pollEntry, ignorePerms bool) bool {
+	if e.isDir != other.isDir {
+		return true
+	}
+	if !ignorePerms && e.mode != other.mode {
+		return true
+	}
+	// The modification time and size of a directory merely reflect changes
+	// to its children, which we report on their own.
+	return !e.isDir && (e.size != other.size || !e.modTime.Equal(other.modTime))
+}
+
+type pollWatcher struct {
+	fs          Filesystem
+	root        string
+	ignore      Matcher
+	ignorePerms bool
+	budget      int
+
+	// The children of each known directory, by name.
+	dirs map[string]map[string]pollEntry
+	// Directories left to check in the current pass.
+	queue []string
+	// The first pass only records the state of things.
+	initial bool
+}
+
+func (w *pollWatcher) loop(ctx context.Context, interval time.Duration, outChan chan<- Event, errChan chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx, outChan); err != nil {
+			if ctx.Err() != nil {
+				l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Stopped")
+				return
+			}
+			select {
+			case errChan <- err:
+				l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Sending error", err)
+			case <-ctx.Done():
+			}
+			l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Stopped due to", err)
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Stopped")
+			return
+		}
+	}
+}
+
+// poll checks queued directories until the budget is spent or the pass is
+// complete, in which case the next pass is queued for the next call.
+func (w *pollWatcher) poll(ctx context.Context, outChan chan<- Event) error {
+	stats := 0
+	for stats < w.budget && len(w.queue) > 0 {
+		dir := w.queue[0]
+		w.queue = w.queue[1:]
+		n, err := w.checkDir(ctx, dir, outChan)
+		if err != nil {
+			return err
+		}
+		stats += n
+	}
+
+	if len(w.queue) == 0 {
+		if w.initial {
+			l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Initial pass complete")
+		}
+		w.initial = false
+		w.queue = []string{w.root}
+	}
+	return nil
+}
+
+// checkDir compares the children of the given directory to what they were
+// at the last pass, sends events for the differences and queues the
+// subdirectories. It returns the number of items stat:ed.
+func (w *pollWatcher) checkDir(ctx context.Context, dir string, outChan chan<- Event) (int, error) {
+	names, err := w.fs.DirNames(dir)
+	if err != nil {
+		if dir == w.root {
+			return 0, err
+		}
+		// The directory was removed or became inaccessible, which will be
+		// noticed when checking its parent on the next pass.
+		l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Skipping", dir, err)
+		return 0, nil
+	}
+
+	stats := 0
+	prev := w.dirs[dir]
+	cur := make(map[string]pollEntry, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		ignored := w.ignore.ShouldIgnore(path)
+		if ignored && w.ignore.SkipIgnoredDirs() {
+			continue
+		}
+
+		info, err := w.fs.Lstat(path)
+		stats++
+		if err != nil {
+			continue
+		}
+
+		entry := newPollEntry(info)
+		cur[name] = entry
+		if entry.isDir {
+			w.queue = append(w.queue, path)
+		}
+
+		old, ok := prev[name]
+		if ok && old.isDir && !entry.isDir {
+			w.forget(path)
+		}
+		if ignored || w.initial || (ok && !old.changed(entry, w.ignorePerms)) {
+			continue
+		}
+		if err := w.send(ctx, outChan, Event{Name: path, Type: NonRemove}); err != nil {
+			return stats, err
+		}
+	}
+
+	for name, old := range prev {
+		if _, ok := cur[name]; ok {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if old.isDir {
+			w.forget(path)
+		}
+		if w.ignore.ShouldIgnore(path) {
+			continue
+		}
+		if err := w.send(ctx, outChan, Event{Name: path, Type: Remove}); err != nil {
+			return stats, err
+		}
+	}
+
+	w.dirs[dir] = cur
+	return stats, nil
+}
+
+// forget drops the state of the given directory and everything below it.
+func (w *pollWatcher) forget(dir string) {
+	prefix := dir + string(PathSeparator)
+	for path := range w.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			delete(w.dirs, path)
+		}
+	}
+}
+
+func (w *pollWatcher) send(ctx context.Context, outChan chan<- Event, ev Event) error {
+	select {
+	case outChan <- ev:
+		l.Debugln(w.fs.Type(), w.fs.URI(), "Watch: Sending", ev.Name, ev.Type)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
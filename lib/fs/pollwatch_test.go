@@ -0,0 +1,163 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type pollMatcher map[string]bool
+
+func (m pollMatcher) ShouldIgnore(name string) bool {
+	return m[name]
+}
+
+func (m pollMatcher) SkipIgnoredDirs() bool {
+	return true
+}
+
+func newTestPollWatcher(fs Filesystem, budget int, ignore Matcher) *pollWatcher {
+	return &pollWatcher{
+		fs:      fs,
+		root:    ".",
+		ignore:  ignore,
+		budget:  budget,
+		dirs:    make(map[string]map[string]pollEntry),
+		queue:   []string{"."},
+		initial: true,
+	}
+}
+
+// pollPass runs the watcher until a full pass is complete and returns the
+// events, sorted by name.
+func pollPass(t *testing.T, w *pollWatcher) []Event {
+	t.Helper()
+	out := make(chan Event, 100)
+	for {
+		if err := w.poll(context.Background(), out); err != nil {
+			t.Fatal(err)
+		}
+		if len(w.queue) == 1 && w.queue[0] == w.root {
+			break
+		}
+	}
+	close(out)
+	var evs []Event
+	for ev := range out {
+		evs = append(evs, ev)
+	}
+	sort.Slice(evs, func(a, b int) bool {
+		return evs[a].Name < evs[b].Name
+	})
+	return evs
+}
+
+func TestPollWatcher(t *testing.T) {
+	fs := newFakeFilesystem("/TestPollWatcher")
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	create := func(name string, size int64) {
+		t.Helper()
+		fd, err := fs.Create(name)
+		must(err)
+		must(fd.Truncate(size))
+		fd.Close()
+	}
+
+	must(fs.MkdirAll("a/b", 0755))
+	must(fs.Mkdir("ignored", 0755))
+	create("a/file", 10)
+	create("a/b/file", 10)
+	create("ignored/file", 10)
+
+	// A budget of one stat per poll makes the passes span several calls.
+	w := newTestPollWatcher(fs, 1, pollMatcher{"ignored": true})
+
+	// The initial pass only records the state, and a pass without changes
+	// has nothing to report.
+
+	if evs := pollPass(t, w); len(evs) != 0 {
+		t.Fatalf("unexpected events on initial pass: %v", evs)
+	}
+	if evs := pollPass(t, w); len(evs) != 0 {
+		t.Fatalf("unexpected events without changes: %v", evs)
+	}
+
+	// Creations, modifications, removals and permission changes are
+	// detected, except for ignored items.
+
+	create("a/new", 1)
+	create("a/file", 20)
+	must(fs.Remove("a/b/file"))
+	must(fs.Chmod("a/b", 0700))
+	create("ignored/new", 1)
+
+	expected := []Event{
+		{filepath.Join("a", "b"), NonRemove},
+		{filepath.Join("a", "b", "file"), Remove},
+		{filepath.Join("a", "file"), NonRemove},
+		{filepath.Join("a", "new"), NonRemove},
+	}
+	if evs := pollPass(t, w); !reflect.DeepEqual(evs, expected) {
+		t.Errorf("got events %v, expected %v", evs, expected)
+	}
+
+	// Removing a directory forgets everything below it.
+
+	must(fs.RemoveAll("a/b"))
+	expected = []Event{{filepath.Join("a", "b"), Remove}}
+	if evs := pollPass(t, w); !reflect.DeepEqual(evs, expected) {
+		t.Errorf("got events %v, expected %v", evs, expected)
+	}
+	if _, ok := w.dirs[filepath.Join("a", "b")]; ok {
+		t.Error("removed directory should have been forgotten")
+	}
+}
+
+func TestPollWatchFilesystem(t *testing.T) {
+	fs := NewPollWatchFilesystem(newFakeFilesystem("/TestPollWatchFilesystem"), 10*time.Millisecond, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventChan, errChan, err := fs.Watch(".", pollMatcher{}, ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Give the initial pass time to complete before changing things.
+	time.Sleep(50 * time.Millisecond)
+
+	fd, err := fs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	select {
+	case ev := <-eventChan:
+		if ev != (Event{"file", NonRemove}) {
+			t.Errorf("unexpected event %v", ev)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	if _, _, err := fs.Watch("file", pollMatcher{}, ctx, false); err == nil {
+		t.Error("watching a file should fail")
+	}
+}
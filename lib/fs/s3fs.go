@@ -0,0 +1,905 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	minio "github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/credentials"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3MetaMtime     = "Mtime"
+	s3MetaMode      = "Mode"
+	s3MetaType      = "Type"
+	s3TypeSymlink   = "symlink"
+)
+
+var (
+	errS3UsageNotSupported = errors.New("usage information not available for object storage")
+	errS3NotEmpty          = errors.New("directory not empty")
+	errS3ReadOnly          = errors.New("file opened read only")
+)
+
+// s3Filesystem implements Filesystem on top of an S3 compatible object
+// store. The URI has the form
+//
+//	s3://[accesskey:secretkey@]host[:port]/bucket[/prefix][?region=...&insecure=true]
+//
+// where insecure selects plain HTTP instead of HTTPS. Without credentials
+// in the URI they are taken from the usual AWS_* and MINIO_* environment
+// variables or the ~/.aws/credentials file.
+//
+// Files are objects named by their slash separated path below the prefix.
+// Directories are zero sized marker objects with a trailing slash, but a
+// directory is also considered to exist when there are objects below it.
+// Modification times and permissions are stored as object metadata and
+// symlinks are objects containing the link target. As objects can't be
+// modified in place, files opened for writing are staged in a local
+// temporary file and uploaded when they are synced or closed.
+type s3Filesystem struct {
+	uri    string
+	bucket string
+	prefix string
+	client *minio.Client
+}
+
+func newS3Filesystem(uri string) (*s3Filesystem, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing host name")
+	}
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if parts[0] == "" {
+		return nil, errors.New("missing bucket name")
+	}
+	bucket := parts[0]
+	var prefix string
+	if len(parts) == 2 {
+		prefix = strings.Trim(path.Clean(parts[1]), "/")
+		if prefix == "." {
+			prefix = ""
+		}
+	}
+
+	params := u.Query()
+	region := params.Get("region")
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	secure := true
+	if insecure, err := strconv.ParseBool(params.Get("insecure")); err == nil {
+		secure = !insecure
+	}
+
+	var creds *credentials.Credentials
+	if u.User != nil {
+		secret, _ := u.User.Password()
+		creds = credentials.NewStaticV4(u.User.Username(), secret, "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+		})
+	}
+
+	client, err := minio.NewWithCredentials(u.Host, creds, secure, region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Present the URI without credentials and parameters; they're not
+	// part of the identity of the filesystem.
+	u.User = nil
+	u.RawQuery = ""
+
+	return &s3Filesystem{
+		uri:    u.String(),
+		bucket: bucket,
+		prefix: prefix,
+		client: client,
+	}, nil
+}
+
+// key returns the object key for the given relative name.
+func (f *s3Filesystem) key(name string) (string, error) {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return f.prefix, nil
+	}
+	return path.Join(f.prefix, filepath.ToSlash(name)), nil
+}
+
+// dirPrefix returns the prefix of all objects inside the directory with
+// the given key, which is also the key of its marker.
+func (f *s3Filesystem) dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (f *s3Filesystem) unkeyed(key string) string {
+	return filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(key, f.prefix), "/"))
+}
+
+// stat returns information about the object or directory at key, as well
+// as the key of the object carrying the metadata. The latter is empty for
+// directories that only exist implicitly.
+func (f *s3Filesystem) stat(key string) (s3FileInfo, string, error) {
+	name := path.Base(key)
+	if key == "" {
+		name = "."
+	}
+
+	if key != f.prefix {
+		info, err := f.client.StatObject(f.bucket, key, minio.StatObjectOptions{})
+		if err == nil {
+			return newS3FileInfo(name, info, false), key, nil
+		} else if !isS3NotExist(err) {
+			return s3FileInfo{}, "", err
+		}
+	}
+
+	dirKey := f.dirPrefix(key)
+	if dirKey != "" {
+		info, err := f.client.StatObject(f.bucket, dirKey, minio.StatObjectOptions{})
+		if err == nil {
+			return newS3FileInfo(name, info, true), dirKey, nil
+		} else if !isS3NotExist(err) {
+			return s3FileInfo{}, "", err
+		}
+	}
+
+	found, err := f.hasObjects(dirKey)
+	if err != nil {
+		return s3FileInfo{}, "", err
+	}
+	if !found && key != f.prefix {
+		return s3FileInfo{}, "", os.ErrNotExist
+	}
+	// The folder root always exists, and so does anything that has objects
+	// below it.
+	return s3FileInfo{name: name, mode: FileMode(os.ModeDir) | 0755}, "", nil
+}
+
+func (f *s3Filesystem) hasObjects(prefix string) (bool, error) {
+	done := make(chan struct{})
+	defer close(done)
+	for obj := range f.client.ListObjectsV2(f.bucket, prefix, false, done) {
+		if obj.Err != nil {
+			return false, obj.Err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// list calls fn for the key of every object below prefix, including
+// directory markers and, unless recursive, common prefixes.
+func (f *s3Filesystem) list(prefix string, recursive bool, fn func(key string) error) error {
+	done := make(chan struct{})
+	defer close(done)
+	for obj := range f.client.ListObjectsV2(f.bucket, prefix, recursive, done) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := fn(obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateMeta replaces the metadata of the object at metaKey with the
+// result of applying fn to the existing metadata. Objects can't be modified
+// in place, so this copies the object onto itself. Directories that exist
+// only implicitly get a marker to hold the metadata.
+func (f *s3Filesystem) updateMeta(key, metaKey string, info s3FileInfo, fn func(meta map[string]string)) error {
+	meta := s3Meta(info.mode, info.mtime)
+	fn(meta)
+	if metaKey == "" {
+		return f.putMarker(f.dirPrefix(key), meta)
+	}
+	dst, err := minio.NewDestinationInfo(f.bucket, metaKey, nil, meta)
+	if err != nil {
+		return err
+	}
+	return f.client.ComposeObject(dst, []minio.SourceInfo{minio.NewSourceInfo(f.bucket, metaKey, nil)})
+}
+
+func (f *s3Filesystem) putMarker(key string, meta map[string]string) error {
+	if key == "" {
+		return nil
+	}
+	_, err := f.client.PutObject(f.bucket, key, bytes.NewReader(nil), 0, minio.PutObjectOptions{UserMetadata: meta})
+	return err
+}
+
+func (f *s3Filesystem) Chmod(name string, mode FileMode) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	info, metaKey, err := f.stat(key)
+	if err != nil {
+		return err
+	}
+	return f.updateMeta(key, metaKey, info, func(meta map[string]string) {
+		meta[s3MetaMode] = strconv.FormatUint(uint64(mode&ModePerm), 8)
+	})
+}
+
+// Lchown is a noop, as object stores have no notion of file ownership.
+func (f *s3Filesystem) Lchown(name string, uid, gid int) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	_, _, err = f.stat(key)
+	return err
+}
+
+func (f *s3Filesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	info, metaKey, err := f.stat(key)
+	if err != nil {
+		return err
+	}
+	return f.updateMeta(key, metaKey, info, func(meta map[string]string) {
+		meta[s3MetaMtime] = strconv.FormatInt(mtime.UnixNano(), 10)
+	})
+}
+
+func (f *s3Filesystem) Create(name string) (File, error) {
+	return f.OpenFile(name, OptReadWrite|OptCreate|OptTruncate, 0666)
+}
+
+func (f *s3Filesystem) CreateSymlink(target, name string) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if _, _, err := f.stat(key); err == nil {
+		return os.ErrExist
+	} else if !IsNotExist(err) {
+		return err
+	}
+	target = filepath.ToSlash(target)
+	meta := s3Meta(ModeSymlink|0777, time.Now())
+	meta[s3MetaType] = s3TypeSymlink
+	_, err = f.client.PutObject(f.bucket, key, strings.NewReader(target), int64(len(target)), minio.PutObjectOptions{UserMetadata: meta})
+	return err
+}
+
+func (f *s3Filesystem) DirNames(name string) ([]string, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := f.stat(key)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+
+	prefix := f.dirPrefix(key)
+	var names []string
+	err = f.list(prefix, false, func(child string) error {
+		if child == prefix {
+			// Our own marker
+			return nil
+		}
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(child, prefix), "/"))
+		return nil
+	})
+	return names, err
+}
+
+func (f *s3Filesystem) Lstat(name string) (FileInfo, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := f.stat(key)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (f *s3Filesystem) Mkdir(name string, perm FileMode) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if _, _, err := f.stat(key); err == nil {
+		return os.ErrExist
+	} else if !IsNotExist(err) {
+		return err
+	}
+	if parent := path.Dir(key); parent != f.prefix && parent != "." {
+		if info, _, err := f.stat(parent); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return errors.New("not a directory")
+		}
+	}
+	return f.putMarker(f.dirPrefix(key), s3Meta(perm, time.Now()))
+}
+
+func (f *s3Filesystem) MkdirAll(name string, perm FileMode) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if key == f.prefix {
+		return nil
+	}
+	current := f.prefix
+	for _, comp := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(key, f.prefix), "/"), "/") {
+		current = path.Join(current, comp)
+		info, _, err := f.stat(current)
+		if err == nil {
+			if !info.IsDir() {
+				return errors.New("not a directory")
+			}
+			continue
+		} else if !IsNotExist(err) {
+			return err
+		}
+		if err := f.putMarker(f.dirPrefix(current), s3Meta(perm, time.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *s3Filesystem) Open(name string) (File, error) {
+	return f.OpenFile(name, OptReadOnly, 0)
+}
+
+func (f *s3Filesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := f.stat(key)
+	exists := err == nil
+	if err != nil && !IsNotExist(err) {
+		return nil, err
+	}
+	if exists && info.IsDir() {
+		return nil, errors.New("is a directory")
+	}
+
+	if flags&(OptWriteOnly|OptReadWrite) == 0 {
+		// Read only, which we can serve directly from the object.
+		if !exists {
+			return nil, os.ErrNotExist
+		}
+		obj, err := f.client.GetObject(f.bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &s3ObjectFile{Object: obj, name: name, info: info}, nil
+	}
+
+	switch {
+	case !exists && flags&OptCreate == 0:
+		return nil, os.ErrNotExist
+	case exists && flags&OptCreate != 0 && flags&OptExclusive != 0:
+		return nil, os.ErrExist
+	}
+
+	staging, err := ioutil.TempFile("", "syncthing-s3-")
+	if err != nil {
+		return nil, err
+	}
+	fd := &s3StagedFile{
+		File:  staging,
+		fs:    f,
+		name:  name,
+		key:   key,
+		mode:  mode & ModePerm,
+		dirty: !exists || flags&OptTruncate != 0,
+	}
+	if exists {
+		fd.mode = info.Mode() & ModePerm
+	}
+
+	if exists && flags&OptTruncate == 0 {
+		// Existing contents must be preserved, so stage them.
+		if err := f.download(key, staging); err != nil {
+			fd.discard()
+			return nil, err
+		}
+		whence := io.SeekStart
+		if flags&OptAppend != 0 {
+			whence = io.SeekEnd
+		}
+		if _, err := staging.Seek(0, whence); err != nil {
+			fd.discard()
+			return nil, err
+		}
+	}
+
+	return fd, nil
+}
+
+func (f *s3Filesystem) download(key string, w io.Writer) error {
+	obj, err := f.client.GetObject(f.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	_, err = io.Copy(w, obj)
+	return err
+}
+
+func (f *s3Filesystem) ReadSymlink(name string) (string, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return "", err
+	}
+	info, _, err := f.stat(key)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsSymlink() {
+		return "", errors.New("not a symlink")
+	}
+	var buf bytes.Buffer
+	if err := f.download(key, &buf); err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(buf.String()), nil
+}
+
+func (f *s3Filesystem) Remove(name string) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	info, metaKey, err := f.stat(key)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return f.client.RemoveObject(f.bucket, key)
+	}
+
+	prefix := f.dirPrefix(key)
+	err = f.list(prefix, false, func(child string) error {
+		if child != prefix {
+			return errS3NotEmpty
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if metaKey == "" {
+		return nil
+	}
+	return f.client.RemoveObject(f.bucket, metaKey)
+}
+
+func (f *s3Filesystem) RemoveAll(name string) error {
+	key, err := f.key(name)
+	if err != nil {
+		return err
+	}
+	if key != f.prefix {
+		if err := f.client.RemoveObject(f.bucket, key); err != nil && !isS3NotExist(err) {
+			return err
+		}
+	}
+	var keys []string
+	err = f.list(f.dirPrefix(key), true, func(child string) error {
+		keys = append(keys, child)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, child := range keys {
+		if err := f.client.RemoveObject(f.bucket, child); err != nil && !isS3NotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rename copies the object(s) to the new name and removes the old ones. It
+// is not atomic.
+func (f *s3Filesystem) Rename(oldname, newname string) error {
+	oldKey, err := f.key(oldname)
+	if err != nil {
+		return err
+	}
+	newKey, err := f.key(newname)
+	if err != nil {
+		return err
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	info, _, err := f.stat(oldKey)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		if err := f.copyObject(oldKey, newKey); err != nil {
+			return err
+		}
+		return f.client.RemoveObject(f.bucket, oldKey)
+	}
+
+	oldPrefix, newPrefix := f.dirPrefix(oldKey), f.dirPrefix(newKey)
+	if strings.HasPrefix(newPrefix, oldPrefix) {
+		return errors.New("cannot move a directory into itself")
+	}
+	var keys []string
+	err = f.list(oldPrefix, true, func(child string) error {
+		keys = append(keys, child)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, child := range keys {
+		if err := f.copyObject(child, newPrefix+strings.TrimPrefix(child, oldPrefix)); err != nil {
+			return err
+		}
+	}
+	for _, child := range keys {
+		if err := f.client.RemoveObject(f.bucket, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyObject does a server side copy, retaining the metadata.
+func (f *s3Filesystem) copyObject(src, dst string) error {
+	dstInfo, err := minio.NewDestinationInfo(f.bucket, dst, nil, nil)
+	if err != nil {
+		return err
+	}
+	return f.client.ComposeObject(dstInfo, []minio.SourceInfo{minio.NewSourceInfo(f.bucket, src, nil)})
+}
+
+// Stat is the same as Lstat, as symlinks are never followed.
+func (f *s3Filesystem) Stat(name string) (FileInfo, error) {
+	return f.Lstat(name)
+}
+
+func (f *s3Filesystem) SymlinksSupported() bool {
+	return true
+}
+
+func (f *s3Filesystem) Walk(name string, walkFn WalkFunc) error {
+	// implemented in WalkFilesystem
+	return errors.New("not implemented")
+}
+
+func (f *s3Filesystem) Watch(path string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
+	return nil, nil, ErrWatchNotSupported
+}
+
+func (f *s3Filesystem) Hide(name string) error {
+	_, err := f.key(name)
+	return err
+}
+
+func (f *s3Filesystem) Unhide(name string) error {
+	_, err := f.key(name)
+	return err
+}
+
+// Glob supports patterns only in the last path component.
+func (f *s3Filesystem) Glob(pattern string) ([]string, error) {
+	pattern, err := f.key(pattern)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(pattern)
+	if dir == "." {
+		dir = ""
+	}
+	prefix := f.dirPrefix(dir)
+	var matches []string
+	err = f.list(prefix, false, func(child string) error {
+		child = strings.TrimSuffix(child, "/")
+		if ok, err := path.Match(pattern, child); err != nil {
+			return err
+		} else if ok {
+			matches = append(matches, f.unkeyed(child))
+		}
+		return nil
+	})
+	return matches, err
+}
+
+func (f *s3Filesystem) Roots() ([]string, error) {
+	return []string{"/"}, nil
+}
+
+func (f *s3Filesystem) Usage(name string) (Usage, error) {
+	return Usage{}, errS3UsageNotSupported
+}
+
+func (f *s3Filesystem) Type() FilesystemType {
+	return FilesystemTypeS3
+}
+
+func (f *s3Filesystem) URI() string {
+	return f.uri
+}
+
+func (f *s3Filesystem) SameFile(fi1, fi2 FileInfo) bool {
+	// There are no inode numbers for objects, so compare what we have.
+	return fi1.Name() == fi2.Name() && fi1.ModTime().Equal(fi2.ModTime()) && fi1.Mode() == fi2.Mode() && fi1.Size() == fi2.Size()
+}
+
+// GetXattr returns ErrXattrsNotSupported, as do the other xattr methods;
+// object storage has no notion of extended attributes.
+func (f *s3Filesystem) GetXattr(name, attr string) ([]byte, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (f *s3Filesystem) SetXattr(name, attr string, value []byte) error {
+	return ErrXattrsNotSupported
+}
+
+func (f *s3Filesystem) ListXattr(name string) ([]string, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (f *s3Filesystem) RemoveXattr(name, attr string) error {
+	return ErrXattrsNotSupported
+}
+
+func isS3NotExist(err error) bool {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return true
+	}
+	return false
+}
+
+// s3Meta returns the object metadata for the given mode and mtime.
+func s3Meta(mode FileMode, mtime time.Time) map[string]string {
+	meta := map[string]string{
+		s3MetaMode: strconv.FormatUint(uint64(mode&ModePerm), 8),
+	}
+	if !mtime.IsZero() {
+		meta[s3MetaMtime] = strconv.FormatInt(mtime.UnixNano(), 10)
+	}
+	if mode&ModeSymlink != 0 {
+		meta[s3MetaType] = s3TypeSymlink
+	}
+	return meta
+}
+
+// s3ObjectFile is a file opened read only, served directly from the
+// object.
+type s3ObjectFile struct {
+	*minio.Object
+	name string
+	info s3FileInfo
+}
+
+func (f *s3ObjectFile) Name() string {
+	return f.name
+}
+
+func (f *s3ObjectFile) Write(p []byte) (int, error) {
+	return 0, errS3ReadOnly
+}
+
+func (f *s3ObjectFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, errS3ReadOnly
+}
+
+func (f *s3ObjectFile) Truncate(size int64) error {
+	return errS3ReadOnly
+}
+
+func (f *s3ObjectFile) Stat() (FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *s3ObjectFile) Sync() error {
+	return nil
+}
+
+// s3StagedFile is a file opened for writing. All operations happen on a
+// local staging file, which is uploaded on Sync and Close if it has been
+// modified.
+type s3StagedFile struct {
+	*os.File
+	fs    *s3Filesystem
+	name  string
+	key   string
+	mode  FileMode
+	mut   sync.Mutex
+	dirty bool
+}
+
+func (f *s3StagedFile) Name() string {
+	return f.name
+}
+
+func (f *s3StagedFile) Write(p []byte) (int, error) {
+	f.setDirty()
+	return f.File.Write(p)
+}
+
+func (f *s3StagedFile) WriteAt(p []byte, off int64) (int, error) {
+	f.setDirty()
+	return f.File.WriteAt(p, off)
+}
+
+func (f *s3StagedFile) Truncate(size int64) error {
+	f.setDirty()
+	return f.File.Truncate(size)
+}
+
+func (f *s3StagedFile) setDirty() {
+	f.mut.Lock()
+	f.dirty = true
+	f.mut.Unlock()
+}
+
+func (f *s3StagedFile) Stat() (FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return s3FileInfo{
+		name:  path.Base(filepath.ToSlash(f.name)),
+		size:  info.Size(),
+		mode:  f.mode,
+		mtime: info.ModTime(),
+	}, nil
+}
+
+// Sync uploads the staged contents, if modified.
+func (f *s3StagedFile) Sync() error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+	info, err := f.File.Stat()
+	if err != nil {
+		return err
+	}
+	// Reading through a section reader leaves the file offset alone.
+	r := io.NewSectionReader(f.File, 0, info.Size())
+	opts := minio.PutObjectOptions{
+		UserMetadata: s3Meta(f.mode, time.Now()),
+		ContentType:  "application/octet-stream",
+	}
+	if _, err := f.fs.client.PutObject(f.fs.bucket, f.key, r, info.Size(), opts); err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+func (f *s3StagedFile) Close() error {
+	err := f.Sync()
+	f.discard()
+	return err
+}
+
+func (f *s3StagedFile) discard() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+// s3FileInfo implements the fs.FileInfo interface from object metadata.
+type s3FileInfo struct {
+	name  string
+	size  int64
+	mode  FileMode
+	mtime time.Time
+}
+
+func newS3FileInfo(name string, info minio.ObjectInfo, dir bool) s3FileInfo {
+	fi := s3FileInfo{
+		name:  name,
+		size:  info.Size,
+		mode:  0644,
+		mtime: info.LastModified,
+	}
+	if dir {
+		fi.size = 0
+		fi.mode = FileMode(os.ModeDir) | 0755
+	}
+	if mode, err := strconv.ParseUint(info.UserMetadata[s3MetaMode], 8, 32); err == nil {
+		fi.mode = fi.mode&^ModePerm | FileMode(mode)&ModePerm
+	}
+	if nanos, err := strconv.ParseInt(info.UserMetadata[s3MetaMtime], 10, 64); err == nil {
+		fi.mtime = time.Unix(0, nanos)
+	}
+	if !dir && info.UserMetadata[s3MetaType] == s3TypeSymlink {
+		fi.mode = ModeSymlink | fi.mode&ModePerm
+	}
+	return fi
+}
+
+func (e s3FileInfo) Name() string {
+	return e.name
+}
+
+func (e s3FileInfo) Mode() FileMode {
+	return e.mode
+}
+
+func (e s3FileInfo) Size() int64 {
+	return e.size
+}
+
+func (e s3FileInfo) ModTime() time.Time {
+	return e.mtime
+}
+
+func (e s3FileInfo) IsDir() bool {
+	return e.mode&FileMode(os.ModeDir) != 0
+}
+
+func (e s3FileInfo) IsRegular() bool {
+	return e.mode&ModeType == 0
+}
+
+func (e s3FileInfo) IsSymlink() bool {
+	return e.mode&ModeSymlink != 0
+}
+
+func (e s3FileInfo) Owner() int {
+	return -1
+}
+
+func (e s3FileInfo) Group() int {
+	return -1
+}
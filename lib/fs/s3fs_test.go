@@ -0,0 +1,663 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand in for an S3 server, implementing just
+// enough of the protocol for the s3Filesystem: path style object get, head,
+// put, copy and delete as well as version 2 listings, for a single bucket.
+// Signatures are not verified.
+type fakeS3 struct {
+	bucket  string
+	mut     sync.Mutex
+	objects map[string]fakeS3Object
+}
+
+type fakeS3Object struct {
+	data     []byte
+	meta     http.Header
+	modified time.Time
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeS3Object),
+	}
+}
+
+func (s *fakeS3) put(key string, data []byte, meta map[string]string) {
+	hdr := make(http.Header)
+	for k, v := range meta {
+		hdr.Set("X-Amz-Meta-"+k, v)
+	}
+	s.mut.Lock()
+	s.objects[key] = fakeS3Object{data: data, meta: hdr, modified: time.Now()}
+	s.mut.Unlock()
+}
+
+func (s *fakeS3) get(key string) (fakeS3Object, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	obj, ok := s.objects[key]
+	return obj, ok
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.bucket {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r.URL.Query())
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.serveObject(w, r, key)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, key)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, key)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (s *fakeS3) serveObject(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := s.objects[key]
+	if !ok {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	for k, v := range obj.meta {
+		w.Header()[k] = v
+	}
+	sum := md5.Sum(obj.data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	data := obj.data
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		var start, end int64
+		bounds := strings.SplitN(strings.TrimPrefix(rng, "bytes="), "-", 2)
+		start, _ = strconv.ParseInt(bounds[0], 10, 64)
+		end = int64(len(data)) - 1
+		if bounds[1] != "" {
+			end, _ = strconv.ParseInt(bounds[1], 10, 64)
+		}
+		if end >= int64(len(data)) {
+			end = int64(len(data)) - 1
+		}
+		if start > end {
+			s.error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (s *fakeS3) putObject(w http.ResponseWriter, r *http.Request, key string) {
+	var data []byte
+	var err error
+	if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+		data, err = decodeAWSChunked(r.Body)
+	} else {
+		data, err = ioutil.ReadAll(r.Body)
+	}
+	if err != nil {
+		s.error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	s.objects[key] = fakeS3Object{data: data, meta: userMetaHeaders(r.Header), modified: time.Now()}
+	sum := md5.Sum(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *fakeS3) copyObject(w http.ResponseWriter, r *http.Request, key string) {
+	src, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		s.error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	obj, ok := s.objects[strings.TrimPrefix(strings.TrimPrefix(src, "/"), s.bucket+"/")]
+	if !ok {
+		s.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	meta := obj.meta
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		meta = userMetaHeaders(r.Header)
+	}
+	now := time.Now()
+	s.objects[key] = fakeS3Object{data: obj.data, meta: meta, modified: now}
+
+	sum := md5.Sum(obj.data)
+	fmt.Fprintf(w, "<CopyObjectResult><ETag>\"%s\"</ETag><LastModified>%s</LastModified></CopyObjectResult>", hex.EncodeToString(sum[:]), now.UTC().Format(time.RFC3339))
+}
+
+type fakeS3ListResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string
+	MaxKeys               int
+	KeyCount              int
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []fakeS3ListEntry
+	CommonPrefixes        []fakeS3ListPrefix
+}
+
+type fakeS3ListEntry struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+type fakeS3ListPrefix struct {
+	Prefix string
+}
+
+// fakeS3ListPageSize is small, to exercise pagination in the client.
+const fakeS3ListPageSize = 3
+
+func (s *fakeS3) list(w http.ResponseWriter, params url.Values) {
+	if params.Get("list-type") != "2" {
+		s.error(w, http.StatusNotImplemented, "NotImplemented")
+		return
+	}
+	prefix := params.Get("prefix")
+	delimiter := params.Get("delimiter")
+	after := params.Get("continuation-token")
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := fakeS3ListResult{
+		Name:      s.bucket,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   fakeS3ListPageSize,
+	}
+	var last string
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+		if res.KeyCount == fakeS3ListPageSize {
+			res.IsTruncated = true
+			res.NextContinuationToken = last
+			break
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if idx := strings.Index(rest, delimiter); delimiter != "" && idx >= 0 {
+			common := prefix + rest[:idx+len(delimiter)]
+			if !seen[common] {
+				seen[common] = true
+				res.CommonPrefixes = append(res.CommonPrefixes, fakeS3ListPrefix{common})
+				res.KeyCount++
+			}
+			// Skip everything below the common prefix.
+			last = common + "\xff"
+			continue
+		}
+		obj := s.objects[key]
+		res.Contents = append(res.Contents, fakeS3ListEntry{
+			Key:          key,
+			LastModified: obj.modified.UTC().Format(time.RFC3339),
+			Size:         len(obj.data),
+		})
+		res.KeyCount++
+		last = key
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+func userMetaHeaders(hdr http.Header) http.Header {
+	meta := make(http.Header)
+	for k, v := range hdr {
+		if strings.HasPrefix(k, "X-Amz-Meta-") {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+// decodeAWSChunked decodes a body sent with the streaming signature, which
+// consists of chunks with a hex size and signature header line each.
+func decodeAWSChunked(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var data []byte
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(line), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2) // including the trailing CRLF
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func setupS3(t *testing.T) (Filesystem, *fakeS3, func()) {
+	t.Helper()
+	srv := newFakeS3("bucket")
+	hs := httptest.NewServer(srv)
+	fs := NewFilesystem(FilesystemTypeS3, "s3://access:secret@"+strings.TrimPrefix(hs.URL, "http://")+"/bucket/some/prefix?insecure=true")
+	if fs.Type() != FilesystemTypeS3 {
+		t.Fatal("unexpected filesystem type", fs.Type())
+	}
+	return fs, srv, hs.Close
+}
+
+func TestS3Files(t *testing.T) {
+	fs, srv, cleanup := setupS3(t)
+	defer cleanup()
+
+	if err := fs.MkdirAll(filepath.Join("a", "b"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.get("some/prefix/a/b/"); !ok {
+		t.Fatal("directory marker not created")
+	}
+	info, err := fs.Lstat("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() || info.Mode()&ModePerm != 0750 {
+		t.Errorf("unexpected directory info: dir %v, mode %v", info.IsDir(), info.Mode())
+	}
+
+	fd, err := fs.OpenFile(filepath.Join("a", "b", "file"), OptReadWrite|OptCreate, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.get("some/prefix/a/b/file"); ok {
+		t.Error("file uploaded before sync or close")
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	obj, ok := srv.get("some/prefix/a/b/file")
+	if !ok {
+		t.Fatal("file not uploaded on close")
+	}
+	if string(obj.data) != "HELLO world" {
+		t.Errorf("object contains %q, expected %q", obj.data, "HELLO world")
+	}
+
+	info, err = fs.Lstat(filepath.Join("a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsRegular() || info.Size() != 11 || info.Name() != "file" || info.Mode()&ModePerm != 0640 {
+		t.Errorf("unexpected file info: regular %v, size %d, name %q, mode %v", info.IsRegular(), info.Size(), info.Name(), info.Mode())
+	}
+
+	mtime := time.Date(2015, 3, 4, 5, 6, 7, 8, time.UTC)
+	if err := fs.Chtimes(filepath.Join("a", "b", "file"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Chmod(filepath.Join("a", "b", "file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err = fs.Stat(filepath.Join("a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime is %v, expected %v", info.ModTime(), mtime)
+	}
+	if info.Mode()&ModePerm != 0600 {
+		t.Errorf("mode is %v, expected 0600", info.Mode())
+	}
+
+	fd, err = fs.Open(filepath.Join("a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := fd.ReadAt(buf, 6); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "world" {
+		t.Errorf("ReadAt returned %q, expected %q", buf, "world")
+	}
+	if _, err := fd.Write([]byte("nope")); err == nil {
+		t.Error("writing to a read only file should fail")
+	}
+	if info, err := fd.Stat(); err != nil || info.Size() != 11 {
+		t.Errorf("unexpected stat result %v, %v", info, err)
+	}
+	fd.Close()
+
+	// Reopening for writing stages the existing contents.
+	fd, err = fs.OpenFile(filepath.Join("a", "b", "file"), OptWriteOnly|OptAppend, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if obj, _ := srv.get("some/prefix/a/b/file"); string(obj.data) != "HELLO world!" {
+		t.Errorf("object contains %q, expected %q", obj.data, "HELLO world!")
+	}
+
+	if _, err := fs.Open("nonexistent"); !IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if _, err := fs.OpenFile("nonexistent", OptReadWrite, 0); !IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if _, err := fs.OpenFile(filepath.Join("a", "b", "file"), OptReadWrite|OptCreate|OptExclusive, 0644); !IsExist(err) {
+		t.Errorf("expected an exist error, got %v", err)
+	}
+	if _, err := fs.Lstat(filepath.Join("..", "escape")); err != ErrNotRelative {
+		t.Errorf("expected %v, got %v", ErrNotRelative, err)
+	}
+}
+
+func TestS3ImplicitDirectories(t *testing.T) {
+	fs, srv, cleanup := setupS3(t)
+	defer cleanup()
+
+	// Objects put there by something else, without directory markers.
+	srv.put("some/prefix/x/y/file", []byte("data"), nil)
+	srv.put("some/prefix/x/other", []byte("data"), nil)
+	srv.put("some/prefixfile", []byte("not ours"), nil)
+
+	info, err := fs.Lstat(filepath.Join("x", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Error("expected an implicit directory")
+	}
+	info, err = fs.Lstat(filepath.Join("x", "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsRegular() || info.Mode()&ModePerm != 0644 || info.Size() != 4 {
+		t.Errorf("unexpected defaults for an object without metadata: mode %v, size %d", info.Mode(), info.Size())
+	}
+
+	names, err := fs.DirNames(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "x" {
+		t.Errorf("unexpected dir names %v", names)
+	}
+
+	mtime := time.Date(2015, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := fs.Chtimes("x", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.get("some/prefix/x/"); !ok {
+		t.Error("expected a marker holding the metadata")
+	}
+	if info, err := fs.Lstat("x"); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("unexpected info %v, %v", info, err)
+	}
+
+	if err := fs.Remove("x"); err != errS3NotEmpty {
+		t.Errorf("expected %v, got %v", errS3NotEmpty, err)
+	}
+	if err := fs.Remove(filepath.Join("x", "y", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat(filepath.Join("x", "y")); !IsNotExist(err) {
+		t.Errorf("implicit directory should be gone with its contents, got %v", err)
+	}
+	if err := fs.RemoveAll("x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.get("some/prefixfile"); !ok {
+		t.Error("object outside the folder removed")
+	}
+	if info, err := fs.Lstat("."); err != nil || !info.IsDir() {
+		t.Errorf("the root should always exist, got %v, %v", info, err)
+	}
+}
+
+func TestS3Rename(t *testing.T) {
+	fs, srv, cleanup := setupS3(t)
+	defer cleanup()
+
+	mtime := time.Date(2015, 3, 4, 5, 6, 7, 0, time.UTC)
+	srv.put("some/prefix/src", []byte("src"), s3Meta(0600, mtime))
+	srv.put("some/prefix/dst", []byte("dst"), nil)
+
+	if err := fs.Rename("src", "dst"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("src"); !IsNotExist(err) {
+		t.Errorf("source still exists after rename: %v", err)
+	}
+	if obj, _ := srv.get("some/prefix/dst"); string(obj.data) != "src" {
+		t.Errorf("unexpected target contents %q", obj.data)
+	}
+	info, err := fs.Lstat("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) || info.Mode()&ModePerm != 0600 {
+		t.Errorf("metadata not retained: mtime %v, mode %v", info.ModTime(), info.Mode())
+	}
+
+	if err := fs.MkdirAll(filepath.Join("d1", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		srv.put(fmt.Sprintf("some/prefix/d1/sub/f%d", i), []byte("data"), nil)
+	}
+	if err := fs.Rename("d1", "d2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("d1"); !IsNotExist(err) {
+		t.Errorf("source directory still exists after rename: %v", err)
+	}
+	names, err := fs.DirNames(filepath.Join("d2", "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 5 {
+		t.Errorf("unexpected names after directory rename: %v", names)
+	}
+	if err := fs.Rename("d2", filepath.Join("d2", "sub", "inner")); err == nil {
+		t.Error("moving a directory into itself should fail")
+	}
+}
+
+func TestS3Symlinks(t *testing.T) {
+	fs, _, cleanup := setupS3(t)
+	defer cleanup()
+
+	if err := fs.CreateSymlink(filepath.Join("some", "target"), "link"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fs.Lstat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsSymlink() || info.IsRegular() {
+		t.Error("expected a symlink")
+	}
+	target, err := fs.ReadSymlink("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != filepath.Join("some", "target") {
+		t.Errorf("read link target %q, expected %q", target, filepath.Join("some", "target"))
+	}
+	if err := fs.CreateSymlink("other", "link"); !IsExist(err) {
+		t.Errorf("expected an exist error, got %v", err)
+	}
+}
+
+func TestS3WalkAndGlob(t *testing.T) {
+	fs, srv, cleanup := setupS3(t)
+	defer cleanup()
+
+	for _, key := range []string{"a/b/c/f1", "a/f2", "a/f3.txt", "f4"} {
+		srv.put("some/prefix/"+key, nil, nil)
+	}
+	if err := fs.MkdirAll("e", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	err := fs.Walk(".", func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, filepath.ToSlash(path))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(walked)
+	expected := []string{".", "a", "a/b", "a/b/c", "a/b/c/f1", "a/f2", "a/f3.txt", "e", "f4"}
+	if strings.Join(walked, ",") != strings.Join(expected, ",") {
+		t.Errorf("walked %v, expected %v", walked, expected)
+	}
+
+	matches, err := fs.Glob(filepath.Join("a", "f*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	if len(matches) != 2 || matches[0] != filepath.Join("a", "f2") || matches[1] != filepath.Join("a", "f3.txt") {
+		t.Errorf("unexpected glob matches %v", matches)
+	}
+}
+
+func TestS3UsageAndWatch(t *testing.T) {
+	fs, _, cleanup := setupS3(t)
+	defer cleanup()
+
+	if _, err := fs.Usage("."); err != errS3UsageNotSupported {
+		t.Errorf("expected %v, got %v", errS3UsageNotSupported, err)
+	}
+	if _, _, err := fs.Watch(".", nil, nil, false); err != ErrWatchNotSupported {
+		t.Errorf("expected %v, got %v", ErrWatchNotSupported, err)
+	}
+}
+
+func TestNewS3Filesystem(t *testing.T) {
+	cases := []struct {
+		uri      string
+		ok       bool
+		expected string
+		bucket   string
+		prefix   string
+	}{
+		{"s3://host/bucket", true, "s3://host/bucket", "bucket", ""},
+		{"s3://key:secret@host:9000/bucket/some/prefix/?insecure=true", true, "s3://host:9000/bucket/some/prefix/", "bucket", "some/prefix"},
+		{"s3://host/bucket/a/../b", true, "s3://host/bucket/a/../b", "bucket", "b"},
+		{"s3://host/", false, "", "", ""},
+		{"s3:///bucket", false, "", "", ""},
+		{"sftp://host/bucket", false, "", "", ""},
+	}
+
+	for _, tc := range cases {
+		fs, err := newS3Filesystem(tc.uri)
+		if tc.ok != (err == nil) {
+			t.Errorf("%s: unexpected error state %v", tc.uri, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if fs.URI() != tc.expected {
+			t.Errorf("%s: URI is %q, expected %q", tc.uri, fs.URI(), tc.expected)
+		}
+		if fs.bucket != tc.bucket || fs.prefix != tc.prefix {
+			t.Errorf("%s: bucket and prefix are %q and %q, expected %q and %q", tc.uri, fs.bucket, fs.prefix, tc.bucket, tc.prefix)
+		}
+	}
+}
@@ -0,0 +1,738 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sftpDialTimeout = 30 * time.Second
+
+var errSFTPNoKeys = errors.New("no usable private key found")
+
+// sftpFilesystem implements Filesystem on top of an SFTP session to a
+// remote host. The URI has the form
+//
+//	sftp://user@host[:port]/path/to/folder[?key=...&knownhosts=...]
+//
+// where the path is absolute on the remote host, unless it starts with
+// "/~/" in which case it's relative to the remote user's home directory.
+// Authentication is key based; the private key is read from the "key"
+// parameter or the usual ~/.ssh/id_* files. The host key is verified
+// against the "knownhosts" parameter or ~/.ssh/known_hosts.
+//
+// The SSH connection is established on first use and shared between all
+// sftpFilesystems for the same user, host and key. It is reestablished
+// transparently if it is lost.
+type sftpFilesystem struct {
+	uri  string
+	root string
+	conn *sftpConn
+}
+
+func newSFTPFilesystem(uri string) (*sftpFilesystem, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "sftp" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("missing user name")
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("missing host name")
+	}
+
+	root := u.Path
+	if strings.HasPrefix(root, "/~/") {
+		// Relative to the home directory, which is where the server
+		// resolves relative paths.
+		root = strings.TrimPrefix(root, "/~/")
+	}
+	root = path.Clean(root)
+	if root == "" || root == "/" || root == "." {
+		return nil, errors.New("refusing to use the remote root directory")
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	params := u.Query()
+	keyFiles := []string{params.Get("key")}
+	if keyFiles[0] == "" {
+		keyFiles = defaultSSHKeyFiles()
+	}
+	knownHostsFile := params.Get("knownhosts")
+	if knownHostsFile == "" {
+		knownHostsFile = "~/.ssh/known_hosts"
+	}
+	for i := range keyFiles {
+		if exp, err := ExpandTilde(keyFiles[i]); err == nil {
+			keyFiles[i] = exp
+		}
+	}
+	if exp, err := ExpandTilde(knownHostsFile); err == nil {
+		knownHostsFile = exp
+	}
+
+	// Present the URI without the query parameters; they're not part of
+	// the identity of the filesystem.
+	u.RawQuery = ""
+
+	return &sftpFilesystem{
+		uri:  u.String(),
+		root: root,
+		conn: getSFTPConn(u.User.Username(), addr, keyFiles, knownHostsFile),
+	}, nil
+}
+
+func defaultSSHKeyFiles() []string {
+	var files []string
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		files = append(files, filepath.Join("~", ".ssh", name))
+	}
+	return files
+}
+
+// rooted returns the full remote path for the given relative name, making
+// sure it doesn't escape the root.
+func (f *sftpFilesystem) rooted(rel string) (string, error) {
+	rel, err := Canonicalize(rel)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(f.root, filepath.ToSlash(rel)), nil
+}
+
+// unrooted returns the relative name for the given full remote path. Paths
+// outside of the root, including siblings sharing the root as a prefix, are
+// returned unchanged.
+func (f *sftpFilesystem) unrooted(p string) string {
+	if p == f.root {
+		return ""
+	}
+	if !strings.HasPrefix(p, f.root+"/") {
+		return filepath.FromSlash(p)
+	}
+	return filepath.FromSlash(p[len(f.root)+1:])
+}
+
+func (f *sftpFilesystem) Chmod(name string, mode FileMode) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return c.Chmod(name, os.FileMode(mode))
+}
+
+// Lchown changes the owner of the file. The SFTP protocol has no way to
+// avoid following symlinks, so this is really a Chown.
+func (f *sftpFilesystem) Lchown(name string, uid, gid int) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return c.Chown(name, uid, gid)
+}
+
+func (f *sftpFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return c.Chtimes(name, atime, mtime)
+}
+
+func (f *sftpFilesystem) Create(name string) (File, error) {
+	return f.OpenFile(name, OptReadWrite|OptCreate|OptTruncate, 0666)
+}
+
+func (f *sftpFilesystem) CreateSymlink(target, name string) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return c.Symlink(filepath.ToSlash(target), name)
+}
+
+func (f *sftpFilesystem) DirNames(name string) ([]string, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := c.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, nil
+}
+
+func (f *sftpFilesystem) Lstat(name string) (FileInfo, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	return sftpFileInfo{info}, nil
+}
+
+func (f *sftpFilesystem) Mkdir(name string, perm FileMode) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	if err := c.Mkdir(name); err != nil {
+		return err
+	}
+	return c.Chmod(name, os.FileMode(perm))
+}
+
+func (f *sftpFilesystem) MkdirAll(name string, perm FileMode) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return sftpMkdirAll(c, name, os.FileMode(perm))
+}
+
+// sftpMkdirAll is like sftp.Client.MkdirAll but applies the permissions to
+// the directories it creates, as Mkdir over SFTP does not take any.
+func sftpMkdirAll(c *sftp.Client, name string, perm os.FileMode) error {
+	info, err := c.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	if parent := path.Dir(name); parent != name && parent != "/" && parent != "." {
+		if err := sftpMkdirAll(c, parent, perm); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mkdir(name); err != nil {
+		// Somebody else might have created it in the meantime.
+		if info, lerr := c.Lstat(name); lerr == nil && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return c.Chmod(name, perm)
+}
+
+func (f *sftpFilesystem) Open(name string) (File, error) {
+	return f.OpenFile(name, OptReadOnly, 0)
+}
+
+func (f *sftpFilesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	rootedName, err := f.rooted(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return nil, err
+	}
+
+	// Files are created with the server's default permissions, so we need
+	// to know whether we are creating one in order to apply mode.
+	created := false
+	if flags&OptCreate != 0 {
+		if _, err := c.Lstat(rootedName); IsNotExist(err) {
+			created = true
+		}
+	}
+
+	fd, err := c.OpenFile(rootedName, flags)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := c.Chmod(rootedName, os.FileMode(mode)); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+	return &sftpFile{File: fd, name: name}, nil
+}
+
+func (f *sftpFilesystem) ReadSymlink(name string) (string, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return "", err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return "", err
+	}
+	target, err := c.ReadLink(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(target), nil
+}
+
+func (f *sftpFilesystem) Remove(name string) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return c.Remove(name)
+}
+
+func (f *sftpFilesystem) RemoveAll(name string) error {
+	name, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	return sftpRemoveAll(c, name)
+}
+
+func sftpRemoveAll(c *sftp.Client, name string) error {
+	info, err := c.Lstat(name)
+	if IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		children, err := c.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := sftpRemoveAll(c, path.Join(name, child.Name())); err != nil {
+				return err
+			}
+		}
+		return c.RemoveDirectory(name)
+	}
+	return c.Remove(name)
+}
+
+func (f *sftpFilesystem) Rename(oldname, newname string) error {
+	oldname, err := f.rooted(oldname)
+	if err != nil {
+		return err
+	}
+	newname, err = f.rooted(newname)
+	if err != nil {
+		return err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return err
+	}
+	// Plain SFTP renames fail when the target exists, the OpenSSH
+	// extension has the POSIX semantics we want.
+	return c.PosixRename(oldname, newname)
+}
+
+func (f *sftpFilesystem) Stat(name string) (FileInfo, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return sftpFileInfo{info}, nil
+}
+
+func (f *sftpFilesystem) SymlinksSupported() bool {
+	return true
+}
+
+func (f *sftpFilesystem) Walk(name string, walkFn WalkFunc) error {
+	// implemented in WalkFilesystem
+	return errors.New("not implemented")
+}
+
+func (f *sftpFilesystem) Watch(path string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
+	return nil, nil, ErrWatchNotSupported
+}
+
+func (f *sftpFilesystem) Hide(name string) error {
+	_, err := f.rooted(name)
+	return err
+}
+
+func (f *sftpFilesystem) Unhide(name string) error {
+	_, err := f.rooted(name)
+	return err
+}
+
+func (f *sftpFilesystem) Glob(pattern string) ([]string, error) {
+	pattern, err := f.rooted(pattern)
+	if err != nil {
+		return nil, err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return nil, err
+	}
+	files, err := c.Glob(pattern)
+	unrooted := make([]string, len(files))
+	for i := range files {
+		unrooted[i] = f.unrooted(files[i])
+	}
+	return unrooted, err
+}
+
+func (f *sftpFilesystem) Roots() ([]string, error) {
+	return []string{"/"}, nil
+}
+
+func (f *sftpFilesystem) Usage(name string) (Usage, error) {
+	name, err := f.rooted(name)
+	if err != nil {
+		return Usage{}, err
+	}
+	c, err := f.conn.client()
+	if err != nil {
+		return Usage{}, err
+	}
+	vfs, err := c.StatVFS(name)
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{
+		Free:  int64(vfs.FreeSpace()),
+		Total: int64(vfs.TotalSpace()),
+	}, nil
+}
+
+func (f *sftpFilesystem) Type() FilesystemType {
+	return FilesystemTypeSFTP
+}
+
+func (f *sftpFilesystem) URI() string {
+	return f.uri
+}
+
+func (f *sftpFilesystem) SameFile(fi1, fi2 FileInfo) bool {
+	// There are no inode numbers over SFTP, so compare what we have.
+	return fi1.Name() == fi2.Name() && fi1.ModTime().Equal(fi2.ModTime()) && fi1.Mode() == fi2.Mode() && fi1.Size() == fi2.Size() && fi1.Owner() == fi2.Owner() && fi1.Group() == fi2.Group()
+}
+
+// GetXattr returns ErrXattrsNotSupported, as do the other xattr methods;
+// the SFTP protocol has no means of accessing extended attributes.
+func (f *sftpFilesystem) GetXattr(name, attr string) ([]byte, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (f *sftpFilesystem) SetXattr(name, attr string, value []byte) error {
+	return ErrXattrsNotSupported
+}
+
+func (f *sftpFilesystem) ListXattr(name string) ([]string, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (f *sftpFilesystem) RemoveXattr(name, attr string) error {
+	return ErrXattrsNotSupported
+}
+
+// sftpFile implements the fs.File interface on top of an sftp.File. The
+// latter only has a single file offset, so the positional reads and writes
+// are serialized with everything else.
+type sftpFile struct {
+	*sftp.File
+	name string
+	mut  sync.Mutex
+}
+
+func (f *sftpFile) Name() string {
+	return f.name
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.File.Read(p)
+}
+
+func (f *sftpFile) Write(p []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.File.Write(p)
+}
+
+func (f *sftpFile) Seek(offset int64, whence int) (int64, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.File.Seek(offset, whence)
+}
+
+func (f *sftpFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	restore, err := f.seekTemporarily(off)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+
+	n, err := io.ReadFull(f.File, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *sftpFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	restore, err := f.seekTemporarily(off)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+
+	return f.File.Write(p)
+}
+
+// seekTemporarily moves the file offset to off and returns a function that
+// moves it back to where it was.
+func (f *sftpFile) seekTemporarily(off int64) (func(), error) {
+	cur, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.File.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return func() { f.File.Seek(cur, io.SeekStart) }, nil
+}
+
+func (f *sftpFile) Stat() (FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return sftpFileInfo{info}, nil
+}
+
+// Sync is a noop, as there is no fsync in the SFTP protocol version we
+// speak. The server writes the data to disk when the file is closed.
+func (f *sftpFile) Sync() error {
+	return nil
+}
+
+// sftpFileInfo implements the fs.FileInfo interface on top of the
+// os.FileInfo returned by the sftp package.
+type sftpFileInfo struct {
+	os.FileInfo
+}
+
+func (e sftpFileInfo) Mode() FileMode {
+	return FileMode(e.FileInfo.Mode())
+}
+
+func (e sftpFileInfo) IsSymlink() bool {
+	return e.Mode()&ModeSymlink != 0
+}
+
+func (e sftpFileInfo) IsRegular() bool {
+	return e.Mode()&ModeType == 0
+}
+
+func (e sftpFileInfo) Owner() int {
+	if st, ok := e.Sys().(*sftp.FileStat); ok {
+		return int(st.UID)
+	}
+	return -1
+}
+
+func (e sftpFileInfo) Group() int {
+	if st, ok := e.Sys().(*sftp.FileStat); ok {
+		return int(st.GID)
+	}
+	return -1
+}
+
+var (
+	sftpConnsMut sync.Mutex
+	sftpConns    = make(map[string]*sftpConn)
+)
+
+// sftpConn is a lazily established, shared SFTP session.
+type sftpConn struct {
+	user           string
+	addr           string
+	keyFiles       []string
+	knownHostsFile string
+
+	mut  sync.Mutex
+	sftp *sftp.Client
+}
+
+func getSFTPConn(user, addr string, keyFiles []string, knownHostsFile string) *sftpConn {
+	key := fmt.Sprintf("%s@%s|%s|%s", user, addr, strings.Join(keyFiles, ","), knownHostsFile)
+
+	sftpConnsMut.Lock()
+	defer sftpConnsMut.Unlock()
+
+	if conn, ok := sftpConns[key]; ok {
+		return conn
+	}
+	conn := &sftpConn{
+		user:           user,
+		addr:           addr,
+		keyFiles:       keyFiles,
+		knownHostsFile: knownHostsFile,
+	}
+	sftpConns[key] = conn
+	return conn
+}
+
+// client returns the current SFTP client, connecting if necessary.
+func (c *sftpConn) client() (*sftp.Client, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.sftp != nil {
+		return c.sftp, nil
+	}
+
+	cfg, err := c.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	sshConn, err := ssh.Dial("tcp", c.addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(sshConn)
+	if err != nil {
+		sshConn.Close()
+		return nil, err
+	}
+	l.Debugf("sftp: connected to %s@%s", c.user, c.addr)
+
+	c.sftp = client
+	go func() {
+		// Forget about the client once the connection is gone, so that
+		// the next call reconnects.
+		err := client.Wait()
+		l.Debugf("sftp: connection to %s@%s closed: %v", c.user, c.addr, err)
+		c.mut.Lock()
+		if c.sftp == client {
+			c.sftp = nil
+		}
+		c.mut.Unlock()
+	}()
+
+	return client, nil
+}
+
+func (c *sftpConn) clientConfig() (*ssh.ClientConfig, error) {
+	var signers []ssh.Signer
+	for _, file := range c.keyFiles {
+		bs, err := ioutil.ReadFile(file)
+		if err != nil {
+			l.Debugf("sftp: reading key %s: %v", file, err)
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(bs)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %s: %w", file, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, errSFTPNoKeys
+	}
+
+	hostKeyCallback, err := knownhosts.New(c.knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts: %w", err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sftpDialTimeout,
+	}, nil
+}
@@ -0,0 +1,445 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// setupSFTP starts an in-process SSH server with the sftp subsystem and
+// returns an sftp filesystem rooted at a fresh temporary directory, the
+// local path of that directory and a function to clean up.
+func setupSFTP(t *testing.T) (Filesystem, string, func()) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the test server serves paths of the local filesystem")
+	}
+
+	tmp, err := ioutil.TempDir("", "sftpfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(tmp, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hostSigner := newTestSSHSigner(t, "")
+	clientKeyFile := filepath.Join(tmp, "id_ecdsa")
+	clientSigner := newTestSSHSigner(t, clientKeyFile)
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "syncthing" && bytes.Equal(key.Marshal(), clientSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized")
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveTestSFTP(ln, cfg)
+
+	knownHostsFile := filepath.Join(tmp, "known_hosts")
+	line := knownhosts.Line([]string{ln.Addr().String()}, hostSigner.PublicKey())
+	if err := ioutil.WriteFile(knownHostsFile, []byte(line+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	uri := "sftp://syncthing@" + ln.Addr().String() + filepath.ToSlash(root) + "?key=" + clientKeyFile + "&knownhosts=" + knownHostsFile
+	fs := NewFilesystem(FilesystemTypeSFTP, uri)
+	if fs.Type() != FilesystemTypeSFTP {
+		t.Fatal("unexpected filesystem type", fs.Type())
+	}
+
+	return fs, root, func() {
+		ln.Close()
+		os.RemoveAll(tmp)
+	}
+}
+
+// newTestSSHSigner generates a key, optionally writing it to the given file.
+func newTestSSHSigner(t *testing.T, file string) ssh.Signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "" {
+		bs, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pemBs := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: bs})
+		if err := ioutil.WriteFile(file, pemBs, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func serveTestSFTP(ln net.Listener, cfg *ssh.ServerConfig) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			for newChannel := range chans {
+				if newChannel.ChannelType() != "session" {
+					newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+					continue
+				}
+				channel, requests, err := newChannel.Accept()
+				if err != nil {
+					continue
+				}
+				go func(in <-chan *ssh.Request) {
+					for req := range in {
+						req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+					}
+				}(requests)
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					channel.Close()
+					continue
+				}
+				go func() {
+					server.Serve()
+					server.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func TestSFTPFiles(t *testing.T) {
+	fs, root, cleanup := setupSFTP(t)
+	defer cleanup()
+
+	if err := fs.MkdirAll(filepath.Join("a", "b"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "a", "b")); err != nil || !info.IsDir() || info.Mode()&os.ModePerm != 0750 {
+		t.Fatalf("directory not created as expected: %v, %v", info, err)
+	}
+
+	fd, err := fs.Create(filepath.Join("a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := fd.ReadAt(buf, 6); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "world" {
+		t.Errorf("ReadAt returned %q, expected %q", buf, "world")
+	}
+	if _, err := fd.ReadAt(buf, 10); err != io.EOF {
+		t.Errorf("expected EOF reading beyond the end, got %v", err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(filepath.Join(root, "a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "HELLO world!" {
+		t.Errorf("file contains %q, expected %q", bs, "HELLO world!")
+	}
+
+	info, err := fs.Lstat(filepath.Join("a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsRegular() || info.Size() != 12 || info.Name() != "file" {
+		t.Errorf("unexpected file info: regular %v, size %d, name %q", info.IsRegular(), info.Size(), info.Name())
+	}
+	if info.Owner() != os.Getuid() {
+		t.Errorf("owner is %d, expected %d", info.Owner(), os.Getuid())
+	}
+
+	if err := fs.Chmod(filepath.Join("a", "b", "file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2015, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := fs.Chtimes(filepath.Join("a", "b", "file"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	info, err = fs.Stat(filepath.Join("a", "b", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&ModePerm != 0600 {
+		t.Errorf("mode is %v, expected 0600", info.Mode())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime is %v, expected %v", info.ModTime(), mtime)
+	}
+
+	if _, err := fs.Lstat("nonexistent"); !IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if _, err := fs.Lstat(filepath.Join("..", "root")); err != ErrNotRelative {
+		t.Errorf("expected %v, got %v", ErrNotRelative, err)
+	}
+}
+
+func TestSFTPRename(t *testing.T) {
+	fs, root, cleanup := setupSFTP(t)
+	defer cleanup()
+
+	for _, name := range []string{"src", "dst"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The target exists, which a plain SFTP rename refuses.
+	if err := fs.Rename("src", "dst"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src")); !os.IsNotExist(err) {
+		t.Error("source still exists after rename")
+	}
+	if bs, err := ioutil.ReadFile(filepath.Join(root, "dst")); err != nil || string(bs) != "src" {
+		t.Errorf("unexpected target contents %q, %v", bs, err)
+	}
+}
+
+func TestSFTPSymlinks(t *testing.T) {
+	fs, root, cleanup := setupSFTP(t)
+	defer cleanup()
+
+	if !fs.SymlinksSupported() {
+		t.Fatal("symlinks should be supported")
+	}
+	if err := fs.CreateSymlink(filepath.Join("some", "target"), "link"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(root, "link")); err != nil || target != "some/target" {
+		t.Errorf("unexpected link target %q, %v", target, err)
+	}
+	target, err := fs.ReadSymlink("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != filepath.Join("some", "target") {
+		t.Errorf("read link target %q, expected %q", target, filepath.Join("some", "target"))
+	}
+	info, err := fs.Lstat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsSymlink() || info.IsRegular() {
+		t.Error("expected a symlink")
+	}
+}
+
+func TestSFTPWalkAndRemove(t *testing.T) {
+	fs, root, cleanup := setupSFTP(t)
+	defer cleanup()
+
+	for _, dir := range []string{"a/b/c", "a/d", "e"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"a/b/c/f1", "a/f2", "f3"} {
+		if err := ioutil.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var walked []string
+	err := fs.Walk(".", func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, filepath.ToSlash(path))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(walked)
+	expected := []string{".", "a", "a/b", "a/b/c", "a/b/c/f1", "a/d", "a/f2", "e", "f3"}
+	if len(walked) != len(expected) {
+		t.Fatalf("walked %v, expected %v", walked, expected)
+	}
+	for i := range walked {
+		if walked[i] != expected[i] {
+			t.Fatalf("walked %v, expected %v", walked, expected)
+		}
+	}
+
+	names, err := fs.DirNames("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "b" || names[1] != "d" || names[2] != "f2" {
+		t.Errorf("unexpected dir names %v", names)
+	}
+
+	if err := fs.Remove("a"); err == nil {
+		t.Error("removing a non-empty directory should fail")
+	}
+	if err := fs.Remove("f3"); err != nil {
+		t.Error(err)
+	}
+	if err := fs.Remove("e"); err != nil {
+		t.Error(err)
+	}
+	if err := fs.RemoveAll("a"); err != nil {
+		t.Error(err)
+	}
+	if err := fs.RemoveAll("a"); err != nil {
+		t.Error("removing a nonexistent path should succeed, got", err)
+	}
+	names, err = fs.DirNames(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected an empty root, got %v", names)
+	}
+}
+
+func TestSFTPUsageAndWatch(t *testing.T) {
+	fs, _, cleanup := setupSFTP(t)
+	defer cleanup()
+
+	usage, err := fs.Usage(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Total <= 0 || usage.Free < 0 || usage.Free > usage.Total {
+		t.Errorf("implausible usage %+v", usage)
+	}
+
+	if _, _, err := fs.Watch(".", nil, nil, false); err != ErrWatchNotSupported {
+		t.Errorf("expected %v, got %v", ErrWatchNotSupported, err)
+	}
+}
+
+func TestSFTPAuthFailure(t *testing.T) {
+	_, root, cleanup := setupSFTP(t)
+	defer cleanup()
+
+	// No usable key makes every operation fail, rather than the
+	// constructor.
+	fs := NewFilesystem(FilesystemTypeSFTP, "sftp://syncthing@127.0.0.1:1"+filepath.ToSlash(root)+"?key="+filepath.Join(root, "nonexistent"))
+	if fs.Type() != FilesystemTypeSFTP {
+		t.Fatal("unexpected filesystem type", fs.Type())
+	}
+	if _, err := fs.Lstat("."); err != errSFTPNoKeys {
+		t.Errorf("expected %v, got %v", errSFTPNoKeys, err)
+	}
+}
+
+func TestNewSFTPFilesystem(t *testing.T) {
+	cases := []struct {
+		uri      string
+		ok       bool
+		expected string
+		root     string
+	}{
+		{"sftp://user@host/some/path", true, "sftp://user@host/some/path", "/some/path"},
+		{"sftp://user@host:2222/some/path/?key=foo", true, "sftp://user@host:2222/some/path/", "/some/path"},
+		{"sftp://user@host/~/data", true, "sftp://user@host/~/data", "data"},
+		{"sftp://host/some/path", false, "", ""},
+		{"sftp://user@/some/path", false, "", ""},
+		{"sftp://user@host/", false, "", ""},
+		{"sftp://user@host/~/", false, "", ""},
+		{"ftp://user@host/some/path", false, "", ""},
+	}
+
+	for _, tc := range cases {
+		fs, err := newSFTPFilesystem(tc.uri)
+		if tc.ok != (err == nil) {
+			t.Errorf("%s: unexpected error state %v", tc.uri, err)
+			continue
+		}
+		if err != nil {
+			if efs := NewFilesystem(FilesystemTypeSFTP, tc.uri); efs.Type() != FilesystemTypeSFTP {
+				t.Errorf("%s: unexpected filesystem type %v", tc.uri, efs.Type())
+			} else if _, err := efs.Lstat("."); err == nil {
+				t.Errorf("%s: expected an error filesystem", tc.uri)
+			}
+			continue
+		}
+		if fs.URI() != tc.expected {
+			t.Errorf("%s: URI is %q, expected %q", tc.uri, fs.URI(), tc.expected)
+		}
+		if fs.root != tc.root {
+			t.Errorf("%s: root is %q, expected %q", tc.uri, fs.root, tc.root)
+		}
+	}
+}
+
+func TestSFTPUnrooted(t *testing.T) {
+	fs := &sftpFilesystem{root: "/data"}
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"/data", ""},
+		{"/data/x", "x"},
+		{"/data/x/y", filepath.Join("x", "y")},
+		{"/data2/x", filepath.FromSlash("/data2/x")},
+		{"/dat", filepath.FromSlash("/dat")},
+	}
+	for _, tc := range cases {
+		if res := fs.unrooted(tc.path); res != tc.expected {
+			t.Errorf("unrooted(%q) = %q, expected %q", tc.path, res, tc.expected)
+		}
+	}
+}
@@ -11,6 +11,8 @@ type FilesystemType int
 const (
 	FilesystemTypeBasic FilesystemType = iota // default is basic
 	FilesystemTypeFake
+	FilesystemTypeSFTP
+	FilesystemTypeS3
 )
 
 func (t FilesystemType) String() string {
@@ -19,6 +21,10 @@ func (t FilesystemType) String() string {
 		return "basic"
 	case FilesystemTypeFake:
 		return "fake"
+	case FilesystemTypeSFTP:
+		return "sftp"
+	case FilesystemTypeS3:
+		return "s3"
 	default:
 		return "unknown"
 	}
@@ -34,6 +40,10 @@ func (t *FilesystemType) UnmarshalText(bs []byte) error {
 		*t = FilesystemTypeBasic
 	case "fake":
 		*t = FilesystemTypeFake
+	case "sftp":
+		*t = FilesystemTypeSFTP
+	case "s3":
+		*t = FilesystemTypeS3
 	default:
 		*t = FilesystemTypeBasic
 	}
@@ -0,0 +1,84 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Xattr is a single extended attribute, including POSIX ACLs which are
+// stored as attributes in the "system" namespace.
+type Xattr struct {
+	Name  string
+	Value []byte
+}
+
+// An XattrFilter decides which extended attributes are synced.
+type XattrFilter interface {
+	Permit(name string) bool
+}
+
+// GetXattrs returns the extended attributes of the given file that are
+// permitted by the filter, sorted by name.
+func GetXattrs(fs Filesystem, name string, filter XattrFilter) ([]Xattr, error) {
+	attrs, err := fs.ListXattr(name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(attrs)
+
+	var xattrs []Xattr
+	for _, attr := range attrs {
+		if !filter.Permit(attr) {
+			continue
+		}
+		value, err := fs.GetXattr(name, attr)
+		if err != nil {
+			return nil, err
+		}
+		xattrs = append(xattrs, Xattr{Name: attr, Value: value})
+	}
+	return xattrs, nil
+}
+
+// SetXattrs makes the extended attributes of the given file that are
+// permitted by the filter equal to the given set, i.e. it sets those that
+// differ and removes those that aren't in the set. Attributes not permitted
+// by the filter are left alone.
+func SetXattrs(fs Filesystem, name string, xattrs []Xattr, filter XattrFilter) error {
+	current, err := GetXattrs(fs, name, filter)
+	if err != nil {
+		return err
+	}
+	have := make(map[string][]byte, len(current))
+	for _, x := range current {
+		have[x.Name] = x.Value
+	}
+
+	for _, x := range xattrs {
+		if !filter.Permit(x.Name) {
+			continue
+		}
+		if value, ok := have[x.Name]; ok {
+			delete(have, x.Name)
+			if bytes.Equal(value, x.Value) {
+				continue
+			}
+		}
+		if err := fs.SetXattr(name, x.Name, x.Value); err != nil {
+			return err
+		}
+	}
+
+	for attr := range have {
+		if err := fs.RemoveXattr(name, attr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
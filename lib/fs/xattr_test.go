@@ -0,0 +1,128 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type prefixXattrFilter string
+
+func (f prefixXattrFilter) Permit(name string) bool {
+	return strings.HasPrefix(name, string(f))
+}
+
+func TestBasicXattrs(t *testing.T) {
+	fs, dir := setup(t)
+	defer os.RemoveAll(dir)
+
+	fd, err := fs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	if err := fs.SetXattr("file", "user.test", []byte("value")); err == ErrXattrsNotSupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := fs.ListXattr("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, attr := range attrs {
+		found = found || attr == "user.test"
+	}
+	if !found {
+		t.Fatalf("user.test not listed in %v", attrs)
+	}
+
+	if value, err := fs.GetXattr("file", "user.test"); err != nil {
+		t.Fatal(err)
+	} else if string(value) != "value" {
+		t.Errorf("got %q, expected %q", value, "value")
+	}
+
+	if err := fs.RemoveXattr("file", "user.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.GetXattr("file", "user.test"); err == nil {
+		t.Error("attribute should be gone")
+	}
+
+	if _, err := fs.ListXattr("nonexistent"); !IsNotExist(err) {
+		t.Error("expected not exist error, got", err)
+	}
+}
+
+func TestGetSetXattrs(t *testing.T) {
+	fs := newFakeFilesystem("/TestGetSetXattrs")
+
+	fd, err := fs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	for _, x := range []Xattr{
+		{"user.b", []byte("b")},
+		{"user.a", []byte("a")},
+		{"user.c", []byte("c")},
+		{"system.posix_acl_access", []byte("acl")},
+	} {
+		if err := fs.SetXattr("file", x.Name, x.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the permitted attributes are returned, in order.
+
+	filter := prefixXattrFilter("user.")
+	xattrs, err := GetXattrs(fs, "file", filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Xattr{
+		{"user.a", []byte("a")},
+		{"user.b", []byte("b")},
+		{"user.c", []byte("c")},
+	}
+	if !reflect.DeepEqual(xattrs, expected) {
+		t.Errorf("got %v, expected %v", xattrs, expected)
+	}
+
+	// Setting changes, adds and removes permitted attributes and leaves the
+	// others alone.
+
+	err = SetXattrs(fs, "file", []Xattr{
+		{"user.b", []byte("new")},
+		{"user.d", []byte("d")},
+		{"system.posix_acl_access", []byte("ignored")},
+	}, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs, err = GetXattrs(fs, "file", prefixXattrFilter(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Xattr{
+		{"system.posix_acl_access", []byte("acl")},
+		{"user.b", []byte("new")},
+		{"user.d", []byte("d")},
+	}
+	if !reflect.DeepEqual(xattrs, expected) {
+		t.Errorf("got %v, expected %v", xattrs, expected)
+	}
+}
@@ -22,18 +22,20 @@ type LocationEnum string
 // Use strings as keys to make printout and serialization of the locations map
 // more meaningful.
 const (
-	ConfigFile    LocationEnum = "config"
-	CertFile      LocationEnum = "certFile"
-	KeyFile       LocationEnum = "keyFile"
-	HTTPSCertFile LocationEnum = "httpsCertFile"
-	HTTPSKeyFile  LocationEnum = "httpsKeyFile"
-	Database      LocationEnum = "database"
-	LogFile       LocationEnum = "logFile"
-	CsrfTokens    LocationEnum = "csrfTokens"
-	PanicLog      LocationEnum = "panicLog"
-	AuditLog      LocationEnum = "auditLog"
-	GUIAssets     LocationEnum = "GUIAssets"
-	DefFolder     LocationEnum = "defFolder"
+	ConfigFile     LocationEnum = "config"
+	CertFile       LocationEnum = "certFile"
+	KeyFile        LocationEnum = "keyFile"
+	HTTPSCertFile  LocationEnum = "httpsCertFile"
+	HTTPSKeyFile   LocationEnum = "httpsKeyFile"
+	Database       LocationEnum = "database"
+	DatabaseImport LocationEnum = "databaseImport"
+	LogFile        LocationEnum = "logFile"
+	CsrfTokens     LocationEnum = "csrfTokens"
+	PanicLog       LocationEnum = "panicLog"
+	AuditLog       LocationEnum = "auditLog"
+	GUIAssets      LocationEnum = "GUIAssets"
+	DefFolder      LocationEnum = "defFolder"
+	WebhookSpool   LocationEnum = "webhookSpool"
 )
 
 type BaseDirEnum string
@@ -47,13 +49,17 @@ const (
 
 	LevelDBDir = "index-v0.14.0.db"
 	BadgerDir  = "indexdb.badger"
+	BoltDBFile = "indexdb.bolt"
 )
 
 // Platform dependent directories
 var baseDirs = make(map[BaseDirEnum]string, 3)
 
 func init() {
-	if os.Getenv("USE_BADGER") != "" {
+	if os.Getenv("USE_BOLT") != "" {
+		// XXX: Replace the leveldb name with the bolt name.
+		locationTemplates[Database] = strings.Replace(locationTemplates[Database], LevelDBDir, BoltDBFile, 1)
+	} else if os.Getenv("USE_BADGER") != "" {
 		// XXX: Replace the leveldb name with the badger name.
 		locationTemplates[Database] = strings.Replace(locationTemplates[Database], LevelDBDir, BadgerDir, 1)
 	}
@@ -90,18 +96,20 @@ func GetBaseDir(baseDir BaseDirEnum) string {
 
 // Use the variables from baseDirs here
 var locationTemplates = map[LocationEnum]string{
-	ConfigFile:    "${config}/config.xml",
-	CertFile:      "${config}/cert.pem",
-	KeyFile:       "${config}/key.pem",
-	HTTPSCertFile: "${config}/https-cert.pem",
-	HTTPSKeyFile:  "${config}/https-key.pem",
-	Database:      "${data}/" + LevelDBDir,
-	LogFile:       "${data}/syncthing.log", // -logfile on Windows
-	CsrfTokens:    "${data}/csrftokens.txt",
-	PanicLog:      "${data}/panic-${timestamp}.log",
-	AuditLog:      "${data}/audit-${timestamp}.log",
-	GUIAssets:     "${config}/gui",
-	DefFolder:     "${userHome}/Sync",
+	ConfigFile:     "${config}/config.xml",
+	CertFile:       "${config}/cert.pem",
+	KeyFile:        "${config}/key.pem",
+	HTTPSCertFile:  "${config}/https-cert.pem",
+	HTTPSKeyFile:   "${config}/https-key.pem",
+	Database:       "${data}/" + LevelDBDir,
+	DatabaseImport: "${data}/index-import.jsonl", // imported at startup
+	LogFile:        "${data}/syncthing.log",      // -logfile on Windows
+	CsrfTokens:     "${data}/csrftokens.txt",
+	PanicLog:       "${data}/panic-${timestamp}.log",
+	AuditLog:       "${data}/audit-${timestamp}.log",
+	GUIAssets:      "${config}/gui",
+	DefFolder:      "${userHome}/Sync",
+	WebhookSpool:   "${data}/webhooks",
 }
 
 var locations = make(map[LocationEnum]string)
@@ -121,10 +129,31 @@ func expandLocations() error {
 		}
 		newLocations[key] = filepath.Clean(dir)
 	}
+	newLocations[Database] = existingDatabase(newLocations[Database])
 	locations = newLocations
 	return nil
 }
 
+// existingDatabase returns the database to use instead of the LevelDB one
+// at path, when there is none but there is one of another type, as left by
+// migrating it with stindex. Other types selected by the environment are
+// used as they are.
+func existingDatabase(path string) string {
+	if filepath.Base(path) != LevelDBDir {
+		return path
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return path
+	}
+	for _, name := range []string{BadgerDir, BoltDBFile} {
+		other := filepath.Join(filepath.Dir(path), name)
+		if _, err := os.Lstat(other); err == nil {
+			return other
+		}
+	}
+	return path
+}
+
 // defaultConfigDir returns the default configuration directory, as figured
 // out by various the environment variables present on each platform, or dies
 // trying.
@@ -156,16 +185,19 @@ func defaultDataDir(userHome, config string) string {
 
 	default:
 		// If a database exists at the "normal" location, use that anyway.
-		// We look for both LevelDB and Badger variants here regardless of
-		// what we're currently configured to use, because we might be
-		// starting up in Badger mode with only a LevelDB database present
-		// (will be converted), or vice versa.
+		// We look for the LevelDB, Badger and Bolt variants here
+		// regardless of what we're currently configured to use, because we
+		// might be starting up in Badger mode with only a LevelDB database
+		// present (will be converted), or vice versa.
 		if _, err := os.Lstat(filepath.Join(config, LevelDBDir)); err == nil {
 			return config
 		}
 		if _, err := os.Lstat(filepath.Join(config, BadgerDir)); err == nil {
 			return config
 		}
+		if _, err := os.Lstat(filepath.Join(config, BoltDBFile)); err == nil {
+			return config
+		}
 		// Always use this env var, as it's explicitly set by the user
 		if xdgHome := os.Getenv("XDG_DATA_HOME"); xdgHome != "" {
 			return filepath.Join(xdgHome, "syncthing")
@@ -0,0 +1,120 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// folderBandwidthBurst is the largest amount of data let through at once,
+// large enough for a block. Waits for more are split up.
+const folderBandwidthBurst = 512 << 10
+
+// folderBandwidth keeps the bandwidth limits and priorities of the folders.
+// The limits apply on top of the global and per device limits of the
+// connections, while the priorities decide how folders share the device and
+// global request limiters when they contend for them.
+type folderBandwidth struct {
+	mut      sync.Mutex
+	send     map[string]*rate.Limiter
+	recv     map[string]*rate.Limiter
+	priority map[string]int
+}
+
+func newFolderBandwidth(folders []config.FolderConfiguration) *folderBandwidth {
+	b := &folderBandwidth{
+		mut:      sync.NewMutex(),
+		send:     make(map[string]*rate.Limiter),
+		recv:     make(map[string]*rate.Limiter),
+		priority: make(map[string]int),
+	}
+	b.setFolders(folders)
+	return b
+}
+
+// setFolders updates the limits and priorities to the given folders,
+// keeping the state of existing limiters.
+func (b *folderBandwidth) setFolders(folders []config.FolderConfiguration) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	seen := make(map[string]struct{}, len(folders))
+	for _, cfg := range folders {
+		seen[cfg.ID] = struct{}{}
+		setFolderLimit(b.send, cfg.ID, cfg.MaxSendKbps)
+		setFolderLimit(b.recv, cfg.ID, cfg.MaxRecvKbps)
+		b.priority[cfg.ID] = cfg.Priority
+	}
+	for id := range b.priority {
+		if _, ok := seen[id]; !ok {
+			delete(b.send, id)
+			delete(b.recv, id)
+			delete(b.priority, id)
+		}
+	}
+}
+
+func setFolderLimit(limiters map[string]*rate.Limiter, folder string, kbps int) {
+	limit := rate.Inf
+	if kbps > 0 {
+		limit = 1024 * rate.Limit(kbps)
+	}
+	if lim, ok := limiters[folder]; ok {
+		lim.SetLimit(limit)
+		return
+	}
+	limiters[folder] = rate.NewLimiter(limit, folderBandwidthBurst)
+}
+
+// weight returns the share of contended bandwidth the folder gets, relative
+// to other folders.
+func (b *folderBandwidth) weight(folder string) int {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	if p := b.priority[folder]; p > 0 {
+		return p
+	}
+	return 1
+}
+
+// waitSend waits until the folder may send the given amount of data.
+func (b *folderBandwidth) waitSend(ctx context.Context, folder string, bytes int) error {
+	b.mut.Lock()
+	lim := b.send[folder]
+	b.mut.Unlock()
+	return waitRate(ctx, lim, bytes)
+}
+
+// waitRecv waits until the folder may receive the given amount of data.
+func (b *folderBandwidth) waitRecv(ctx context.Context, folder string, bytes int) error {
+	b.mut.Lock()
+	lim := b.recv[folder]
+	b.mut.Unlock()
+	return waitRate(ctx, lim, bytes)
+}
+
+func waitRate(ctx context.Context, lim *rate.Limiter, bytes int) error {
+	if lim == nil || lim.Limit() == rate.Inf {
+		return nil
+	}
+	for bytes > 0 {
+		n := bytes
+		if n > folderBandwidthBurst {
+			n = folderBandwidthBurst
+		}
+		if err := lim.WaitN(ctx, n); err != nil {
+			return err
+		}
+		bytes -= n
+	}
+	return nil
+}
@@ -16,6 +16,26 @@ type byteSemaphore struct {
 	available int
 	mut       sync.Mutex
 	cond      *sync.Cond
+
+	// Contending takes are granted in the order of their start tags, which
+	// shares the semaphore between keys in proportion to their weights
+	// (start-time fair queuing).
+	vtime   float64            // start tag of the last granted take
+	finish  map[string]float64 // finish tag of the last take per key
+	waiting map[*semaphoreTake]struct{}
+	seq     uint64
+}
+
+type semaphoreTake struct {
+	start float64
+	seq   uint64
+}
+
+func (t *semaphoreTake) before(other *semaphoreTake) bool {
+	if t.start != other.start {
+		return t.start < other.start
+	}
+	return t.seq < other.seq
 }
 
 func newByteSemaphore(max int) *byteSemaphore {
@@ -25,16 +45,24 @@ func newByteSemaphore(max int) *byteSemaphore {
 	s := byteSemaphore{
 		max:       max,
 		available: max,
+		finish:    make(map[string]float64),
+		waiting:   make(map[*semaphoreTake]struct{}),
 	}
 	s.cond = sync.NewCond(&s.mut)
 	return &s
 }
 
 func (s *byteSemaphore) takeWithContext(ctx context.Context, bytes int) error {
+	return s.takeWeighted(ctx, bytes, "", 1)
+}
+
+// takeWeighted is like takeWithContext, but when takes have to wait, each
+// key gets a share of the semaphore in proportion to its weight.
+func (s *byteSemaphore) takeWeighted(ctx context.Context, bytes int, key string, weight int) error {
 	done := make(chan struct{})
 	var err error
 	go func() {
-		err = s.takeInner(ctx, bytes)
+		err = s.takeInner(ctx, bytes, key, weight)
 		close(done)
 	}()
 	select {
@@ -47,10 +75,10 @@ func (s *byteSemaphore) takeWithContext(ctx context.Context, bytes int) error {
 }
 
 func (s *byteSemaphore) take(bytes int) {
-	_ = s.takeInner(context.Background(), bytes)
+	_ = s.takeInner(context.Background(), bytes, "", 1)
 }
 
-func (s *byteSemaphore) takeInner(ctx context.Context, bytes int) error {
+func (s *byteSemaphore) takeInner(ctx context.Context, bytes int, key string, weight int) error {
 	// Checking context for bytes <= s.available is required for testing and doesn't do any harm.
 	select {
 	case <-ctx.Done():
@@ -62,7 +90,24 @@ func (s *byteSemaphore) takeInner(ctx context.Context, bytes int) error {
 	if bytes > s.max {
 		bytes = s.max
 	}
-	for bytes > s.available {
+	if weight < 1 {
+		weight = 1
+	}
+
+	t := &semaphoreTake{start: s.vtime, seq: s.seq}
+	s.seq++
+	if finish := s.finish[key]; finish > t.start {
+		t.start = finish
+	}
+	s.finish[key] = t.start + float64(bytes)/float64(weight)
+	s.waiting[t] = struct{}{}
+	defer func() {
+		delete(s.waiting, t)
+		// Someone else may be first in line now.
+		s.cond.Broadcast()
+	}()
+
+	for bytes > s.available || !s.firstLocked(t) {
 		s.cond.Wait()
 		select {
 		case <-ctx.Done():
@@ -73,10 +118,23 @@ func (s *byteSemaphore) takeInner(ctx context.Context, bytes int) error {
 			bytes = s.max
 		}
 	}
+	if t.start > s.vtime {
+		s.vtime = t.start
+	}
 	s.available -= bytes
 	return nil
 }
 
+// firstLocked returns whether no waiting take comes before t.
+func (s *byteSemaphore) firstLocked(t *semaphoreTake) bool {
+	for other := range s.waiting {
+		if other.before(t) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *byteSemaphore) give(bytes int) {
 	s.mut.Lock()
 	if bytes > s.max {
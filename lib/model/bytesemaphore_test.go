@@ -6,7 +6,12 @@
 
 package model
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestZeroByteSempahore(t *testing.T) {
 	// A semaphore with zero capacity is just a no-op.
@@ -111,3 +116,46 @@ func TestByteSempahoreGiveMore(t *testing.T) {
 		t.Errorf("bad state after large take + give with adjustment")
 	}
 }
+
+func TestByteSemaphoreWeighted(t *testing.T) {
+	// Waiting takes are granted in proportion to the weights of their keys
+
+	s := newByteSemaphore(100)
+	s.take(100)
+
+	granted := make(chan string)
+	enqueue := func(key string, weight int) {
+		s.mut.Lock()
+		waiting := len(s.waiting)
+		s.mut.Unlock()
+		go func() {
+			_ = s.takeWeighted(context.Background(), 10, key, weight)
+			granted <- key
+		}()
+		for {
+			s.mut.Lock()
+			n := len(s.waiting)
+			s.mut.Unlock()
+			if n > waiting {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		enqueue("a", 1)
+	}
+	for i := 0; i < 4; i++ {
+		enqueue("b", 4)
+	}
+
+	var order []string
+	for i := 0; i < 7; i++ {
+		s.give(10)
+		order = append(order, <-granted)
+	}
+	expected := []string{"a", "b", "b", "b", "b", "a", "a"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("granted in order %v, expected %v", order, expected)
+	}
+}
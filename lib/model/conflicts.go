@@ -0,0 +1,184 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+type conflictOutcome int
+
+const (
+	conflictKeepBoth conflictOutcome = iota
+	conflictLocalWins
+	conflictRemoteWins
+)
+
+// conflictOutcome decides between the local file and the remote one
+// conflicting with it, according to the folder's conflict strategy.
+func (f *sendReceiveFolder) conflictOutcome(cur, file protocol.FileInfo) conflictOutcome {
+	if f.Type != config.FolderTypeSendReceive || cur.Type != protocol.FileInfoTypeFile || cur.IsDeleted() || cur.IsInvalid() {
+		return conflictKeepBoth
+	}
+
+	switch f.ConflictStrategy {
+	case config.ConflictStrategyNewest:
+		switch {
+		case file.ModTime().After(cur.ModTime()):
+			return conflictRemoteWins
+		case cur.ModTime().After(file.ModTime()):
+			return conflictLocalWins
+		}
+	case config.ConflictStrategyLargest:
+		switch {
+		case file.Size > cur.Size:
+			return conflictRemoteWins
+		case cur.Size > file.Size:
+			return conflictLocalWins
+		}
+	case config.ConflictStrategyDevice:
+		winner := f.ConflictWinner.Short()
+		switch {
+		case file.ModifiedBy == winner:
+			return conflictRemoteWins
+		case cur.ModifiedBy == winner:
+			return conflictLocalWins
+		}
+	}
+	return conflictKeepBoth
+}
+
+// keepLocalFile resolves a conflict in favour of the local file, without
+// touching it on disk: Its new version supersedes the remote one, which
+// makes the other devices pull it.
+func (f *sendReceiveFolder) keepLocalFile(cur, file protocol.FileInfo, dbUpdateChan chan<- dbUpdateJob) {
+	l.Infof("Conflict for %v in folder %v resolved in favour of the local version (%v)", cur.Name, f.Description(), f.ConflictStrategy)
+	cur.Version = cur.Version.Merge(file.Version).Update(f.shortID)
+	dbUpdateChan <- dbUpdateJob{cur, dbUpdateKeepLocal}
+}
+
+// A Conflict is a conflict copy of a file.
+type Conflict struct {
+	Name       string    `json:"name"`
+	Original   string    `json:"original"`
+	Created    time.Time `json:"created"`
+	ModifiedBy string    `json:"modifiedBy"` // Short ID of the device that changed the original
+	Size       int64     `json:"size"`
+}
+
+var conflictNameExp = regexp.MustCompile(`^(.*)\.sync-conflict-(\d{8}-\d{6})-([A-Z0-9]*)([^/\\]*)$`)
+
+// parseConflictName returns the conflict copy of the given name, or false
+// if it isn't one.
+func parseConflictName(name string) (Conflict, bool) {
+	m := conflictNameExp.FindStringSubmatch(name)
+	if m == nil {
+		return Conflict{}, false
+	}
+	created, err := time.ParseInLocation("20060102-150405", m[2], time.Local)
+	if err != nil {
+		return Conflict{}, false
+	}
+	return Conflict{
+		Name:       name,
+		Original:   m[1] + m[4],
+		Created:    created,
+		ModifiedBy: m[3],
+	}, true
+}
+
+// Conflicts returns the conflict copies in the folder.
+func (m *model) Conflicts(folder string) ([]Conflict, error) {
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	conflicts := []Conflict{}
+	snap := fset.Snapshot()
+	defer snap.Release()
+	snap.WithHaveTruncated(protocol.LocalDeviceID, func(fi protocol.FileIntf) bool {
+		if fi.IsDeleted() || fi.IsInvalid() || fi.IsDirectory() || !isConflict(fi.FileName()) {
+			return true
+		}
+		if c, ok := parseConflictName(fi.FileName()); ok {
+			c.Size = fi.FileSize()
+			conflicts = append(conflicts, c)
+		}
+		return true
+	})
+	return conflicts, nil
+}
+
+// ResolveConflict resolves a conflict by keeping either the conflict copy
+// or the original. The other one is archived by the versioner, if the
+// folder has one, and the result is scanned to be propagated.
+func (m *model) ResolveConflict(folder, name string, keepConflict bool) error {
+	m.fmut.RLock()
+	err := m.checkFolderRunningLocked(folder)
+	runner := m.folderRunners[folder]
+	ver := m.folderVersioners[folder]
+	m.fmut.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	name, err = fs.Canonicalize(name)
+	if err != nil {
+		return err
+	}
+	c, ok := parseConflictName(name)
+	if !ok || fs.IsInternal(name) {
+		return fmt.Errorf("%v: not a conflict copy", name)
+	}
+
+	return runner.ResolveConflict(c, keepConflict, ver)
+}
+
+// ResolveConflict replaces or archives the files of the conflict in the
+// folder loop, so it doesn't race with pulling or scanning them.
+func (f *folder) ResolveConflict(c Conflict, keepConflict bool, ver versioner.Versioner) error {
+	<-f.initialScanFinished
+	return f.doInSync(func() error {
+		ffs := f.Filesystem()
+		if info, err := ffs.Lstat(c.Name); err != nil {
+			return err
+		} else if !info.IsRegular() {
+			return fmt.Errorf("%v: not a regular file", c.Name)
+		}
+
+		loser := c.Name
+		if keepConflict {
+			loser = c.Original
+		}
+		var err error
+		if ver != nil {
+			err = ver.Archive(loser)
+		} else {
+			err = ffs.Remove(loser)
+		}
+		if err != nil && !fs.IsNotExist(err) {
+			return err
+		}
+		if keepConflict {
+			if err := ffs.Rename(c.Name, c.Original); err != nil {
+				return err
+			}
+		}
+
+		return f.scanSubdirs([]string{c.Name, c.Original})
+	})
+}
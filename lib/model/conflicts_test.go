@@ -0,0 +1,151 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestConflictOutcome(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+
+	now := time.Now()
+	local := protocol.FileInfo{Name: "foo", Size: 10, ModifiedS: now.Unix(), ModifiedBy: myID.Short()}
+	remote := protocol.FileInfo{Name: "foo", Size: 20, ModifiedS: now.Add(-time.Hour).Unix(), ModifiedBy: device1.Short()}
+
+	cases := []struct {
+		strategy config.ConflictStrategy
+		winner   protocol.DeviceID
+		outcome  conflictOutcome
+	}{
+		{config.ConflictStrategyKeepBoth, protocol.EmptyDeviceID, conflictKeepBoth},
+		{config.ConflictStrategyNewest, protocol.EmptyDeviceID, conflictLocalWins},
+		{config.ConflictStrategyLargest, protocol.EmptyDeviceID, conflictRemoteWins},
+		{config.ConflictStrategyDevice, device1, conflictRemoteWins},
+		{config.ConflictStrategyDevice, myID, conflictLocalWins},
+		{config.ConflictStrategyDevice, device2, conflictKeepBoth},
+	}
+	for _, tc := range cases {
+		f.ConflictStrategy = tc.strategy
+		f.ConflictWinner = tc.winner
+		if outcome := f.conflictOutcome(local, remote); outcome != tc.outcome {
+			t.Errorf("%v (%v): got outcome %v, expected %v", tc.strategy, tc.winner, outcome, tc.outcome)
+		}
+	}
+
+	// Ties keep both.
+	f.ConflictStrategy = config.ConflictStrategyLargest
+	remote.Size = local.Size
+	if outcome := f.conflictOutcome(local, remote); outcome != conflictKeepBoth {
+		t.Errorf("got outcome %v for a tie", outcome)
+	}
+
+	// As do deleted local files.
+	remote.Size = 20
+	local.Deleted = true
+	if outcome := f.conflictOutcome(local, remote); outcome != conflictKeepBoth {
+		t.Errorf("got outcome %v for a deleted file", outcome)
+	}
+}
+
+func TestConflictKeepLocal(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+
+	local := protocol.FileInfo{Name: "foo", Version: protocol.Vector{}.Update(myID.Short())}
+	remote := protocol.FileInfo{Name: "foo", Version: protocol.Vector{}.Update(device1.Short())}
+
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	f.keepLocalFile(local, remote, dbUpdateChan)
+	job := <-dbUpdateChan
+	if job.jobType != dbUpdateKeepLocal {
+		t.Errorf("unexpected job type %v", job.jobType)
+	}
+	if !job.file.Version.GreaterEqual(remote.Version) || job.file.Version.Equal(remote.Version.Merge(local.Version)) {
+		t.Errorf("version %v doesn't supersede %v and %v", job.file.Version, local.Version, remote.Version)
+	}
+}
+
+func TestParseConflictName(t *testing.T) {
+	name := conflictName("dir/foo.txt", "ABCDEFG")
+	c, ok := parseConflictName(name)
+	if !ok {
+		t.Fatalf("%v not parsed as conflict copy", name)
+	}
+	if c.Original != "dir/foo.txt" || c.ModifiedBy != "ABCDEFG" || time.Since(c.Created) > time.Minute {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+	if _, ok := parseConflictName("dir/foo.txt"); ok {
+		t.Error("regular file parsed as conflict copy")
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer cleanupModelAndRemoveDir(m, fcfg.Filesystem().URI())
+	ffs := fcfg.Filesystem()
+
+	write := func(name, content string) {
+		t.Helper()
+		fd, err := ffs.Create(name)
+		must(t, err)
+		_, err = fd.Write([]byte(content))
+		must(t, err)
+		fd.Close()
+	}
+	read := func(name string) string {
+		t.Helper()
+		fd, err := ffs.Open(name)
+		must(t, err)
+		defer fd.Close()
+		bs, err := ioutil.ReadAll(fd)
+		must(t, err)
+		return string(bs)
+	}
+
+	conflict := conflictName("foo.txt", device1.Short().String())
+	write("foo.txt", "original")
+	write(conflict, "conflict")
+	must(t, m.ScanFolder(fcfg.ID))
+
+	conflicts, err := m.Conflicts(fcfg.ID)
+	must(t, err)
+	if len(conflicts) != 1 || conflicts[0].Name != conflict || conflicts[0].Original != "foo.txt" || conflicts[0].Size != 8 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+
+	must(t, m.ResolveConflict(fcfg.ID, conflict, true))
+	if content := read("foo.txt"); content != "conflict" {
+		t.Errorf("original has content %q after keeping the conflict copy", content)
+	}
+	if _, err := ffs.Lstat(conflict); !fs.IsNotExist(err) {
+		t.Error("conflict copy still exists")
+	}
+	if conflicts, err := m.Conflicts(fcfg.ID); err != nil || len(conflicts) != 0 {
+		t.Errorf("unexpected conflicts %+v after resolving (%v)", conflicts, err)
+	}
+
+	if err := m.ResolveConflict(fcfg.ID, "foo.txt", false); err == nil {
+		t.Error("expected error resolving a regular file")
+	}
+
+	fcfg.Paused = true
+	waiter, err := w.SetFolder(fcfg)
+	must(t, err)
+	waiter.Wait()
+	if err := m.ResolveConflict(fcfg.ID, conflict, true); err != ErrFolderPaused {
+		t.Errorf("got %v resolving in a paused folder, expected %v", err, ErrFolderPaused)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"math/rand"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -42,11 +43,12 @@ type folder struct {
 
 	localFlags uint32
 
-	model   *model
-	shortID protocol.ShortID
-	fset    *db.FileSet
-	ignores *ignore.Matcher
-	ctx     context.Context
+	model     *model
+	shortID   protocol.ShortID
+	fset      *db.FileSet
+	ignores   *ignore.Matcher
+	selection db.Selection
+	ctx       context.Context
 
 	scanInterval        time.Duration
 	scanTimer           *time.Timer
@@ -61,6 +63,10 @@ type folder struct {
 
 	doInSyncChan chan syncRequest
 
+	schedule        config.Schedule
+	scheduleTimer   *time.Timer
+	outsideSchedule bool
+
 	forcedRescanRequested chan struct{}
 	forcedRescanPaths     map[string]struct{}
 	forcedRescanPathsMut  sync.Mutex
@@ -71,6 +77,16 @@ type folder struct {
 	watchErr         error
 	watchMut         sync.Mutex
 
+	quotaBlocked db.Counts
+	quotaMut     sync.Mutex
+
+	xattrsSupported bool // as of the last health check
+	xattrsMut       sync.Mutex
+
+	nextSelection     *db.Selection
+	selectionMut      sync.Mutex
+	unselectedRemoved bool // by the puller, since the selection was set
+
 	puller puller
 }
 
@@ -79,6 +95,11 @@ type syncRequest struct {
 	err chan error
 }
 
+const (
+	defaultPollWatchInterval = 10 * time.Second
+	defaultPollWatchMaxStats = 1000
+)
+
 type puller interface {
 	pull() bool // true when successfull and should not be retried
 }
@@ -90,10 +111,11 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 		FolderStatisticsReference: stats.NewFolderStatisticsReference(model.db, cfg.ID),
 		ioLimiter:                 ioLimiter,
 
-		model:   model,
-		shortID: model.shortID,
-		fset:    fset,
-		ignores: ignores,
+		model:     model,
+		shortID:   model.shortID,
+		fset:      fset,
+		ignores:   ignores,
+		selection: folderSelection(cfg),
 
 		scanInterval:        time.Duration(cfg.RescanIntervalS) * time.Second,
 		scanTimer:           time.NewTimer(time.Millisecond), // The first scan should be done immediately.
@@ -105,6 +127,8 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 
 		doInSyncChan: make(chan syncRequest),
 
+		schedule: cfg.Schedule(),
+
 		forcedRescanRequested: make(chan struct{}, 1),
 		forcedRescanPaths:     make(map[string]struct{}),
 		forcedRescanPathsMut:  sync.NewMutex(),
@@ -112,10 +136,18 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 		watchCancel:      func() {},
 		restartWatchChan: make(chan struct{}, 1),
 		watchMut:         sync.NewMutex(),
+
+		quotaMut: sync.NewMutex(),
+
+		xattrsMut: sync.NewMutex(),
+
+		selectionMut: sync.NewMutex(),
 	}
 	f.pullPause = f.pullBasePause()
 	f.pullFailTimer = time.NewTimer(0)
 	<-f.pullFailTimer.C
+	f.scheduleTimer = time.NewTimer(0)
+	<-f.scheduleTimer.C
 	return f
 }
 
@@ -130,9 +162,12 @@ func (f *folder) serve(ctx context.Context) {
 
 	defer func() {
 		f.scanTimer.Stop()
+		f.scheduleTimer.Stop()
 		f.setState(FolderIdle)
 	}()
 
+	f.updateSchedule()
+
 	if f.FSWatcherEnabled && f.getHealthErrorAndLoadIgnores() == nil {
 		f.startWatch()
 	}
@@ -165,6 +200,9 @@ func (f *folder) serve(ctx context.Context) {
 			l.Debugln(f, "Scanning due to timer")
 			f.scanTimerFired()
 
+		case <-f.scheduleTimer.C:
+			f.updateSchedule()
+
 		case req := <-f.doInSyncChan:
 			l.Debugln(f, "Running something due to request")
 			req.err <- req.fn()
@@ -174,6 +212,11 @@ func (f *folder) serve(ctx context.Context) {
 			f.scanTimer.Reset(next)
 
 		case fsEvents := <-f.watchChan:
+			if f.outsideSchedule {
+				// The full scan when the schedule opens picks these up.
+				l.Debugln(f, "Ignoring watcher events outside schedule")
+				continue
+			}
 			l.Debugln(f, "Scan due to watcher")
 			f.scanSubdirs(fsEvents)
 
@@ -268,6 +311,7 @@ func (f *folder) getHealthErrorWithoutIgnores() error {
 	if err := f.CheckPath(); err != nil {
 		return err
 	}
+	f.checkXattrsSupported()
 
 	dbPath := locations.Get(locations.Database)
 	if usage, err := fs.NewFilesystem(fs.FilesystemTypeBasic, dbPath).Usage("."); err == nil {
@@ -293,6 +337,11 @@ func (f *folder) pull() (success bool) {
 		return true
 	}
 
+	if f.outsideSchedule {
+		// A pull is scheduled when the schedule opens.
+		return true
+	}
+
 	defer func() {
 		if success {
 			// We're good, reset the pause interval.
@@ -300,8 +349,13 @@ func (f *folder) pull() (success bool) {
 		}
 	}()
 
+	if err := f.updateSelection(); err != nil {
+		l.Debugf("Scanning newly selected paths in %v: %v", f.Description(), err)
+		return false
+	}
+
 	// If there is nothing to do, don't even enter sync-waiting state.
-	abort := true
+	abort := f.selection.All() || f.unselectedRemoved
 	snap := f.fset.Snapshot()
 	snap.WithNeed(protocol.LocalDeviceID, func(intf protocol.FileIntf) bool {
 		abort = false
@@ -394,6 +448,7 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 
 	f.setState(FolderScanning)
 
+	xattrFilter := f.xattrFilter()
 	mtimefs := f.fset.MtimeFS()
 	fchan := scanner.Walk(f.ctx, scanner.Config{
 		Folder:                f.ID,
@@ -409,7 +464,9 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 		ProgressTickIntervalS: f.ScanProgressIntervalS,
 		LocalFlags:            f.localFlags,
 		ModTimeWindow:         f.ModTimeWindow(),
+		XattrFilter:           xattrFilter,
 		EventLogger:           f.evLogger,
+		Selected:              f.selectedFn(),
 	})
 
 	batchFn := func(fs []protocol.FileInfo) error {
@@ -428,7 +485,7 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 				switch gf, ok := snap.GetGlobal(fs[i].Name); {
 				case !ok:
 					continue
-				case gf.IsEquivalentOptional(fs[i], f.ModTimeWindow(), false, false, protocol.FlagLocalReceiveOnly):
+				case gf.IsEquivalentOptional(fs[i], f.ModTimeWindow(), false, false, xattrFilter == nil, protocol.FlagLocalReceiveOnly):
 					// What we have locally is equivalent to the global file.
 					fs[i].Version = fs[i].Version.Merge(gf.Version)
 					fallthrough
@@ -528,6 +585,11 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 				ignoredParent = ""
 			}
 
+			if !f.selection.Selected(file.Name) {
+				// Not walked, and removed by the puller on purpose.
+				return true
+			}
+
 			switch ignored := f.ignores.Match(file.Name).IsIgnored(); {
 			case !file.IsIgnored() && ignored:
 				// File was not ignored at last pass but has been ignored.
@@ -679,6 +741,18 @@ func (f *folder) findRename(snap *db.Snapshot, mtimefs fs.Filesystem, file proto
 }
 
 func (f *folder) scanTimerFired() {
+	select {
+	case <-f.initialScanFinished:
+		if f.outsideSchedule {
+			// The schedule resets the timer when it opens.
+			l.Debugln(f, "Skipping scan outside schedule")
+			return
+		}
+	default:
+		// The initial scan is done regardless, so that the folder is
+		// ready to be used.
+	}
+
 	err := f.scanSubdirs(nil)
 
 	select {
@@ -695,12 +769,66 @@ func (f *folder) scanTimerFired() {
 	f.Reschedule()
 }
 
+// updateSchedule checks whether the folder is within its schedule and sets
+// the timer for when that changes. The scans and pulls skipped while outside
+// the schedule are done once it opens.
+func (f *folder) updateSchedule() {
+	now := time.Now()
+	if next := f.schedule.Next(now); !next.IsZero() {
+		f.scheduleTimer.Reset(next.Sub(now))
+	}
+
+	outside := !f.schedule.Open(now)
+	if outside == f.outsideSchedule {
+		return
+	}
+	f.outsideSchedule = outside
+	f.setScheduled(outside)
+	if outside {
+		l.Infof("Pausing scans and pulls of folder %v until its schedule opens", f.Description())
+		return
+	}
+	l.Infof("Schedule of folder %v opened, resuming scans and pulls", f.Description())
+	f.scanTimer.Reset(0)
+	f.SchedulePull()
+}
+
 func (f *folder) WatchError() error {
 	f.watchMut.Lock()
 	defer f.watchMut.Unlock()
 	return f.watchErr
 }
 
+// QuotaBlocked returns the counts of the needed files that weren't pulled
+// during the last puller iteration, as they would have made the folder
+// exceed its maximum size.
+func (f *folder) QuotaBlocked() db.Counts {
+	f.quotaMut.Lock()
+	defer f.quotaMut.Unlock()
+	return f.quotaBlocked
+}
+
+// setQuotaBlocked records what was blocked by the quota in a puller
+// iteration and should be called regardless of whether anything was.
+func (f *folder) setQuotaBlocked(blocked db.Counts, maxBytes int64) {
+	f.quotaMut.Lock()
+	prev := f.quotaBlocked
+	f.quotaBlocked = blocked
+	f.quotaMut.Unlock()
+	if blocked.Files == 0 || (blocked.Files == prev.Files && blocked.Bytes == prev.Bytes) {
+		return
+	}
+	if prev.Files == 0 {
+		l.Infof("Not pulling %d files (%d bytes) in folder %s as it would exceed its maximum size of %d bytes", blocked.Files, blocked.Bytes, f.Description(), maxBytes)
+	}
+	f.evLogger.Log(events.FolderQuotaExceeded, map[string]interface{}{
+		"folder":        f.ID,
+		"maxFolderSize": maxBytes,
+		"blockedFiles":  blocked.Files,
+		"blockedBytes":  blocked.Bytes,
+	})
+}
+
 // stopWatch immediately aborts watching and may be called asynchronously
 func (f *folder) stopWatch() {
 	f.watchMut.Lock()
@@ -754,7 +882,7 @@ func (f *folder) monitorWatch(ctx context.Context) {
 	for {
 		select {
 		case <-failTimer.C:
-			eventChan, errChan, err = f.Filesystem().Watch(".", f.ignores, ctx, f.IgnorePerms)
+			eventChan, errChan, err = f.watch(ctx)
 			// We do this once per minute initially increased to
 			// max one hour in case of repeat failures.
 			f.scanOnWatchErr()
@@ -799,6 +927,32 @@ func (f *folder) monitorWatch(ctx context.Context) {
 	}
 }
 
+// watch starts watching the folder for changes, by polling if so
+// configured or if native filesystem notifications are unavailable.
+func (f *folder) watch(ctx context.Context) (<-chan fs.Event, <-chan error, error) {
+	if f.FSWatcherPoll {
+		return f.pollWatchFilesystem().Watch(".", f.ignores, ctx, f.IgnorePerms)
+	}
+	eventChan, errChan, err := f.Filesystem().Watch(".", f.ignores, ctx, f.IgnorePerms)
+	if err == nil {
+		return eventChan, errChan, nil
+	}
+	l.Infof("Filesystem notifications are unavailable for folder %s, polling for changes instead: %v", f.Description(), err)
+	return f.pollWatchFilesystem().Watch(".", f.ignores, ctx, f.IgnorePerms)
+}
+
+func (f *folder) pollWatchFilesystem() fs.Filesystem {
+	interval := defaultPollWatchInterval
+	if f.FSWatcherPollIntervalS > 0 {
+		interval = time.Duration(f.FSWatcherPollIntervalS) * time.Second
+	}
+	maxStats := defaultPollWatchMaxStats
+	if f.FSWatcherPollMaxStats > 0 {
+		maxStats = f.FSWatcherPollMaxStats
+	}
+	return fs.NewPollWatchFilesystem(f.Filesystem(), interval, maxStats)
+}
+
 // setWatchError sets the current error state of the watch and should be called
 // regardless of whether err is nil or not.
 func (f *folder) setWatchError(err error, nextTryIn time.Duration) {
@@ -879,6 +1033,96 @@ func (f *folder) pullBasePause() time.Duration {
 	return time.Duration(f.PullerPauseS) * time.Second
 }
 
+// checkXattrsSupported probes whether the filesystem supports extended
+// attributes, if we sync them.
+func (f *folder) checkXattrsSupported() {
+	supported := false
+	if f.SyncXattrs {
+		_, err := f.Filesystem().ListXattr(".")
+		supported = err != fs.ErrXattrsNotSupported
+	}
+	f.xattrsMut.Lock()
+	f.xattrsSupported = supported
+	f.xattrsMut.Unlock()
+}
+
+// xattrFilter returns the filter selecting the extended attributes to sync,
+// or nil if we don't sync them or the filesystem didn't support them at the
+// last health check.
+func (f *folder) xattrFilter() fs.XattrFilter {
+	f.xattrsMut.Lock()
+	supported := f.xattrsSupported
+	f.xattrsMut.Unlock()
+	if !supported {
+		return nil
+	}
+	return f.XattrFilter
+}
+
+// SetSelection changes the files pulled for selective sync. It takes effect
+// at the next pull.
+func (f *folder) SetSelection(sel db.Selection) {
+	f.selectionMut.Lock()
+	f.nextSelection = &sel
+	f.selectionMut.Unlock()
+	f.SchedulePull()
+}
+
+// updateSelection switches to the selection given to SetSelection, if any.
+// The newly selected paths are scanned, as local copies that were removed
+// need to be pulled again.
+func (f *folder) updateSelection() error {
+	f.selectionMut.Lock()
+	next := f.nextSelection
+	f.nextSelection = nil
+	f.selectionMut.Unlock()
+	if next == nil {
+		return nil
+	}
+
+	prev := f.selection
+	f.selection = *next
+	f.fset.SetSelection(*next)
+	f.unselectedRemoved = false
+
+	if next.All() {
+		if prev.All() {
+			return nil
+		}
+		return f.scanSubdirs(nil)
+	}
+	var subs []string
+	for _, p := range next.Paths() {
+		if !prev.All() && !selectionContains(prev, p) {
+			subs = append(subs, osutil.NativeFilename(p))
+		}
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	return f.scanSubdirs(subs)
+}
+
+// selectionContains returns true if the given path is one of the selected
+// paths, or inside one.
+func selectionContains(sel db.Selection, name string) bool {
+	for _, p := range sel.Paths() {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedFn returns the scanner's filter for selective sync, or nil if
+// everything is selected.
+func (f *folder) selectedFn() func(string) bool {
+	if f.selection.All() {
+		return nil
+	}
+	return f.selection.Selected
+}
+
 func (f *folder) String() string {
 	return fmt.Sprintf("%s/%s@%p", f.Type, f.folderID, f)
 }
@@ -0,0 +1,138 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+func init() {
+	folderFactories[config.FolderTypeIndexOnly] = newIndexOnlyFolder
+}
+
+/*
+indexOnlyFolder is a folder that keeps the full global index, but only pulls
+the contents of files that are explicitly fetched.
+
+It is selective sync where nothing is selected until fetched: the fetched
+paths are the folder's selected paths in the config. Fetching adds a path,
+which gets rescanned and pulled. Evicting removes a path, after which the
+puller removes the local copies and flags them as unselected, keeping the
+index entries.
+
+Implementation wise an indexOnlyFolder is just a sendReceiveFolder with a
+restricted selection.
+*/
+type indexOnlyFolder struct {
+	*sendReceiveFolder
+}
+
+func newIndexOnlyFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg config.FolderConfiguration, ver versioner.Versioner, fs fs.Filesystem, evLogger events.Logger, ioLimiter *byteSemaphore) service {
+	sr := newSendReceiveFolder(model, fset, ignores, cfg, ver, fs, evLogger, ioLimiter).(*sendReceiveFolder)
+	return &indexOnlyFolder{sr}
+}
+
+// folderSelection returns the files pulled in the given folder.
+func folderSelection(cfg config.FolderConfiguration) db.Selection {
+	if cfg.Type == config.FolderTypeIndexOnly {
+		return db.NewRestrictedSelection(cfg.SelectedPaths)
+	}
+	return db.NewSelection(cfg.SelectedPaths)
+}
+
+// Fetch makes an index only folder pull the given file or directory, and
+// keep it in sync until it's evicted.
+func (m *model) Fetch(folder, file string) error {
+	cfg, fset, err := m.indexOnlyFolder(folder)
+	if err != nil {
+		return err
+	}
+	snap := fset.Snapshot()
+	gf, ok := snap.GetGlobalTruncated(file)
+	snap.Release()
+	if !ok || gf.IsDeleted() || gf.IsInvalid() {
+		return fmt.Errorf("%v: no such file in folder", file)
+	}
+
+	sel := db.NewRestrictedSelection(append(append([]string(nil), cfg.SelectedPaths...), file))
+	return m.setFetched(cfg, sel.Paths())
+}
+
+// Evict makes an index only folder remove its copy of the given file or
+// directory, which must have been fetched before. Fetched paths inside it
+// are evicted as well.
+func (m *model) Evict(folder, file string) error {
+	cfg, _, err := m.indexOnlyFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	// Evicting the root evicts everything.
+	var name string
+	if paths := db.NewSelection([]string{file}).Paths(); len(paths) > 0 {
+		name = paths[0]
+	}
+	var fetched []string
+	for _, p := range folderSelection(cfg).Paths() {
+		switch {
+		case name == "" || p == name || strings.HasPrefix(p, name+"/"):
+			// Evicted
+		case strings.HasPrefix(name, p+"/"):
+			return fmt.Errorf("%v: part of fetched %v, which can only be evicted as a whole", file, p)
+		default:
+			fetched = append(fetched, p)
+		}
+	}
+	return m.setFetched(cfg, fetched)
+}
+
+func (m *model) indexOnlyFolder(folder string) (config.FolderConfiguration, *db.FileSet, error) {
+	m.fmut.RLock()
+	err := m.checkFolderRunningLocked(folder)
+	fset := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if err != nil {
+		return config.FolderConfiguration{}, nil, err
+	}
+	// The fetched paths change without restarting the folder, so the
+	// running folder's config isn't up to date.
+	cfg, _ := m.cfg.Folder(folder)
+	if cfg.Type != config.FolderTypeIndexOnly {
+		return config.FolderConfiguration{}, nil, errNotIndexOnly
+	}
+	return cfg, fset, nil
+}
+
+func (m *model) setFetched(cfg config.FolderConfiguration, paths []string) error {
+	if len(paths) == len(cfg.SelectedPaths) {
+		changed := false
+		for i := range paths {
+			if paths[i] != cfg.SelectedPaths[i] {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+	cfg.SelectedPaths = paths
+	waiter, err := m.cfg.SetFolder(cfg)
+	if err != nil {
+		return err
+	}
+	waiter.Wait()
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestIndexOnlyFetchEvict(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.Type = config.FolderTypeIndexOnly
+	waiter, err := w.SetFolder(fcfg)
+	must(t, err)
+	waiter.Wait()
+	m, fc := setupModelWithConnectionFromWrapper(w)
+	tfs := fcfg.Filesystem()
+	defer cleanupModelAndRemoveDir(m, tfs.URI())
+
+	// Index updates for b, as pulled and as evicted.
+	b := filepath.FromSlash("a/b")
+	pulled := make(chan struct{}, 1)
+	evicted := make(chan struct{}, 1)
+	fc.mut.Lock()
+	fc.indexFn = func(_ context.Context, folder string, fs []protocol.FileInfo) {
+		for _, f := range fs {
+			if f.Name != b {
+				continue
+			}
+			ch := pulled
+			if f.IsInvalid() {
+				ch = evicted
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+	fc.mut.Unlock()
+	fc.addFile("a", 0755, protocol.FileInfoTypeDirectory, nil)
+	fc.addFile(b, 0644, protocol.FileInfoTypeFile, []byte("b"))
+	fc.addFile("c", 0644, protocol.FileInfoTypeFile, []byte("c"))
+	fc.sendIndexUpdate()
+
+	// Everything is indexed, nothing needed.
+	snap := dbSnapshot(t, m, "default")
+	if gs := snap.GlobalSize(); gs.Files != 2 || gs.Directories != 1 {
+		t.Errorf("unexpected global size %v", gs)
+	}
+	if need := snap.NeedSize(protocol.LocalDeviceID); need.TotalItems() != 0 {
+		t.Errorf("unexpected need %v", need)
+	}
+	snap.Release()
+
+	if err := m.Fetch("default", "d"); err == nil {
+		t.Error("fetching a nonexistent file should fail")
+	}
+
+	must(t, m.Fetch("default", "a"))
+	select {
+	case <-pulled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a/b to be pulled")
+	}
+	if err := equalContents(filepath.Join(tfs.URI(), b), []byte("b")); err != nil {
+		t.Error("a/b did not sync correctly:", err)
+	}
+	if _, err := tfs.Lstat("c"); !fs.IsNotExist(err) {
+		t.Error("c should not have been pulled:", err)
+	}
+
+	if err := m.Evict("default", b); err == nil {
+		t.Error("evicting part of a fetched directory should fail")
+	}
+
+	must(t, m.Evict("default", "a"))
+	select {
+	case <-evicted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a/b to be evicted")
+	}
+	if _, err := tfs.Lstat(b); !fs.IsNotExist(err) {
+		t.Error("a/b should have been removed:", err)
+	}
+	if cfg, _ := w.Folder("default"); len(cfg.SelectedPaths) != 0 {
+		t.Errorf("unexpected fetched paths %v", cfg.SelectedPaths)
+	}
+	if lf, ok := m.CurrentFolderFile("default", b); !ok || !lf.IsUnselected() {
+		t.Errorf("a/b should be indexed as unselected, got %v", lf)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+func init() {
+	folderFactories[config.FolderTypeReceiveEncrypted] = newReceiveEncryptedFolder
+}
+
+/*
+receiveEncryptedFolder is a folder on an untrusted device, which only ever
+gets encrypted data: The names, contents and metadata of the files are
+encrypted by the trusted devices, which set a password for us, and decrypted
+again when they pull from us.
+
+The encrypted files are stored and served like any other, except that the
+block hashes aren't of the data we have, so there is nothing to verify it
+against. Local changes are kept from propagating like in receive only
+folders.
+
+The trusted devices send a token in the cluster config proving they know the
+password. We remember the first one we get and refuse devices with another
+one, as they would encrypt with a different key.
+*/
+type receiveEncryptedFolder struct {
+	*receiveOnlyFolder
+}
+
+func newReceiveEncryptedFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg config.FolderConfiguration, ver versioner.Versioner, fs fs.Filesystem, evLogger events.Logger, ioLimiter *byteSemaphore) service {
+	ro := newReceiveOnlyFolder(model, fset, ignores, cfg, ver, fs, evLogger, ioLimiter).(*receiveOnlyFolder)
+	return &receiveEncryptedFolder{ro}
+}
+
+var errEncryptionTokenStored = errors.New("got password token for encrypted folder, reconnecting")
+
+func encryptionTokenKey(folder string) string {
+	return "encryptionToken/" + folder
+}
+
+// encryptionToken returns the password token we got for the given receive
+// encrypted folder, if any.
+func (m *model) encryptionToken(folder string) []byte {
+	token, _, _ := db.NewMiscDataNamespace(m.db).Bytes(encryptionTokenKey(folder))
+	return token
+}
+
+// ccCheckEncryption checks that the device and we agree on what is
+// encrypted in the given folder, and on the password. Anything else would
+// have us send plaintext to an untrusted device, or store encrypted data
+// as if it was plaintext.
+func (m *model) ccCheckEncryption(fcfg config.FolderConfiguration, folder protocol.Folder, deviceID protocol.DeviceID, caps protocol.Capabilities) error {
+	var ourToken, theirToken []byte
+	for _, dev := range folder.Devices {
+		switch dev.ID {
+		case m.id:
+			ourToken = dev.EncryptionPasswordToken
+		case deviceID:
+			theirToken = dev.EncryptionPasswordToken
+		}
+	}
+
+	if fcfg.Type == config.FolderTypeReceiveEncrypted {
+		if len(theirToken) > 0 {
+			// Another untrusted device, which sends us the same encrypted
+			// data we have.
+			return nil
+		}
+		if len(ourToken) == 0 {
+			return fmt.Errorf("folder %v is receive encrypted, but device %v has no password set for us", folder.Description(), deviceID)
+		}
+		token := m.encryptionToken(folder.ID)
+		if len(token) == 0 {
+			if err := db.NewMiscDataNamespace(m.db).PutBytes(encryptionTokenKey(folder.ID), ourToken); err != nil {
+				return err
+			}
+			// We didn't announce the folder without a token, which we
+			// now can after reconnecting.
+			return errEncryptionTokenStored
+		}
+		if !bytes.Equal(token, ourToken) {
+			return fmt.Errorf("device %v has a different password for folder %v than other devices", deviceID, folder.Description())
+		}
+		return nil
+	}
+
+	fdcfg, _ := fcfg.Device(deviceID)
+	if fdcfg.EncryptionPassword == "" {
+		if len(theirToken) > 0 {
+			return fmt.Errorf("folder %v is receive encrypted on device %v, but we have no password set for it", folder.Description(), deviceID)
+		}
+		return nil
+	}
+	if len(theirToken) == 0 {
+		if !caps.Has(protocol.CapabilityEncryption) {
+			return fmt.Errorf("we have a password set for folder %v on device %v, which doesn't support encrypted folders", folder.Description(), deviceID)
+		}
+		return fmt.Errorf("folder %v is not receive encrypted on device %v, but we have a password set for it", folder.Description(), deviceID)
+	}
+	if !bytes.Equal(theirToken, protocol.PasswordToken(folder.ID, fdcfg.EncryptionPassword)) {
+		return fmt.Errorf("device %v has a different password for folder %v", deviceID, folder.Description())
+	}
+	return nil
+}
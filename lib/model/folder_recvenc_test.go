@@ -0,0 +1,99 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestCCCheckEncryption(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer cleanupModelAndRemoveDir(m, fcfg.Filesystem().URI())
+
+	caps := protocol.LocalCapabilities()
+	token := protocol.PasswordToken(fcfg.ID, "password")
+	otherToken := protocol.PasswordToken(fcfg.ID, "other")
+	folder := func(ours, theirs []byte) protocol.Folder {
+		return protocol.Folder{
+			ID: fcfg.ID,
+			Devices: []protocol.Device{
+				{ID: myID, EncryptionPasswordToken: ours},
+				{ID: device1, EncryptionPasswordToken: theirs},
+			},
+		}
+	}
+
+	// Trusted on both sides.
+	must(t, m.ccCheckEncryption(fcfg, folder(nil, nil), device1, caps))
+	if err := m.ccCheckEncryption(fcfg, folder(nil, token), device1, caps); err == nil {
+		t.Error("expected error for an encrypted folder without password")
+	}
+
+	// We have a password for device1.
+	setPassword := func(password string) {
+		for i := range fcfg.Devices {
+			if fcfg.Devices[i].DeviceID == device1 {
+				fcfg.Devices[i].EncryptionPassword = password
+			}
+		}
+		waiter, err := w.SetFolder(fcfg)
+		must(t, err)
+		waiter.Wait()
+	}
+	deviceToken := func(cc protocol.ClusterConfig, device protocol.DeviceID) []byte {
+		for _, dev := range cc.Folders[0].Devices {
+			if dev.ID == device {
+				return dev.EncryptionPasswordToken
+			}
+		}
+		return nil
+	}
+
+	setPassword("password")
+	must(t, m.ccCheckEncryption(fcfg, folder(nil, token), device1, caps))
+	if err := m.ccCheckEncryption(fcfg, folder(nil, nil), device1, caps); err == nil {
+		t.Error("expected error for an unencrypted folder with password")
+	}
+	if err := m.ccCheckEncryption(fcfg, folder(nil, otherToken), device1, caps); err == nil {
+		t.Error("expected error for mismatching password")
+	}
+	if err := m.ccCheckEncryption(fcfg, folder(nil, nil), device1, 0); err == nil || !strings.Contains(err.Error(), "doesn't support") {
+		t.Errorf("expected error for a device without encryption support, got %v", err)
+	}
+	cc := m.generateClusterConfig(device1)
+	if len(cc.Folders) != 1 || !bytes.Equal(deviceToken(cc, device1), token) {
+		t.Errorf("expected password token for device1, got %v", cc.Folders)
+	}
+
+	// We are untrusted.
+	fcfg.Type = config.FolderTypeReceiveEncrypted
+	setPassword("")
+	if cc := m.generateClusterConfig(device1); len(cc.Folders) != 0 {
+		t.Error("folder shouldn't be announced before getting a token")
+	}
+	if err := m.ccCheckEncryption(fcfg, folder(nil, nil), device1, caps); err == nil {
+		t.Error("expected error for a trusted device without password")
+	}
+	if err := m.ccCheckEncryption(fcfg, folder(token, nil), device1, caps); err != errEncryptionTokenStored {
+		t.Errorf("expected the token to be stored, got %v", err)
+	}
+	must(t, m.ccCheckEncryption(fcfg, folder(token, nil), device1, caps))
+	if err := m.ccCheckEncryption(fcfg, folder(otherToken, nil), device1, caps); err == nil {
+		t.Error("expected error for mismatching password")
+	}
+	must(t, m.ccCheckEncryption(fcfg, folder(nil, token), device1, caps))
+	cc = m.generateClusterConfig(device1)
+	if len(cc.Folders) != 1 || !bytes.Equal(deviceToken(cc, myID), token) {
+		t.Errorf("expected our password token, got %v", cc.Folders)
+	}
+}
@@ -72,6 +72,7 @@ var (
 	errModified                 = errors.New("file modified but not rescanned; will try again later")
 	errUnexpectedDirOnFileDel   = errors.New("encountered directory when trying to remove file/symlink")
 	errIncompatibleSymlink      = errors.New("incompatible symlink entry; rescan with newer Syncthing on source")
+	errFolderQuotaExceeded      = errors.New("pulling this file would exceed the maximum folder size")
 	contextRemovingOldItem      = "removing item to be replaced"
 )
 
@@ -83,6 +84,7 @@ const (
 	dbUpdateShortcutFile
 	dbUpdateHandleSymlink
 	dbUpdateInvalidate
+	dbUpdateKeepLocal
 )
 
 const (
@@ -282,6 +284,10 @@ func (f *sendReceiveFolder) pullerIteration(scanChan chan<- string) int {
 		f.processDeletions(fileDeletions, dirDeletions, snap, dbUpdateChan, scanChan)
 	}
 
+	if err == nil && !f.unselectedRemoved {
+		f.unselectedRemoved = f.removeUnselected(snap, dbUpdateChan, scanChan)
+	}
+
 	// Wait for db updates and scan scheduling to complete
 	close(dbUpdateChan)
 	updateWg.Wait()
@@ -426,6 +432,14 @@ func (f *sendReceiveFolder) processNeeded(snap *db.Snapshot, dbUpdateChan chan<-
 
 	// Process the file queue.
 
+	quota := folderQuota{
+		max:  f.MaxFolderSizeBytes(),
+		used: snap.LocalSize().Bytes,
+	}
+	defer func() {
+		f.setQuotaBlocked(quota.blocked, quota.max)
+	}()
+
 nextFile:
 	for {
 		select {
@@ -482,6 +496,17 @@ nextFile:
 		devices := snap.Availability(fileName)
 		for _, dev := range devices {
 			if _, ok := f.model.Connection(dev); ok {
+				curFile, hasCurFile := snap.Get(protocol.LocalDeviceID, fileName)
+				if hasCurFile && f.inConflict(curFile.Version, fi.Version) && f.conflictOutcome(curFile, fi) == conflictLocalWins {
+					f.keepLocalFile(curFile, fi, dbUpdateChan)
+					f.queue.Done(fileName)
+					continue nextFile
+				}
+				if !quota.reserve(fi, curFile, hasCurFile) {
+					f.newPullError(fileName, errFolderQuotaExceeded)
+					f.queue.Done(fileName)
+					continue nextFile
+				}
 				// Handle the file normally, by coping and pulling, etc.
 				f.handleFile(fi, snap, copyChan)
 				continue nextFile
@@ -494,6 +519,31 @@ nextFile:
 	return changed, fileDeletions, dirDeletions, nil
 }
 
+// A folderQuota keeps track of how much the folder grows during a puller
+// iteration, starting from its size according to the database.
+type folderQuota struct {
+	max     int64 // zero means unlimited
+	used    int64
+	blocked db.Counts
+}
+
+// reserve accounts for replacing the current file, if any, with the given
+// one. It returns false if that would make the folder exceed its maximum
+// size, in which case the file shouldn't be pulled.
+func (q *folderQuota) reserve(file, curFile protocol.FileInfo, hasCurFile bool) bool {
+	growth := file.FileSize()
+	if hasCurFile {
+		growth -= curFile.FileSize()
+	}
+	if q.max > 0 && growth > 0 && q.used+growth > q.max {
+		q.blocked.Files++
+		q.blocked.Bytes += file.FileSize()
+		return false
+	}
+	q.used += growth
+	return true
+}
+
 func popCandidate(buckets map[string][]protocol.FileInfo, key string) (protocol.FileInfo, bool) {
 	cands := buckets[key]
 	if len(cands) == 0 {
@@ -529,6 +579,104 @@ func (f *sendReceiveFolder) processDeletions(fileDeletions map[string]protocol.F
 	}
 }
 
+// removeUnselected removes the local copies of files that aren't selected
+// for selective sync, i.e. after the selection changed. They stay in the db
+// flagged as unselected, so the removal isn't announced as a deletion. Files
+// that no other device has are kept. It returns true if everything was
+// removed.
+func (f *sendReceiveFolder) removeUnselected(snap *db.Snapshot, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) bool {
+	if f.selection.All() {
+		return true
+	}
+
+	var files, dirs []string
+	snap.WithHaveTruncated(protocol.LocalDeviceID, func(intf protocol.FileIntf) bool {
+		if intf.IsDeleted() || intf.IsInvalid() || f.selection.Selected(intf.FileName()) {
+			return true
+		}
+		if intf.IsDirectory() {
+			dirs = append(dirs, intf.FileName())
+		} else {
+			files = append(files, intf.FileName())
+		}
+		return true
+	})
+
+	done := true
+	kept := make(map[string]struct{}) // directories containing kept files
+	remove := func(name string, fn func(cur protocol.FileInfo) error) {
+		cur, ok := snap.Get(protocol.LocalDeviceID, name)
+		if !ok {
+			return
+		}
+		if err := fn(cur); err != nil && !fs.IsNotExist(err) {
+			if err != errModified && err != errDirHasToBeScanned {
+				f.newPullError(name, errors.Wrap(err, "removing unselected item"))
+			}
+			done = false
+			return
+		}
+		l.Debugln(f, "removed unselected", name)
+		cur.SetUnselected(f.shortID)
+		dbUpdateChan <- dbUpdateJob{cur, dbUpdateInvalidate}
+	}
+
+	for _, name := range files {
+		select {
+		case <-f.ctx.Done():
+			return false
+		default:
+		}
+
+		if !availableRemotely(snap, name) {
+			l.Debugln(f, "not removing unselected file nobody else has", name)
+			for dir := filepath.Dir(name); dir != "."; dir = filepath.Dir(dir) {
+				kept[dir] = struct{}{}
+			}
+			continue
+		}
+		remove(name, func(cur protocol.FileInfo) error {
+			if _, err := f.fs.Lstat(cur.Name); fs.IsNotExist(err) {
+				return nil
+			}
+			if err := f.checkToBeDeleted(cur, scanChan); err != nil {
+				return err
+			}
+			return f.deleteItemOnDisk(cur, snap, scanChan)
+		})
+	}
+
+	// Process in reverse order to delete depth first
+	for i := range dirs {
+		select {
+		case <-f.ctx.Done():
+			return false
+		default:
+		}
+
+		dir := dirs[len(dirs)-i-1]
+		if _, ok := kept[dir]; ok {
+			continue
+		}
+		remove(dir, func(cur protocol.FileInfo) error {
+			return f.deleteDirOnDisk(cur.Name, snap, scanChan)
+		})
+	}
+
+	return done
+}
+
+// availableRemotely returns true if a device other than us has the global
+// version of the given file.
+func availableRemotely(snap *db.Snapshot, name string) bool {
+	for _, dev := range snap.Availability(name) {
+		if dev != protocol.LocalDeviceID {
+			return true
+		}
+	}
+	return false
+}
+
 // handleDir creates or updates the given directory
 func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, snap *db.Snapshot, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) {
 	// Used in the defer closure below, updated by the function body. Take
@@ -604,10 +752,17 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, snap *db.Snapshot,
 		// not MkdirAll because the parent should already exist.
 		mkdir := func(path string) error {
 			err = f.fs.Mkdir(path, mode)
-			if err != nil || f.IgnorePerms || file.NoPermissions {
+			if err != nil {
 				return err
 			}
 
+			if err := f.setXattrs(path, file); err != nil {
+				return err
+			}
+			if f.IgnorePerms || file.NoPermissions {
+				return nil
+			}
+
 			// Copy the parent owner and group, if we are supposed to do that.
 			if err := f.maybeCopyOwner(path); err != nil {
 				return err
@@ -637,9 +792,14 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, snap *db.Snapshot,
 		return
 	}
 
-	// The directory already exists, so we just correct the mode bits. (We
-	// don't handle modification times on directories, because that sucks...)
-	// It's OK to change mode bits on stuff within non-writable directories.
+	// The directory already exists, so we just correct the extended
+	// attributes and mode bits. (We don't handle modification times on
+	// directories, because that sucks...) It's OK to change mode bits on
+	// stuff within non-writable directories.
+	if err := f.setXattrs(file.Name, file); err != nil {
+		f.newPullError(file.Name, err)
+		return
+	}
 	if !f.IgnorePerms && !file.NoPermissions {
 		if err := f.fs.Chmod(file.Name, mode|(fs.FileMode(info.Mode())&retainBits)); err != nil {
 			f.newPullError(file.Name, err)
@@ -946,8 +1106,8 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, sn
 		err = errModified
 	default:
 		var fi protocol.FileInfo
-		if fi, err = scanner.CreateFileInfo(stat, target.Name, f.fs); err == nil {
-			if !fi.IsEquivalentOptional(curTarget, f.ModTimeWindow(), f.IgnorePerms, true, protocol.LocalAllFlags) {
+		if fi, err = scanner.CreateFileInfo(stat, target.Name, f.fs, nil); err == nil {
+			if !fi.IsEquivalentOptional(curTarget, f.ModTimeWindow(), f.IgnorePerms, true, true, protocol.LocalAllFlags) {
 				// Target changed
 				scanChan <- target.Name
 				err = errModified
@@ -1139,8 +1299,8 @@ func populateOffsets(blocks []protocol.BlockInfo) {
 	}
 }
 
-// shortcutFile sets file mode and modification time, when that's the only
-// thing that has changed.
+// shortcutFile sets file mode, modification time and extended attributes,
+// when that's the only thing that has changed.
 func (f *sendReceiveFolder) shortcutFile(file, curFile protocol.FileInfo, dbUpdateChan chan<- dbUpdateJob) {
 	l.Debugln(f, "taking shortcut on", file.Name)
 
@@ -1162,6 +1322,11 @@ func (f *sendReceiveFolder) shortcutFile(file, curFile protocol.FileInfo, dbUpda
 
 	f.queue.Done(file.Name)
 
+	if err = f.setXattrs(file.Name, file); err != nil {
+		f.newPullError(file.Name, err)
+		return
+	}
+
 	if !f.IgnorePerms && !file.NoPermissions {
 		if err = f.fs.Chmod(file.Name, fs.FileMode(file.Permissions&0777)); err != nil {
 			f.newPullError(file.Name, err)
@@ -1273,8 +1438,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 					return true
 				}
 
-				_, err = f.limitedWriteAt(dstFd, buf, block.Offset)
-				if err != nil {
+				if err := f.copyBlock(dstFd, file, offset, block, buf); err != nil {
 					state.fail(errors.Wrap(err, "dst write"))
 				}
 				if offset == block.Offset {
@@ -1297,8 +1461,10 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 						return false
 					}
 
-					_, err = fd.ReadAt(buf, int64(state.file.BlockSize())*int64(index))
-					fd.Close()
+					defer fd.Close()
+
+					srcOffset := int64(state.file.BlockSize()) * int64(index)
+					_, err = fd.ReadAt(buf, srcOffset)
 					if err != nil {
 						return false
 					}
@@ -1308,8 +1474,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 						return false
 					}
 
-					_, err = f.limitedWriteAt(dstFd, buf, block.Offset)
-					if err != nil {
+					if err := f.copyBlock(dstFd, fd, srcOffset, block, buf); err != nil {
 						state.fail(errors.Wrap(err, "dst write"))
 					}
 					if path == state.file.Name {
@@ -1344,6 +1509,41 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 	}
 }
 
+// copyBlock writes the block, read from src at srcOffset into buf and
+// verified, to the temporary file. Unless we're using the standard copy range
+// method the filesystem is asked to copy the data itself first, which may let
+// the files share storage. As the source may have changed since it was read
+// into buf, the copied data is verified again. Should copying or verifying
+// fail we write the buffer as usual, replacing whatever was copied.
+func (f *sendReceiveFolder) copyBlock(dstFd *lockedWriterAt, src fs.File, srcOffset int64, block protocol.BlockInfo, buf []byte) error {
+	if f.CopyRangeMethod != fs.CopyRangeMethodStandard {
+		if err := f.writeLimiter.takeWithContext(f.ctx, 1); err != nil {
+			return err
+		}
+		err := dstFd.CopyRange(f.CopyRangeMethod, src, srcOffset, block.Offset, int64(block.Size))
+		f.writeLimiter.give(1)
+		if err == nil {
+			err = verifyCopiedBlock(dstFd, block)
+		}
+		if err == nil {
+			return nil
+		}
+		l.Debugf("%v copy range (%v) of block at offset %d in %s: %v", f, f.CopyRangeMethod, block.Offset, src.Name(), err)
+	}
+	_, err := f.limitedWriteAt(dstFd, buf, block.Offset)
+	return err
+}
+
+// verifyCopiedBlock verifies the block as it ended up in the temporary file.
+func verifyCopiedBlock(dstFd *lockedWriterAt, block protocol.BlockInfo) error {
+	buf := protocol.BufferPool.Get(int(block.Size))
+	defer protocol.BufferPool.Put(buf)
+	if _, err := dstFd.ReadAt(buf, block.Offset); err != nil {
+		return err
+	}
+	return verifyBuffer(buf, block)
+}
+
 func verifyBuffer(buf []byte, block protocol.BlockInfo) error {
 	if len(buf) != int(block.Size) {
 		return fmt.Errorf("length mismatch %d != %d", len(buf), block.Size)
@@ -1441,7 +1641,8 @@ func (f *sendReceiveFolder) pullBlock(state pullBlockState, out chan<- *sharedPu
 		// leastBusy can select another device when someone else asks.
 		activity.using(selected)
 		var buf []byte
-		buf, lastError = f.model.requestGlobal(f.ctx, selected.ID, f.folderID, state.file.Name, state.block.Offset, int(state.block.Size), state.block.Hash, state.block.WeakHash, selected.FromTemporary)
+		blockNo := int(state.block.Offset / int64(state.file.BlockSize()))
+		buf, lastError = f.model.requestGlobal(f.ctx, selected.ID, f.folderID, state.file.Name, blockNo, state.block.Offset, int(state.block.Size), state.block.Hash, state.block.WeakHash, selected.FromTemporary)
 		activity.done(selected)
 		if lastError != nil {
 			l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, "returned error:", lastError)
@@ -1449,8 +1650,11 @@ func (f *sendReceiveFolder) pullBlock(state pullBlockState, out chan<- *sharedPu
 		}
 
 		// Verify that the received block matches the desired hash, if not
-		// try pulling it from another device.
-		lastError = verifyBuffer(buf, state.block)
+		// try pulling it from another device. Encrypted data can't be
+		// verified, the hashes are of the plaintext.
+		if f.Type != config.FolderTypeReceiveEncrypted {
+			lastError = verifyBuffer(buf, state.block)
+		}
 		if lastError != nil {
 			l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, "hash mismatch")
 			continue
@@ -1469,6 +1673,11 @@ func (f *sendReceiveFolder) pullBlock(state pullBlockState, out chan<- *sharedPu
 }
 
 func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCurFile bool, tempName string, snap *db.Snapshot, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) error {
+	// Set the extended attributes while the file is still writable
+	if err := f.setXattrs(tempName, file); err != nil {
+		return err
+	}
+
 	// Set the correct permission bits on the new file
 	if !f.IgnorePerms && !file.NoPermissions {
 		if err := f.fs.Chmod(tempName, fs.FileMode(file.Permissions&0777)); err != nil {
@@ -1481,6 +1690,7 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 		return err
 	}
 
+	merged := false
 	if stat, err := f.fs.Lstat(file.Name); err == nil {
 		// There is an old file or directory already in place. We need to
 		// handle that.
@@ -1494,14 +1704,26 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 		if !curFile.IsDirectory() && !curFile.IsSymlink() && f.inConflict(curFile.Version, file.Version) {
 			// The new file has been changed in conflict with the existing one. We
 			// should file it away as a conflict instead of just removing or
-			// archiving. Also merge with the version vector we had, to indicate
-			// we have resolved the conflict.
+			// archiving, unless the conflict strategy let the new file win.
+			// Also merge with the version vector we had, to indicate we have
+			// resolved the conflict.
 			// Directories and symlinks aren't checked for conflicts.
 
 			file.Version = file.Version.Merge(curFile.Version)
-			err = f.inWritableDir(func(name string) error {
-				return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-			}, curFile.Name)
+			switch {
+			case f.conflictOutcome(curFile, file) == conflictRemoteWins:
+				l.Infof("Conflict for %v in folder %v resolved in favour of the remote version (%v)", file.Name, f.Description(), f.ConflictStrategy)
+				err = f.deleteItemOnDisk(curFile, snap, scanChan)
+			case f.mergeConflict(curFile, file, tempName):
+				// The merged file replaces the local one, which is
+				// archived like any other replaced file.
+				merged = true
+				err = f.deleteItemOnDisk(curFile, snap, scanChan)
+			default:
+				err = f.inWritableDir(func(name string) error {
+					return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
+				}, curFile.Name)
+			}
 		} else {
 			err = f.deleteItemOnDisk(curFile, snap, scanChan)
 		}
@@ -1516,8 +1738,17 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 		return err
 	}
 
-	// Set the correct timestamp on the new file
-	f.fs.Chtimes(file.Name, file.ModTime(), file.ModTime()) // never fails
+	if merged {
+		// The disk holds neither side's content, so don't record the
+		// remote blocks for it. The entry keeps the merged version vector
+		// but must be rescanned, which picks up the merged content as a new
+		// version superseding both sides.
+		file.SetMustRescan(f.shortID)
+		scanChan <- file.Name
+	} else {
+		// Set the correct timestamp on the new file
+		f.fs.Chtimes(file.Name, file.ModTime(), file.ModTime()) // never fails
+	}
 
 	// Record the updated file in the index
 	dbUpdateChan <- dbUpdateJob{file, dbUpdateHandleFile}
@@ -1628,9 +1859,9 @@ loop:
 				changedDirs[filepath.Dir(job.file.Name)] = struct{}{}
 			case dbUpdateHandleDir:
 				changedDirs[job.file.Name] = struct{}{}
-			case dbUpdateHandleSymlink, dbUpdateInvalidate:
-				// fsyncing symlinks is only supported by MacOS
-				// and invalidated files are db only changes -> no sync
+			case dbUpdateHandleSymlink, dbUpdateInvalidate, dbUpdateKeepLocal:
+				// fsyncing symlinks is only supported by MacOS,
+				// invalidated and kept files are db only changes -> no sync
 			}
 
 			// For some reason we seem to care about file deletions and
@@ -1841,14 +2072,14 @@ func (f *sendReceiveFolder) deleteDirOnDisk(dir string, snap *db.Snapshot, scanC
 			scanChan <- fullDirFile
 			hasToBeScanned = true
 			continue
-		case ok && f.Type == config.FolderTypeReceiveOnly && cf.IsReceiveOnlyChanged():
+		case ok && (f.Type == config.FolderTypeReceiveOnly || f.Type == config.FolderTypeReceiveEncrypted) && cf.IsReceiveOnlyChanged():
 			hasReceiveOnlyChanged = true
 			continue
 		}
 		info, err := f.fs.Lstat(fullDirFile)
 		var diskFile protocol.FileInfo
 		if err == nil {
-			diskFile, err = scanner.CreateFileInfo(info, fullDirFile, f.fs)
+			diskFile, err = scanner.CreateFileInfo(info, fullDirFile, f.fs, nil)
 		}
 		if err != nil {
 			// Lets just assume the file has changed.
@@ -1856,7 +2087,7 @@ func (f *sendReceiveFolder) deleteDirOnDisk(dir string, snap *db.Snapshot, scanC
 			hasToBeScanned = true
 			continue
 		}
-		if !cf.IsEquivalentOptional(diskFile, f.ModTimeWindow(), f.IgnorePerms, true, protocol.LocalAllFlags) {
+		if !cf.IsEquivalentOptional(diskFile, f.ModTimeWindow(), f.IgnorePerms, true, true, protocol.LocalAllFlags) {
 			// File on disk changed compared to what we have in db
 			// -> schedule scan.
 			scanChan <- fullDirFile
@@ -1920,12 +2151,12 @@ func (f *sendReceiveFolder) scanIfItemChanged(stat fs.FileInfo, item protocol.Fi
 	// to the database. If there's a mismatch here, there might be local
 	// changes that we don't know about yet and we should scan before
 	// touching the item.
-	statItem, err := scanner.CreateFileInfo(stat, item.Name, f.fs)
+	statItem, err := scanner.CreateFileInfo(stat, item.Name, f.fs, nil)
 	if err != nil {
 		return errors.Wrap(err, "comparing item on disk to db")
 	}
 
-	if !statItem.IsEquivalentOptional(item, f.ModTimeWindow(), f.IgnorePerms, true, protocol.LocalAllFlags) {
+	if !statItem.IsEquivalentOptional(item, f.ModTimeWindow(), f.IgnorePerms, true, true, protocol.LocalAllFlags) {
 		return errModified
 	}
 
@@ -1969,6 +2200,24 @@ func (f *sendReceiveFolder) maybeCopyOwner(path string) error {
 	return nil
 }
 
+// setXattrs makes the permitted extended attributes of the item on disk
+// match those of the given file, if we are supposed to sync them.
+func (f *sendReceiveFolder) setXattrs(path string, file protocol.FileInfo) error {
+	filter := f.xattrFilter()
+	if filter == nil {
+		return nil
+	}
+
+	xattrs := make([]fs.Xattr, len(file.Xattrs))
+	for i, x := range file.Xattrs {
+		xattrs[i] = fs.Xattr{Name: x.Name, Value: x.Value}
+	}
+	if err := fs.SetXattrs(f.fs, path, xattrs, filter); err != nil {
+		return errors.Wrap(err, "setting extended attributes")
+	}
+	return nil
+}
+
 func (f *sendReceiveFolder) inWritableDir(fn func(string) error, path string) error {
 	return inWritableDir(fn, f.fs, path, f.IgnorePerms)
 }
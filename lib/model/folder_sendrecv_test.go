@@ -10,15 +10,18 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/ignore"
@@ -83,7 +86,7 @@ func createFile(t *testing.T, name string, fs fs.Filesystem) protocol.FileInfo {
 	f.Close()
 	fi, err := fs.Stat(name)
 	must(t, err)
-	file, err := scanner.CreateFileInfo(fi, name, fs)
+	file, err := scanner.CreateFileInfo(fi, name, fs, nil)
 	must(t, err)
 	return file
 }
@@ -202,6 +205,53 @@ func TestHandleFileWithTemp(t *testing.T) {
 }
 
 func TestCopierFinder(t *testing.T) {
+	methods := []fs.CopyRangeMethod{
+		fs.CopyRangeMethodStandard,
+		fs.CopyRangeMethodIoctl,
+		fs.CopyRangeMethodCopyFileRange,
+		fs.CopyRangeMethodAllWithFallback,
+	}
+	for _, method := range methods {
+		t.Run(method.String(), func(t *testing.T) {
+			testCopierFinder(t, method)
+		})
+	}
+}
+
+func TestCopyBlockChangedSource(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+	f.CopyRangeMethod = fs.CopyRangeMethodAllWithFallback
+	ffs := f.Filesystem()
+
+	verified := []byte("verified data")
+	hash := sha256.Sum256(verified)
+	block := protocol.BlockInfo{Size: int32(len(verified)), Hash: hash[:]}
+
+	// The source changes after the block was read and verified.
+	src, err := ffs.Create("src")
+	must(t, err)
+	defer src.Close()
+	_, err = src.Write([]byte("changed data!"))
+	must(t, err)
+
+	dst, err := ffs.OpenFile("dst", fs.OptReadWrite|fs.OptCreate, 0644)
+	must(t, err)
+	dstFd := &lockedWriterAt{sync.NewRWMutex(), dst}
+	must(t, f.copyBlock(dstFd, src, 0, block, verified))
+	must(t, dstFd.SyncClose(false))
+
+	fd, err := ffs.Open("dst")
+	must(t, err)
+	defer fd.Close()
+	bs, err := ioutil.ReadAll(fd)
+	must(t, err)
+	if !bytes.Equal(bs, verified) {
+		t.Errorf("got %q, expected the verified %q", bs, verified)
+	}
+}
+
+func testCopierFinder(t *testing.T, method fs.CopyRangeMethod) {
 	// After diff between required and existing we should:
 	// Copy: 1, 2, 3, 4, 6, 7, 8
 	// Since there is no existing file, nor a temp file
@@ -219,6 +269,7 @@ func TestCopierFinder(t *testing.T) {
 	requiredFile.Name = "file2"
 
 	m, f := setupSendReceiveFolder(existingFile)
+	f.CopyRangeMethod = method
 	defer cleanupSRFolder(f, m)
 
 	if _, err := prepareTmpFile(f.Filesystem()); err != nil {
@@ -763,7 +814,7 @@ func TestDeleteIgnorePerms(t *testing.T) {
 
 	stat, err := file.Stat()
 	must(t, err)
-	fi, err := scanner.CreateFileInfo(stat, name, ffs)
+	fi, err := scanner.CreateFileInfo(stat, name, ffs, nil)
 	must(t, err)
 	ffs.Chmod(name, 0600)
 	scanChan := make(chan string)
@@ -895,6 +946,96 @@ func TestCopyOwner(t *testing.T) {
 	}
 }
 
+func TestPullXattrs(t *testing.T) {
+	// Verifies that the permitted extended attributes are applied to
+	// directories and files, and the others are left alone.
+
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+	f.folder.FolderConfiguration = config.NewFolderConfiguration(m.id, f.ID, f.Label, fs.FilesystemTypeFake, "/TestPullXattrs")
+	f.folder.FolderConfiguration.SyncXattrs = true
+	f.folder.FolderConfiguration.XattrFilter = config.XattrFilter{Allow: []string{"user"}}
+
+	f.fs = f.Filesystem()
+	f.checkXattrsSupported()
+	if f.xattrFilter() == nil {
+		t.Fatal("expected extended attributes to be supported")
+	}
+
+	xattrs := []protocol.Xattr{
+		{Name: "security.foo", Value: []byte("denied")},
+		{Name: "user.foo", Value: []byte("permitted")},
+	}
+	expectXattrs := func(name string, expected []fs.Xattr) {
+		t.Helper()
+		got, err := fs.GetXattrs(f.fs, name, config.XattrFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: got xattrs %v, expected %v", name, got, expected)
+		}
+	}
+
+	// Have the folder create a directory.
+
+	dir := protocol.FileInfo{
+		Name:        "dir",
+		Type:        protocol.FileInfoTypeDirectory,
+		Permissions: 0755,
+		Xattrs:      xattrs,
+	}
+
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	scanChan := make(chan string)
+	defer close(dbUpdateChan)
+	f.handleDir(dir, f.fset.Snapshot(), dbUpdateChan, scanChan)
+	select {
+	case <-dbUpdateChan:
+	case toScan := <-scanChan:
+		t.Fatal("Unexpected receive on scanChan:", toScan)
+	}
+	expectXattrs("dir", []fs.Xattr{{Name: "user.foo", Value: []byte("permitted")}})
+
+	// Have the folder create a file, through the copier and finisher.
+
+	file := protocol.FileInfo{
+		Name:        "dir/file",
+		Type:        protocol.FileInfoTypeFile,
+		Permissions: 0644,
+		Xattrs:      xattrs,
+	}
+
+	snap := f.fset.Snapshot()
+	finisherChan := make(chan *sharedPullerState)
+	copierChan, copyWg := startCopier(f, nil, finisherChan)
+	go f.finisherRoutine(snap, finisherChan, dbUpdateChan, nil)
+	defer func() {
+		close(copierChan)
+		copyWg.Wait()
+		close(finisherChan)
+	}()
+
+	f.handleFile(file, snap, copierChan)
+	<-dbUpdateChan
+	expectXattrs("dir/file", []fs.Xattr{{Name: "user.foo", Value: []byte("permitted")}})
+
+	// A metadata only change replaces the permitted attributes and keeps
+	// the others.
+
+	if err := f.fs.SetXattr("dir/file", "trusted.bar", []byte("local")); err != nil {
+		t.Fatal(err)
+	}
+	changed := file
+	changed.Xattrs = []protocol.Xattr{{Name: "user.bar", Value: []byte("new")}}
+	f.shortcutFile(changed, file, dbUpdateChan)
+	<-dbUpdateChan
+	expectXattrs("dir/file", []fs.Xattr{
+		{Name: "trusted.bar", Value: []byte("local")},
+		{Name: "user.bar", Value: []byte("new")},
+	})
+}
+
 // TestSRConflictReplaceFileByDir checks that a conflict is created when an existing file
 // is replaced with a directory and versions are conflicting
 func TestSRConflictReplaceFileByDir(t *testing.T) {
@@ -1076,3 +1217,128 @@ func startCopier(f *sendReceiveFolder, pullChan chan<- pullBlockState, finisherC
 	}()
 	return copyChan, wg
 }
+
+func TestFolderQuota(t *testing.T) {
+	file := func(size int64) protocol.FileInfo {
+		return protocol.FileInfo{Name: "file", Type: protocol.FileInfoTypeFile, Size: size}
+	}
+
+	q := folderQuota{max: 100, used: 50}
+
+	// Replacing a file with a smaller one is always fine, and frees space.
+	if !q.reserve(file(10), file(40), true) {
+		t.Error("shrinking a file should be permitted")
+	}
+	if q.used != 20 {
+		t.Errorf("used %d, expected 20", q.used)
+	}
+
+	// New files and growing files are permitted up to the maximum.
+	if !q.reserve(file(50), protocol.FileInfo{}, false) {
+		t.Error("new file within quota should be permitted")
+	}
+	if !q.reserve(file(40), file(10), true) {
+		t.Error("growing file within quota should be permitted")
+	}
+	if q.used != 100 {
+		t.Errorf("used %d, expected 100", q.used)
+	}
+	if q.reserve(file(1), protocol.FileInfo{}, false) {
+		t.Error("new file exceeding quota should be refused")
+	}
+	if q.reserve(file(30), file(20), true) {
+		t.Error("growing file exceeding quota should be refused")
+	}
+	if q.used != 100 {
+		t.Errorf("used %d, expected 100", q.used)
+	}
+	if q.blocked.Files != 2 || q.blocked.Bytes != 31 {
+		t.Errorf("blocked %d files, %d bytes, expected 2 files, 31 bytes", q.blocked.Files, q.blocked.Bytes)
+	}
+
+	// Without a maximum everything goes.
+	q = folderQuota{used: 50}
+	if !q.reserve(file(1<<40), protocol.FileInfo{}, false) {
+		t.Error("file should be permitted without quota")
+	}
+}
+
+func TestSetQuotaBlocked(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+
+	sub := m.evLogger.Subscribe(events.FolderQuotaExceeded)
+	defer sub.Unsubscribe()
+
+	f.setQuotaBlocked(db.Counts{Files: 2, Bytes: 1000}, 500)
+	ev, err := sub.Poll(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := ev.Data.(map[string]interface{})
+	if data["folder"] != f.ID || data["blockedFiles"] != int32(2) || data["blockedBytes"] != int64(1000) || data["maxFolderSize"] != int64(500) {
+		t.Errorf("unexpected event data %v", data)
+	}
+	if blocked := m.QuotaBlocked(f.ID); blocked.Files != 2 || blocked.Bytes != 1000 {
+		t.Errorf("unexpected blocked counts %v", blocked)
+	}
+
+	// No news is no event.
+	f.setQuotaBlocked(db.Counts{Files: 2, Bytes: 1000}, 500)
+	f.setQuotaBlocked(db.Counts{}, 500)
+	if ev, err := sub.Poll(100 * time.Millisecond); err == nil {
+		t.Errorf("unexpected event %v", ev)
+	}
+	if blocked := m.QuotaBlocked(f.ID); blocked.Files != 0 {
+		t.Errorf("unexpected blocked counts %v", blocked)
+	}
+}
+
+func TestRemoveUnselected(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+	ffs := f.Filesystem()
+
+	var local []protocol.FileInfo
+	for _, dir := range []string{"a", "b"} {
+		must(t, ffs.Mkdir(dir, 0755))
+		info, err := ffs.Lstat(dir)
+		must(t, err)
+		fi, err := scanner.CreateFileInfo(info, dir, ffs, nil)
+		must(t, err)
+		local = append(local, fi)
+	}
+	for _, name := range []string{"a/x", "b/y", "b/z"} {
+		local = append(local, createFile(t, filepath.FromSlash(name), ffs))
+	}
+	f.updateLocalsFromScanning(local)
+
+	// Everything but b/z is available remotely.
+	f.fset.Update(device1, []protocol.FileInfo{local[0], local[1], local[2], local[3]})
+
+	f.selection = db.NewSelection([]string{"a"})
+	dbUpdateChan := make(chan dbUpdateJob, 10)
+	snap := f.fset.Snapshot()
+	defer snap.Release()
+	if !f.removeUnselected(snap, dbUpdateChan, make(chan string, 10)) {
+		t.Fatal("not everything was removed")
+	}
+	close(dbUpdateChan)
+
+	for _, name := range []string{"a/x", "b/z", "b"} {
+		if _, err := ffs.Lstat(filepath.FromSlash(name)); err != nil {
+			t.Errorf("%v should still exist: %v", name, err)
+		}
+	}
+	if _, err := ffs.Lstat(filepath.FromSlash("b/y")); !fs.IsNotExist(err) {
+		t.Errorf("b/y should have been removed: %v", err)
+	}
+
+	var jobs []dbUpdateJob
+	for job := range dbUpdateChan {
+		jobs = append(jobs, job)
+	}
+	if len(jobs) != 1 || jobs[0].file.Name != filepath.FromSlash("b/y") || !jobs[0].file.IsUnselected() || jobs[0].jobType != dbUpdateInvalidate {
+		t.Errorf("unexpected db updates %v", jobs)
+	}
+}
@@ -123,7 +123,7 @@ func (c *folderSummaryService) Summary(folder string) (map[string]interface{}, e
 	}
 	res["needFiles"], res["needDirectories"], res["needSymlinks"], res["needDeletes"], res["needBytes"], res["needTotalItems"] = need.Files, need.Directories, need.Symlinks, need.Deleted, need.Bytes, need.TotalItems()
 
-	if haveFcfg && fcfg.Type == config.FolderTypeReceiveOnly {
+	if haveFcfg && (fcfg.Type == config.FolderTypeReceiveOnly || fcfg.Type == config.FolderTypeReceiveEncrypted) {
 		// Add statistics for things that have changed locally in a receive
 		// only folder.
 		res["receiveOnlyChangedFiles"] = ro.Files
@@ -136,6 +136,12 @@ func (c *folderSummaryService) Summary(folder string) (map[string]interface{}, e
 
 	res["inSyncFiles"], res["inSyncBytes"] = global.Files-need.Files, global.Bytes-need.Bytes
 
+	if haveFcfg {
+		res["maxFolderSize"] = fcfg.MaxFolderSizeBytes()
+	}
+	blocked := c.model.QuotaBlocked(folder)
+	res["quotaBlockedFiles"], res["quotaBlockedBytes"] = blocked.Files, blocked.Bytes
+
 	res["state"], res["stateChanged"], err = c.model.State(folder)
 	if err != nil {
 		res["error"] = err.Error()
@@ -221,7 +227,7 @@ func (c *folderSummaryService) processUpdate(ev events.Event) {
 
 	case events.StateChanged:
 		data := ev.Data.(map[string]interface{})
-		if data["to"].(string) != "idle" {
+		if to := data["to"].(string); to != "idle" && to != "scheduled" {
 			return
 		}
 		if from := data["from"].(string); from != "syncing" && from != "sync-preparing" {
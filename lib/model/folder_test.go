@@ -7,9 +7,11 @@
 package model
 
 import (
+	"fmt"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 	"github.com/syncthing/syncthing/lib/config"
@@ -148,3 +150,33 @@ func BenchmarkUnifySubs(b *testing.B) {
 		}
 	}
 }
+
+func TestFolderSchedule(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	// A window that doesn't open for an hour.
+	start := time.Now().Add(time.Hour)
+	fcfg.ScheduleWindows = []string{fmt.Sprintf("%s-%s", start.Format("15:04"), start.Add(time.Hour).Format("15:04"))}
+	waiter, err := w.SetFolder(fcfg)
+	must(t, err)
+	waiter.Wait()
+	m := setupModel(w)
+	defer cleanupModelAndRemoveDir(m, fcfg.Filesystem().URI())
+
+	// The initial scan is done regardless of the schedule.
+	if state, _, err := m.State(fcfg.ID); state != "scheduled" {
+		t.Errorf("expected state scheduled, got %v (%v)", state, err)
+	}
+
+	m.fmut.RLock()
+	f := m.folderRunners[fcfg.ID].(*sendReceiveFolder)
+	m.fmut.RUnlock()
+	f.setScheduled(false)
+	if state, _, _ := m.State(fcfg.ID); state != "idle" {
+		t.Errorf("expected state idle, got %v", state)
+	}
+	f.setScheduled(true)
+	f.setError(nil)
+	if state, _, _ := m.State(fcfg.ID); state != "scheduled" {
+		t.Errorf("expected state scheduled after clearing errors, got %v", state)
+	}
+}
@@ -23,6 +23,7 @@ const (
 	FolderSyncPreparing
 	FolderSyncing
 	FolderError
+	FolderScheduled
 )
 
 func (s folderState) String() string {
@@ -41,6 +42,8 @@ func (s folderState) String() string {
 		return "syncing"
 	case FolderError:
 		return "error"
+	case FolderScheduled:
+		return "scheduled"
 	default:
 		return "unknown"
 	}
@@ -50,10 +53,11 @@ type stateTracker struct {
 	folderID string
 	evLogger events.Logger
 
-	mut     sync.Mutex
-	current folderState
-	err     error
-	changed time.Time
+	mut       sync.Mutex
+	current   folderState
+	err       error
+	changed   time.Time
+	scheduled bool // idle is reported as FolderScheduled
 }
 
 func newStateTracker(id string, evLogger events.Logger) stateTracker {
@@ -73,6 +77,29 @@ func (s *stateTracker) setState(newState folderState) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
+	if newState == FolderIdle && s.scheduled {
+		newState = FolderScheduled
+	}
+	s.changeState(newState)
+}
+
+// setScheduled sets whether the folder is outside its schedule, in which
+// case it is FolderScheduled rather than FolderIdle.
+func (s *stateTracker) setScheduled(scheduled bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.scheduled = scheduled
+	switch {
+	case scheduled && s.current == FolderIdle:
+		s.changeState(FolderScheduled)
+	case !scheduled && s.current == FolderScheduled:
+		s.changeState(FolderIdle)
+	}
+}
+
+// changeState must be called with the lock held.
+func (s *stateTracker) changeState(newState folderState) {
 	if newState == s.current {
 		return
 	}
@@ -109,7 +136,7 @@ func (s *stateTracker) getState() (current folderState, changed time.Time, err e
 }
 
 // setError sets the folder state to FolderError with the specified error or
-// to FolderIdle if the error is nil
+// to FolderIdle (or FolderScheduled) if the error is nil
 func (s *stateTracker) setError(err error) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -122,6 +149,8 @@ func (s *stateTracker) setError(err error) {
 	if err != nil {
 		eventData["error"] = err.Error()
 		s.current = FolderError
+	} else if s.scheduled {
+		s.current = FolderScheduled
 	} else {
 		s.current = FolderIdle
 	}
@@ -0,0 +1,238 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/thejerf/suture"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+// The hookService runs the commands configured as hooks of the folders on
+// the events they are set up for. The details of the event are passed in
+// environment variables:
+//
+//   ST_EVENT          the hook event, e.g. itemFinished
+//   ST_FOLDER_ID      the folder ID
+//   ST_FOLDER_LABEL   the folder label
+//   ST_FOLDER_PATH    the folder path
+//   ST_ITEM           the path of the item, relative to the folder
+//   ST_ITEMS          with debouncing, the paths of all items, one per line
+//   ST_ACTION         what happened to the item, e.g. update or deleted
+//   ST_TYPE           the type of the item, e.g. file or dir
+//   ST_ERROR          the error syncing the item, if any
+type hookService struct {
+	cfg      config.Wrapper
+	model    Model
+	evLogger events.Logger
+	limiter  *byteSemaphore
+
+	mut     sync.Mutex
+	pending map[string]*pendingHook
+}
+
+// A pendingHook is a debounced hook waiting for events to stop.
+type pendingHook struct {
+	timer *time.Timer
+	env   map[string]string
+	items []string
+}
+
+func NewHookService(cfg config.Wrapper, m Model, evLogger events.Logger) suture.Service {
+	s := &hookService{
+		cfg:      cfg,
+		model:    m,
+		evLogger: evLogger,
+		limiter:  newByteSemaphore(cfg.Options().MaxConcurrentHooks()),
+		mut:      sync.NewMutex(),
+		pending:  make(map[string]*pendingHook),
+	}
+	return util.AsService(s.serve, s.String())
+}
+
+func (s *hookService) String() string {
+	return fmt.Sprintf("hookService@%p", s)
+}
+
+func (s *hookService) serve(ctx context.Context) {
+	s.cfg.Subscribe(s)
+	defer s.cfg.Unsubscribe(s)
+
+	sub := s.evLogger.Subscribe(events.ItemFinished | events.StateChanged | events.LocalChangeDetected)
+	defer sub.Unsubscribe()
+
+	for {
+		// This loop needs to be fast so we don't miss too many events.
+		select {
+		case ev := <-sub.C():
+			s.processEvent(ctx, ev)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *hookService) processEvent(ctx context.Context, ev events.Event) {
+	var hookEvent config.HookEvent
+	var folder string
+	env := make(map[string]string)
+
+	switch ev.Type {
+	case events.ItemFinished:
+		data := ev.Data.(map[string]interface{})
+		hookEvent = config.HookEventItemFinished
+		folder = data["folder"].(string)
+		env["ST_ITEM"] = data["item"].(string)
+		env["ST_ACTION"] = data["action"].(string)
+		env["ST_TYPE"] = data["type"].(string)
+		if err, ok := data["error"].(*string); ok && err != nil {
+			env["ST_ERROR"] = *err
+		}
+
+	case events.LocalChangeDetected:
+		data := ev.Data.(map[string]string)
+		hookEvent = config.HookEventLocalChangeDetected
+		folder = data["folder"]
+		env["ST_ITEM"] = data["path"]
+		env["ST_ACTION"] = data["action"]
+		env["ST_TYPE"] = data["type"]
+
+	case events.StateChanged:
+		data := ev.Data.(map[string]interface{})
+		if to := data["to"].(string); to != "idle" && to != "scheduled" {
+			return
+		}
+		if from := data["from"].(string); from != "syncing" {
+			return
+		}
+		// Whether the folder is in sync is checked before running the
+		// hook, which isn't done here to keep this loop fast.
+		hookEvent = config.HookEventFolderSynced
+		folder = data["folder"].(string)
+
+	default:
+		return
+	}
+
+	fcfg, ok := s.cfg.Folder(folder)
+	if !ok {
+		return
+	}
+	env["ST_EVENT"] = hookEvent.String()
+	env["ST_FOLDER_ID"] = fcfg.ID
+	env["ST_FOLDER_LABEL"] = fcfg.Label
+	env["ST_FOLDER_PATH"] = fcfg.Filesystem().URI()
+
+	for i, hook := range fcfg.Hooks {
+		if hook.Event == hookEvent && hook.Command != "" {
+			s.trigger(ctx, fmt.Sprintf("%s/%d", fcfg.ID, i), hook, env)
+		}
+	}
+}
+
+// trigger runs the hook, or with debouncing, schedules it to run once the
+// events for it stop.
+func (s *hookService) trigger(ctx context.Context, key string, hook config.FolderHookConfiguration, env map[string]string) {
+	debounce := hook.Debounce()
+	if debounce <= 0 {
+		go s.run(ctx, hook, env, nil)
+		return
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	p, ok := s.pending[key]
+	if ok {
+		p.timer.Reset(debounce)
+	} else {
+		p = &pendingHook{}
+		p.timer = time.AfterFunc(debounce, func() {
+			s.mut.Lock()
+			delete(s.pending, key)
+			env, items := p.env, p.items
+			s.mut.Unlock()
+			s.run(ctx, hook, env, items)
+		})
+		s.pending[key] = p
+	}
+	p.env = env
+	if item, ok := env["ST_ITEM"]; ok {
+		p.items = append(p.items, item)
+	}
+}
+
+func (s *hookService) run(ctx context.Context, hook config.FolderHookConfiguration, env map[string]string, items []string) {
+	if hook.Event == config.HookEventFolderSynced {
+		if comp := s.model.Completion(protocol.LocalDeviceID, env["ST_FOLDER_ID"]); comp.CompletionPct < 100 {
+			l.Debugf("%v: not running hook %q, folder %v not in sync", s, hook.Command, env["ST_FOLDER_ID"])
+			return
+		}
+	}
+
+	if err := s.limiter.takeWithContext(ctx, 1); err != nil {
+		return
+	}
+	defer s.limiter.give(1)
+
+	ctx, cancel := context.WithTimeout(ctx, hook.Timeout())
+	defer cancel()
+
+	if err := runHookCommand(ctx, hook.Command, env, items); err != nil {
+		l.Infof("Running %v hook %q for folder %v: %v", hook.Event, hook.Command, env["ST_FOLDER_LABEL"], err)
+	}
+}
+
+func runHookCommand(ctx context.Context, command string, env map[string]string, items []string) error {
+	if runtime.GOOS == "windows" {
+		command = strings.Replace(command, `\`, `\\`, -1)
+	}
+	words, err := shellquote.Split(command)
+	if err != nil {
+		return fmt.Errorf("command is invalid: %v", err)
+	}
+	if len(words) == 0 {
+		return errors.New("command is empty")
+	}
+
+	cmd := exec.CommandContext(ctx, words[0], words[1:]...)
+	cmd.Env = commandEnviron()
+	for key, val := range env {
+		cmd.Env = append(cmd.Env, key+"="+val)
+	}
+	if items != nil {
+		cmd.Env = append(cmd.Env, "ST_ITEMS="+strings.Join(items, "\n"))
+	}
+	combinedOutput, err := cmd.CombinedOutput()
+	l.Debugln("hook command output:", string(combinedOutput))
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("timed out")
+	}
+	return err
+}
+
+func (s *hookService) VerifyConfiguration(from, to config.Configuration) error {
+	return nil
+}
+
+func (s *hookService) CommitConfiguration(from, to config.Configuration) bool {
+	s.limiter.setCapacity(to.Options.MaxConcurrentHooks())
+	return true
+}
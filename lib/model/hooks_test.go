@@ -0,0 +1,92 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands use sh")
+	}
+
+	dir, err := ioutil.TempDir("", "syncthing-hooks-")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	// Each run appends one line with the event and items.
+	command := `sh -c 'echo "$ST_EVENT $ST_FOLDER_ID $ST_ITEM $ST_ACTION $ST_ERROR|$ST_ITEMS" >> "$0"' ` + out
+
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.Hooks = []config.FolderHookConfiguration{
+		{Event: config.HookEventItemFinished, Command: command},
+		{Event: config.HookEventLocalChangeDetected, Command: command, DebounceS: 1},
+		{Event: config.HookEventFolderSynced, Command: command},
+	}
+	waiter, err := w.SetFolder(fcfg)
+	must(t, err)
+	waiter.Wait()
+	m := setupModel(w)
+	defer cleanupModelAndRemoveDir(m, fcfg.Filesystem().URI())
+
+	svc := NewHookService(w, m, m.evLogger)
+	go svc.Serve()
+	defer svc.Stop()
+	// Let the service subscribe to events.
+	time.Sleep(100 * time.Millisecond)
+
+	waitFor := func(expected string) {
+		t.Helper()
+		var bs []byte
+		for i := 0; i < 100; i++ {
+			bs, _ = ioutil.ReadFile(out)
+			if string(bs) == expected {
+				os.Remove(out)
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("got hook output %q, expected %q", bs, expected)
+	}
+
+	errStr := "failed"
+	m.evLogger.Log(events.ItemFinished, map[string]interface{}{
+		"folder": fcfg.ID,
+		"item":   "foo",
+		"error":  &errStr,
+		"type":   "file",
+		"action": "update",
+	})
+	waitFor("itemFinished default foo update failed|\n")
+
+	for _, name := range []string{"bar", "baz"} {
+		m.evLogger.Log(events.LocalChangeDetected, map[string]string{
+			"folder": fcfg.ID,
+			"action": "modified",
+			"type":   "file",
+			"path":   name,
+		})
+	}
+	waitFor("localChangeDetected default baz modified |bar\nbaz\n")
+
+	m.evLogger.Log(events.StateChanged, map[string]interface{}{
+		"folder": fcfg.ID,
+		"from":   "syncing",
+		"to":     "idle",
+	})
+	waitFor("folderSynced default   |\n")
+}
@@ -0,0 +1,225 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+// mergeCommandTimeout is how long the merge command may run before it's
+// killed and the conflict is handled as if it couldn't be merged.
+const mergeCommandTimeout = time.Minute
+
+// shouldMerge returns whether conflicting changes to the file are merged by
+// the merge command of the folder.
+func (f *sendReceiveFolder) shouldMerge(cur protocol.FileInfo) bool {
+	if f.MergeCommand == "" || cur.Type != protocol.FileInfoTypeFile || cur.IsDeleted() || cur.IsInvalid() {
+		return false
+	}
+	base := filepath.Base(cur.Name)
+	for _, pattern := range f.MergePatterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, cur.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeConflict merges the local file with the conflicting remote one in
+// tempName, using the merge command. The result replaces the content of
+// tempName. If merging fails, false is returned and tempName is untouched.
+func (f *sendReceiveFolder) mergeConflict(cur, file protocol.FileInfo, tempName string) bool {
+	if !f.shouldMerge(cur) {
+		return false
+	}
+	if err := f.merge(cur, file, tempName); err != nil {
+		l.Infof("Merging conflicting changes to %v in folder %v failed, keeping both versions: %v", file.Name, f.Description(), err)
+		return false
+	}
+	l.Infof("Merged conflicting changes to %v in folder %v", file.Name, f.Description())
+	return true
+}
+
+func (f *sendReceiveFolder) merge(cur, file protocol.FileInfo, tempName string) error {
+	dir, err := ioutil.TempDir("", "syncthing-merge-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Keep the extension, as merge tools may go by it.
+	ext := filepath.Ext(file.Name)
+	basePath := filepath.Join(dir, "base"+ext)
+	localPath := filepath.Join(dir, "local"+ext)
+	remotePath := filepath.Join(dir, "remote"+ext)
+
+	if err := f.writeMergeBase(cur, file, basePath); err != nil {
+		return err
+	}
+	if err := f.copyToMerge(file.Name, localPath); err != nil {
+		return err
+	}
+	if err := f.copyToMerge(tempName, remotePath); err != nil {
+		return err
+	}
+
+	if err := f.runMergeCommand(map[string]string{
+		"%BASE%":        basePath,
+		"%LOCAL%":       localPath,
+		"%REMOTE%":      remotePath,
+		"%FOLDER_PATH%": f.fs.URI(),
+		"%FILE_PATH%":   file.Name,
+	}); err != nil {
+		return err
+	}
+
+	merged, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer merged.Close()
+
+	// The temporary file was made read only, if the file is.
+	if err := f.fs.Chmod(tempName, 0644); err != nil {
+		return err
+	}
+	fd, err := f.fs.OpenFile(tempName, fs.OptWriteOnly|fs.OptTruncate, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fd, merged); err != nil {
+		fd.Close()
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		return err
+	}
+	if !f.IgnorePerms && !file.NoPermissions {
+		return f.fs.Chmod(tempName, fs.FileMode(file.Permissions&0777))
+	}
+	return nil
+}
+
+// writeMergeBase writes the last common version of the file to path: The
+// newest version archived by the versioner that isn't newer than either
+// side. Without one the base is empty.
+func (f *sendReceiveFolder) writeMergeBase(cur, file protocol.FileInfo, path string) error {
+	fd, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	if f.versioner == nil {
+		return nil
+	}
+	versions, err := f.versioner.GetFileVersions(file.Name)
+	if err != nil {
+		l.Debugln(f, "no merge base for", file.Name, err)
+		return nil
+	}
+	var base versioner.FileVersion
+	for _, v := range versions {
+		if v.ModTime.After(cur.ModTime()) || v.ModTime.After(file.ModTime()) {
+			continue
+		}
+		if v.VersionTime.After(base.VersionTime) {
+			base = v
+		}
+	}
+	if base.VersionTime.IsZero() {
+		return nil
+	}
+
+	src, err := f.versioner.Open(file.Name, base.VersionTime)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := io.Copy(fd, src); err != nil {
+		return err
+	}
+	return fd.Close()
+}
+
+// copyToMerge copies the named file in the folder to path.
+func (f *sendReceiveFolder) copyToMerge(name, path string) error {
+	src, err := f.fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (f *sendReceiveFolder) runMergeCommand(vars map[string]string) error {
+	command := f.MergeCommand
+	if runtime.GOOS == "windows" {
+		command = strings.Replace(command, `\`, `\\`, -1)
+	}
+
+	words, err := shellquote.Split(command)
+	if err != nil {
+		return errors.New("merge command is invalid: " + err.Error())
+	}
+	if len(words) == 0 {
+		return errors.New("merge command is empty")
+	}
+	for i, word := range words {
+		for key, val := range vars {
+			word = strings.Replace(word, key, val, -1)
+		}
+		words[i] = word
+	}
+
+	ctx, cancel := context.WithTimeout(f.ctx, mergeCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, words[0], words[1:]...)
+	cmd.Env = commandEnviron()
+	combinedOutput, err := cmd.CombinedOutput()
+	l.Debugln("merge command output:", string(combinedOutput))
+	return err
+}
+
+// commandEnviron returns the environment for external commands, without
+// the GUI credentials.
+func commandEnviron() []string {
+	env := os.Environ()
+	filteredEnv := make([]string, 0, len(env))
+	for _, x := range env {
+		if !strings.HasPrefix(x, "STGUIAUTH=") && !strings.HasPrefix(x, "STGUIAPIKEY=") {
+			filteredEnv = append(filteredEnv, x)
+		}
+	}
+	return filteredEnv
+}
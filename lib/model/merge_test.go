@@ -0,0 +1,146 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+func TestMergeConflict(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("merge command uses sh")
+	}
+
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+	ffs := f.Filesystem()
+
+	write := func(name, content string, modTime time.Time) {
+		t.Helper()
+		fd, err := ffs.Create(name)
+		must(t, err)
+		_, err = fd.Write([]byte(content))
+		must(t, err)
+		fd.Close()
+		must(t, ffs.Chtimes(name, modTime, modTime))
+	}
+	read := func(name string) string {
+		t.Helper()
+		fd, err := ffs.Open(name)
+		must(t, err)
+		defer fd.Close()
+		bs, err := ioutil.ReadAll(fd)
+		must(t, err)
+		return string(bs)
+	}
+
+	ver, err := versioner.New(ffs, config.VersioningConfiguration{Type: "simple", Params: map[string]string{"keep": "5"}})
+	must(t, err)
+	f.versioner = ver
+
+	now := time.Now()
+	write("foo.txt", "base\n", now.Add(-time.Hour))
+	must(t, ver.Archive("foo.txt"))
+
+	tempName := fs.TempName("foo.txt")
+	write("foo.txt", "local\n", now)
+	write(tempName, "remote\n", now)
+	cur := protocol.FileInfo{Name: "foo.txt", Type: protocol.FileInfoTypeFile, ModifiedS: now.Unix(), Permissions: 0644}
+	file := protocol.FileInfo{Name: "foo.txt", Type: protocol.FileInfoTypeFile, ModifiedS: now.Unix(), Permissions: 0644}
+
+	// The command concatenates base, local and remote into local.
+	f.MergeCommand = `sh -c 'cat "$0" "$1" "$2" > "$1.tmp" && mv "$1.tmp" "$1"' %BASE% %LOCAL% %REMOTE%`
+
+	if f.mergeConflict(cur, file, tempName) {
+		t.Fatal("merged a file not matching the merge patterns")
+	}
+
+	f.MergePatterns = []string{"*.txt"}
+	if !f.mergeConflict(cur, file, tempName) {
+		t.Fatal("merge failed")
+	}
+	if content := read(tempName); content != "base\nlocal\nremote\n" {
+		t.Errorf("unexpected merge result %q", content)
+	}
+	if content := read("foo.txt"); content != "local\n" {
+		t.Errorf("local file changed to %q", content)
+	}
+
+	write(tempName, "remote\n", now)
+	f.MergeCommand = "false"
+	if f.mergeConflict(cur, file, tempName) {
+		t.Fatal("failing merge command succeeded")
+	}
+	if content := read(tempName); content != "remote\n" {
+		t.Errorf("remote file changed to %q by failed merge", content)
+	}
+}
+
+func TestMergeConflictFinish(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("merge command uses sh")
+	}
+
+	m, f := setupSendReceiveFolder()
+	defer cleanupSRFolder(f, m)
+	ffs := f.Filesystem()
+
+	write := func(name, content string) {
+		t.Helper()
+		fd, err := ffs.Create(name)
+		must(t, err)
+		_, err = fd.Write([]byte(content))
+		must(t, err)
+		fd.Close()
+	}
+
+	f.MergeCommand = `sh -c 'cat "$0" "$1" > "$0.tmp" && mv "$0.tmp" "$0"' %LOCAL% %REMOTE%`
+	f.MergePatterns = []string{"*.txt"}
+
+	write("foo.txt", "local\n")
+	stat, err := ffs.Lstat("foo.txt")
+	must(t, err)
+	cur, err := scanner.CreateFileInfo(stat, "foo.txt", ffs, nil)
+	must(t, err)
+	cur.Version = protocol.Vector{}.Update(myID.Short())
+
+	tempName := fs.TempName("foo.txt")
+	write(tempName, "remote\n")
+	file := cur
+	file.Size = 7
+	file.Blocks = []protocol.BlockInfo{{Size: 7, Hash: []byte("remote")}}
+	file.Version = protocol.Vector{}.Update(device1.Short())
+	file.ModifiedBy = device1.Short()
+
+	snap := f.fset.Snapshot()
+	defer snap.Release()
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	scanChan := make(chan string, 1)
+	must(t, f.performFinish(file, cur, true, tempName, snap, dbUpdateChan, scanChan))
+
+	// The merged content isn't the remote file, so its blocks must not be
+	// recorded; the entry is rescanned instead.
+	job := <-dbUpdateChan
+	if !job.file.MustRescan() || len(job.file.Blocks) != 0 {
+		t.Errorf("merged file recorded as %v", job.file)
+	}
+	if !job.file.Version.GreaterEqual(cur.Version) || !job.file.Version.GreaterEqual(file.Version) {
+		t.Errorf("version %v doesn't supersede both sides", job.file.Version)
+	}
+	if name := <-scanChan; name != "foo.txt" {
+		t.Errorf("expected a scan of foo.txt, got %v", name)
+	}
+}
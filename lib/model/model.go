@@ -11,6 +11,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"path/filepath"
 	"reflect"
@@ -56,8 +57,11 @@ type service interface {
 	Stop()
 	Errors() []FileError
 	WatchError() error
+	QuotaBlocked() db.Counts
 	ScheduleForceRescan(path string)
 	GetStatistics() (stats.FolderStatistics, error)
+	SetSelection(sel db.Selection)
+	ResolveConflict(c Conflict, keepConflict bool, ver versioner.Versioner) error
 
 	getState() (folderState, time.Time, error)
 }
@@ -73,6 +77,7 @@ type Model interface {
 	connections.Model
 
 	ResetFolder(folder string)
+	ExportDatabase(w io.Writer) error
 	DelayScan(folder string, next time.Duration)
 	ScanFolder(folder string) error
 	ScanFolders() map[string]error
@@ -80,8 +85,13 @@ type Model interface {
 	State(folder string) (string, time.Time, error)
 	FolderErrors(folder string) ([]FileError, error)
 	WatchError(folder string) error
+	QuotaBlocked(folder string) db.Counts
 	Override(folder string)
 	Revert(folder string)
+	Fetch(folder, file string) error
+	Evict(folder, file string) error
+	Conflicts(folder string) ([]Conflict, error)
+	ResolveConflict(folder, name string, keepConflict bool) error
 	BringToFront(folder, file string)
 	GetIgnores(folder string) ([]string, []string, error)
 	SetIgnores(folder string, content []string) error
@@ -130,6 +140,9 @@ type model struct {
 	// folderIOLimiter limits the number of concurrent I/O heavy operations,
 	// such as scans and pulls.
 	folderIOLimiter *byteSemaphore
+	// folderBandwidth limits the data rates of folders and weighs them
+	// against each other.
+	folderBandwidth *folderBandwidth
 
 	// fields protected by fmut
 	fmut               sync.RWMutex
@@ -146,7 +159,10 @@ type model struct {
 	pmut                sync.RWMutex
 	conn                map[protocol.DeviceID]connections.Connection
 	connRequestLimiters map[protocol.DeviceID]*byteSemaphore
+	connPullLimiters    map[protocol.DeviceID]*byteSemaphore
 	closed              map[protocol.DeviceID]chan struct{}
+	connCtxs            map[protocol.DeviceID]context.Context // cancelled when the connection closes
+	connCancels         map[protocol.DeviceID]context.CancelFunc
 	helloMessages       map[protocol.DeviceID]protocol.HelloResult
 	deviceDownloads     map[protocol.DeviceID]*deviceDownloadState
 	remotePausedFolders map[protocol.DeviceID][]string // deviceID -> folders
@@ -170,6 +186,7 @@ var (
 	errFolderMissing     = errors.New("no such folder")
 	errNetworkNotAllowed = errors.New("network not allowed")
 	errNoVersioner       = errors.New("folder has no versioner")
+	errNotIndexOnly      = errors.New("folder is not index only")
 	// errors about why a connection is closed
 	errIgnoredFolderRemoved = errors.New("folder no longer ignored")
 	errReplacingConnection  = errors.New("replacing connection")
@@ -204,6 +221,7 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, clientName, clientVersio
 		cacheIgnoredFiles:    cfg.Options().CacheIgnoredFiles,
 		globalRequestLimiter: newByteSemaphore(1024 * cfg.Options().MaxConcurrentIncomingRequestKiB()),
 		folderIOLimiter:      newByteSemaphore(cfg.Options().MaxFolderConcurrency()),
+		folderBandwidth:      newFolderBandwidth(cfg.FolderList()),
 
 		// fields protected by fmut
 		fmut:               sync.NewRWMutex(),
@@ -219,7 +237,10 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, clientName, clientVersio
 		pmut:                sync.NewRWMutex(),
 		conn:                make(map[protocol.DeviceID]connections.Connection),
 		connRequestLimiters: make(map[protocol.DeviceID]*byteSemaphore),
+		connPullLimiters:    make(map[protocol.DeviceID]*byteSemaphore),
 		closed:              make(map[protocol.DeviceID]chan struct{}),
+		connCtxs:            make(map[protocol.DeviceID]context.Context),
+		connCancels:         make(map[protocol.DeviceID]context.CancelFunc),
 		helloMessages:       make(map[protocol.DeviceID]protocol.HelloResult),
 		deviceDownloads:     make(map[protocol.DeviceID]*deviceDownloadState),
 		remotePausedFolders: make(map[protocol.DeviceID][]string),
@@ -288,6 +309,9 @@ func (m *model) addAndStartFolderLocked(cfg config.FolderConfiguration, fset *db
 
 // Only needed for testing, use addAndStartFolderLocked instead.
 func (m *model) addAndStartFolderLockedWithIgnores(cfg config.FolderConfiguration, fset *db.FileSet, ignores *ignore.Matcher) {
+	fset.SetFileHistoryRetention(cfg.FileHistoryRetention())
+	fset.SetSelection(folderSelection(cfg))
+
 	m.folderCfgs[cfg.ID] = cfg
 	m.folderFiles[cfg.ID] = fset
 	m.folderIgnores[cfg.ID] = ignores
@@ -312,6 +336,7 @@ func (m *model) addAndStartFolderLockedWithIgnores(cfg config.FolderConfiguratio
 		if _, ok := expected[available]; !ok {
 			l.Debugln("dropping", folder, "state for", available)
 			fset.Drop(available)
+			fset.DropFileHistory(available)
 		}
 	}
 
@@ -423,6 +448,7 @@ func (m *model) removeFolder(cfg config.FolderConfiguration) {
 
 	// Remove it from the database
 	db.DropFolder(m.db, cfg.ID)
+	db.NewMiscDataNamespace(m.db).Delete(encryptionTokenKey(cfg.ID))
 }
 
 func (m *model) stopFolder(cfg config.FolderConfiguration, err error) {
@@ -621,19 +647,26 @@ type ConnectionInfo struct {
 	ClientVersion string
 	Type          string
 	Crypto        string
+	Capabilities  protocol.Capabilities
+	Connections   []ConnectionInfo
 }
 
 func (info ConnectionInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]interface{}{
-		"at":            info.At,
-		"inBytesTotal":  info.InBytesTotal,
-		"outBytesTotal": info.OutBytesTotal,
-		"connected":     info.Connected,
-		"paused":        info.Paused,
-		"address":       info.Address,
-		"clientVersion": info.ClientVersion,
-		"type":          info.Type,
-		"crypto":        info.Crypto,
+		"at":                  info.At,
+		"inBytesTotal":        info.InBytesTotal,
+		"outBytesTotal":       info.OutBytesTotal,
+		"compression":         info.Compression.String(),
+		"inCompressionRatio":  info.InCompressionRatio,
+		"outCompressionRatio": info.OutCompressionRatio,
+		"connected":           info.Connected,
+		"paused":              info.Paused,
+		"address":             info.Address,
+		"clientVersion":       info.ClientVersion,
+		"type":                info.Type,
+		"crypto":              info.Crypto,
+		"capabilities":        info.Capabilities.Names(),
+		"connections":         info.Connections,
 	})
 }
 
@@ -660,9 +693,24 @@ func (m *model) ConnectionStats() map[string]interface{} {
 			ci.Crypto = conn.Crypto()
 			ci.Connected = ok
 			ci.Statistics = conn.Statistics()
+			ci.Capabilities = conn.Capabilities()
 			if addr := conn.RemoteAddr(); addr != nil {
 				ci.Address = addr.String()
 			}
+			if mc, ok := conn.(connections.MultiConnection); ok {
+				for _, c := range mc.Connections() {
+					sub := ConnectionInfo{
+						Statistics: c.Statistics(),
+						Connected:  true,
+						Type:       c.Type(),
+						Crypto:     c.Crypto(),
+					}
+					if addr := c.RemoteAddr(); addr != nil {
+						sub.Address = addr.String()
+					}
+					ci.Connections = append(ci.Connections, sub)
+				}
+			}
 		}
 
 		conns[device.String()] = ci
@@ -713,21 +761,25 @@ func (m *model) FolderStatistics() (map[string]stats.FolderStatistics, error) {
 }
 
 type FolderCompletion struct {
-	CompletionPct float64
-	NeedBytes     int64
-	GlobalBytes   int64
-	NeedItems     int32
-	NeedDeletes   int32
+	CompletionPct     float64
+	NeedBytes         int64
+	GlobalBytes       int64
+	NeedItems         int32
+	NeedDeletes       int32
+	QuotaBlockedBytes int64 // Only known for the local device
+	QuotaBlockedItems int32
 }
 
 // Map returns the members as a map, e.g. used in api to serialize as Json.
 func (comp FolderCompletion) Map() map[string]interface{} {
 	return map[string]interface{}{
-		"completion":  comp.CompletionPct,
-		"needBytes":   comp.NeedBytes,
-		"needItems":   comp.NeedItems,
-		"globalBytes": comp.GlobalBytes,
-		"needDeletes": comp.NeedDeletes,
+		"completion":        comp.CompletionPct,
+		"needBytes":         comp.NeedBytes,
+		"needItems":         comp.NeedItems,
+		"globalBytes":       comp.GlobalBytes,
+		"needDeletes":       comp.NeedDeletes,
+		"quotaBlockedBytes": comp.QuotaBlockedBytes,
+		"quotaBlockedItems": comp.QuotaBlockedItems,
 	}
 }
 
@@ -776,13 +828,19 @@ func (m *model) Completion(device protocol.DeviceID, folder string) FolderComple
 
 	l.Debugf("%v Completion(%s, %q): %f (%d / %d = %f)", m, device, folder, completionPct, need.Bytes, tot, needRatio)
 
-	return FolderCompletion{
+	comp := FolderCompletion{
 		CompletionPct: completionPct,
 		NeedBytes:     need.Bytes,
 		NeedItems:     need.Files + need.Directories + need.Symlinks,
 		GlobalBytes:   tot,
 		NeedDeletes:   need.Deleted,
 	}
+	if device == protocol.LocalDeviceID || device == m.id {
+		blocked := m.QuotaBlocked(folder)
+		comp.QuotaBlockedBytes = blocked.Bytes
+		comp.QuotaBlockedItems = blocked.Files
+	}
+	return comp
 }
 
 // DBSnapshot returns a snapshot of the database content relevant to the given folder.
@@ -1013,6 +1071,16 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 			continue
 		}
 
+		if err := m.ccCheckEncryption(cfg, folder, deviceID, conn.Capabilities()); err != nil {
+			m.fmut.RUnlock()
+			if err == errEncryptionTokenStored {
+				l.Infof("Device %v folder %s: %v", deviceID, folder.Description(), err)
+			} else {
+				l.Warnln(err)
+			}
+			return err
+		}
+
 		if !folder.DisableTempIndexes {
 			tempIndexFolders = append(tempIndexFolders, folder.ID)
 		}
@@ -1335,11 +1403,15 @@ func (m *model) Closed(conn protocol.Connection, err error) {
 	}
 	delete(m.conn, device)
 	delete(m.connRequestLimiters, device)
+	delete(m.connPullLimiters, device)
 	delete(m.helloMessages, device)
 	delete(m.deviceDownloads, device)
 	delete(m.remotePausedFolders, device)
 	closed := m.closed[device]
 	delete(m.closed, device)
+	m.connCancels[device]()
+	delete(m.connCtxs, device)
+	delete(m.connCancels, device)
 	m.pmut.Unlock()
 
 	m.progressEmitter.temporaryIndexUnsubscribe(conn)
@@ -1418,7 +1490,7 @@ func (r *requestResponse) Wait() {
 
 // Request returns the specified data segment by reading it from local disk.
 // Implements the protocol.Model interface.
-func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (out protocol.RequestResponse, err error) {
+func (m *model) Request(deviceID protocol.DeviceID, folder, name string, blockNo, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (out protocol.RequestResponse, err error) {
 	if size < 0 || offset < 0 {
 		return nil, protocol.ErrInvalid
 	}
@@ -1465,20 +1537,35 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 
 	folderFs := folderCfg.Filesystem()
 
+	if folderCfg.Type == config.FolderTypeReceiveEncrypted {
+		// We only have the encrypted data, which the announced hashes
+		// don't match.
+		hash, weakHash = nil, 0
+	}
+
 	if err := osutil.TraversesSymlink(folderFs, filepath.Dir(name)); err != nil {
 		l.Debugf("%v REQ(in) traversal check: %s - %s: %q / %q o=%d s=%d", m, err, deviceID, folder, name, offset, size)
 		return nil, protocol.ErrNoSuchFile
 	}
 
-	// Restrict parallel requests by connection/device
-
 	m.pmut.RLock()
 	limiter := m.connRequestLimiters[deviceID]
+	ctx, ok := m.connCtxs[deviceID]
 	m.pmut.RUnlock()
+	if !ok {
+		ctx = context.Background()
+	}
+
+	// Waiting for the folder's bandwidth stops when the connection closes.
+	if err := m.folderBandwidth.waitSend(ctx, folder, int(size)); err != nil {
+		return nil, protocol.ErrGeneric
+	}
+
+	// Restrict parallel requests by connection/device
 
 	// The requestResponse releases the bytes to the buffer pool and the
 	// limiters when its Close method is called.
-	res := newLimitedRequestResponse(int(size), limiter, m.globalRequestLimiter)
+	res := newLimitedRequestResponse(int(size), folder, m.folderBandwidth.weight(folder), limiter, m.globalRequestLimiter)
 
 	defer func() {
 		// Close it ourselves if it isn't returned due to an error
@@ -1533,11 +1620,12 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 // newLimitedRequestResponse takes size bytes from the limiters in order,
 // skipping nil limiters, then returns a requestResponse of the given size.
 // When the requestResponse is closed the limiters are given back the bytes,
-// in reverse order.
-func newLimitedRequestResponse(size int, limiters ...*byteSemaphore) *requestResponse {
+// in reverse order. The folder's weight decides its share of the limiters
+// when other folders wait for them too.
+func newLimitedRequestResponse(size int, folder string, weight int, limiters ...*byteSemaphore) *requestResponse {
 	for _, limiter := range limiters {
 		if limiter != nil {
-			limiter.take(size)
+			_ = limiter.takeWeighted(context.Background(), size, folder, weight)
 		}
 	}
 
@@ -1730,13 +1818,18 @@ func (m *model) OnHello(remoteID protocol.DeviceID, addr net.Addr, hello protoco
 // GetHello is called when we are about to connect to some remote device.
 func (m *model) GetHello(id protocol.DeviceID) protocol.HelloIntf {
 	name := ""
-	if _, ok := m.cfg.Device(id); ok {
+	numConnections := 0
+	if cfg, ok := m.cfg.Device(id); ok {
 		name = m.cfg.MyName()
+		numConnections = cfg.NumConnections
 	}
 	return &protocol.Hello{
-		DeviceName:    name,
-		ClientName:    m.clientName,
-		ClientVersion: m.clientVersion,
+		DeviceName:     name,
+		ClientName:     m.clientName,
+		ClientVersion:  m.clientVersion,
+		Compressions:   protocol.LocalMessageCompressions(),
+		NumConnections: int32(numConnections),
+		Capabilities:   protocol.LocalCapabilities(),
 	}
 }
 
@@ -1768,6 +1861,7 @@ func (m *model) AddConnection(conn connections.Connection, hello protocol.HelloR
 
 	m.conn[deviceID] = conn
 	m.closed[deviceID] = make(chan struct{})
+	m.connCtxs[deviceID], m.connCancels[deviceID] = context.WithCancel(context.Background())
 	m.deviceDownloads[deviceID] = newDeviceDownloadState()
 	// 0: default, <0: no limiting
 	switch {
@@ -1776,8 +1870,14 @@ func (m *model) AddConnection(conn connections.Connection, hello protocol.HelloR
 	case device.MaxRequestKiB == 0:
 		m.connRequestLimiters[deviceID] = newByteSemaphore(1024 * defaultPullerPendingKiB)
 	}
+	// Our requests to the device are shared between the folders pulling
+	// from it according to their priorities, within the default pending
+	// data of a puller. MaxRequestKiB only concerns incoming requests.
+	m.connPullLimiters[deviceID] = newByteSemaphore(1024 * defaultPullerPendingKiB)
 
 	m.helloMessages[deviceID] = hello
+	// Devices not advertising capabilities, being older, get none.
+	conn.SetCapabilities(protocol.LocalCapabilities() & hello.Capabilities)
 
 	event := map[string]string{
 		"id":            deviceID.String(),
@@ -2006,18 +2106,29 @@ func (s *indexSender) String() string {
 	return fmt.Sprintf("indexSender@%p for %s to %s at %s", s, s.folder, s.dev, s.conn)
 }
 
-func (m *model) requestGlobal(ctx context.Context, deviceID protocol.DeviceID, folder, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
+func (m *model) requestGlobal(ctx context.Context, deviceID protocol.DeviceID, folder, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
 	m.pmut.RLock()
 	nc, ok := m.conn[deviceID]
+	limiter := m.connPullLimiters[deviceID]
 	m.pmut.RUnlock()
 
 	if !ok {
 		return nil, fmt.Errorf("requestGlobal: no such device: %s", deviceID)
 	}
 
+	if err := m.folderBandwidth.waitRecv(ctx, folder, size); err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		if err := limiter.takeWeighted(ctx, size, folder, m.folderBandwidth.weight(folder)); err != nil {
+			return nil, err
+		}
+		defer limiter.give(size)
+	}
+
 	l.Debugf("%v REQ(out): %s: %q / %q o=%d s=%d h=%x wh=%x ft=%t", m, deviceID, folder, name, offset, size, hash, weakHash, fromTemporary)
 
-	return nc.Request(ctx, folder, name, offset, size, hash, weakHash, fromTemporary)
+	return nc.Request(ctx, folder, name, blockNo, offset, size, hash, weakHash, fromTemporary)
 }
 
 func (m *model) ScanFolders() map[string]error {
@@ -2118,6 +2229,16 @@ func (m *model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 			continue
 		}
 
+		var encryptionToken []byte
+		if folderCfg.Type == config.FolderTypeReceiveEncrypted {
+			// Without a token from a trusted device the others can't
+			// tell we are receive encrypted, so we don't announce the
+			// folder yet.
+			if encryptionToken = m.encryptionToken(folderCfg.ID); len(encryptionToken) == 0 {
+				continue
+			}
+		}
+
 		protocolFolder := protocol.Folder{
 			ID:                 folderCfg.ID,
 			Label:              folderCfg.Label,
@@ -2145,6 +2266,12 @@ func (m *model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 				Introducer:  deviceCfg.Introducer,
 			}
 
+			if deviceCfg.DeviceID == m.id {
+				protocolDevice.EncryptionPasswordToken = encryptionToken
+			} else if device.EncryptionPassword != "" {
+				protocolDevice.EncryptionPasswordToken = protocol.PasswordToken(folderCfg.ID, device.EncryptionPassword)
+			}
+
 			if fs != nil {
 				if deviceCfg.DeviceID == m.id {
 					protocolDevice.IndexID = fs.IndexID(protocol.LocalDeviceID)
@@ -2200,6 +2327,19 @@ func (m *model) WatchError(folder string) error {
 	return runner.WatchError()
 }
 
+// QuotaBlocked returns the counts of the needed files that aren't being
+// pulled as they would make the folder exceed its maximum size.
+func (m *model) QuotaBlocked(folder string) db.Counts {
+	m.fmut.RLock()
+	err := m.checkFolderRunningLocked(folder)
+	runner := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if err != nil {
+		return db.Counts{}
+	}
+	return runner.QuotaBlocked()
+}
+
 func (m *model) Override(folder string) {
 	// Grab the runner and the file set.
 
@@ -2248,6 +2388,7 @@ func (m *model) GlobalDirectoryTree(folder, prefix string, levels int, dirsonly
 
 	snap := files.Snapshot()
 	defer snap.Release()
+	sel := snap.Selection()
 	snap.WithPrefixedGlobalTruncated(prefix, func(fi protocol.FileIntf) bool {
 		f := fi.(db.FileInfoTruncated)
 
@@ -2256,6 +2397,7 @@ func (m *model) GlobalDirectoryTree(folder, prefix string, levels int, dirsonly
 			return true
 		}
 
+		name := f.Name
 		f.Name = strings.Replace(f.Name, prefix, "", 1)
 
 		var dir, base string
@@ -2285,9 +2427,16 @@ func (m *model) GlobalDirectoryTree(folder, prefix string, levels int, dirsonly
 		}
 
 		if !dirsonly && base != "" {
-			last[base] = []interface{}{
+			entry := []interface{}{
 				f.ModTime(), f.FileSize(),
 			}
+			if !sel.Selected(name) {
+				// Not pulled due to selective sync, but available remotely.
+				if lf, ok := snap.Get(protocol.LocalDeviceID, name); !ok || lf.IsInvalid() || lf.IsDeleted() {
+					entry = append(entry, "remote")
+				}
+			}
+			last[base] = entry
 		}
 
 		return true
@@ -2398,6 +2547,11 @@ func (m *model) ResetFolder(folder string) {
 	db.DropFolder(m.db, folder)
 }
 
+// ExportDatabase writes the contents of the database to w.
+func (m *model) ExportDatabase(w io.Writer) error {
+	return m.db.Export(w)
+}
+
 func (m *model) String() string {
 	return fmt.Sprintf("model@%p", m)
 }
@@ -2441,6 +2595,13 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 		// Check if anything differs that requires a restart.
 		if !reflect.DeepEqual(fromCfg.RequiresRestartOnly(), toCfg.RequiresRestartOnly()) {
 			m.restartFolder(fromCfg, toCfg)
+		} else if sel := folderSelection(toCfg); !reflect.DeepEqual(folderSelection(fromCfg), sel) {
+			m.fmut.RLock()
+			runner, ok := m.folderRunners[folderID]
+			m.fmut.RUnlock()
+			if ok {
+				runner.SetSelection(sel)
+			}
 		}
 
 		// Emit the folder pause/resume event
@@ -2501,6 +2662,7 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 
 	m.globalRequestLimiter.setCapacity(1024 * to.Options.MaxConcurrentIncomingRequestKiB())
 	m.folderIOLimiter.setCapacity(to.Options.MaxFolderConcurrency())
+	m.folderBandwidth.setFolders(to.Folders)
 
 	// Some options don't require restart as those components handle it fine
 	// by themselves. Compare the options structs containing only the
@@ -137,7 +137,7 @@ func TestRequest(t *testing.T) {
 	defer cleanupModel(m)
 
 	// Existing, shared file
-	res, err := m.Request(device1, "default", "foo", 6, 0, nil, 0, false)
+	res, err := m.Request(device1, "default", "foo", 0, 6, 0, nil, 0, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -147,31 +147,31 @@ func TestRequest(t *testing.T) {
 	}
 
 	// Existing, nonshared file
-	_, err = m.Request(device2, "default", "foo", 6, 0, nil, 0, false)
+	_, err = m.Request(device2, "default", "foo", 0, 6, 0, nil, 0, false)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Nonexistent file
-	_, err = m.Request(device1, "default", "nonexistent", 6, 0, nil, 0, false)
+	_, err = m.Request(device1, "default", "nonexistent", 0, 6, 0, nil, 0, false)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Shared folder, but disallowed file name
-	_, err = m.Request(device1, "default", "../walk.go", 6, 0, nil, 0, false)
+	_, err = m.Request(device1, "default", "../walk.go", 0, 6, 0, nil, 0, false)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Negative offset
-	_, err = m.Request(device1, "default", "foo", -4, 0, nil, 0, false)
+	_, err = m.Request(device1, "default", "foo", 0, -4, 0, nil, 0, false)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
 
 	// Larger block than available
-	_, err = m.Request(device1, "default", "foo", 42, 0, nil, 0, false)
+	_, err = m.Request(device1, "default", "foo", 0, 42, 0, nil, 0, false)
 	if err == nil {
 		t.Error("Unexpected nil error on insecure file read")
 	}
@@ -259,7 +259,7 @@ func BenchmarkRequestOut(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		data, err := m.requestGlobal(context.Background(), device1, "default", files[i%n].Name, 0, 32, nil, 0, false)
+		data, err := m.requestGlobal(context.Background(), device1, "default", files[i%n].Name, 0, 0, 32, nil, 0, false)
 		if err != nil {
 			b.Error(err)
 		}
@@ -285,7 +285,7 @@ func BenchmarkRequestInSingleFile(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		if _, err := m.Request(device1, "default", "request/for/a/file/in/a/couple/of/dirs/128k", 128<<10, 0, nil, 0, false); err != nil {
+		if _, err := m.Request(device1, "default", "request/for/a/file/in/a/couple/of/dirs/128k", 0, 128<<10, 0, nil, 0, false); err != nil {
 			b.Error(err)
 		}
 	}
@@ -367,6 +367,24 @@ func TestDeviceRename(t *testing.T) {
 	}
 }
 
+func TestConnectionCapabilities(t *testing.T) {
+	m := setupModel(defaultCfgWrapper)
+	defer cleanupModel(m)
+
+	conn := &fakeConnection{id: device1, model: m}
+	m.AddConnection(conn, protocol.HelloResult{Capabilities: protocol.CapabilityEncryption | 1<<40})
+	if caps := conn.Capabilities(); caps != protocol.CapabilityEncryption {
+		t.Errorf("got capabilities %v, expected only the common ones", caps)
+	}
+
+	// Older devices don't advertise any.
+	m.Closed(conn, protocol.ErrTimeout)
+	m.AddConnection(conn, protocol.HelloResult{})
+	if caps := conn.Capabilities(); caps != 0 {
+		t.Errorf("got capabilities %v, expected none", caps)
+	}
+}
+
 func TestClusterConfig(t *testing.T) {
 	cfg := config.New(device1)
 	cfg.Devices = []config.DeviceConfiguration{
@@ -2309,6 +2327,30 @@ func TestIndexesForUnknownDevicesDropped(t *testing.T) {
 	}
 }
 
+func TestFileHistoryForUnknownDevicesDropped(t *testing.T) {
+	dbi := db.NewLowlevel(backend.OpenMemory())
+
+	files := db.NewFileSet("default", defaultFs, dbi)
+	files.SetFileHistoryRetention(10, 0)
+	files.Update(device1, genFiles(1))
+	unknown := genFiles(1)
+	unknown[0].Name = "unknown"
+	files.Update(device2, unknown)
+
+	m := newModel(defaultCfgWrapper, myID, "syncthing", "dev", dbi, nil)
+	m.newFolder(defaultFolderConfig)
+	defer cleanupModel(m)
+
+	snap := db.NewFileSet("default", defaultFs, dbi).Snapshot()
+	defer snap.Release()
+	if hist := snap.FileHistory("file0"); len(hist) != 1 {
+		t.Errorf("expected the history of the shared device to remain, got %v", hist)
+	}
+	if hist := snap.FileHistory("unknown"); len(hist) != 0 {
+		t.Errorf("expected the history of the unknown device to be dropped, got %v", hist)
+	}
+}
+
 func TestSharedWithClearedOnDisconnect(t *testing.T) {
 	wcfg := createTmpWrapper(defaultCfg)
 	wcfg.SetDevice(config.NewDeviceConfiguration(device2, "device2"))
@@ -3255,14 +3297,14 @@ func TestRequestLimit(t *testing.T) {
 
 	file := "tmpfile"
 	befReq := time.Now()
-	first, err := m.Request(device1, "default", file, 2000, 0, nil, 0, false)
+	first, err := m.Request(device1, "default", file, 0, 2000, 0, nil, 0, false)
 	if err != nil {
 		t.Fatalf("First request failed: %v", err)
 	}
 	reqDur := time.Since(befReq)
 	returned := make(chan struct{})
 	go func() {
-		second, err := m.Request(device1, "default", file, 2000, 0, nil, 0, false)
+		second, err := m.Request(device1, "default", file, 0, 2000, 0, nil, 0, false)
 		if err != nil {
 			t.Errorf("Second request failed: %v", err)
 		}
@@ -3343,7 +3385,7 @@ func TestConnCloseOnRestart(t *testing.T) {
 
 	br := &testutils.BlockingRW{}
 	nw := &testutils.NoopRW{}
-	m.AddConnection(newFakeProtoConn(protocol.NewConnection(device1, br, nw, m, "testConn", protocol.CompressNever)), protocol.HelloResult{})
+	m.AddConnection(newFakeProtoConn(protocol.NewConnection(device1, br, nw, m, "testConn", protocol.CompressNever, protocol.MessageCompressionLZ4, 0, nil)), protocol.HelloResult{})
 	m.pmut.RLock()
 	if len(m.closed) != 1 {
 		t.Fatalf("Expected just one conn (len(m.conn) == %v)", len(m.conn))
@@ -3472,7 +3514,7 @@ func TestNewLimitedRequestResponse(t *testing.T) {
 	l2 := (*byteSemaphore)(nil)
 
 	// Should take 500 bytes from any non-unlimited non-nil limiters.
-	res := newLimitedRequestResponse(500, l0, l1, l2)
+	res := newLimitedRequestResponse(500, "default", 1, l0, l1, l2)
 
 	if l1.available != 1024-500 {
 		t.Error("should have taken bytes from limited limiter")
@@ -7,7 +7,6 @@
 package model
 
 import (
-	"io"
 	"time"
 
 	"github.com/pkg/errors"
@@ -101,6 +100,21 @@ func (w *lockedWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
 	return w.fd.WriteAt(p, off)
 }
 
+// CopyRange copies data from src into the file using the given method, and
+// is goroutine safe like WriteAt.
+func (w *lockedWriterAt) CopyRange(method fs.CopyRangeMethod, src fs.File, srcOffset, dstOffset, size int64) error {
+	w.mut.RLock()
+	defer w.mut.RUnlock()
+	return fs.CopyRange(method, src, w.fd, srcOffset, dstOffset, size)
+}
+
+// ReadAt is goroutine safe like WriteAt.
+func (w *lockedWriterAt) ReadAt(p []byte, off int64) (n int, err error) {
+	w.mut.RLock()
+	defer w.mut.RUnlock()
+	return w.fd.ReadAt(p, off)
+}
+
 // SyncClose ensures that no more writes are happening before going ahead and
 // syncing and closing the fd, thus needs to acquire a write-lock.
 func (w *lockedWriterAt) SyncClose(fsync bool) error {
@@ -118,7 +132,7 @@ func (w *lockedWriterAt) SyncClose(fsync bool) error {
 
 // tempFile returns the fd for the temporary file, reusing an open fd
 // or creating the file as necessary.
-func (s *sharedPullerState) tempFile() (io.WriterAt, error) {
+func (s *sharedPullerState) tempFile() (*lockedWriterAt, error) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
@@ -34,6 +34,7 @@ const (
 	messageTypeDownloadProgress MessageType = 5
 	messageTypePing             MessageType = 6
 	messageTypeClose            MessageType = 7
+	messageTypeRequests         MessageType = 8
 )
 
 var MessageType_name = map[int32]string{
@@ -45,6 +46,7 @@ var MessageType_name = map[int32]string{
 	5: "DOWNLOAD_PROGRESS",
 	6: "PING",
 	7: "CLOSE",
+	8: "REQUESTS",
 }
 
 var MessageType_value = map[string]int32{
@@ -56,6 +58,7 @@ var MessageType_value = map[string]int32{
 	"DOWNLOAD_PROGRESS": 5,
 	"PING":              6,
 	"CLOSE":             7,
+	"REQUESTS":          8,
 }
 
 func (x MessageType) String() string {
@@ -71,16 +74,19 @@ type MessageCompression int32
 const (
 	MessageCompressionNone MessageCompression = 0
 	MessageCompressionLZ4  MessageCompression = 1
+	MessageCompressionZstd MessageCompression = 2
 )
 
 var MessageCompression_name = map[int32]string{
 	0: "NONE",
 	1: "LZ4",
+	2: "ZSTD",
 }
 
 var MessageCompression_value = map[string]int32{
 	"NONE": 0,
 	"LZ4":  1,
+	"ZSTD": 2,
 }
 
 func (x MessageCompression) String() string {
@@ -213,6 +219,15 @@ type Hello struct {
 	DeviceName    string `protobuf:"bytes,1,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`
 	ClientName    string `protobuf:"bytes,2,opt,name=client_name,json=clientName,proto3" json:"client_name,omitempty"`
 	ClientVersion string `protobuf:"bytes,3,opt,name=client_version,json=clientVersion,proto3" json:"client_version,omitempty"`
+	// The message compressions the sender can decompress, besides LZ4
+	// which is always supported.
+	Compressions []MessageCompression `protobuf:"varint,4,rep,packed,name=compressions,proto3,enum=protocol.MessageCompression" json:"compressions,omitempty"`
+	// The number of connections the sender wants to the receiving device,
+	// when both support multiple connections. Zero means one.
+	NumConnections int32 `protobuf:"varint,5,opt,name=num_connections,json=numConnections,proto3" json:"num_connections,omitempty"`
+	// The optional protocol features the sender supports, a bit set of
+	// the Capability values.
+	Capabilities Capabilities `protobuf:"varint,6,opt,name=capabilities,proto3,casttype=Capabilities" json:"capabilities,omitempty"`
 }
 
 func (m *Hello) Reset()         { *m = Hello{} }
@@ -377,6 +392,7 @@ type Device struct {
 	Introducer               bool        `protobuf:"varint,7,opt,name=introducer,proto3" json:"introducer,omitempty"`
 	IndexID                  IndexID     `protobuf:"varint,8,opt,name=index_id,json=indexId,proto3,customtype=IndexID" json:"index_id"`
 	SkipIntroductionRemovals bool        `protobuf:"varint,9,opt,name=skip_introduction_removals,json=skipIntroductionRemovals,proto3" json:"skip_introduction_removals,omitempty"`
+	EncryptionPasswordToken  []byte      `protobuf:"bytes,10,opt,name=encryption_password_token,json=encryptionPasswordToken,proto3" json:"encryption_password_token,omitempty"`
 }
 
 func (m *Device) Reset()         { *m = Device{} }
@@ -498,6 +514,8 @@ type FileInfo struct {
 	Blocks        []BlockInfo  `protobuf:"bytes,16,rep,name=blocks,proto3" json:"blocks"`
 	SymlinkTarget string       `protobuf:"bytes,17,opt,name=symlink_target,json=symlinkTarget,proto3" json:"symlink_target,omitempty"`
 	BlocksHash    []byte       `protobuf:"bytes,18,opt,name=blocks_hash,json=blocksHash,proto3" json:"blocks_hash,omitempty"`
+	Xattrs        []Xattr      `protobuf:"bytes,19,rep,name=xattrs,proto3" json:"xattrs"`
+	Encrypted     []byte       `protobuf:"bytes,20,opt,name=encrypted,proto3" json:"encrypted,omitempty"`
 	Type          FileInfoType `protobuf:"varint,2,opt,name=type,proto3,enum=protocol.FileInfoType" json:"type,omitempty"`
 	Permissions   uint32       `protobuf:"varint,4,opt,name=permissions,proto3" json:"permissions,omitempty"`
 	ModifiedNs    int32        `protobuf:"varint,11,opt,name=modified_ns,json=modifiedNs,proto3" json:"modified_ns,omitempty"`
@@ -586,6 +604,44 @@ func (m *BlockInfo) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_BlockInfo proto.InternalMessageInfo
 
+type Xattr struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Xattr) Reset()         { *m = Xattr{} }
+func (m *Xattr) String() string { return proto.CompactTextString(m) }
+func (*Xattr) ProtoMessage()    {}
+func (*Xattr) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e3f59eb60afbbc6e, []int{9}
+}
+func (m *Xattr) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Xattr) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Xattr.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Xattr) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Xattr.Merge(m, src)
+}
+func (m *Xattr) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *Xattr) XXX_DiscardUnknown() {
+	xxx_messageInfo_Xattr.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Xattr proto.InternalMessageInfo
+
 type Vector struct {
 	Counters []Counter `protobuf:"bytes,1,rep,name=counters,proto3" json:"counters"`
 }
@@ -594,7 +650,7 @@ func (m *Vector) Reset()         { *m = Vector{} }
 func (m *Vector) String() string { return proto.CompactTextString(m) }
 func (*Vector) ProtoMessage()    {}
 func (*Vector) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{9}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{10}
 }
 func (m *Vector) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -632,7 +688,7 @@ func (m *Counter) Reset()         { *m = Counter{} }
 func (m *Counter) String() string { return proto.CompactTextString(m) }
 func (*Counter) ProtoMessage()    {}
 func (*Counter) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{10}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{11}
 }
 func (m *Counter) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -670,13 +726,14 @@ type Request struct {
 	Hash          []byte `protobuf:"bytes,6,opt,name=hash,proto3" json:"hash,omitempty"`
 	FromTemporary bool   `protobuf:"varint,7,opt,name=from_temporary,json=fromTemporary,proto3" json:"from_temporary,omitempty"`
 	WeakHash      uint32 `protobuf:"varint,8,opt,name=weak_hash,json=weakHash,proto3" json:"weak_hash,omitempty"`
+	BlockNo       int32  `protobuf:"varint,9,opt,name=block_no,json=blockNo,proto3" json:"block_no,omitempty"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
 func (m *Request) String() string { return proto.CompactTextString(m) }
 func (*Request) ProtoMessage()    {}
 func (*Request) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{11}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{12}
 }
 func (m *Request) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -705,6 +762,43 @@ func (m *Request) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Request proto.InternalMessageInfo
 
+type Requests struct {
+	Requests []Request `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests"`
+}
+
+func (m *Requests) Reset()         { *m = Requests{} }
+func (m *Requests) String() string { return proto.CompactTextString(m) }
+func (*Requests) ProtoMessage()    {}
+func (*Requests) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e3f59eb60afbbc6e, []int{13}
+}
+func (m *Requests) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Requests) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Requests.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Requests) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Requests.Merge(m, src)
+}
+func (m *Requests) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *Requests) XXX_DiscardUnknown() {
+	xxx_messageInfo_Requests.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Requests proto.InternalMessageInfo
+
 type Response struct {
 	ID   int32     `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Data []byte    `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -715,7 +809,7 @@ func (m *Response) Reset()         { *m = Response{} }
 func (m *Response) String() string { return proto.CompactTextString(m) }
 func (*Response) ProtoMessage()    {}
 func (*Response) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{12}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{14}
 }
 func (m *Response) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -753,7 +847,7 @@ func (m *DownloadProgress) Reset()         { *m = DownloadProgress{} }
 func (m *DownloadProgress) String() string { return proto.CompactTextString(m) }
 func (*DownloadProgress) ProtoMessage()    {}
 func (*DownloadProgress) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{13}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{15}
 }
 func (m *DownloadProgress) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -794,7 +888,7 @@ func (m *FileDownloadProgressUpdate) Reset()         { *m = FileDownloadProgress
 func (m *FileDownloadProgressUpdate) String() string { return proto.CompactTextString(m) }
 func (*FileDownloadProgressUpdate) ProtoMessage()    {}
 func (*FileDownloadProgressUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{14}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{16}
 }
 func (m *FileDownloadProgressUpdate) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -830,7 +924,7 @@ func (m *Ping) Reset()         { *m = Ping{} }
 func (m *Ping) String() string { return proto.CompactTextString(m) }
 func (*Ping) ProtoMessage()    {}
 func (*Ping) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{15}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{17}
 }
 func (m *Ping) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -867,7 +961,7 @@ func (m *Close) Reset()         { *m = Close{} }
 func (m *Close) String() string { return proto.CompactTextString(m) }
 func (*Close) ProtoMessage()    {}
 func (*Close) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e3f59eb60afbbc6e, []int{16}
+	return fileDescriptor_e3f59eb60afbbc6e, []int{18}
 }
 func (m *Close) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -912,9 +1006,11 @@ func init() {
 	proto.RegisterType((*IndexUpdate)(nil), "protocol.IndexUpdate")
 	proto.RegisterType((*FileInfo)(nil), "protocol.FileInfo")
 	proto.RegisterType((*BlockInfo)(nil), "protocol.BlockInfo")
+	proto.RegisterType((*Xattr)(nil), "protocol.Xattr")
 	proto.RegisterType((*Vector)(nil), "protocol.Vector")
 	proto.RegisterType((*Counter)(nil), "protocol.Counter")
 	proto.RegisterType((*Request)(nil), "protocol.Request")
+	proto.RegisterType((*Requests)(nil), "protocol.Requests")
 	proto.RegisterType((*Response)(nil), "protocol.Response")
 	proto.RegisterType((*DownloadProgress)(nil), "protocol.DownloadProgress")
 	proto.RegisterType((*FileDownloadProgressUpdate)(nil), "protocol.FileDownloadProgressUpdate")
@@ -925,123 +1021,135 @@ func init() {
 func init() { proto.RegisterFile("bep.proto", fileDescriptor_e3f59eb60afbbc6e) }
 
 var fileDescriptor_e3f59eb60afbbc6e = []byte{
-	// 1842 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xcf, 0x73, 0xdb, 0xc6,
-	0xf5, 0x27, 0x48, 0xf0, 0xd7, 0x23, 0xa5, 0x40, 0x6b, 0x5b, 0x5f, 0x7c, 0x61, 0x9b, 0x84, 0x69,
-	0x3b, 0x66, 0x34, 0xa9, 0xed, 0x26, 0x69, 0x3b, 0xed, 0xb4, 0x9d, 0xe1, 0x0f, 0x48, 0xe6, 0x54,
-	0x26, 0xd5, 0x25, 0xe5, 0xd4, 0x39, 0x14, 0x03, 0x11, 0x4b, 0x0a, 0x63, 0x10, 0xcb, 0x02, 0xa0,
-	0x64, 0xe6, 0x4f, 0xe0, 0xa9, 0xc7, 0x5e, 0x38, 0x93, 0x99, 0x9c, 0xfa, 0x9f, 0xf8, 0xe8, 0xf6,
-	0xd4, 0xe9, 0x41, 0xd3, 0xc8, 0x97, 0xf4, 0xd6, 0x4b, 0xaf, 0x9d, 0xce, 0xee, 0x02, 0x24, 0x28,
-	0xc5, 0x99, 0x1c, 0x7a, 0xc2, 0xee, 0x7b, 0x9f, 0x7d, 0xbb, 0xef, 0xb3, 0xef, 0x7d, 0x16, 0x50,
-	0x3c, 0x21, 0xd3, 0xc7, 0x53, 0x9f, 0x86, 0x14, 0x15, 0xf8, 0x67, 0x48, 0x5d, 0xed, 0xbe, 0x4f,
-	0xa6, 0x34, 0x78, 0xc2, 0xe7, 0x27, 0xb3, 0xd1, 0x93, 0x31, 0x1d, 0x53, 0x3e, 0xe1, 0x23, 0x01,
-	0xaf, 0x4d, 0x21, 0xfb, 0x8c, 0xb8, 0x2e, 0x45, 0x55, 0x28, 0xd9, 0xe4, 0xcc, 0x19, 0x12, 0xd3,
-	0xb3, 0x26, 0x44, 0x95, 0x74, 0xa9, 0x5e, 0xc4, 0x20, 0x4c, 0x5d, 0x6b, 0x42, 0x18, 0x60, 0xe8,
-	0x3a, 0xc4, 0x0b, 0x05, 0x20, 0x2d, 0x00, 0xc2, 0xc4, 0x01, 0x0f, 0x61, 0x3b, 0x02, 0x9c, 0x11,
-	0x3f, 0x70, 0xa8, 0xa7, 0x66, 0x38, 0x66, 0x4b, 0x58, 0x5f, 0x08, 0x63, 0x2d, 0x80, 0xdc, 0x33,
-	0x62, 0xd9, 0xc4, 0x47, 0x1f, 0x81, 0x1c, 0xce, 0xa7, 0x62, 0xaf, 0xed, 0x4f, 0x6e, 0x3d, 0x8e,
-	0x4f, 0xfe, 0xf8, 0x39, 0x09, 0x02, 0x6b, 0x4c, 0x06, 0xf3, 0x29, 0xc1, 0x1c, 0x82, 0x7e, 0x0d,
-	0xa5, 0x21, 0x9d, 0x4c, 0x7d, 0x12, 0xf0, 0xc0, 0x69, 0xbe, 0xe2, 0xce, 0xb5, 0x15, 0xad, 0x35,
-	0x06, 0x27, 0x17, 0xd4, 0x1a, 0xb0, 0xd5, 0x72, 0x67, 0x41, 0x48, 0xfc, 0x16, 0xf5, 0x46, 0xce,
-	0x18, 0x3d, 0x85, 0xfc, 0x88, 0xba, 0x36, 0xf1, 0x03, 0x55, 0xd2, 0x33, 0xf5, 0xd2, 0x27, 0xca,
-	0x3a, 0xd8, 0x3e, 0x77, 0x34, 0xe5, 0x37, 0x17, 0xd5, 0x14, 0x8e, 0x61, 0xb5, 0xaf, 0xd3, 0x90,
-	0x13, 0x1e, 0xb4, 0x0b, 0x69, 0xc7, 0x16, 0x14, 0x35, 0x73, 0x97, 0x17, 0xd5, 0x74, 0xa7, 0x8d,
-	0xd3, 0x8e, 0x8d, 0x6e, 0x42, 0xd6, 0xb5, 0x4e, 0x88, 0x1b, 0x91, 0x23, 0x26, 0xe8, 0x36, 0x14,
-	0x7d, 0x62, 0xd9, 0x26, 0xf5, 0xdc, 0x39, 0xa7, 0xa4, 0x80, 0x0b, 0xcc, 0xd0, 0xf3, 0xdc, 0x39,
-	0xfa, 0x11, 0x20, 0x67, 0xec, 0x51, 0x9f, 0x98, 0x53, 0xe2, 0x4f, 0x1c, 0x7e, 0xda, 0x40, 0x95,
-	0x39, 0x6a, 0x47, 0x78, 0x8e, 0xd6, 0x0e, 0x74, 0x1f, 0xb6, 0x22, 0xb8, 0x4d, 0x5c, 0x12, 0x12,
-	0x35, 0xcb, 0x91, 0x65, 0x61, 0x6c, 0x73, 0x1b, 0x7a, 0x0a, 0x37, 0x6d, 0x27, 0xb0, 0x4e, 0x5c,
-	0x62, 0x86, 0x64, 0x32, 0x35, 0x1d, 0xcf, 0x26, 0xaf, 0x49, 0xa0, 0xe6, 0x38, 0x16, 0x45, 0xbe,
-	0x01, 0x99, 0x4c, 0x3b, 0xc2, 0x83, 0x76, 0x21, 0x37, 0xb5, 0x66, 0x01, 0xb1, 0xd5, 0x3c, 0xc7,
-	0x44, 0x33, 0xc6, 0x92, 0xa8, 0x80, 0x40, 0x55, 0xae, 0xb2, 0xd4, 0xe6, 0x8e, 0x98, 0xa5, 0x08,
-	0x56, 0xfb, 0x57, 0x1a, 0x72, 0xc2, 0x83, 0x3e, 0x5c, 0xb1, 0x54, 0x6e, 0xee, 0x32, 0xd4, 0xdf,
-	0x2f, 0xaa, 0x05, 0xe1, 0xeb, 0xb4, 0x13, 0xac, 0x21, 0x90, 0x13, 0x15, 0xc5, 0xc7, 0xe8, 0x0e,
-	0x14, 0x2d, 0xdb, 0x66, 0xb7, 0x47, 0x02, 0x35, 0xa3, 0x67, 0xea, 0x45, 0xbc, 0x36, 0xa0, 0x9f,
-	0x6d, 0x56, 0x83, 0x7c, 0xb5, 0x7e, 0xde, 0x57, 0x06, 0xec, 0x2a, 0x86, 0xc4, 0x8f, 0x2a, 0x38,
-	0xcb, 0xf7, 0x2b, 0x30, 0x03, 0xaf, 0xdf, 0x7b, 0x50, 0x9e, 0x58, 0xaf, 0xcd, 0x80, 0xfc, 0x61,
-	0x46, 0xbc, 0x21, 0xe1, 0x74, 0x65, 0x70, 0x69, 0x62, 0xbd, 0xee, 0x47, 0x26, 0x54, 0x01, 0x70,
-	0xbc, 0xd0, 0xa7, 0xf6, 0x6c, 0x48, 0xfc, 0x88, 0xab, 0x84, 0x05, 0xfd, 0x04, 0x0a, 0x9c, 0x6c,
-	0xd3, 0xb1, 0xd5, 0x82, 0x2e, 0xd5, 0xe5, 0xa6, 0x16, 0x25, 0x9e, 0xe7, 0x54, 0xf3, 0xbc, 0xe3,
-	0x21, 0xce, 0x73, 0x6c, 0xc7, 0x46, 0xbf, 0x04, 0x2d, 0x78, 0xe5, 0xb0, 0x8b, 0x12, 0x91, 0x42,
-	0x87, 0x7a, 0xa6, 0x4f, 0x26, 0xf4, 0xcc, 0x72, 0x03, 0xb5, 0xc8, 0xb7, 0x51, 0x19, 0xa2, 0x93,
-	0x00, 0xe0, 0xc8, 0x5f, 0xeb, 0x41, 0x96, 0x47, 0x64, 0xb7, 0x28, 0x8a, 0x35, 0xea, 0xde, 0x68,
-	0x86, 0x1e, 0x43, 0x76, 0xe4, 0xb8, 0x24, 0x50, 0xd3, 0xfc, 0x0e, 0x51, 0xa2, 0xd2, 0x1d, 0x97,
-	0x74, 0xbc, 0x11, 0x8d, 0x6e, 0x51, 0xc0, 0x6a, 0xc7, 0x50, 0xe2, 0x01, 0x8f, 0xa7, 0xb6, 0x15,
-	0x92, 0xff, 0x59, 0xd8, 0xaf, 0xb3, 0x50, 0x88, 0x3d, 0xab, 0x4b, 0x97, 0x12, 0x97, 0x8e, 0x40,
-	0x0e, 0x9c, 0x2f, 0x09, 0xef, 0x91, 0x0c, 0xe6, 0x63, 0x74, 0x17, 0x60, 0x42, 0x6d, 0x67, 0xe4,
-	0x10, 0xdb, 0x0c, 0xf8, 0x95, 0x65, 0x70, 0x31, 0xb6, 0xf4, 0xd1, 0x53, 0x28, 0xad, 0xdc, 0x27,
-	0x73, 0xb5, 0xcc, 0x39, 0xff, 0x20, 0xe6, 0xbc, 0x7f, 0x4a, 0xfd, 0xb0, 0xd3, 0xc6, 0xab, 0x10,
-	0xcd, 0x39, 0x2b, 0xe9, 0x58, 0x9e, 0x18, 0xb1, 0x1b, 0x25, 0xfd, 0x82, 0x0c, 0x43, 0xba, 0x6a,
-	0xfc, 0x08, 0x86, 0x34, 0x28, 0xac, 0x6a, 0x02, 0xf8, 0x01, 0x56, 0x73, 0xf4, 0x63, 0xc8, 0x9d,
-	0xb8, 0x74, 0xf8, 0x2a, 0xee, 0x8f, 0x1b, 0xeb, 0x60, 0x4d, 0x66, 0x4f, 0xb0, 0x10, 0x01, 0x99,
-	0x4c, 0x06, 0xf3, 0x89, 0xeb, 0x78, 0xaf, 0xcc, 0xd0, 0xf2, 0xc7, 0x24, 0x54, 0x77, 0x84, 0x4c,
-	0x46, 0xd6, 0x01, 0x37, 0x32, 0xb9, 0x15, 0x0b, 0xcc, 0x53, 0x2b, 0x38, 0x55, 0x11, 0x6b, 0x23,
-	0x0c, 0xc2, 0xf4, 0xcc, 0x0a, 0x4e, 0xd1, 0x5e, 0xa4, 0x9e, 0x42, 0x0b, 0x77, 0xaf, 0xb3, 0x9f,
-	0x90, 0x4f, 0x1d, 0x4a, 0x57, 0xe5, 0x65, 0x0b, 0x27, 0x4d, 0x6c, 0xbb, 0x15, 0x91, 0x5e, 0xa0,
-	0x96, 0x74, 0xa9, 0x9e, 0x5d, 0xf3, 0xd6, 0x0d, 0xd0, 0x13, 0x10, 0x9b, 0x9b, 0xfc, 0x8a, 0xb6,
-	0x98, 0xbf, 0xa9, 0x5c, 0x5e, 0x54, 0xcb, 0xd8, 0x3a, 0xe7, 0xa9, 0xf6, 0x9d, 0x2f, 0x09, 0x2e,
-	0x9e, 0xc4, 0x43, 0xb6, 0xa7, 0x4b, 0x87, 0x96, 0x6b, 0x8e, 0x5c, 0x6b, 0x1c, 0xa8, 0xdf, 0xe6,
-	0xf9, 0xa6, 0xc0, 0x6d, 0xfb, 0xcc, 0x84, 0x6a, 0x50, 0x8e, 0x38, 0x16, 0x39, 0xfe, 0x33, 0xcf,
-	0x93, 0x2c, 0x45, 0x46, 0x9e, 0xa5, 0xca, 0x14, 0x88, 0xa9, 0x9a, 0x1d, 0xc9, 0x57, 0x3c, 0x45,
-	0x75, 0xc8, 0x3b, 0xde, 0x99, 0xe5, 0x3a, 0x91, 0x68, 0x35, 0xb7, 0x2f, 0x2f, 0xaa, 0x80, 0xad,
-	0xf3, 0x8e, 0xb0, 0xe2, 0xd8, 0xcd, 0x18, 0xf7, 0xe8, 0x86, 0xbe, 0x16, 0x78, 0xa8, 0x2d, 0x8f,
-	0x26, 0xb4, 0xf5, 0x17, 0xf2, 0x9f, 0xbe, 0xaa, 0xa6, 0x6a, 0x1e, 0x14, 0x57, 0x37, 0xc7, 0x2a,
-	0x92, 0x9f, 0x2c, 0xc3, 0x0f, 0xc6, 0xc7, 0xac, 0x1d, 0xe8, 0x68, 0x14, 0x90, 0x90, 0xd7, 0x6e,
-	0x06, 0x47, 0xb3, 0x55, 0xf5, 0xa6, 0x39, 0x75, 0xa2, 0x7a, 0x6f, 0x43, 0xf1, 0x9c, 0x58, 0xaf,
-	0x44, 0x7a, 0x82, 0xf5, 0x02, 0x33, 0xb0, 0xd4, 0xa2, 0xfd, 0x7e, 0x05, 0x39, 0x51, 0x76, 0xe8,
-	0x53, 0x28, 0x0c, 0xe9, 0xcc, 0x0b, 0xd7, 0x6f, 0xd2, 0x4e, 0x52, 0xd2, 0xb8, 0x27, 0xaa, 0xa5,
-	0x15, 0xb0, 0xb6, 0x0f, 0xf9, 0xc8, 0x85, 0x1e, 0xae, 0xf4, 0x56, 0x6e, 0xde, 0xba, 0xd2, 0x02,
-	0x9b, 0x8f, 0xd4, 0x99, 0xe5, 0xce, 0xc4, 0x41, 0x65, 0x2c, 0x26, 0xb5, 0xbf, 0x48, 0x90, 0xc7,
-	0xac, 0xaa, 0x83, 0x30, 0xf1, 0xbc, 0x65, 0x37, 0x9e, 0xb7, 0xb5, 0x10, 0xa4, 0x37, 0x84, 0x20,
-	0xee, 0xe5, 0x4c, 0xa2, 0x97, 0xd7, 0x2c, 0xc9, 0xdf, 0xc9, 0x52, 0x36, 0xc1, 0x52, 0xcc, 0x72,
-	0x2e, 0xc1, 0xf2, 0x43, 0xd8, 0x1e, 0xf9, 0x74, 0xc2, 0x1f, 0x30, 0xea, 0x5b, 0xfe, 0x3c, 0x52,
-	0xdb, 0x2d, 0x66, 0x1d, 0xc4, 0xc6, 0x4d, 0x82, 0x0b, 0x9b, 0x04, 0xd7, 0x4c, 0x28, 0x60, 0x12,
-	0x4c, 0xa9, 0x17, 0x90, 0xf7, 0xe6, 0x84, 0x40, 0xb6, 0xad, 0xd0, 0xe2, 0x19, 0x95, 0x31, 0x1f,
-	0xa3, 0x47, 0x20, 0x0f, 0xa9, 0x2d, 0xf2, 0xd9, 0x4e, 0xb6, 0xb4, 0xe1, 0xfb, 0xd4, 0x6f, 0x51,
-	0x9b, 0x60, 0x0e, 0xa8, 0x4d, 0x41, 0x69, 0xd3, 0x73, 0xcf, 0xa5, 0x96, 0x7d, 0xe4, 0xd3, 0x31,
-	0x7b, 0x65, 0xde, 0xab, 0x96, 0x6d, 0xc8, 0xcf, 0xb8, 0x9e, 0xc6, 0x7a, 0xf9, 0x60, 0xb3, 0x63,
-	0xaf, 0x06, 0x12, 0xe2, 0x1b, 0x6b, 0x51, 0xb4, 0xb4, 0xf6, 0x6f, 0x09, 0xb4, 0xf7, 0xa3, 0x51,
-	0x07, 0x4a, 0x02, 0x69, 0x26, 0x7e, 0xac, 0xea, 0x3f, 0x64, 0x23, 0x2e, 0x16, 0x30, 0x5b, 0x8d,
-	0xbf, 0xf3, 0x55, 0x4e, 0x68, 0x67, 0xe6, 0x87, 0x69, 0xe7, 0x23, 0xd8, 0x12, 0xaa, 0x11, 0xff,
-	0x83, 0xc8, 0x7a, 0xa6, 0x9e, 0x6d, 0xa6, 0x95, 0x14, 0x2e, 0x9f, 0x88, 0x36, 0x13, 0x7f, 0x20,
-	0x77, 0x37, 0xe4, 0x45, 0x54, 0xc7, 0x5a, 0x4c, 0x6a, 0x39, 0x90, 0x8f, 0x1c, 0x6f, 0x5c, 0xab,
-	0x42, 0xb6, 0xe5, 0x52, 0x7e, 0x9f, 0x39, 0x9f, 0x58, 0x01, 0xf5, 0x62, 0x9a, 0xc5, 0x6c, 0xef,
-	0xaf, 0x69, 0x28, 0x25, 0x7e, 0x1f, 0xd1, 0x53, 0xd8, 0x6e, 0x1d, 0x1e, 0xf7, 0x07, 0x06, 0x36,
-	0x5b, 0xbd, 0xee, 0x7e, 0xe7, 0x40, 0x49, 0x69, 0x77, 0x16, 0x4b, 0x5d, 0x9d, 0xac, 0x41, 0x9b,
-	0x7f, 0x86, 0x55, 0xc8, 0x76, 0xba, 0x6d, 0xe3, 0x77, 0x8a, 0xa4, 0xdd, 0x5c, 0x2c, 0x75, 0x25,
-	0x01, 0x14, 0xcf, 0xec, 0xc7, 0x50, 0xe6, 0x00, 0xf3, 0xf8, 0xa8, 0xdd, 0x18, 0x18, 0x4a, 0x5a,
-	0xd3, 0x16, 0x4b, 0x7d, 0xf7, 0x2a, 0x2e, 0xba, 0x92, 0xfb, 0x90, 0xc7, 0xc6, 0x6f, 0x8f, 0x8d,
-	0xfe, 0x40, 0xc9, 0x68, 0xbb, 0x8b, 0xa5, 0x8e, 0x12, 0xc0, 0xb8, 0xe3, 0x1e, 0x42, 0x01, 0x1b,
-	0xfd, 0xa3, 0x5e, 0xb7, 0x6f, 0x28, 0xb2, 0xf6, 0x7f, 0x8b, 0xa5, 0x7e, 0x63, 0x03, 0x15, 0x15,
-	0xf1, 0x4f, 0x61, 0xa7, 0xdd, 0xfb, 0xbc, 0x7b, 0xd8, 0x6b, 0xb4, 0xcd, 0x23, 0xdc, 0x3b, 0xc0,
-	0x46, 0xbf, 0xaf, 0x64, 0xb5, 0xea, 0x62, 0xa9, 0xdf, 0x4e, 0xe0, 0xaf, 0xd5, 0xe4, 0x5d, 0x90,
-	0x8f, 0x3a, 0xdd, 0x03, 0x25, 0xa7, 0xdd, 0x58, 0x2c, 0xf5, 0x0f, 0x12, 0x50, 0x46, 0x2a, 0xcb,
-	0xb8, 0x75, 0xd8, 0xeb, 0x1b, 0x4a, 0xfe, 0x5a, 0xc6, 0x9c, 0xec, 0xbd, 0xdf, 0x03, 0xba, 0xfe,
-	0x83, 0x8d, 0x1e, 0x80, 0xdc, 0xed, 0x75, 0x0d, 0x25, 0x25, 0xf2, 0xbf, 0x8e, 0xe8, 0x52, 0x8f,
-	0xa0, 0x1a, 0x64, 0x0e, 0xbf, 0xf8, 0x4c, 0x91, 0xb4, 0xff, 0x5f, 0x2c, 0xf5, 0x5b, 0xd7, 0x41,
-	0x87, 0x5f, 0x7c, 0xb6, 0x47, 0xa1, 0x94, 0x0c, 0x5c, 0x83, 0xc2, 0x73, 0x63, 0xd0, 0x68, 0x37,
-	0x06, 0x0d, 0x25, 0x25, 0x8e, 0x14, 0xbb, 0x9f, 0x93, 0xd0, 0xe2, 0x3d, 0x7a, 0x07, 0xb2, 0x5d,
-	0xe3, 0x85, 0x81, 0x15, 0x49, 0xdb, 0x59, 0x2c, 0xf5, 0xad, 0x18, 0xd0, 0x25, 0x67, 0xc4, 0x47,
-	0x15, 0xc8, 0x35, 0x0e, 0x3f, 0x6f, 0xbc, 0xec, 0x2b, 0x69, 0x0d, 0x2d, 0x96, 0xfa, 0x76, 0xec,
-	0x6e, 0xb8, 0xe7, 0xd6, 0x3c, 0xd8, 0xfb, 0x8f, 0x04, 0xe5, 0xe4, 0x33, 0x89, 0x2a, 0x20, 0xef,
-	0x77, 0x0e, 0x8d, 0x78, 0xbb, 0xa4, 0x8f, 0x8d, 0x51, 0x1d, 0x8a, 0xed, 0x0e, 0x36, 0x5a, 0x83,
-	0x1e, 0x7e, 0x19, 0xe7, 0x92, 0x04, 0xb5, 0x1d, 0x9f, 0xd7, 0xff, 0x1c, 0xfd, 0x1c, 0xca, 0xfd,
-	0x97, 0xcf, 0x0f, 0x3b, 0xdd, 0xdf, 0x98, 0x3c, 0x62, 0x5a, 0x7b, 0xb4, 0x58, 0xea, 0xf7, 0x36,
-	0xc0, 0x64, 0xea, 0x93, 0xa1, 0x15, 0x12, 0xbb, 0x2f, 0x9e, 0x7c, 0xe6, 0x2c, 0x48, 0xa8, 0x05,
-	0x3b, 0xf1, 0xd2, 0xf5, 0x66, 0x19, 0xed, 0xe3, 0xc5, 0x52, 0xff, 0xf0, 0x7b, 0xd7, 0xaf, 0x76,
-	0x2f, 0x48, 0xe8, 0x01, 0xe4, 0xa3, 0x20, 0x71, 0x25, 0x25, 0x97, 0x46, 0x0b, 0xf6, 0xfe, 0x2c,
-	0x41, 0x71, 0xa5, 0x66, 0x8c, 0xf0, 0x6e, 0xcf, 0x34, 0x30, 0xee, 0xe1, 0x98, 0x81, 0x95, 0xb3,
-	0x4b, 0xf9, 0x10, 0xdd, 0x83, 0xfc, 0x81, 0xd1, 0x35, 0x70, 0xa7, 0x15, 0x37, 0xc6, 0x0a, 0x72,
-	0x40, 0x3c, 0xe2, 0x3b, 0x43, 0xf4, 0x11, 0x94, 0xbb, 0x3d, 0xb3, 0x7f, 0xdc, 0x7a, 0x16, 0xa7,
-	0xce, 0xf7, 0x4f, 0x84, 0xea, 0xcf, 0x86, 0xa7, 0x9c, 0xcf, 0x3d, 0xd6, 0x43, 0x2f, 0x1a, 0x87,
-	0x9d, 0xb6, 0x80, 0x66, 0x34, 0x75, 0xb1, 0xd4, 0x6f, 0xae, 0xa0, 0xd1, 0x1b, 0xce, 0xb0, 0x7b,
-	0x36, 0x54, 0xbe, 0x5f, 0xb7, 0x90, 0x0e, 0xb9, 0xc6, 0xd1, 0x91, 0xd1, 0x6d, 0xc7, 0xa7, 0x5f,
-	0xfb, 0x1a, 0xd3, 0x29, 0xf1, 0x6c, 0x86, 0xd8, 0xef, 0xe1, 0x03, 0x63, 0x10, 0x1f, 0x7e, 0x8d,
-	0xd8, 0xa7, 0xec, 0x7f, 0xab, 0x59, 0x7f, 0xf3, 0x4d, 0x25, 0xf5, 0xf6, 0x9b, 0x4a, 0xea, 0xcd,
-	0x65, 0x45, 0x7a, 0x7b, 0x59, 0x91, 0xfe, 0x71, 0x59, 0x49, 0x7d, 0x7b, 0x59, 0x91, 0xfe, 0xf8,
-	0xae, 0x92, 0xfa, 0xea, 0x5d, 0x45, 0x7a, 0xfb, 0xae, 0x92, 0xfa, 0xdb, 0xbb, 0x4a, 0xea, 0x24,
-	0xc7, 0x35, 0xef, 0xd3, 0xff, 0x06, 0x00, 0x00, 0xff, 0xff, 0xfb, 0x99, 0x31, 0x0e, 0x75, 0x0f,
-	0x00, 0x00,
+	// 2042 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0xcd, 0x73, 0xdb, 0xc6,
+	0x15, 0x27, 0xf8, 0x09, 0x3e, 0x52, 0x32, 0xb4, 0x96, 0x15, 0x98, 0x71, 0x48, 0x98, 0xb6, 0x63,
+	0x45, 0x93, 0xd8, 0x8e, 0xe3, 0xb6, 0xd3, 0x4c, 0xbf, 0xf8, 0x01, 0xc9, 0x9c, 0xca, 0xa4, 0xba,
+	0xa4, 0x9c, 0xd8, 0x17, 0x0c, 0x44, 0xac, 0x64, 0x8c, 0x40, 0x2c, 0x0b, 0x80, 0x92, 0x99, 0x73,
+	0x4f, 0x3c, 0xb5, 0xb7, 0x5e, 0x38, 0x93, 0x99, 0x9e, 0x3a, 0xd3, 0x3f, 0xc4, 0x47, 0x1f, 0x3b,
+	0x3d, 0x68, 0x1a, 0xf9, 0x92, 0xce, 0xf4, 0xd0, 0x4b, 0x0f, 0xed, 0xa1, 0xd3, 0xd9, 0x5d, 0x00,
+	0x04, 0x25, 0x3b, 0xcd, 0xa1, 0x27, 0xee, 0xbe, 0xf7, 0xc3, 0xdb, 0x7d, 0xbf, 0xf7, 0xb5, 0x84,
+	0xe2, 0x01, 0x19, 0xdf, 0x1b, 0x7b, 0x34, 0xa0, 0x48, 0xe6, 0x3f, 0x43, 0xea, 0x54, 0x6e, 0x79,
+	0x64, 0x4c, 0xfd, 0xfb, 0x7c, 0x7f, 0x30, 0x39, 0xbc, 0x7f, 0x44, 0x8f, 0x28, 0xdf, 0xf0, 0x95,
+	0x80, 0xd7, 0x7f, 0x97, 0x86, 0xdc, 0x63, 0xe2, 0x38, 0x14, 0xd5, 0xa0, 0x64, 0x91, 0x13, 0x7b,
+	0x48, 0x0c, 0xd7, 0x1c, 0x11, 0x55, 0xd2, 0xa4, 0xcd, 0x22, 0x06, 0x21, 0xea, 0x9a, 0x23, 0xc2,
+	0x00, 0x43, 0xc7, 0x26, 0x6e, 0x20, 0x00, 0x69, 0x01, 0x10, 0x22, 0x0e, 0xb8, 0x03, 0xab, 0x21,
+	0xe0, 0x84, 0x78, 0xbe, 0x4d, 0x5d, 0x35, 0xc3, 0x31, 0x2b, 0x42, 0xfa, 0x54, 0x08, 0xd1, 0x2f,
+	0xa0, 0x3c, 0xa4, 0xa3, 0xb1, 0x47, 0x7c, 0xb6, 0xf5, 0xd5, 0xac, 0x96, 0xd9, 0x5c, 0x7d, 0x78,
+	0xe3, 0x5e, 0x74, 0xf1, 0x7b, 0x4f, 0x88, 0xef, 0x9b, 0x47, 0xa4, 0xb5, 0x00, 0xe1, 0xa5, 0x2f,
+	0xd0, 0x5d, 0xb8, 0xe2, 0x4e, 0x46, 0xc6, 0x90, 0xba, 0x2e, 0x19, 0x06, 0xdc, 0x48, 0x4e, 0x93,
+	0x36, 0x73, 0x78, 0xd5, 0x9d, 0x8c, 0x5a, 0x0b, 0x29, 0x7a, 0x04, 0xe5, 0xa1, 0x39, 0x36, 0x0f,
+	0x6c, 0xc7, 0x0e, 0x6c, 0xe2, 0xab, 0x79, 0x4d, 0xda, 0xcc, 0x36, 0x95, 0x7f, 0x9f, 0xd5, 0xca,
+	0xad, 0x84, 0x1c, 0x2f, 0xa1, 0xea, 0x3e, 0xe4, 0x1f, 0x13, 0xd3, 0x22, 0x1e, 0xfa, 0x08, 0xb2,
+	0xc1, 0x74, 0x2c, 0xc8, 0x58, 0x7d, 0x78, 0xed, 0xd2, 0x15, 0x07, 0xd3, 0x31, 0xc1, 0x1c, 0x82,
+	0x7e, 0x06, 0xa5, 0xc4, 0x1d, 0x39, 0x3b, 0xff, 0xcb, 0xa9, 0xe4, 0x07, 0xf5, 0x06, 0xac, 0xb4,
+	0x9c, 0x89, 0x1f, 0x10, 0xaf, 0x45, 0xdd, 0x43, 0xfb, 0x08, 0x3d, 0x80, 0xc2, 0x21, 0x75, 0x2c,
+	0xe2, 0xf9, 0xaa, 0xa4, 0x65, 0x36, 0x4b, 0x0f, 0x95, 0x85, 0xb1, 0x6d, 0xae, 0x68, 0x66, 0x5f,
+	0x9d, 0xd5, 0x52, 0x38, 0x82, 0xd5, 0xff, 0x90, 0x86, 0xbc, 0xd0, 0xa0, 0x0d, 0x48, 0xdb, 0x96,
+	0x88, 0x61, 0x33, 0x7f, 0x7e, 0x56, 0x4b, 0x77, 0xda, 0x38, 0x6d, 0x5b, 0x68, 0x1d, 0x72, 0x8e,
+	0x79, 0x40, 0x9c, 0x30, 0x7a, 0x62, 0x83, 0xde, 0x87, 0xa2, 0x47, 0x4c, 0xcb, 0xa0, 0xae, 0x33,
+	0xe5, 0x31, 0x93, 0xb1, 0xcc, 0x04, 0x3d, 0xd7, 0x99, 0xa2, 0x4f, 0x00, 0xd9, 0x47, 0x2e, 0xf5,
+	0x88, 0x31, 0x26, 0xde, 0xc8, 0x8e, 0x83, 0xc6, 0x50, 0x6b, 0x42, 0xb3, 0xb7, 0x50, 0xa0, 0x5b,
+	0xb0, 0x12, 0xc2, 0x2d, 0xe2, 0x90, 0x80, 0xf0, 0xc8, 0xc8, 0xb8, 0x2c, 0x84, 0x6d, 0x2e, 0x43,
+	0x0f, 0x60, 0xdd, 0xb2, 0x7d, 0xf3, 0xc0, 0x21, 0x46, 0x40, 0x46, 0x63, 0xc3, 0x76, 0x2d, 0xf2,
+	0x32, 0x8c, 0x8f, 0x8c, 0x51, 0xa8, 0x1b, 0x90, 0xd1, 0xb8, 0x23, 0x34, 0x68, 0x03, 0xf2, 0x63,
+	0x73, 0xe2, 0x13, 0x4b, 0x2d, 0x70, 0x4c, 0xb8, 0x63, 0x2c, 0x89, 0x14, 0xf5, 0x55, 0xe5, 0x22,
+	0x4b, 0x6d, 0xae, 0x88, 0x58, 0x0a, 0x61, 0xf5, 0x3f, 0x65, 0x20, 0x2f, 0x34, 0xe8, 0xc3, 0x98,
+	0xa5, 0x72, 0x73, 0x83, 0xa1, 0xfe, 0x72, 0x56, 0x93, 0x85, 0xae, 0xd3, 0x4e, 0xb0, 0x86, 0x20,
+	0x9b, 0x48, 0x79, 0xbe, 0x46, 0x37, 0xa0, 0x68, 0x5a, 0x16, 0x8b, 0x1e, 0xf1, 0xd5, 0x8c, 0x96,
+	0xd9, 0x2c, 0xe2, 0x85, 0x00, 0xfd, 0x68, 0x39, 0x1b, 0xb2, 0x17, 0xf3, 0xe7, 0x5d, 0x69, 0xc0,
+	0x42, 0x31, 0x24, 0x5e, 0x58, 0x62, 0x39, 0x7e, 0x9e, 0xcc, 0x04, 0xbc, 0xc0, 0x6e, 0x42, 0x79,
+	0x64, 0xbe, 0x34, 0x7c, 0xf2, 0xeb, 0x09, 0x71, 0x87, 0x84, 0xd3, 0x95, 0xc1, 0xa5, 0x91, 0xf9,
+	0xb2, 0x1f, 0x8a, 0x50, 0x15, 0xc0, 0x76, 0x03, 0x8f, 0x5a, 0x93, 0x21, 0xf1, 0x42, 0xae, 0x12,
+	0x12, 0xf4, 0x03, 0x90, 0x39, 0xd9, 0x86, 0x6d, 0xa9, 0x32, 0xaf, 0x86, 0x4a, 0xe8, 0x78, 0x81,
+	0x53, 0xcd, 0xfd, 0x8e, 0x96, 0xb8, 0xc0, 0xb1, 0x1d, 0x0b, 0xfd, 0x04, 0x2a, 0xfe, 0xb1, 0x3d,
+	0x36, 0x22, 0x4b, 0xac, 0xbc, 0x0c, 0x8f, 0x8c, 0xe8, 0x89, 0xe9, 0xf8, 0x6a, 0x91, 0x1f, 0xa3,
+	0x32, 0x44, 0x27, 0x01, 0xc0, 0xa1, 0x1e, 0x7d, 0x0e, 0xd7, 0x89, 0x3b, 0xf4, 0xa6, 0x63, 0xfe,
+	0xd9, 0xd8, 0xf4, 0xfd, 0x53, 0xea, 0x59, 0x46, 0x40, 0x8f, 0x89, 0xab, 0x02, 0xa3, 0x1f, 0xbf,
+	0xb7, 0x00, 0xec, 0x85, 0xfa, 0x01, 0x53, 0xd7, 0x7b, 0x90, 0xe3, 0xb7, 0x61, 0x19, 0x20, 0x12,
+	0x3d, 0x6c, 0x4d, 0xe1, 0x0e, 0xdd, 0x83, 0xdc, 0xa1, 0xed, 0x10, 0x5f, 0x4d, 0xf3, 0xf8, 0xa3,
+	0x44, 0x95, 0xd8, 0x0e, 0xe9, 0xb8, 0x87, 0x34, 0xcc, 0x00, 0x01, 0xab, 0xef, 0x43, 0x89, 0x1b,
+	0xdc, 0x1f, 0x5b, 0x66, 0x40, 0xfe, 0x6f, 0x66, 0xff, 0x91, 0x03, 0x39, 0xd2, 0xc4, 0x09, 0x23,
+	0x25, 0x12, 0x06, 0x41, 0xd6, 0xb7, 0xbf, 0x22, 0xbc, 0xbe, 0x32, 0x98, 0xaf, 0xd1, 0x07, 0x00,
+	0x23, 0x6a, 0xd9, 0x87, 0x36, 0xb1, 0x0c, 0xd1, 0xc3, 0x32, 0xb8, 0x18, 0x49, 0xfa, 0xe8, 0x01,
+	0x94, 0x62, 0xf5, 0xc1, 0x54, 0x2d, 0xf3, 0x78, 0x5d, 0x89, 0xe2, 0xd5, 0x7f, 0x41, 0xbd, 0xa0,
+	0xd3, 0xc6, 0xb1, 0x89, 0xe6, 0x94, 0x95, 0x43, 0xd4, 0x7b, 0x59, 0x50, 0x96, 0xca, 0xe1, 0x29,
+	0x19, 0x06, 0x34, 0x6e, 0x1a, 0x21, 0x0c, 0x55, 0x40, 0x8e, 0xf3, 0x09, 0xf8, 0x05, 0xe2, 0x3d,
+	0xfa, 0x14, 0xf2, 0x07, 0x0e, 0x1d, 0x1e, 0x47, 0xb5, 0x75, 0x75, 0x61, 0xac, 0xc9, 0xe4, 0x09,
+	0x16, 0x42, 0x20, 0x9b, 0x01, 0xfe, 0x74, 0xe4, 0xd8, 0xee, 0xb1, 0x11, 0x98, 0xde, 0x11, 0x09,
+	0xd4, 0x35, 0x31, 0x03, 0x42, 0xe9, 0x80, 0x0b, 0xd9, 0x2c, 0x11, 0x1f, 0x18, 0x2f, 0x4c, 0xff,
+	0x85, 0x8a, 0x78, 0x0e, 0x80, 0x10, 0x3d, 0x36, 0xfd, 0x17, 0xe8, 0x13, 0xc8, 0xbf, 0x34, 0x83,
+	0xc0, 0xf3, 0xd5, 0xab, 0xfc, 0xe8, 0x2b, 0x8b, 0xa3, 0xbf, 0x64, 0xf2, 0xe8, 0x58, 0x01, 0x62,
+	0xd5, 0x18, 0x26, 0x10, 0xb1, 0xd4, 0x75, 0x6e, 0x6d, 0x21, 0x40, 0x5b, 0x61, 0x1b, 0x17, 0x4d,
+	0x79, 0xe3, 0x72, 0x28, 0x13, 0x7d, 0x5c, 0x83, 0xd2, 0xc5, 0x3e, 0xb7, 0x82, 0x93, 0x22, 0x76,
+	0xf7, 0x38, 0x2a, 0xae, 0xaf, 0x96, 0xf8, 0xe4, 0x89, 0x83, 0xd0, 0xf5, 0xd1, 0x7d, 0x10, 0x9e,
+	0x18, 0x3c, 0xde, 0x2b, 0x4c, 0xdf, 0x54, 0xce, 0xcf, 0x6a, 0x65, 0x6c, 0x9e, 0x72, 0xde, 0xfa,
+	0xf6, 0x57, 0x04, 0x17, 0x0f, 0xa2, 0x25, 0x3b, 0xd3, 0xa1, 0x43, 0xd3, 0x31, 0x0e, 0x1d, 0xf3,
+	0xc8, 0x57, 0xbf, 0x2d, 0xf0, 0x43, 0x81, 0xcb, 0xb6, 0x99, 0x08, 0xd5, 0xa1, 0x1c, 0x06, 0x4c,
+	0x10, 0xf6, 0xb7, 0x02, 0xf7, 0xb1, 0x14, 0x0a, 0x39, 0x65, 0x2a, 0x6b, 0x85, 0xac, 0xbd, 0x5a,
+	0x61, 0x1f, 0x8d, 0xb6, 0x68, 0x13, 0x0a, 0xb6, 0x7b, 0x62, 0x3a, 0x76, 0xd8, 0x3d, 0x9b, 0xab,
+	0xe7, 0x67, 0x35, 0xc0, 0xe6, 0x69, 0x47, 0x48, 0x71, 0xa4, 0x66, 0xe1, 0x73, 0xe9, 0x52, 0xa3,
+	0x97, 0xb9, 0xa9, 0x15, 0x97, 0x26, 0x9a, 0xfc, 0xe7, 0xd9, 0xdf, 0x7f, 0x5d, 0x4b, 0xd5, 0x5d,
+	0x28, 0xc6, 0x69, 0xc0, 0xd2, 0x9b, 0xdf, 0x2c, 0xc3, 0x2f, 0xc6, 0xd7, 0xac, 0xb6, 0xe8, 0xe1,
+	0xa1, 0x4f, 0x02, 0x5e, 0x08, 0x19, 0x1c, 0xee, 0xe2, 0x52, 0x48, 0x73, 0xea, 0xf8, 0x9a, 0x35,
+	0xbe, 0x53, 0x62, 0x1e, 0x0b, 0xf7, 0x04, 0xeb, 0x32, 0x13, 0x30, 0xd7, 0xc2, 0xf3, 0x3e, 0x85,
+	0x1c, 0x8f, 0xfd, 0x5b, 0xcb, 0x6b, 0x1d, 0x72, 0x27, 0xa6, 0x33, 0x11, 0x46, 0xcb, 0x58, 0x6c,
+	0xea, 0x3f, 0x85, 0xbc, 0x48, 0x7b, 0xf4, 0x19, 0xc8, 0x43, 0x3a, 0x71, 0x83, 0xc5, 0x3c, 0x5d,
+	0x4b, 0xb6, 0x63, 0xae, 0x09, 0x93, 0x2a, 0x06, 0xd6, 0xb7, 0xa1, 0x10, 0xaa, 0xd0, 0x9d, 0x78,
+	0x56, 0x64, 0x9b, 0xd7, 0x2e, 0x94, 0xe0, 0xf2, 0x80, 0x5d, 0x5c, 0x23, 0x1b, 0x5d, 0xe3, 0xef,
+	0x12, 0x14, 0x30, 0xab, 0x2a, 0x3f, 0x48, 0x8c, 0xe6, 0xdc, 0xd2, 0x68, 0x5e, 0x34, 0xa2, 0xf4,
+	0x52, 0x23, 0x8a, 0x9c, 0xcd, 0x24, 0x9c, 0x5d, 0x10, 0x9b, 0x7d, 0x2b, 0xb1, 0xb9, 0x04, 0xb1,
+	0x51, 0x60, 0xf2, 0x89, 0xc0, 0xdc, 0x81, 0xd5, 0x43, 0x8f, 0x8e, 0xf8, 0xf0, 0xa5, 0x9e, 0xe9,
+	0x4d, 0xc3, 0x49, 0xb1, 0xc2, 0xa4, 0x83, 0x48, 0xb8, 0x1c, 0x13, 0x79, 0x39, 0x26, 0xe8, 0x3a,
+	0xc8, 0x22, 0xcb, 0x5d, 0xca, 0x7b, 0x4d, 0x0e, 0x17, 0xf8, 0xbe, 0x4b, 0xeb, 0x3f, 0x07, 0x39,
+	0xf4, 0xd6, 0x67, 0xbc, 0x7b, 0xe1, 0xfa, 0x32, 0xef, 0x21, 0x2a, 0xe2, 0x3d, 0x02, 0xd6, 0x0d,
+	0x66, 0xc0, 0x1f, 0x53, 0xd7, 0x27, 0xef, 0xe4, 0x0b, 0x41, 0xd6, 0x32, 0x03, 0x33, 0x8c, 0x37,
+	0x5f, 0xa3, 0xbb, 0x90, 0x1d, 0x52, 0x4b, 0x70, 0xb5, 0x9a, 0x6c, 0x57, 0xba, 0xe7, 0x51, 0xaf,
+	0x45, 0x2d, 0x82, 0x39, 0xa0, 0x3e, 0x06, 0xa5, 0x4d, 0x4f, 0x5d, 0x87, 0x9a, 0xd6, 0x9e, 0x47,
+	0x8f, 0xd8, 0xf4, 0x7d, 0xe7, 0x24, 0x68, 0x43, 0x61, 0xc2, 0x67, 0x45, 0x34, 0x0b, 0x6e, 0x2f,
+	0x37, 0x90, 0x8b, 0x86, 0xc4, 0x60, 0x89, 0xfa, 0x6c, 0xf8, 0x69, 0xfd, 0x9f, 0x12, 0x54, 0xde,
+	0x8d, 0x46, 0x1d, 0x28, 0x09, 0xa4, 0x91, 0x78, 0x70, 0x6e, 0x7e, 0x9f, 0x83, 0x78, 0xef, 0x82,
+	0x49, 0xbc, 0x7e, 0xeb, 0x6b, 0x25, 0x31, 0x17, 0x32, 0xdf, 0x6f, 0x2e, 0xdc, 0x85, 0x15, 0x11,
+	0xde, 0xe8, 0x6d, 0xc6, 0x9e, 0xe9, 0xb9, 0x66, 0x5a, 0x49, 0xe1, 0xf2, 0x81, 0xa8, 0x7a, 0x2e,
+	0x67, 0x33, 0x2c, 0xd1, 0xed, 0x44, 0xe6, 0x2d, 0x7a, 0x5b, 0x3d, 0x0f, 0xd9, 0x3d, 0xdb, 0x3d,
+	0xaa, 0xd7, 0x20, 0xd7, 0x72, 0x28, 0x8f, 0x67, 0xde, 0x23, 0xa6, 0x4f, 0xdd, 0x88, 0x66, 0xb1,
+	0xdb, 0xfa, 0x57, 0x1a, 0x4a, 0x89, 0x67, 0x35, 0x7a, 0x00, 0xab, 0xad, 0xdd, 0xfd, 0xfe, 0x40,
+	0xc7, 0x46, 0xab, 0xd7, 0xdd, 0xee, 0xec, 0x28, 0xa9, 0xca, 0x8d, 0xd9, 0x5c, 0x53, 0x47, 0x0b,
+	0xd0, 0xf2, 0x8b, 0xb9, 0x06, 0xb9, 0x4e, 0xb7, 0xad, 0x7f, 0xa9, 0x48, 0x95, 0xf5, 0xd9, 0x5c,
+	0x53, 0x12, 0x40, 0xf1, 0x84, 0xf8, 0x18, 0xca, 0x1c, 0x60, 0xec, 0xef, 0xb5, 0x1b, 0x03, 0x5d,
+	0x49, 0x57, 0x2a, 0xb3, 0xb9, 0xb6, 0x71, 0x11, 0x17, 0x86, 0xe4, 0x16, 0x14, 0xb0, 0xfe, 0xab,
+	0x7d, 0xbd, 0x3f, 0x50, 0x32, 0x95, 0x8d, 0xd9, 0x5c, 0x43, 0x09, 0x60, 0x54, 0xcd, 0x77, 0x40,
+	0xc6, 0x7a, 0x7f, 0xaf, 0xd7, 0xed, 0xeb, 0x4a, 0xb6, 0xf2, 0xde, 0x6c, 0xae, 0x5d, 0x5d, 0x42,
+	0x85, 0x49, 0xfc, 0x43, 0x58, 0x6b, 0xf7, 0xbe, 0xe8, 0xee, 0xf6, 0x1a, 0x6d, 0x63, 0x0f, 0xf7,
+	0x76, 0xb0, 0xde, 0xef, 0x2b, 0xb9, 0x4a, 0x6d, 0x36, 0xd7, 0xde, 0x4f, 0xe0, 0x2f, 0xe5, 0xe4,
+	0x07, 0x90, 0xdd, 0xeb, 0x74, 0x77, 0x94, 0x7c, 0xe5, 0xea, 0x6c, 0xae, 0x5d, 0x49, 0x40, 0x19,
+	0xa9, 0xcc, 0xe3, 0xd6, 0x6e, 0xaf, 0xaf, 0x2b, 0x85, 0x4b, 0x1e, 0x0b, 0xb2, 0xf9, 0xf5, 0xb8,
+	0x0f, 0x7d, 0x45, 0x7e, 0xcb, 0xf5, 0x44, 0xbd, 0x6d, 0xfd, 0x46, 0x02, 0x74, 0xf9, 0x0f, 0x0a,
+	0xba, 0x0d, 0xd9, 0x6e, 0xaf, 0xab, 0x2b, 0x29, 0xc1, 0xd3, 0x65, 0x44, 0x97, 0xba, 0x04, 0xd5,
+	0x21, 0xb3, 0xfb, 0xfc, 0x91, 0x22, 0x55, 0xae, 0xcf, 0xe6, 0xda, 0xb5, 0xcb, 0xa0, 0xdd, 0xe7,
+	0x8f, 0x98, 0xa5, 0xe7, 0xfd, 0x41, 0x3b, 0x62, 0xfc, 0x32, 0xe8, 0xb9, 0x1f, 0x58, 0x5b, 0x14,
+	0x4a, 0xc9, 0xe3, 0xeb, 0x20, 0x3f, 0xd1, 0x07, 0x8d, 0x76, 0x63, 0xd0, 0x50, 0x52, 0xc2, 0xc1,
+	0x48, 0xfd, 0x84, 0x04, 0x26, 0xaf, 0xf8, 0x1b, 0x90, 0xeb, 0xea, 0x4f, 0x75, 0xac, 0x48, 0x95,
+	0xb5, 0xd9, 0x5c, 0x5b, 0x89, 0x00, 0x5d, 0x72, 0x42, 0x3c, 0x54, 0x85, 0x7c, 0x63, 0xf7, 0x8b,
+	0xc6, 0xb3, 0xbe, 0x92, 0xae, 0xa0, 0xd9, 0x5c, 0x5b, 0x8d, 0xd4, 0x0d, 0xe7, 0xd4, 0x9c, 0xfa,
+	0x5b, 0xff, 0x91, 0xa0, 0x9c, 0x7c, 0x03, 0xa0, 0x2a, 0x64, 0xb7, 0x3b, 0xbb, 0x7a, 0x74, 0x5c,
+	0x52, 0xc7, 0xd6, 0x68, 0x13, 0x8a, 0xed, 0x0e, 0xd6, 0x5b, 0x83, 0x1e, 0x7e, 0x16, 0x79, 0x9c,
+	0x04, 0xb5, 0x6d, 0x8f, 0x57, 0xd3, 0x14, 0xfd, 0x18, 0xca, 0xfd, 0x67, 0x4f, 0x76, 0x3b, 0xdd,
+	0x5f, 0x1a, 0xdc, 0x62, 0xba, 0x72, 0x77, 0x36, 0xd7, 0x6e, 0x2e, 0x81, 0xc9, 0xd8, 0x23, 0x43,
+	0x33, 0x20, 0x56, 0x5f, 0x3c, 0x8e, 0x98, 0x52, 0x96, 0x50, 0x0b, 0xd6, 0xa2, 0x4f, 0x17, 0x87,
+	0x65, 0x2a, 0x1f, 0xcf, 0xe6, 0xda, 0x87, 0xdf, 0xf9, 0x7d, 0x7c, 0xba, 0x2c, 0xa1, 0xdb, 0x50,
+	0x08, 0x8d, 0x44, 0x79, 0x99, 0xfc, 0x34, 0xfc, 0x60, 0xeb, 0x8f, 0x12, 0x14, 0xe3, 0xde, 0xc8,
+	0x08, 0xef, 0xf6, 0x0c, 0x1d, 0xe3, 0x1e, 0x8e, 0x18, 0x88, 0x95, 0x5d, 0xca, 0x97, 0xe8, 0x26,
+	0x14, 0x76, 0xf4, 0xae, 0x8e, 0x3b, 0xad, 0xa8, 0xcc, 0x62, 0xc8, 0x0e, 0x71, 0x89, 0x67, 0x0f,
+	0xd1, 0x47, 0x50, 0xee, 0xf6, 0x8c, 0xfe, 0x7e, 0xeb, 0x71, 0xe4, 0x3a, 0x3f, 0x3f, 0x61, 0xaa,
+	0x3f, 0x19, 0xbe, 0xe0, 0x7c, 0x6e, 0xb1, 0x8a, 0x7c, 0xda, 0xd8, 0xed, 0xb4, 0x05, 0x34, 0x53,
+	0x51, 0x67, 0x73, 0x6d, 0x3d, 0x86, 0x86, 0x0f, 0x14, 0x86, 0xdd, 0xb2, 0xa0, 0xfa, 0xdd, 0x5d,
+	0x10, 0x69, 0x90, 0x6f, 0xec, 0xed, 0xe9, 0xdd, 0x76, 0x74, 0xfb, 0x85, 0xae, 0x31, 0x1e, 0x13,
+	0xd7, 0x62, 0x88, 0xed, 0x1e, 0xde, 0xd1, 0x07, 0x8a, 0x74, 0x11, 0xb1, 0x4d, 0xd9, 0xcb, 0xb4,
+	0xb9, 0xf9, 0xea, 0x9b, 0x6a, 0xea, 0xf5, 0x37, 0xd5, 0xd4, 0xab, 0xf3, 0xaa, 0xf4, 0xfa, 0xbc,
+	0x2a, 0xfd, 0xf5, 0xbc, 0x9a, 0xfa, 0xf6, 0xbc, 0x2a, 0xfd, 0xf6, 0x4d, 0x35, 0xf5, 0xf5, 0x9b,
+	0xaa, 0xf4, 0xfa, 0x4d, 0x35, 0xf5, 0xe7, 0x37, 0xd5, 0xd4, 0x41, 0x9e, 0x77, 0xd0, 0xcf, 0xfe,
+	0x3b, 0x00, 0x04, 0x48, 0x8e, 0x09, 0x7d, 0x11, 0x00, 0x00,
 }
 
 func (m *Hello) Marshal() (dAtA []byte, err error) {
@@ -1064,6 +1172,34 @@ func (m *Hello) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Capabilities != 0 {
+		i = encodeVarintBep(dAtA, i, uint64(m.Capabilities))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.NumConnections != 0 {
+		i = encodeVarintBep(dAtA, i, uint64(m.NumConnections))
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.Compressions) > 0 {
+		dAtA2 := make([]byte, len(m.Compressions)*10)
+		var j1 int
+		for _, num := range m.Compressions {
+			for num >= 1<<7 {
+				dAtA2[j1] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j1++
+			}
+			dAtA2[j1] = uint8(num)
+			j1++
+		}
+		i -= j1
+		copy(dAtA[i:], dAtA2[:j1])
+		i = encodeVarintBep(dAtA, i, uint64(j1))
+		i--
+		dAtA[i] = 0x22
+	}
 	if len(m.ClientVersion) > 0 {
 		i -= len(m.ClientVersion)
 		copy(dAtA[i:], m.ClientVersion)
@@ -1281,6 +1417,13 @@ func (m *Device) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.EncryptionPasswordToken) > 0 {
+		i -= len(m.EncryptionPasswordToken)
+		copy(dAtA[i:], m.EncryptionPasswordToken)
+		i = encodeVarintBep(dAtA, i, uint64(len(m.EncryptionPasswordToken)))
+		i--
+		dAtA[i] = 0x52
+	}
 	if m.SkipIntroductionRemovals {
 		i--
 		if m.SkipIntroductionRemovals {
@@ -1476,6 +1619,31 @@ func (m *FileInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i--
 		dAtA[i] = 0xc0
 	}
+	if len(m.Encrypted) > 0 {
+		i -= len(m.Encrypted)
+		copy(dAtA[i:], m.Encrypted)
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Encrypted)))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xa2
+	}
+	if len(m.Xattrs) > 0 {
+		for iNdEx := len(m.Xattrs) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Xattrs[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintBep(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1
+			i--
+			dAtA[i] = 0x9a
+		}
+	}
 	if len(m.BlocksHash) > 0 {
 		i -= len(m.BlocksHash)
 		copy(dAtA[i:], m.BlocksHash)
@@ -1645,6 +1813,43 @@ func (m *BlockInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *Xattr) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Xattr) MarshalTo(dAtA []byte) (int, error) {
+	size := m.ProtoSize()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Xattr) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Value) > 0 {
+		i -= len(m.Value)
+		copy(dAtA[i:], m.Value)
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Value)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Name) > 0 {
+		i -= len(m.Name)
+		copy(dAtA[i:], m.Name)
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Name)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *Vector) Marshal() (dAtA []byte, err error) {
 	size := m.ProtoSize()
 	dAtA = make([]byte, size)
@@ -1735,6 +1940,11 @@ func (m *Request) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.BlockNo != 0 {
+		i = encodeVarintBep(dAtA, i, uint64(m.BlockNo))
+		i--
+		dAtA[i] = 0x48
+	}
 	if m.WeakHash != 0 {
 		i = encodeVarintBep(dAtA, i, uint64(m.WeakHash))
 		i--
@@ -1789,6 +1999,43 @@ func (m *Request) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *Requests) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Requests) MarshalTo(dAtA []byte) (int, error) {
+	size := m.ProtoSize()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Requests) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Requests) > 0 {
+		for iNdEx := len(m.Requests) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Requests[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintBep(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *Response) Marshal() (dAtA []byte, err error) {
 	size := m.ProtoSize()
 	dAtA = make([]byte, size)
@@ -2012,6 +2259,19 @@ func (m *Hello) ProtoSize() (n int) {
 	if l > 0 {
 		n += 1 + l + sovBep(uint64(l))
 	}
+	if len(m.Compressions) > 0 {
+		l = 0
+		for _, e := range m.Compressions {
+			l += sovBep(uint64(e))
+		}
+		n += 1 + sovBep(uint64(l)) + l
+	}
+	if m.NumConnections != 0 {
+		n += 1 + sovBep(uint64(m.NumConnections))
+	}
+	if m.Capabilities != 0 {
+		n += 1 + sovBep(uint64(m.Capabilities))
+	}
 	return n
 }
 
@@ -2120,6 +2380,10 @@ func (m *Device) ProtoSize() (n int) {
 	if m.SkipIntroductionRemovals {
 		n += 2
 	}
+	l = len(m.EncryptionPasswordToken)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
 	return n
 }
 
@@ -2220,6 +2484,16 @@ func (m *FileInfo) ProtoSize() (n int) {
 	if l > 0 {
 		n += 2 + l + sovBep(uint64(l))
 	}
+	if len(m.Xattrs) > 0 {
+		for _, e := range m.Xattrs {
+			l = e.ProtoSize()
+			n += 2 + l + sovBep(uint64(l))
+		}
+	}
+	l = len(m.Encrypted)
+	if l > 0 {
+		n += 2 + l + sovBep(uint64(l))
+	}
 	if m.LocalFlags != 0 {
 		n += 2 + sovBep(uint64(m.LocalFlags))
 	}
@@ -2252,6 +2526,23 @@ func (m *BlockInfo) ProtoSize() (n int) {
 	return n
 }
 
+func (m *Xattr) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	return n
+}
+
 func (m *Vector) ProtoSize() (n int) {
 	if m == nil {
 		return 0
@@ -2315,6 +2606,24 @@ func (m *Request) ProtoSize() (n int) {
 	if m.WeakHash != 0 {
 		n += 1 + sovBep(uint64(m.WeakHash))
 	}
+	if m.BlockNo != 0 {
+		n += 1 + sovBep(uint64(m.BlockNo))
+	}
+	return n
+}
+
+func (m *Requests) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Requests) > 0 {
+		for _, e := range m.Requests {
+			l = e.ProtoSize()
+			n += 1 + l + sovBep(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -2535,13 +2844,120 @@ func (m *Hello) Unmarshal(dAtA []byte) error {
 			}
 			m.ClientVersion = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipBep(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 4:
+			if wireType == 0 {
+				var v MessageCompression
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowBep
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= MessageCompression(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.Compressions = append(m.Compressions, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowBep
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthBep
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthBep
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				if elementCount != 0 && len(m.Compressions) == 0 {
+					m.Compressions = make([]MessageCompression, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v MessageCompression
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowBep
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= MessageCompression(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.Compressions = append(m.Compressions, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Compressions", wireType)
 			}
-			if skippy < 0 {
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NumConnections", wireType)
+			}
+			m.NumConnections = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NumConnections |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Capabilities", wireType)
+			}
+			m.Capabilities = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Capabilities |= Capabilities(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthBep
 			}
 			if (iNdEx + skippy) < 0 {
@@ -3243,6 +3659,40 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.SkipIntroductionRemovals = bool(v != 0)
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EncryptionPasswordToken", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBep
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EncryptionPasswordToken = append(m.EncryptionPasswordToken[:0], dAtA[iNdEx:postIndex]...)
+			if m.EncryptionPasswordToken == nil {
+				m.EncryptionPasswordToken = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -3911,6 +4361,74 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				m.BlocksHash = []byte{}
 			}
 			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Xattrs", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthBep
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Xattrs = append(m.Xattrs, Xattr{})
+			if err := m.Xattrs[len(m.Xattrs)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Encrypted", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBep
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Encrypted = append(m.Encrypted[:0], dAtA[iNdEx:postIndex]...)
+			if m.Encrypted == nil {
+				m.Encrypted = []byte{}
+			}
+			iNdEx = postIndex
 		case 1000:
 			if wireType != 0 {
 				return fmt.Errorf("proto: wrong wireType = %d for field LocalFlags", wireType)
@@ -4132,6 +4650,125 @@ func (m *BlockInfo) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *Xattr) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Xattr: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Xattr: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBep
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthBep
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func (m *Vector) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
@@ -4533,6 +5170,112 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockNo", wireType)
+			}
+			m.BlockNo = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BlockNo |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Requests) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Requests: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Requests: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Requests", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthBep
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Requests = append(m.Requests, Request{})
+			if err := m.Requests[len(m.Requests)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -35,6 +35,7 @@ type FileIntf interface {
 	IsUnsupported() bool
 	MustRescan() bool
 	IsReceiveOnlyChanged() bool
+	IsUnselected() bool
 	IsDirectory() bool
 	IsSymlink() bool
 	ShouldConflict() bool
@@ -92,6 +93,10 @@ func (f FileInfo) IsReceiveOnlyChanged() bool {
 	return f.LocalFlags&FlagLocalReceiveOnly != 0
 }
 
+func (f FileInfo) IsUnselected() bool {
+	return f.LocalFlags&FlagLocalUnselected != 0
+}
+
 func (f FileInfo) IsDirectory() bool {
 	return f.Type == FileInfoTypeDirectory
 }
@@ -197,21 +202,24 @@ func (f FileInfo) IsEmpty() bool {
 }
 
 func (f FileInfo) IsEquivalent(other FileInfo, modTimeWindow time.Duration) bool {
-	return f.isEquivalent(other, modTimeWindow, false, false, 0)
+	return f.isEquivalent(other, modTimeWindow, false, false, false, 0)
 }
 
-func (f FileInfo) IsEquivalentOptional(other FileInfo, modTimeWindow time.Duration, ignorePerms bool, ignoreBlocks bool, ignoreFlags uint32) bool {
-	return f.isEquivalent(other, modTimeWindow, ignorePerms, ignoreBlocks, ignoreFlags)
+func (f FileInfo) IsEquivalentOptional(other FileInfo, modTimeWindow time.Duration, ignorePerms bool, ignoreBlocks bool, ignoreXattrs bool, ignoreFlags uint32) bool {
+	return f.isEquivalent(other, modTimeWindow, ignorePerms, ignoreBlocks, ignoreXattrs, ignoreFlags)
 }
 
 // isEquivalent checks that the two file infos represent the same actual file content,
 // i.e. it does purposely not check only selected (see below) struct members.
-// Permissions (config) and blocks (scanning) can be excluded from the comparison.
+// Permissions (config), blocks (scanning) and extended attributes (config) can
+// be excluded from the comparison.
 // Any file info is not "equivalent", if it has different
 //  - type
 //  - deleted flag
 //  - invalid flag
 //  - permissions, unless they are ignored
+// A file or directory is not "equivalent", if it has different
+//  - extended attributes, unless they are ignored
 // A file is not "equivalent", if it has different
 //  - modification time (difference bigger than modTimeWindow)
 //  - size
@@ -219,7 +227,7 @@ func (f FileInfo) IsEquivalentOptional(other FileInfo, modTimeWindow time.Durati
 // A symlink is not "equivalent", if it has different
 //  - target
 // A directory does not have anything specific to check.
-func (f FileInfo) isEquivalent(other FileInfo, modTimeWindow time.Duration, ignorePerms bool, ignoreBlocks bool, ignoreFlags uint32) bool {
+func (f FileInfo) isEquivalent(other FileInfo, modTimeWindow time.Duration, ignorePerms bool, ignoreBlocks bool, ignoreXattrs bool, ignoreFlags uint32) bool {
 	if f.MustRescan() || other.MustRescan() {
 		// These are per definition not equivalent because they don't
 		// represent a valid state, even if both happen to have the
@@ -239,6 +247,10 @@ func (f FileInfo) isEquivalent(other FileInfo, modTimeWindow time.Duration, igno
 		return false
 	}
 
+	if !ignoreXattrs && f.Type != FileInfoTypeSymlink && !XattrsEqual(f.Xattrs, other.Xattrs) {
+		return false
+	}
+
 	switch f.Type {
 	case FileInfoTypeFile:
 		return f.Size == other.Size && ModTimeEqual(f.ModTime(), other.ModTime(), modTimeWindow) && (ignoreBlocks || f.BlocksEqual(other))
@@ -274,6 +286,20 @@ func PermsEqual(a, b uint32) bool {
 	}
 }
 
+// XattrsEqual returns true when the two lists contain the same extended
+// attributes, in the same order.
+func XattrsEqual(a, b []Xattr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || !bytes.Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
 // BlocksEqual returns true when the two files have identical block lists.
 func (f FileInfo) BlocksEqual(other FileInfo) bool {
 	// If both sides have blocks hashes then we can just compare those.
@@ -313,6 +339,10 @@ func (f *FileInfo) SetUnsupported(by ShortID) {
 	f.setLocalFlags(by, FlagLocalUnsupported)
 }
 
+func (f *FileInfo) SetUnselected(by ShortID) {
+	f.setLocalFlags(by, FlagLocalUnselected)
+}
+
 func (f *FileInfo) SetDeleted(by ShortID) {
 	f.ModifiedBy = by
 	f.Deleted = true
@@ -0,0 +1,56 @@
+// Copyright (C) 2020 The Protocol Authors.
+
+package protocol
+
+import "strings"
+
+// Capabilities is a set of optional protocol features, advertised in the
+// Hello message. A feature is only used when both devices support it, so
+// nothing beyond the base protocol is used with devices that don't
+// advertise any capabilities.
+type Capabilities uint64
+
+const (
+	// The device can hold encrypted data for folders as an untrusted
+	// device.
+	CapabilityEncryption Capabilities = 1 << iota
+	// The device handles batches of requests in a Requests message.
+	CapabilityBatchedRequests
+	// The device keeps several connections to the same device, spreading
+	// requests over them.
+	CapabilityMultipleConnections
+)
+
+var capabilityNames = []struct {
+	capability Capabilities
+	name       string
+}{
+	{CapabilityEncryption, "encryption"},
+	{CapabilityBatchedRequests, "batchedRequests"},
+	{CapabilityMultipleConnections, "multipleConnections"},
+}
+
+// LocalCapabilities returns the capabilities of this implementation.
+func LocalCapabilities() Capabilities {
+	return CapabilityEncryption | CapabilityBatchedRequests | CapabilityMultipleConnections
+}
+
+// Has returns true if all of the given capabilities are in the set.
+func (c Capabilities) Has(other Capabilities) bool {
+	return c&other == other
+}
+
+// Names returns the names of the known capabilities in the set.
+func (c Capabilities) Names() []string {
+	names := []string{}
+	for _, cn := range capabilityNames {
+		if c.Has(cn.capability) {
+			names = append(names, cn.name)
+		}
+	}
+	return names
+}
+
+func (c Capabilities) String() string {
+	return strings.Join(c.Names(), ",")
+}
@@ -2,12 +2,17 @@
 
 package protocol
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type TestModel struct {
 	data          []byte
+	mut           sync.Mutex // protects the request fields below
 	folder        string
 	name          string
+	blockNo       int32
 	offset        int64
 	size          int32
 	hash          []byte
@@ -36,9 +41,12 @@ func (t *TestModel) IndexUpdate(deviceID DeviceID, folder string, files []FileIn
 	return nil
 }
 
-func (t *TestModel) Request(deviceID DeviceID, folder, name string, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (RequestResponse, error) {
+func (t *TestModel) Request(deviceID DeviceID, folder, name string, blockNo, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (RequestResponse, error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
 	t.folder = folder
 	t.name = name
+	t.blockNo = blockNo
 	t.offset = offset
 	t.size = size
 	t.hash = hash
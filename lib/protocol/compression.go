@@ -25,6 +25,24 @@ var compressionUnmarshal = map[string]Compression{
 	"always":   CompressAlways,
 }
 
+// LocalMessageCompressions returns the message compressions we can
+// decompress besides LZ4, to advertise in the Hello message.
+func LocalMessageCompressions() []MessageCompression {
+	return []MessageCompression{MessageCompressionZstd}
+}
+
+// NegotiateMessageCompression returns the message compression to use when
+// sending to a device advertising the given compressions in its Hello.
+// Devices not advertising anything get LZ4, which all devices support.
+func NegotiateMessageCompression(remote []MessageCompression) MessageCompression {
+	for _, mc := range remote {
+		if mc == MessageCompressionZstd {
+			return MessageCompressionZstd
+		}
+	}
+	return MessageCompressionLZ4
+}
+
 func (c Compression) GoString() string {
 	return fmt.Sprintf("%q", c.String())
 }
@@ -0,0 +1,84 @@
+// Copyright (C) 2020 The Protocol Authors.
+
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// Encoders and decoders can be used concurrently. We create an encoder
+	// per compression level as they are needed.
+	zstdEncoders    = make(map[zstd.EncoderLevel]*zstd.Encoder)
+	zstdEncodersMut sync.Mutex
+	zstdDecoder     = newZstdDecoder()
+)
+
+func newZstdDecoder() *zstd.Decoder {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxMessageLen))
+	if err != nil {
+		panic("bug: creating zstd decoder: " + err.Error())
+	}
+	return dec
+}
+
+// zstdEncoder returns the encoder for the given zstd compression level,
+// where zero selects the default.
+func zstdEncoder(level int) (*zstd.Encoder, error) {
+	encLevel := zstd.SpeedDefault
+	if level != 0 {
+		encLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	zstdEncodersMut.Lock()
+	defer zstdEncodersMut.Unlock()
+	if enc, ok := zstdEncoders[encLevel]; ok {
+		return enc, nil
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encLevel))
+	if err != nil {
+		return nil, err
+	}
+	zstdEncoders[encLevel] = enc
+	return enc, nil
+}
+
+// zstdCompress returns the compressed message, prefixed by its uncompressed
+// length like for LZ4. Zero selects the default compression level.
+func zstdCompress(src []byte, level int) ([]byte, error) {
+	enc, err := zstdEncoder(level)
+	if err != nil {
+		return nil, err
+	}
+	// The buffer is grown by the encoder in the rare case that the data
+	// doesn't compress.
+	buf := BufferPool.Get(4 + len(src))
+	buf = enc.EncodeAll(src, buf[:4])
+	binary.BigEndian.PutUint32(buf, uint32(len(src)))
+	return buf, nil
+}
+
+func zstdDecompress(src []byte) ([]byte, error) {
+	if len(src) < 4 {
+		return nil, fmt.Errorf("zstd message too short (%d bytes)", len(src))
+	}
+	size := binary.BigEndian.Uint32(src)
+	if size > MaxMessageLen {
+		return nil, fmt.Errorf("decompressed message length %d exceeds maximum %d", size, MaxMessageLen)
+	}
+	buf := BufferPool.Get(int(size))
+	decoded, err := zstdDecoder.DecodeAll(src[4:], buf[:0])
+	if err != nil {
+		BufferPool.Put(buf)
+		return nil, err
+	}
+	if len(decoded) != int(size) {
+		BufferPool.Put(decoded)
+		return nil, fmt.Errorf("decompressed message length %d, expected %d", len(decoded), size)
+	}
+	return decoded, nil
+}
@@ -60,3 +60,25 @@ func (c *countingWriter) Last() time.Time {
 func TotalInOut() (int64, int64) {
 	return atomic.LoadInt64(&totalIncoming), atomic.LoadInt64(&totalOutgoing)
 }
+
+// compressionCounter counts the size of compressed messages before and
+// after compression.
+type compressionCounter struct {
+	uncompressed int64 // bytes (atomic, must remain 64-bit aligned)
+	compressed   int64 // bytes (atomic, must remain 64-bit aligned)
+}
+
+func (c *compressionCounter) add(uncompressed, compressed int) {
+	atomic.AddInt64(&c.uncompressed, int64(uncompressed))
+	atomic.AddInt64(&c.compressed, int64(compressed))
+}
+
+// Ratio returns the uncompressed size divided by the compressed size, or
+// zero if nothing has been compressed.
+func (c *compressionCounter) Ratio() float64 {
+	compressed := atomic.LoadInt64(&c.compressed)
+	if compressed == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&c.uncompressed)) / float64(compressed)
+}
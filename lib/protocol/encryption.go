@@ -0,0 +1,449 @@
+// Copyright (C) 2020 The Protocol Authors.
+
+package protocol
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Data sent to untrusted devices is encrypted with a key derived from the
+// folder ID and the password set for that device. File names are encrypted
+// deterministically, so that the same name always maps to the same
+// encrypted name. Block data is encrypted with a per file key and a random
+// nonce, making each block blockOverhead bytes larger. The complete
+// FileInfo is encrypted and attached to the FileInfo the untrusted device
+// gets, which otherwise only carries what it needs to store and serve the
+// encrypted data.
+
+const (
+	nonceSize             = chacha20poly1305.NonceSizeX
+	tagSize               = 16 // the Poly1305 tag, chacha20poly1305.Overhead in later versions
+	keySize               = chacha20poly1305.KeySize
+	blockOverhead         = nonceSize + tagSize
+	maxPathComponent      = 200
+	encryptedDirExtension = ".syncthing-enc"
+	encryptedModTime      = 1234567890 // Fixed, as not to leak the real one
+)
+
+var (
+	errDecryption         = errors.New("decryption failed")
+	errEncryptedName      = errors.New("invalid encrypted name")
+	errMismatchedNames    = errors.New("encrypted name doesn't match the encrypted metadata")
+	errMismatchedVersions = errors.New("version doesn't match the encrypted metadata")
+
+	base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+)
+
+var folderKeyCache = struct {
+	sync.Mutex
+	keys map[[sha256.Size]byte]*[keySize]byte
+}{keys: make(map[[sha256.Size]byte]*[keySize]byte)}
+
+// keyFromPassword returns the key for the given folder and password. The
+// derivation is expensive by design, so keys are cached, by a hash of the
+// folder and password rather than the password itself. The derivation runs
+// without holding the cache lock, so that deriving one key doesn't hold up
+// the others.
+func keyFromPassword(folderID, password string) *[keySize]byte {
+	cacheKey := sha256.Sum256([]byte(folderID + "\x00" + password))
+	folderKeyCache.Lock()
+	key, ok := folderKeyCache.keys[cacheKey]
+	folderKeyCache.Unlock()
+	if ok {
+		return key
+	}
+
+	bs, err := scrypt.Key([]byte(password), knownBytes(folderID), 32768, 8, 1, keySize)
+	if err != nil {
+		panic("key derivation failure: " + err.Error())
+	}
+	key = new([keySize]byte)
+	copy(key[:], bs)
+
+	folderKeyCache.Lock()
+	folderKeyCache.keys[cacheKey] = key
+	folderKeyCache.Unlock()
+	return key
+}
+
+func knownBytes(folderID string) []byte {
+	return []byte("syncthing" + folderID)
+}
+
+// PasswordToken returns a token proving knowledge of the folder password,
+// without revealing the password itself.
+func PasswordToken(folderID, password string) []byte {
+	return encryptDeterministic(knownBytes(folderID), keyFromPassword(folderID, password))
+}
+
+// fileKey returns the key used for the block data of the given file.
+func fileKey(name string, folderKey *[keySize]byte) *[keySize]byte {
+	kdf := hkdf.New(sha256.New, append(folderKey[:], name...), []byte("syncthing"), nil)
+	var key [keySize]byte
+	if _, err := io.ReadFull(kdf, key[:]); err != nil {
+		panic("key derivation failure: " + err.Error())
+	}
+	return &key
+}
+
+// encryptBytes encrypts the data with a random nonce, which is prepended to
+// the result.
+func encryptBytes(data []byte, key *[keySize]byte) []byte {
+	nonce := make([]byte, nonceSize, nonceSize+len(data)+tagSize)
+	if _, err := rand.Read(nonce); err != nil {
+		panic("random failure: " + err.Error())
+	}
+	return encrypt(data, nonce, key)
+}
+
+// encryptDeterministic encrypts the data with a nonce derived from the data
+// itself, such that the same data always gives the same result.
+func encryptDeterministic(data []byte, key *[keySize]byte) []byte {
+	nonce := make([]byte, nonceSize, nonceSize+len(data)+tagSize)
+	copy(nonce, deterministicNonce(data, key))
+	return encrypt(data, nonce, key)
+}
+
+func deterministicNonce(data []byte, key *[keySize]byte) []byte {
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(data)
+	return mac.Sum(nil)[:nonceSize]
+}
+
+func encrypt(data, nonce []byte, key *[keySize]byte) []byte {
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	return aead.Seal(nonce, nonce, data, nil)
+}
+
+func decryptBytes(data []byte, key *[keySize]byte) ([]byte, error) {
+	if len(data) < blockOverhead {
+		return nil, errDecryption
+	}
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	dec, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, errDecryption
+	}
+	return dec, nil
+}
+
+func decryptDeterministic(data []byte, key *[keySize]byte) ([]byte, error) {
+	dec, err := decryptBytes(data, key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(data[:nonceSize], deterministicNonce(dec, key)) {
+		return nil, errDecryption
+	}
+	return dec, nil
+}
+
+// encryptName returns the encrypted file name, split into path components
+// short enough for any filesystem.
+func encryptName(name string, folderKey *[keySize]byte) string {
+	return slashify(base32Hex.EncodeToString(encryptDeterministic([]byte(name), folderKey)))
+}
+
+func decryptName(name string, folderKey *[keySize]byte) (string, error) {
+	name, err := deslashify(name)
+	if err != nil {
+		return "", err
+	}
+	bs, err := base32Hex.DecodeString(name)
+	if err != nil {
+		return "", errEncryptedName
+	}
+	dec, err := decryptDeterministic(bs, folderKey)
+	if err != nil {
+		return "", err
+	}
+	return string(dec), nil
+}
+
+// slashify turns "ABCDEF..." into "A.syncthing-enc/BC/DEF...", with the last
+// part split into components of at most maxPathComponent characters. The
+// input is always base32, i.e. one byte per character and long enough.
+func slashify(s string) string {
+	comps := make([]string, 0, len(s)/maxPathComponent+3)
+	comps = append(comps, s[:1]+encryptedDirExtension, s[1:3])
+	s = s[3:]
+	for len(s) > maxPathComponent {
+		comps = append(comps, s[:maxPathComponent])
+		s = s[maxPathComponent:]
+	}
+	if len(s) > 0 {
+		comps = append(comps, s)
+	}
+	return strings.Join(comps, "/")
+}
+
+func deslashify(s string) (string, error) {
+	if len(s) < len(encryptedDirExtension)+5 || s[1:1+len(encryptedDirExtension)+1] != encryptedDirExtension+"/" {
+		return "", errEncryptedName
+	}
+	return s[:1] + strings.Replace(s[len(encryptedDirExtension)+2:], "/", "", -1), nil
+}
+
+// encryptedBlockHash returns what stands in for the block hash in the
+// encrypted FileInfo: it's unique per block, but reveals nothing about the
+// data.
+func encryptedBlockHash(hash []byte, fileKey *[keySize]byte) []byte {
+	mac := hmac.New(sha256.New, fileKey[:])
+	mac.Write(hash)
+	return mac.Sum(nil)
+}
+
+// encryptFileInfo returns the FileInfo sent to untrusted devices. Everything
+// but the version, sequence and deletion state is replaced by fixed values or
+// those matching the encrypted data.
+func encryptFileInfo(fi FileInfo, folderKey *[keySize]byte) FileInfo {
+	plain := fi
+	plain.LocalFlags = 0
+	plain.VersionHash = nil
+	bs, err := plain.Marshal()
+	if err != nil {
+		panic("impossible serialization mishap: " + err.Error())
+	}
+
+	// Directories stay directories, anything else without data is as
+	// good as one.
+	typ := FileInfoTypeDirectory
+	if fi.Type == FileInfoTypeFile {
+		typ = FileInfoTypeFile
+	}
+
+	enc := FileInfo{
+		Name:          encryptName(fi.Name, folderKey),
+		Type:          typ,
+		ModifiedS:     encryptedModTime,
+		Permissions:   0644,
+		NoPermissions: true,
+		Version:       fi.Version,
+		Sequence:      fi.Sequence,
+		Deleted:       fi.Deleted,
+		RawInvalid:    fi.IsInvalid(),
+		Encrypted:     encryptBytes(bs, folderKey),
+	}
+	if typ != FileInfoTypeFile || fi.Deleted {
+		return enc
+	}
+
+	key := fileKey(fi.Name, folderKey)
+	enc.Blocks = make([]BlockInfo, len(fi.Blocks))
+	for i, b := range fi.Blocks {
+		size := b.Size + blockOverhead
+		enc.Blocks[i] = BlockInfo{
+			Offset: enc.Size,
+			Size:   size,
+			Hash:   encryptedBlockHash(b.Hash, key),
+		}
+		enc.Size += int64(size)
+	}
+	enc.RawBlockSize = int32(fi.BlockSize() + blockOverhead)
+	return enc
+}
+
+// decryptFileInfo returns the FileInfo encrypted by encryptFileInfo, with
+// the sequence and local bookkeeping of the untrusted device's copy.
+func decryptFileInfo(fi FileInfo, folderKey *[keySize]byte) (FileInfo, error) {
+	bs, err := decryptBytes(fi.Encrypted, folderKey)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	var dec FileInfo
+	if err := dec.Unmarshal(bs); err != nil {
+		return FileInfo{}, err
+	}
+	// Make sure the metadata wasn't moved from another file.
+	if encryptName(dec.Name, folderKey) != fi.Name {
+		return FileInfo{}, errMismatchedNames
+	}
+	// The version in the clear must be the one that was encrypted, as the
+	// untrusted device could otherwise make old data win over new.
+	if !dec.Version.Equal(fi.Version) {
+		return FileInfo{}, errMismatchedVersions
+	}
+	dec.Sequence = fi.Sequence
+	dec.LocalFlags = fi.LocalFlags
+	dec.VersionHash = fi.VersionHash
+	return dec, nil
+}
+
+func folderKeys(passwords map[string]string) map[string]*[keySize]byte {
+	keys := make(map[string]*[keySize]byte, len(passwords))
+	for folder, password := range passwords {
+		if password != "" {
+			keys[folder] = keyFromPassword(folder, password)
+		}
+	}
+	return keys
+}
+
+// encryptedConnection encrypts what we send to an untrusted device in the
+// folders we have a password for, and decrypts the data it returns.
+type encryptedConnection struct {
+	Connection
+	folderKeys map[string]*[keySize]byte
+}
+
+func (e encryptedConnection) Index(ctx context.Context, folder string, files []FileInfo) error {
+	if folderKey, ok := e.folderKeys[folder]; ok {
+		files = encryptFileInfos(files, folderKey)
+	}
+	return e.Connection.Index(ctx, folder, files)
+}
+
+func (e encryptedConnection) IndexUpdate(ctx context.Context, folder string, files []FileInfo) error {
+	if folderKey, ok := e.folderKeys[folder]; ok {
+		files = encryptFileInfos(files, folderKey)
+	}
+	return e.Connection.IndexUpdate(ctx, folder, files)
+}
+
+func (e encryptedConnection) Request(ctx context.Context, folder string, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
+	folderKey, ok := e.folderKeys[folder]
+	if !ok {
+		return e.Connection.Request(ctx, folder, name, blockNo, offset, size, hash, weakHash, fromTemporary)
+	}
+
+	key := fileKey(name, folderKey)
+	encName := encryptName(name, folderKey)
+	encOffset := offset + int64(blockNo)*blockOverhead
+	encSize := size + blockOverhead
+	bs, err := e.Connection.Request(ctx, folder, encName, blockNo, encOffset, encSize, encryptedBlockHash(hash, key), 0, fromTemporary)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) != encSize {
+		return nil, fmt.Errorf("response size %d, expected %d", len(bs), encSize)
+	}
+	return decryptBytes(bs, key)
+}
+
+func (e encryptedConnection) DownloadProgress(ctx context.Context, folder string, updates []FileDownloadProgressUpdate) {
+	if _, ok := e.folderKeys[folder]; ok {
+		// The progress updates would leak the names, and the untrusted
+		// device can't make use of our temporary files anyway.
+		return
+	}
+	e.Connection.DownloadProgress(ctx, folder, updates)
+}
+
+func encryptFileInfos(files []FileInfo, folderKey *[keySize]byte) []FileInfo {
+	encs := make([]FileInfo, len(files))
+	for i, fi := range files {
+		encs[i] = encryptFileInfo(fi, folderKey)
+	}
+	return encs
+}
+
+// encryptedModel decrypts what an untrusted device sends us in the folders
+// we have a password for, and encrypts the data we return to it.
+type encryptedModel struct {
+	Model
+	folderKeys map[string]*[keySize]byte
+}
+
+func (e encryptedModel) Index(deviceID DeviceID, folder string, files []FileInfo) error {
+	if folderKey, ok := e.folderKeys[folder]; ok {
+		var err error
+		if files, err = decryptFileInfos(files, folderKey); err != nil {
+			return err
+		}
+	}
+	return e.Model.Index(deviceID, folder, files)
+}
+
+func (e encryptedModel) IndexUpdate(deviceID DeviceID, folder string, files []FileInfo) error {
+	if folderKey, ok := e.folderKeys[folder]; ok {
+		var err error
+		if files, err = decryptFileInfos(files, folderKey); err != nil {
+			return err
+		}
+	}
+	return e.Model.IndexUpdate(deviceID, folder, files)
+}
+
+func (e encryptedModel) Request(deviceID DeviceID, folder, name string, blockNo, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (RequestResponse, error) {
+	folderKey, ok := e.folderKeys[folder]
+	if !ok {
+		return e.Model.Request(deviceID, folder, name, blockNo, size, offset, hash, weakHash, fromTemporary)
+	}
+
+	realName, err := decryptName(name, folderKey)
+	if err != nil {
+		return nil, ErrNoSuchFile
+	}
+	realSize := size - blockOverhead
+	realOffset := offset - int64(blockNo)*blockOverhead
+	if realSize < 0 || realOffset < 0 {
+		return nil, ErrInvalid
+	}
+
+	// The hash is of the encrypted data, so we have nothing to verify
+	// against.
+	res, err := e.Model.Request(deviceID, folder, realName, blockNo, realSize, realOffset, nil, 0, fromTemporary)
+	if err != nil {
+		return nil, err
+	}
+	enc := encryptBytes(res.Data(), fileKey(realName, folderKey))
+	res.Close()
+	return rawResponse{enc}, nil
+}
+
+func (e encryptedModel) DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate) error {
+	if _, ok := e.folderKeys[folder]; ok {
+		// The names are encrypted and we don't pull from temporary files
+		// on untrusted devices.
+		return nil
+	}
+	return e.Model.DownloadProgress(deviceID, folder, updates)
+}
+
+// decryptFileInfos returns the decrypted files, skipping those the
+// untrusted device added itself, which have no encrypted metadata.
+func decryptFileInfos(files []FileInfo, folderKey *[keySize]byte) ([]FileInfo, error) {
+	decs := make([]FileInfo, 0, len(files))
+	for _, fi := range files {
+		if len(fi.Encrypted) == 0 {
+			continue
+		}
+		dec, err := decryptFileInfo(fi, folderKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting %v: %w", fi.Name, err)
+		}
+		decs = append(decs, dec)
+	}
+	return decs, nil
+}
+
+type rawResponse struct {
+	data []byte
+}
+
+func (r rawResponse) Data() []byte {
+	return r.data
+}
+
+func (r rawResponse) Close() {}
+
+func (r rawResponse) Wait() {}
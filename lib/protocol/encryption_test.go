@@ -0,0 +1,198 @@
+// Copyright (C) 2020 The Protocol Authors.
+
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEnDecryptName(t *testing.T) {
+	key := keyFromPassword("folder", "password")
+	names := []string{
+		"a",
+		"a/b/c.txt",
+		strings.Repeat("long/", 200) + "name",
+	}
+	for _, name := range names {
+		enc := encryptName(name, key)
+		if enc != encryptName(name, key) {
+			t.Errorf("encrypting %q isn't deterministic", name)
+		}
+		if strings.Contains(enc, name) {
+			t.Errorf("encrypted name %q contains plaintext", enc)
+		}
+		for _, comp := range strings.Split(enc, "/") {
+			if len(comp) > maxPathComponent {
+				t.Errorf("path component of %q is %d long", name, len(comp))
+			}
+		}
+		dec, err := decryptName(enc, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dec != name {
+			t.Errorf("%q decrypted to %q", name, dec)
+		}
+	}
+
+	enc := encryptName("a", key)
+	if _, err := decryptName(enc, keyFromPassword("folder", "other")); err == nil {
+		t.Error("decrypting with the wrong key should fail")
+	}
+	if _, err := decryptName(enc[:len(enc)-1]+"0", key); err == nil {
+		t.Error("decrypting a modified name should fail")
+	}
+	if _, err := decryptName("a/b", key); err == nil {
+		t.Error("decrypting a plaintext name should fail")
+	}
+}
+
+func TestEnDecryptBytes(t *testing.T) {
+	key := keyFromPassword("folder", "password")
+	data := []byte("hello, world")
+	enc := encryptBytes(data, key)
+	if len(enc) != len(data)+blockOverhead {
+		t.Errorf("encrypted length %d, expected %d", len(enc), len(data)+blockOverhead)
+	}
+	if bytes.Equal(enc, encryptBytes(data, key)) {
+		t.Error("encrypting twice gave the same result")
+	}
+	dec, err := decryptBytes(enc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, data) {
+		t.Errorf("decrypted to %q", dec)
+	}
+	enc[len(enc)-1]++
+	if _, err := decryptBytes(enc, key); err == nil {
+		t.Error("decrypting modified data should fail")
+	}
+}
+
+func TestEnDecryptFileInfo(t *testing.T) {
+	key := keyFromPassword("folder", "password")
+	fi := FileInfo{
+		Name:         "dir/file.txt",
+		Size:         200,
+		ModifiedS:    1598000000,
+		Permissions:  0755,
+		Version:      Vector{}.Update(1),
+		Sequence:     42,
+		RawBlockSize: 128,
+		Blocks: []BlockInfo{
+			{Offset: 0, Size: 128, Hash: []byte("hash1")},
+			{Offset: 128, Size: 72, Hash: []byte("hash2")},
+		},
+	}
+
+	enc := encryptFileInfo(fi, key)
+	if enc.Name == fi.Name || enc.ModifiedS == fi.ModifiedS || !enc.NoPermissions {
+		t.Errorf("metadata not hidden: %v", enc)
+	}
+	if !enc.Version.Equal(fi.Version) || enc.Sequence != fi.Sequence {
+		t.Errorf("version and sequence should be kept: %v", enc)
+	}
+	if enc.Size != fi.Size+2*blockOverhead || enc.BlockSize() != 128+blockOverhead {
+		t.Errorf("unexpected size %d or block size %d", enc.Size, enc.BlockSize())
+	}
+	if len(enc.Blocks) != 2 || enc.Blocks[1].Offset != 128+blockOverhead || enc.Blocks[1].Size != 72+blockOverhead {
+		t.Errorf("unexpected blocks %v", enc.Blocks)
+	}
+	if bytes.Equal(enc.Blocks[0].Hash, fi.Blocks[0].Hash) {
+		t.Error("block hash not hidden")
+	}
+
+	// The untrusted device has its own sequence.
+	enc.Sequence = 7
+	dec, err := decryptFileInfo(enc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Sequence != 7 {
+		t.Errorf("expected sequence 7, got %d", dec.Sequence)
+	}
+	dec.Sequence = fi.Sequence
+	if !dec.IsEquivalent(fi, 0) || dec.Size != fi.Size || !dec.BlocksEqual(fi) {
+		t.Errorf("decrypted %v, expected %v", dec, fi)
+	}
+
+	// A version that doesn't match the metadata is rejected.
+	bumped := enc
+	bumped.Version = enc.Version.Update(2)
+	if _, err := decryptFileInfo(bumped, key); err != errMismatchedVersions {
+		t.Errorf("expected %v for a changed version, got %v", errMismatchedVersions, err)
+	}
+
+	// Metadata moved to another file is rejected.
+	other := encryptFileInfo(FileInfo{Name: "other", Type: FileInfoTypeDirectory}, key)
+	other.Encrypted = enc.Encrypted
+	if _, err := decryptFileInfo(other, key); err == nil {
+		t.Error("decrypting swapped metadata should fail")
+	}
+}
+
+func TestEncryptedRequests(t *testing.T) {
+	passwords := map[string]string{"folder": "password"}
+	key := keyFromPassword("folder", "password")
+
+	trusted := newTestModel()
+	untrusted := newTestModel()
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+	c0 := NewConnection(c0ID, ar, bw, trusted, "trusted", CompressNever, MessageCompressionLZ4, 0, passwords)
+	c0.Start()
+	c1 := NewConnection(c1ID, br, aw, untrusted, "untrusted", CompressNever, MessageCompressionLZ4, 0, nil)
+	c1.Start()
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+	defer c0.Close(errManual)
+	defer c1.Close(errManual)
+
+	// Pulling from the untrusted device, which only has encrypted data.
+	data := []byte("some data")
+	untrusted.data = encryptBytes(data, fileKey("file", key))
+	res, err := c0.Request(context.Background(), "folder", "file", 2, 2*128, len(data), []byte("hash"), 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res, data) {
+		t.Errorf("got %q, expected %q", res, data)
+	}
+	if name, _ := decryptName(untrusted.name, key); name != "file" {
+		t.Errorf("untrusted device got request for %q", untrusted.name)
+	}
+	if untrusted.blockNo != 2 || untrusted.offset != 2*(128+blockOverhead) || int(untrusted.size) != len(data)+blockOverhead || untrusted.weakHash != 0 {
+		t.Errorf("untrusted device got request for block %d at %d, size %d", untrusted.blockNo, untrusted.offset, untrusted.size)
+	}
+
+	// The untrusted device pulling from us.
+	trusted.data = data
+	encName := encryptName("file", key)
+	res, err = c1.Request(context.Background(), "folder", encName, 2, 2*(128+blockOverhead), len(data)+blockOverhead, []byte("hash"), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trusted.name != "file" || trusted.offset != 2*128 || int(trusted.size) != len(data) || trusted.hash != nil {
+		t.Errorf("trusted device got request for %q at %d, size %d", trusted.name, trusted.offset, trusted.size)
+	}
+	dec, err := decryptBytes(res, fileKey("file", key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, data) {
+		t.Errorf("got %q, expected %q", dec, data)
+	}
+
+	// Unencrypted folders are unaffected.
+	if _, err := c0.Request(context.Background(), "other", "file", 2, 2*128, len(data), nil, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if untrusted.name != "file" || untrusted.offset != 2*128 {
+		t.Errorf("untrusted device got request for %q at %d", untrusted.name, untrusted.offset)
+	}
+}
@@ -105,3 +105,37 @@ func (rw *readWriter) Write(data []byte) (int, error) {
 func (rw *readWriter) Read(data []byte) (int, error) {
 	return rw.r.Read(data)
 }
+
+func TestHelloCapabilities(t *testing.T) {
+	caps := CapabilityEncryption | CapabilityBatchedRequests | 1<<40
+	for _, sent := range []Capabilities{0, caps} {
+		msgBuf, err := (&Hello{DeviceName: "test device", Capabilities: sent}).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdrBuf := make([]byte, 6)
+		binary.BigEndian.PutUint32(hdrBuf, HelloMessageMagic)
+		binary.BigEndian.PutUint16(hdrBuf[4:], uint16(len(msgBuf)))
+
+		outBuf := new(bytes.Buffer)
+		outBuf.Write(hdrBuf)
+		outBuf.Write(msgBuf)
+		conn := &readWriter{outBuf, new(bytes.Buffer)}
+
+		res, err := ExchangeHello(conn, &Hello{Capabilities: LocalCapabilities()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Capabilities != sent {
+			t.Errorf("got capabilities %v, expected %v", res.Capabilities, sent)
+		}
+	}
+
+	// Unknown capabilities are kept, but not named.
+	if !caps.Has(CapabilityEncryption) || Capabilities(0).Has(CapabilityEncryption) {
+		t.Error("unexpected Has result")
+	}
+	if names := caps.Names(); len(names) != 2 || names[0] != "encryption" || names[1] != "batchedRequests" {
+		t.Errorf("unexpected names %v", names)
+	}
+}
@@ -86,16 +86,17 @@ const (
 	FlagLocalIgnored     = 1 << 1 // Matches local ignore patterns
 	FlagLocalMustRescan  = 1 << 2 // Doesn't match content on disk, must be rechecked fully
 	FlagLocalReceiveOnly = 1 << 3 // Change detected on receive only folder
+	FlagLocalUnselected  = 1 << 4 // Removed locally as it's not selected for selective sync
 
 	// Flags that should result in the Invalid bit on outgoing updates
-	LocalInvalidFlags = FlagLocalUnsupported | FlagLocalIgnored | FlagLocalMustRescan | FlagLocalReceiveOnly
+	LocalInvalidFlags = FlagLocalUnsupported | FlagLocalIgnored | FlagLocalMustRescan | FlagLocalReceiveOnly | FlagLocalUnselected
 
 	// Flags that should result in a file being in conflict with its
 	// successor, due to us not having an up to date picture of its state on
 	// disk.
-	LocalConflictFlags = FlagLocalUnsupported | FlagLocalIgnored | FlagLocalReceiveOnly
+	LocalConflictFlags = FlagLocalUnsupported | FlagLocalIgnored | FlagLocalReceiveOnly | FlagLocalUnselected
 
-	LocalAllFlags = FlagLocalUnsupported | FlagLocalIgnored | FlagLocalMustRescan | FlagLocalReceiveOnly
+	LocalAllFlags = FlagLocalUnsupported | FlagLocalIgnored | FlagLocalMustRescan | FlagLocalReceiveOnly | FlagLocalUnselected
 )
 
 var (
@@ -115,7 +116,7 @@ type Model interface {
 	// An index update was received from the peer device
 	IndexUpdate(deviceID DeviceID, folder string, files []FileInfo) error
 	// A request was made by the peer device
-	Request(deviceID DeviceID, folder, name string, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (RequestResponse, error)
+	Request(deviceID DeviceID, folder, name string, blockNo, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (RequestResponse, error)
 	// A cluster configuration message was received
 	ClusterConfig(deviceID DeviceID, config ClusterConfig) error
 	// The peer device closed the connection
@@ -137,11 +138,17 @@ type Connection interface {
 	Name() string
 	Index(ctx context.Context, folder string, files []FileInfo) error
 	IndexUpdate(ctx context.Context, folder string, files []FileInfo) error
-	Request(ctx context.Context, folder string, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error)
+	Request(ctx context.Context, folder string, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error)
 	ClusterConfig(config ClusterConfig)
 	DownloadProgress(ctx context.Context, folder string, updates []FileDownloadProgressUpdate)
 	Statistics() Statistics
 	Closed() bool
+	// Capabilities returns the capabilities supported by both us and the
+	// other device.
+	Capabilities() Capabilities
+	// SetCapabilities sets the capabilities supported by both us and the
+	// other device. It must be called before Start.
+	SetCapabilities(caps Capabilities)
 }
 
 type rawConnection struct {
@@ -153,6 +160,9 @@ type rawConnection struct {
 	cr *countingReader
 	cw *countingWriter
 
+	inCompression  *compressionCounter
+	outCompression *compressionCounter
+
 	awaiting    map[int32]chan asyncResult
 	awaitingMut sync.Mutex
 
@@ -161,6 +171,10 @@ type rawConnection struct {
 	nextID    int32
 	nextIDMut sync.Mutex
 
+	requestBatch      []Request
+	requestBatchMut   sync.Mutex
+	requestBatchReady chan struct{}
+
 	inbox                 chan message
 	outbox                chan asyncMessage
 	closeBox              chan asyncMessage
@@ -170,6 +184,9 @@ type rawConnection struct {
 	closeOnce             sync.Once
 	sendCloseOnce         sync.Once
 	compression           Compression
+	msgCompression        MessageCompression
+	compressionLevel      int
+	capabilities          Capabilities
 }
 
 type asyncResult struct {
@@ -198,22 +215,41 @@ const (
 	ReceiveTimeout = 300 * time.Second
 )
 
+const (
+	// maxBatchedRequests is the largest number of requests sent in a
+	// single Requests message.
+	maxBatchedRequests = 1000
+)
+
 // CloseTimeout is the longest we'll wait when trying to send the close
 // message before just closing the connection.
 // Should not be modified in production code, just for testing.
 var CloseTimeout = 10 * time.Second
 
-func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiver Model, name string, compress Compression) Connection {
+// NewConnection returns a connection to the given device. Compressed
+// messages are sent using msgCompression, at the given level where
+// applicable. Data in the folders with a password in passwords is encrypted
+// for, and decrypted from, the device.
+func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiver Model, name string, compress Compression, msgCompression MessageCompression, compressionLevel int, passwords map[string]string) Connection {
 	cr := &countingReader{Reader: reader}
 	cw := &countingWriter{Writer: writer}
 
+	receiver = nativeModel{receiver}
+	keys := folderKeys(passwords)
+	if len(keys) > 0 {
+		receiver = encryptedModel{receiver, keys}
+	}
+
 	c := rawConnection{
 		id:                    deviceID,
 		name:                  name,
-		receiver:              nativeModel{receiver},
+		receiver:              receiver,
 		cr:                    cr,
 		cw:                    cw,
+		inCompression:         &compressionCounter{},
+		outCompression:        &compressionCounter{},
 		awaiting:              make(map[int32]chan asyncResult),
+		requestBatchReady:     make(chan struct{}, 1),
 		inbox:                 make(chan message),
 		outbox:                make(chan asyncMessage),
 		closeBox:              make(chan asyncMessage),
@@ -221,8 +257,13 @@ func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiv
 		dispatcherLoopStopped: make(chan struct{}),
 		closed:                make(chan struct{}),
 		compression:           compress,
+		msgCompression:        msgCompression,
+		compressionLevel:      compressionLevel,
 	}
 
+	if len(keys) > 0 {
+		return wireFormatConnection{encryptedConnection{&c, keys}}
+	}
 	return wireFormatConnection{&c}
 }
 
@@ -248,6 +289,14 @@ func (c *rawConnection) Name() string {
 	return c.name
 }
 
+func (c *rawConnection) Capabilities() Capabilities {
+	return c.capabilities
+}
+
+func (c *rawConnection) SetCapabilities(caps Capabilities) {
+	c.capabilities = caps
+}
+
 // Index writes the list of file information to the connected peer device
 func (c *rawConnection) Index(ctx context.Context, folder string, idx []FileInfo) error {
 	select {
@@ -281,7 +330,7 @@ func (c *rawConnection) IndexUpdate(ctx context.Context, folder string, idx []Fi
 }
 
 // Request returns the bytes for the specified block after fetching them from the connected peer.
-func (c *rawConnection) Request(ctx context.Context, folder string, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
+func (c *rawConnection) Request(ctx context.Context, folder string, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
 	c.nextIDMut.Lock()
 	id := c.nextID
 	c.nextID++
@@ -296,16 +345,23 @@ func (c *rawConnection) Request(ctx context.Context, folder string, name string,
 	c.awaiting[id] = rc
 	c.awaitingMut.Unlock()
 
-	ok := c.send(ctx, &Request{
+	req := Request{
 		ID:            id,
 		Folder:        folder,
 		Name:          name,
 		Offset:        offset,
 		Size:          int32(size),
+		BlockNo:       int32(blockNo),
 		Hash:          hash,
 		WeakHash:      weakHash,
 		FromTemporary: fromTemporary,
-	}, nil)
+	}
+	var ok bool
+	if c.capabilities.Has(CapabilityBatchedRequests) {
+		ok = c.queueRequest(req)
+	} else {
+		ok = c.send(ctx, &req, nil)
+	}
 	if !ok {
 		return nil, ErrClosed
 	}
@@ -321,6 +377,49 @@ func (c *rawConnection) Request(ctx context.Context, folder string, name string,
 	}
 }
 
+// queueRequest adds the request to the batch sent by the writerLoop. The
+// batch is sent as soon as the writerLoop is free, so requests are only
+// delayed, and batched, while it's busy writing other messages.
+func (c *rawConnection) queueRequest(req Request) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+
+	c.requestBatchMut.Lock()
+	c.requestBatch = append(c.requestBatch, req)
+	c.requestBatchMut.Unlock()
+
+	c.requestBatchIsReady()
+	return true
+}
+
+func (c *rawConnection) requestBatchIsReady() {
+	select {
+	case c.requestBatchReady <- struct{}{}:
+	default:
+	}
+}
+
+// takeRequestBatch returns up to maxBatchedRequests of the queued requests.
+func (c *rawConnection) takeRequestBatch() []Request {
+	c.requestBatchMut.Lock()
+	defer c.requestBatchMut.Unlock()
+
+	batch := c.requestBatch
+	if len(batch) > maxBatchedRequests {
+		batch = batch[:maxBatchedRequests:maxBatchedRequests]
+		c.requestBatch = append([]Request(nil), c.requestBatch[maxBatchedRequests:]...)
+		// The remaining requests are sent right away, as they have waited
+		// as long as the others.
+		c.requestBatchIsReady()
+	} else {
+		c.requestBatch = nil
+	}
+	return batch
+}
+
 // ClusterConfig sends the cluster configuration message to the peer.
 // It must be called just once (as per BEP), otherwise it will panic.
 func (c *rawConnection) ClusterConfig(config ClusterConfig) {
@@ -430,6 +529,18 @@ func (c *rawConnection) dispatcherLoop() (err error) {
 			}
 			go c.handleRequest(*msg)
 
+		case *Requests:
+			l.Debugln("read Requests message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: requests message in state %d", state)
+			}
+			for _, req := range msg.Requests {
+				if err := checkFilename(req.Name); err != nil {
+					return errors.Wrapf(err, "protocol error: requests: %q", req.Name)
+				}
+			}
+			go c.handleRequests(msg.Requests)
+
 		case *Response:
 			l.Debugln("read Response message")
 			if state != stateReady {
@@ -505,6 +616,16 @@ func (c *rawConnection) readMessageAfterHeader(hdr Header, fourByteBuf []byte) (
 		if err != nil {
 			return nil, errors.Wrap(err, "decompressing message")
 		}
+		c.inCompression.add(len(decomp), int(msgLen))
+		buf = decomp
+
+	case MessageCompressionZstd:
+		decomp, err := zstdDecompress(buf)
+		BufferPool.Put(buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "decompressing message")
+		}
+		c.inCompression.add(len(decomp), int(msgLen))
 		buf = decomp
 
 	default:
@@ -625,7 +746,7 @@ func checkFilename(name string) error {
 }
 
 func (c *rawConnection) handleRequest(req Request) {
-	res, err := c.receiver.Request(c.id, req.Folder, req.Name, req.Size, req.Offset, req.Hash, req.WeakHash, req.FromTemporary)
+	res, err := c.receiver.Request(c.id, req.Folder, req.Name, req.BlockNo, req.Size, req.Offset, req.Hash, req.WeakHash, req.FromTemporary)
 	if err != nil {
 		c.send(context.Background(), &Response{
 			ID:   req.ID,
@@ -643,6 +764,14 @@ func (c *rawConnection) handleRequest(req Request) {
 	res.Close()
 }
 
+// handleRequests serves a batch of requests concurrently, like separately
+// received ones, sending a Response for each.
+func (c *rawConnection) handleRequests(reqs []Request) {
+	for _, req := range reqs {
+		go c.handleRequest(req)
+	}
+}
+
 func (c *rawConnection) handleResponse(resp Response) {
 	c.awaitingMut.Lock()
 	if rc := c.awaiting[resp.ID]; rc != nil {
@@ -693,6 +822,16 @@ func (c *rawConnection) writerLoop() {
 				return
 			}
 
+		case <-c.requestBatchReady:
+			batch := c.takeRequestBatch()
+			if len(batch) == 0 {
+				continue
+			}
+			if err := c.writeMessage(&Requests{Requests: batch}); err != nil {
+				c.internalClose(err)
+				return
+			}
+
 		case hm := <-c.closeBox:
 			_ = c.writeMessage(hm.msg)
 			close(hm.done)
@@ -718,14 +857,24 @@ func (c *rawConnection) writeCompressedMessage(msg message) error {
 		return errors.Wrap(err, "marshalling message")
 	}
 
-	compressed, err := c.lz4Compress(buf)
+	var compressed []byte
+	var err error
+	switch c.msgCompression {
+	case MessageCompressionZstd:
+		compressed, err = zstdCompress(buf, c.compressionLevel)
+	case MessageCompressionLZ4:
+		compressed, err = c.lz4Compress(buf)
+	default:
+		panic("unknown message compression")
+	}
 	if err != nil {
 		return errors.Wrap(err, "compressing message")
 	}
+	c.outCompression.add(size, len(compressed))
 
 	hdr := Header{
 		Type:        c.typeOf(msg),
-		Compression: MessageCompressionLZ4,
+		Compression: c.msgCompression,
 	}
 	hdrSize := hdr.ProtoSize()
 	if hdrSize > 1<<16-1 {
@@ -804,6 +953,8 @@ func (c *rawConnection) typeOf(msg message) MessageType {
 		return messageTypeIndexUpdate
 	case *Request:
 		return messageTypeRequest
+	case *Requests:
+		return messageTypeRequests
 	case *Response:
 		return messageTypeResponse
 	case *DownloadProgress:
@@ -827,6 +978,8 @@ func (c *rawConnection) newMessage(t MessageType) (message, error) {
 		return new(IndexUpdate), nil
 	case messageTypeRequest:
 		return new(Request), nil
+	case messageTypeRequests:
+		return new(Requests), nil
 	case messageTypeResponse:
 		return new(Response), nil
 	case messageTypeDownloadProgress:
@@ -962,14 +1115,27 @@ type Statistics struct {
 	InBytesTotal  int64
 	OutBytesTotal int64
 	StartedAt     time.Time
+	// The compression used for sending, and the ratios of uncompressed to
+	// compressed size achieved for the compressed messages, or zero if
+	// none have been compressed.
+	Compression         MessageCompression
+	InCompressionRatio  float64
+	OutCompressionRatio float64
 }
 
 func (c *rawConnection) Statistics() Statistics {
+	compression := c.msgCompression
+	if c.compression == CompressNever {
+		compression = MessageCompressionNone
+	}
 	return Statistics{
-		At:            time.Now(),
-		InBytesTotal:  c.cr.Tot(),
-		OutBytesTotal: c.cw.Tot(),
-		StartedAt:     c.startTime,
+		At:                  time.Now(),
+		InBytesTotal:        c.cr.Tot(),
+		OutBytesTotal:       c.cw.Tot(),
+		StartedAt:           c.startTime,
+		Compression:         compression,
+		InCompressionRatio:  c.inCompression.Ratio(),
+		OutCompressionRatio: c.outCompression.Ratio(),
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"runtime"
@@ -31,9 +32,9 @@ func TestPing(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -55,9 +56,9 @@ func TestClose(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways)
+	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways, MessageCompressionLZ4, 0, nil)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -80,7 +81,7 @@ func TestClose(t *testing.T) {
 	c0.Index(ctx, "default", nil)
 	c0.Index(ctx, "default", nil)
 
-	if _, err := c0.Request(ctx, "default", "foo", 0, 0, nil, 0, false); err == nil {
+	if _, err := c0.Request(ctx, "default", "foo", 0, 0, 0, nil, 0, false); err == nil {
 		t.Error("Request should return an error")
 	}
 }
@@ -97,7 +98,7 @@ func TestCloseOnBlockingSend(t *testing.T) {
 
 	m := newTestModel()
 
-	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.BlockingRW{}, m, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.BlockingRW{}, m, "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c.Start()
 
 	wg := sync.WaitGroup{}
@@ -147,9 +148,9 @@ func TestCloseRace(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, m0, "c0", CompressNever).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, m0, "c0", CompressNever, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, m1, "c1", CompressNever)
+	c1 := NewConnection(c1ID, br, aw, m1, "c1", CompressNever, MessageCompressionLZ4, 0, nil)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -184,7 +185,7 @@ func TestCloseRace(t *testing.T) {
 func TestClusterConfigFirst(t *testing.T) {
 	m := newTestModel()
 
-	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.NoopRW{}, m, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.NoopRW{}, m, "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c.Start()
 
 	select {
@@ -234,7 +235,7 @@ func TestCloseTimeout(t *testing.T) {
 
 	m := newTestModel()
 
-	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.BlockingRW{}, m, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.BlockingRW{}, m, "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c.Start()
 
 	done := make(chan struct{})
@@ -273,6 +274,15 @@ func TestMarshalIndexMessage(t *testing.T) {
 			if len(f.Version.Counters) == 0 {
 				m1.Files[i].Version.Counters = nil
 			}
+			if len(f.Xattrs) == 0 {
+				m1.Files[i].Xattrs = nil
+			} else {
+				for j := range f.Xattrs {
+					if len(f.Xattrs[j].Value) == 0 {
+						f.Xattrs[j].Value = nil
+					}
+				}
+			}
 		}
 
 		return testMarshal(t, "index", &m1, &Index{})
@@ -469,6 +479,166 @@ func TestStressLZ4CompressGrows(t *testing.T) {
 	}
 }
 
+func TestZstdCompression(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		dataLen := 150 + rand.Intn(150)
+		data := make([]byte, dataLen)
+		_, err := io.ReadFull(rand.Reader, data[100:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		comp, err := zstdCompress(data, i)
+		if err != nil {
+			t.Errorf("compressing %d bytes: %v", dataLen, err)
+			continue
+		}
+
+		res, err := zstdDecompress(comp)
+		if err != nil {
+			t.Errorf("decompressing %d bytes to %d: %v", len(comp), dataLen, err)
+			continue
+		}
+		if !bytes.Equal(data, res) {
+			t.Error("Incorrect decompressed data")
+		}
+	}
+
+	if _, err := zstdDecompress([]byte{0, 0, 1, 0, 1, 2, 3}); err == nil {
+		t.Error("expected error decompressing garbage")
+	}
+}
+
+func TestNegotiateMessageCompression(t *testing.T) {
+	if mc := NegotiateMessageCompression(nil); mc != MessageCompressionLZ4 {
+		t.Errorf("got %v for a device not advertising compressions, expected LZ4", mc)
+	}
+	if mc := NegotiateMessageCompression(LocalMessageCompressions()); mc != MessageCompressionZstd {
+		t.Errorf("got %v, expected zstd", mc)
+	}
+}
+
+func TestCompressionStatistics(t *testing.T) {
+	m1 := newTestModel()
+	received := make(chan []FileInfo, 1)
+	m1.indexFn = func(_ DeviceID, _ string, files []FileInfo) {
+		received <- files
+	}
+
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "c0", CompressMetadata, MessageCompressionZstd, 9, nil)
+	c0.Start()
+	defer c0.Close(errManual)
+	c1 := NewConnection(c1ID, br, aw, m1, "c1", CompressMetadata, MessageCompressionLZ4, 0, nil)
+	c1.Start()
+	defer c1.Close(errManual)
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+
+	files := make([]FileInfo, 100)
+	for i := range files {
+		files[i] = FileInfo{Name: fmt.Sprintf("some/rather/long/path/to/dir%d", i), Type: FileInfoTypeDirectory, Permissions: 0755}
+	}
+	if err := c0.Index(context.Background(), "default", files); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-received:
+		if len(got) != len(files) || got[42].Name != files[42].Name {
+			t.Error("received index differs from the sent one")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for index")
+	}
+
+	if s := c0.Statistics(); s.Compression != MessageCompressionZstd || s.OutCompressionRatio <= 1 {
+		t.Errorf("unexpected sender statistics %+v", s)
+	}
+	if s := c1.Statistics(); s.Compression != MessageCompressionLZ4 || s.InCompressionRatio <= 1 {
+		t.Errorf("unexpected receiver statistics %+v", s)
+	}
+}
+
+func TestBatchedRequests(t *testing.T) {
+	m1 := newTestModel()
+	m1.data = []byte("some data")
+
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "c0", CompressNever, MessageCompressionLZ4, 0, nil)
+	c0.SetCapabilities(CapabilityBatchedRequests)
+	c0.Start()
+	defer c0.Close(errManual)
+	c1 := NewConnection(c1ID, br, aw, m1, "c1", CompressNever, MessageCompressionLZ4, 0, nil)
+	c1.SetCapabilities(CapabilityBatchedRequests)
+	c1.Start()
+	defer c1.Close(errManual)
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := c0.Request(ctx, "default", fmt.Sprintf("file%d", i), 0, 0, len(m1.data), nil, 0, false)
+			if err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(data, m1.data) {
+				t.Errorf("got %q, expected %q", data, m1.data)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRequestBatchSize(t *testing.T) {
+	c := &rawConnection{
+		closed:            make(chan struct{}),
+		requestBatchReady: make(chan struct{}, 1),
+	}
+
+	for i := 0; i < maxBatchedRequests+10; i++ {
+		c.queueRequest(Request{ID: int32(i)})
+	}
+	select {
+	case <-c.requestBatchReady:
+	default:
+		t.Fatal("full batch not ready")
+	}
+	if batch := c.takeRequestBatch(); len(batch) != maxBatchedRequests || batch[0].ID != 0 {
+		t.Fatalf("unexpected batch of %d requests", len(batch))
+	}
+
+	// The rest is ready to be sent too.
+	select {
+	case <-c.requestBatchReady:
+	default:
+		t.Fatal("rest of the batch not ready")
+	}
+	if batch := c.takeRequestBatch(); len(batch) != 10 || batch[0].ID != maxBatchedRequests {
+		t.Fatalf("unexpected batch of %d requests", len(batch))
+	}
+	if batch := c.takeRequestBatch(); len(batch) != 0 {
+		t.Fatalf("unexpected batch of %d requests", len(batch))
+	}
+
+	// A single request is sent right away.
+	c.queueRequest(Request{ID: 1})
+	select {
+	case <-c.requestBatchReady:
+	default:
+		t.Fatal("single request not ready")
+	}
+	if batch := c.takeRequestBatch(); len(batch) != 1 {
+		t.Fatalf("unexpected batch of %d requests", len(batch))
+	}
+}
+
 func TestCheckFilename(t *testing.T) {
 	cases := []struct {
 		name string
@@ -628,6 +798,7 @@ func TestIsEquivalent(t *testing.T) {
 		b         FileInfo
 		ignPerms  *bool // nil means should not matter, we'll test both variants
 		ignBlocks *bool
+		ignXattrs *bool
 		ignFlags  uint32
 		eq        bool
 	}
@@ -725,6 +896,35 @@ func TestIsEquivalent(t *testing.T) {
 			eq:        true,
 		},
 
+		// Difference in extended attributes is not OK
+		{
+			a:         FileInfo{Xattrs: []Xattr{{Name: "user.foo", Value: []byte("a")}}},
+			b:         FileInfo{Xattrs: []Xattr{{Name: "user.foo", Value: []byte("b")}}},
+			ignXattrs: b(false),
+			eq:        false,
+		},
+		{
+			a:         FileInfo{Type: FileInfoTypeDirectory, Xattrs: []Xattr{{Name: "user.foo"}}},
+			b:         FileInfo{Type: FileInfoTypeDirectory},
+			ignXattrs: b(false),
+			eq:        false,
+		},
+
+		// ... unless we say it is
+		{
+			a:         FileInfo{Xattrs: []Xattr{{Name: "user.foo", Value: []byte("a")}}},
+			b:         FileInfo{Xattrs: []Xattr{{Name: "user.bar", Value: []byte("a")}}},
+			ignXattrs: b(true),
+			eq:        true,
+		},
+
+		// ... and they are never checked for symlinks
+		{
+			a:  FileInfo{Type: FileInfoTypeSymlink, Xattrs: []Xattr{{Name: "user.foo"}}},
+			b:  FileInfo{Type: FileInfoTypeSymlink},
+			eq: true,
+		},
+
 		// Difference in permissions is not OK.
 		{
 			a:        FileInfo{Permissions: 0444},
@@ -798,18 +998,23 @@ func TestIsEquivalent(t *testing.T) {
 		// in the tests.
 		for _, ignPerms := range []bool{true, false} {
 			for _, ignBlocks := range []bool{true, false} {
-				if tc.ignPerms != nil && *tc.ignPerms != ignPerms {
-					continue
-				}
-				if tc.ignBlocks != nil && *tc.ignBlocks != ignBlocks {
-					continue
-				}
+				for _, ignXattrs := range []bool{true, false} {
+					if tc.ignPerms != nil && *tc.ignPerms != ignPerms {
+						continue
+					}
+					if tc.ignBlocks != nil && *tc.ignBlocks != ignBlocks {
+						continue
+					}
+					if tc.ignXattrs != nil && *tc.ignXattrs != ignXattrs {
+						continue
+					}
 
-				if res := tc.a.isEquivalent(tc.b, 0, ignPerms, ignBlocks, tc.ignFlags); res != tc.eq {
-					t.Errorf("Case %d:\na: %v\nb: %v\na.IsEquivalent(b, %v, %v) => %v, expected %v", i, tc.a, tc.b, ignPerms, ignBlocks, res, tc.eq)
-				}
-				if res := tc.b.isEquivalent(tc.a, 0, ignPerms, ignBlocks, tc.ignFlags); res != tc.eq {
-					t.Errorf("Case %d:\na: %v\nb: %v\nb.IsEquivalent(a, %v, %v) => %v, expected %v", i, tc.a, tc.b, ignPerms, ignBlocks, res, tc.eq)
+					if res := tc.a.isEquivalent(tc.b, 0, ignPerms, ignBlocks, ignXattrs, tc.ignFlags); res != tc.eq {
+						t.Errorf("Case %d:\na: %v\nb: %v\na.IsEquivalent(b, %v, %v, %v) => %v, expected %v", i, tc.a, tc.b, ignPerms, ignBlocks, ignXattrs, res, tc.eq)
+					}
+					if res := tc.b.isEquivalent(tc.a, 0, ignPerms, ignBlocks, ignXattrs, tc.ignFlags); res != tc.eq {
+						t.Errorf("Case %d:\na: %v\nb: %v\nb.IsEquivalent(a, %v, %v, %v) => %v, expected %v", i, tc.a, tc.b, ignPerms, ignBlocks, ignXattrs, res, tc.eq)
+					}
 				}
 			}
 		}
@@ -831,7 +1036,7 @@ func TestSha256OfEmptyBlock(t *testing.T) {
 func TestClusterConfigAfterClose(t *testing.T) {
 	m := newTestModel()
 
-	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.BlockingRW{}, m, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.BlockingRW{}, m, "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c.Start()
 
 	c.internalClose(errManual)
@@ -853,7 +1058,7 @@ func TestDispatcherToCloseDeadlock(t *testing.T) {
 	// Verify that we don't deadlock when calling Close() from within one of
 	// the model callbacks (ClusterConfig).
 	m := newTestModel()
-	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.NoopRW{}, m, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c := NewConnection(c0ID, &testutils.BlockingRW{}, &testutils.NoopRW{}, m, "name", CompressAlways, MessageCompressionLZ4, 0, nil).(wireFormatConnection).Connection.(*rawConnection)
 	m.ccFn = func(devID DeviceID, cc ClusterConfig) {
 		c.Close(errManual)
 	}
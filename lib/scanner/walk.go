@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -57,8 +58,14 @@ type Config struct {
 	LocalFlags uint32
 	// Modification time is to be considered unchanged if the difference is lower.
 	ModTimeWindow time.Duration
+	// If XattrFilter is not nil, the extended attributes it permits are
+	// picked up and changes to them are detected.
+	XattrFilter fs.XattrFilter
 	// Event logger to which the scan progress events are sent
 	EventLogger events.Logger
+	// If Selected is not nil, only the paths for which it returns true are
+	// walked, for selective sync.
+	Selected func(path string) bool
 }
 
 type CurrentFiler interface {
@@ -241,6 +248,11 @@ func (w *walker) walkAndHashFiles(ctx context.Context, toHashChan chan<- protoco
 			return skip
 		}
 
+		if w.Selected != nil && !w.Selected(path) {
+			l.Debugln("not selected:", path)
+			return skip
+		}
+
 		if w.Matcher.Match(path).IsIgnored() {
 			l.Debugln("ignored (patterns):", path)
 			// Only descend if matcher says so and the current file is not a symlink.
@@ -322,13 +334,13 @@ func (w *walker) handleItem(ctx context.Context, path string, info fs.FileInfo,
 		err = w.walkDir(ctx, path, info, finishedChan)
 
 	case info.IsRegular():
-		err = w.walkRegular(ctx, path, info, toHashChan)
+		err = w.walkRegular(ctx, path, info, toHashChan, finishedChan)
 	}
 
 	return err
 }
 
-func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileInfo, toHashChan chan<- protocol.FileInfo) error {
+func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileInfo, toHashChan chan<- protocol.FileInfo, finishedChan chan<- ScanResult) error {
 	curFile, hasCurFile := w.CurrentFiler.CurrentFile(relPath)
 
 	blockSize := protocol.BlockSize(info.Size())
@@ -347,13 +359,17 @@ func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileIn
 		}
 	}
 
-	f, _ := CreateFileInfo(info, relPath, nil)
+	f, err := CreateFileInfo(info, relPath, w.Filesystem, w.XattrFilter)
+	if err != nil {
+		w.handleError(ctx, "reading extended attributes:", relPath, err, finishedChan)
+		return nil
+	}
 	f = w.updateFileInfo(f, curFile)
 	f.NoPermissions = w.IgnorePerms
 	f.RawBlockSize = int32(blockSize)
 
 	if hasCurFile {
-		if curFile.IsEquivalentOptional(f, w.ModTimeWindow, w.IgnorePerms, true, w.LocalFlags) {
+		if curFile.IsEquivalentOptional(f, w.ModTimeWindow, w.IgnorePerms, true, w.XattrFilter == nil, w.LocalFlags) {
 			return nil
 		}
 		if curFile.ShouldConflict() {
@@ -381,12 +397,16 @@ func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileIn
 func (w *walker) walkDir(ctx context.Context, relPath string, info fs.FileInfo, finishedChan chan<- ScanResult) error {
 	curFile, hasCurFile := w.CurrentFiler.CurrentFile(relPath)
 
-	f, _ := CreateFileInfo(info, relPath, nil)
+	f, err := CreateFileInfo(info, relPath, w.Filesystem, w.XattrFilter)
+	if err != nil {
+		w.handleError(ctx, "reading extended attributes:", relPath, err, finishedChan)
+		return nil
+	}
 	f = w.updateFileInfo(f, curFile)
 	f.NoPermissions = w.IgnorePerms
 
 	if hasCurFile {
-		if curFile.IsEquivalentOptional(f, w.ModTimeWindow, w.IgnorePerms, true, w.LocalFlags) {
+		if curFile.IsEquivalentOptional(f, w.ModTimeWindow, w.IgnorePerms, true, w.XattrFilter == nil, w.LocalFlags) {
 			return nil
 		}
 		if curFile.ShouldConflict() {
@@ -419,7 +439,7 @@ func (w *walker) walkSymlink(ctx context.Context, relPath string, info fs.FileIn
 		return nil
 	}
 
-	f, err := CreateFileInfo(info, relPath, w.Filesystem)
+	f, err := CreateFileInfo(info, relPath, w.Filesystem, nil)
 	if err != nil {
 		w.handleError(ctx, "reading link:", relPath, err, finishedChan)
 		return nil
@@ -430,7 +450,7 @@ func (w *walker) walkSymlink(ctx context.Context, relPath string, info fs.FileIn
 	f = w.updateFileInfo(f, curFile)
 
 	if hasCurFile {
-		if curFile.IsEquivalentOptional(f, w.ModTimeWindow, w.IgnorePerms, true, w.LocalFlags) {
+		if curFile.IsEquivalentOptional(f, w.ModTimeWindow, w.IgnorePerms, true, w.XattrFilter == nil, w.LocalFlags) {
 			return nil
 		}
 		if curFile.ShouldConflict() {
@@ -520,12 +540,33 @@ func (w *walker) updateFileInfo(file, curFile protocol.FileInfo) protocol.FileIn
 		// from there.
 		file.Permissions |= (curFile.Permissions & 0111)
 	}
+	if file.Type != protocol.FileInfoTypeSymlink && file.Type == curFile.Type {
+		// Extended attributes we don't look at ourselves (everything, if
+		// we don't sync them at all) are kept as they were, so we don't
+		// remove them for other devices that do.
+		file.Xattrs = mergeXattrs(file.Xattrs, curFile.Xattrs, w.XattrFilter)
+	}
 	file.Version = curFile.Version.Update(w.ShortID)
 	file.ModifiedBy = w.ShortID
 	file.LocalFlags = w.LocalFlags
 	return file
 }
 
+// mergeXattrs returns the given extended attributes plus those from the
+// previous ones that are not permitted by the filter, sorted by name. A nil
+// filter permits nothing.
+func mergeXattrs(xattrs, prev []protocol.Xattr, filter fs.XattrFilter) []protocol.Xattr {
+	for _, x := range prev {
+		if filter == nil || !filter.Permit(x.Name) {
+			xattrs = append(xattrs, x)
+		}
+	}
+	sort.Slice(xattrs, func(a, b int) bool {
+		return xattrs[a].Name < xattrs[b].Name
+	})
+	return xattrs
+}
+
 func (w *walker) handleError(ctx context.Context, context, path string, err error, finishedChan chan<- ScanResult) {
 	// Ignore missing items, as deletions are not handled by the scanner.
 	if fs.IsNotExist(err) {
@@ -594,7 +635,10 @@ func (noCurrentFiler) CurrentFile(name string) (protocol.FileInfo, bool) {
 	return protocol.FileInfo{}, false
 }
 
-func CreateFileInfo(fi fs.FileInfo, name string, filesystem fs.Filesystem) (protocol.FileInfo, error) {
+// CreateFileInfo returns the file info for the given item. The filesystem is
+// used to read symlink targets and, if xattrFilter is not nil, the permitted
+// extended attributes of files and directories.
+func CreateFileInfo(fi fs.FileInfo, name string, filesystem fs.Filesystem, xattrFilter fs.XattrFilter) (protocol.FileInfo, error) {
 	f := protocol.FileInfo{Name: name}
 	if fi.IsSymlink() {
 		f.Type = protocol.FileInfoTypeSymlink
@@ -609,6 +653,15 @@ func CreateFileInfo(fi fs.FileInfo, name string, filesystem fs.Filesystem) (prot
 	f.Permissions = uint32(fi.Mode() & fs.ModePerm)
 	f.ModifiedS = fi.ModTime().Unix()
 	f.ModifiedNs = int32(fi.ModTime().Nanosecond())
+	if xattrFilter != nil {
+		xattrs, err := fs.GetXattrs(filesystem, name, xattrFilter)
+		if err != nil && err != fs.ErrXattrsNotSupported {
+			return protocol.FileInfo{}, err
+		}
+		for _, x := range xattrs {
+			f.Xattrs = append(f.Xattrs, protocol.Xattr{Name: x.Name, Value: x.Value})
+		}
+	}
 	if fi.IsDir() {
 		f.Type = protocol.FileInfoTypeDirectory
 		return f, nil
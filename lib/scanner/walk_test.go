@@ -19,6 +19,7 @@ import (
 	"runtime"
 	rdebug "runtime/debug"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 
@@ -805,6 +806,99 @@ func TestSkipIgnoredDirs(t *testing.T) {
 	}
 }
 
+type prefixXattrFilter string
+
+func (f prefixXattrFilter) Permit(name string) bool {
+	return strings.HasPrefix(name, string(f))
+}
+
+func TestWalkXattrs(t *testing.T) {
+	fss := fs.NewFilesystem(fs.FilesystemTypeFake, "/TestWalkXattrs")
+
+	if err := fss.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := fss.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	for _, x := range []struct{ name, attr, value string }{
+		{"file", "user.a", "a"},
+		{"file", "trusted.b", "b"},
+		{"dir", "user.c", "c"},
+	} {
+		if err := fss.SetXattr(x.name, x.attr, []byte(x.value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current := make(fakeCurrentFiler)
+	walk := func() []protocol.FileInfo {
+		t.Helper()
+		cfg := testConfig()
+		cfg.Filesystem = fss
+		cfg.CurrentFiler = current
+		cfg.XattrFilter = prefixXattrFilter("user.")
+		var files []protocol.FileInfo
+		for res := range Walk(context.TODO(), cfg) {
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			files = append(files, res.File)
+		}
+		sort.Sort(fileList(files))
+		return files
+	}
+
+	// The initial scan picks up only the permitted attributes.
+
+	files := walk()
+	if len(files) != 2 {
+		t.Fatalf("expected two items, got %v", files)
+	}
+	if x := files[0].Xattrs; len(x) != 1 || x[0].Name != "user.c" || string(x[0].Value) != "c" {
+		t.Errorf("unexpected xattrs on dir: %v", x)
+	}
+	if x := files[1].Xattrs; len(x) != 1 || x[0].Name != "user.a" || string(x[0].Value) != "a" {
+		t.Errorf("unexpected xattrs on file: %v", x)
+	}
+
+	// Attributes we don't sync ourselves, as received from another device,
+	// don't cause a change.
+
+	file := files[1]
+	file.Xattrs = append([]protocol.Xattr{{Name: "security.d", Value: []byte("d")}}, file.Xattrs...)
+	current["dir"] = files[0]
+	current["file"] = file
+	if files := walk(); len(files) != 0 {
+		t.Fatalf("expected no changes, got %v", files)
+	}
+
+	// A change to a permitted attribute alone is a change to the file, and
+	// the others are retained.
+
+	if err := fss.SetXattr("file", "user.a", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	oldVersion := file.Version.Copy()
+	files = walk()
+	if len(files) != 1 || files[0].Name != "file" {
+		t.Fatalf("expected the file to change, got %v", files)
+	}
+	expected := []protocol.Xattr{
+		{Name: "security.d", Value: []byte("d")},
+		{Name: "user.a", Value: []byte("changed")},
+	}
+	if !protocol.XattrsEqual(files[0].Xattrs, expected) {
+		t.Errorf("got xattrs %v, expected %v", files[0].Xattrs, expected)
+	}
+	if files[0].Version.Compare(oldVersion) != protocol.Greater {
+		t.Errorf("version should have been updated: %v -> %v", oldVersion, files[0].Version)
+	}
+}
+
 // https://github.com/syncthing/syncthing/issues/6487
 func TestIncludedSubdir(t *testing.T) {
 	fss := fs.NewFilesystem(fs.FilesystemTypeFake, "")
@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
@@ -126,3 +127,26 @@ func copyFile(src, dst string) error {
 func OpenDBBackend(path string, tuning config.Tuning) (backend.Backend, error) {
 	return backend.Open(path, backend.Tuning(tuning))
 }
+
+// importStagedDB imports the database export staged at path, if any, and
+// removes it. A failed import is moved aside so it isn't retried on every
+// start.
+func importStagedDB(ll *db.Lowlevel, path string) error {
+	fd, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	l.Infoln("Importing staged database export", path)
+	err = ll.Import(fd)
+	fd.Close()
+	if err != nil {
+		if renameErr := os.Rename(path, path+".failed"); renameErr != nil {
+			l.Warnln("Moving aside failed database import:", renameErr)
+		}
+		return errors.Wrap(err, "importing database")
+	}
+	return os.Remove(path)
+}
@@ -111,6 +111,14 @@ func (v external) GetVersions() (map[string][]FileVersion, error) {
 	return nil, ErrRestorationNotSupported
 }
 
+func (v external) GetFileVersions(filePath string) ([]FileVersion, error) {
+	return nil, ErrRestorationNotSupported
+}
+
 func (v external) Restore(filePath string, versionTime time.Time) error {
 	return ErrRestorationNotSupported
 }
+
+func (v external) Open(filePath string, versionTime time.Time) (fs.File, error) {
+	return nil, ErrRestorationNotSupported
+}
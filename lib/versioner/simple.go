@@ -67,6 +67,14 @@ func (v simple) GetVersions() (map[string][]FileVersion, error) {
 	return retrieveVersions(v.versionsFs)
 }
 
+func (v simple) GetFileVersions(filepath string) ([]FileVersion, error) {
+	return retrieveFileVersions(v.versionsFs, filepath)
+}
+
 func (v simple) Restore(filepath string, versionTime time.Time) error {
 	return restoreFile(v.versionsFs, v.folderFs, filepath, versionTime, TagFilename)
 }
+
+func (v simple) Open(filepath string, versionTime time.Time) (fs.File, error) {
+	return openVersion(v.versionsFs, filepath, versionTime, TagFilename)
+}
@@ -9,6 +9,7 @@ package versioner
 import (
 	"io/ioutil"
 	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -87,3 +88,54 @@ func TestSimpleVersioningVersionCount(t *testing.T) {
 		time.Sleep(time.Second)
 	}
 }
+
+func TestSimpleVersioningOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	folderFs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
+	v := newSimple(folderFs, map[string]string{"keep": "2"})
+
+	if err := folderFs.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, folderFs, "dir/file.txt", "A")
+	if err := v.Archive("dir/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions["dir/file.txt"]) != 1 {
+		t.Fatalf("unexpected versions %v", versions)
+	}
+
+	if fileVersions, err := v.GetFileVersions("dir/file.txt"); err != nil {
+		t.Fatal(err)
+	} else if len(fileVersions) != 1 || fileVersions[0] != versions["dir/file.txt"][0] {
+		t.Errorf("unexpected file versions %v", fileVersions)
+	}
+	if fileVersions, err := v.GetFileVersions("other/file.txt"); err != nil || len(fileVersions) != 0 {
+		t.Errorf("unexpected file versions %v (%v) of a file never archived", fileVersions, err)
+	}
+
+	fd, err := v.Open("dir/file.txt", versions["dir/file.txt"][0].VersionTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if bs, err := ioutil.ReadAll(fd); err != nil {
+		t.Fatal(err)
+	} else if string(bs) != "A" {
+		t.Errorf("expected A got %s", bs)
+	}
+
+	if _, err := v.Open("dir/file.txt", time.Now().Add(time.Hour)); err == nil {
+		t.Error("expected error opening a nonexistent version")
+	}
+}
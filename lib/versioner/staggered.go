@@ -229,10 +229,18 @@ func (v *staggered) GetVersions() (map[string][]FileVersion, error) {
 	return retrieveVersions(v.versionsFs)
 }
 
+func (v *staggered) GetFileVersions(filepath string) ([]FileVersion, error) {
+	return retrieveFileVersions(v.versionsFs, filepath)
+}
+
 func (v *staggered) Restore(filepath string, versionTime time.Time) error {
 	return restoreFile(v.versionsFs, v.folderFs, filepath, versionTime, TagFilename)
 }
 
+func (v *staggered) Open(filepath string, versionTime time.Time) (fs.File, error) {
+	return openVersion(v.versionsFs, filepath, versionTime, TagFilename)
+}
+
 func (v *staggered) String() string {
 	return fmt.Sprintf("Staggered/@%p", v)
 }
@@ -125,6 +125,10 @@ func (t *trashcan) GetVersions() (map[string][]FileVersion, error) {
 	return retrieveVersions(t.versionsFs)
 }
 
+func (t *trashcan) GetFileVersions(filepath string) ([]FileVersion, error) {
+	return retrieveFileVersions(t.versionsFs, filepath)
+}
+
 func (t *trashcan) Restore(filepath string, versionTime time.Time) error {
 	// If we have an untagged file A and want to restore it on top of existing file A, we can't first archive the
 	// existing A as we'd overwrite the old A version, therefore when we archive existing file, we archive it with a
@@ -151,3 +155,9 @@ func (t *trashcan) Restore(filepath string, versionTime time.Time) error {
 
 	return t.versionsFs.Rename(taggedName, filepath)
 }
+
+func (t *trashcan) Open(filepath string, versionTime time.Time) (fs.File, error) {
+	return openVersion(t.versionsFs, filepath, versionTime, func(name, tag string) string {
+		return name
+	})
+}
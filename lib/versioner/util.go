@@ -90,41 +90,73 @@ func retrieveVersions(fileSystem fs.Filesystem) (map[string][]FileVersion, error
 			return nil
 		}
 
-		modTime := f.ModTime().Truncate(time.Second)
-
-		path = osutil.NormalizedFilename(path)
-
-		name, tag := UntagFilename(path)
-		// Something invalid, assume it's an untagged file (trashcan versioner stuff)
-		if name == "" || tag == "" {
-			files[path] = append(files[path], FileVersion{
-				VersionTime: modTime,
-				ModTime:     modTime,
-				Size:        f.Size(),
-			})
-			return nil
+		if name, version, ok := parseVersion(path, f); ok {
+			files[name] = append(files[name], version)
 		}
+		return nil
+	})
 
-		versionTime, err := time.ParseInLocation(TimeFormat, tag, time.Local)
-		if err != nil {
-			// Can't parse it, welp, continue
-			return nil
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// retrieveFileVersions returns the versions of a single file, looking only
+// in the directory it's archived in.
+func retrieveFileVersions(fileSystem fs.Filesystem, filePath string) ([]FileVersion, error) {
+	filePath = osutil.NormalizedFilename(filePath)
+	dir := filepath.Dir(osutil.NativeFilename(filePath))
+	names, err := fileSystem.DirNames(dir)
+	if fs.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var versions []FileVersion
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		f, err := fileSystem.Lstat(path)
+		if err != nil || !f.IsRegular() {
+			continue
+		}
+		if name, version, ok := parseVersion(path, f); ok && name == filePath {
+			versions = append(versions, version)
 		}
+	}
+	return versions, nil
+}
 
-		files[name] = append(files[name], FileVersion{
-			VersionTime: versionTime,
+// parseVersion returns the name of the file the archived file at path is a
+// version of, and the details of that version.
+func parseVersion(path string, f fs.FileInfo) (string, FileVersion, bool) {
+	modTime := f.ModTime().Truncate(time.Second)
+
+	path = osutil.NormalizedFilename(path)
+
+	name, tag := UntagFilename(path)
+	// Something invalid, assume it's an untagged file (trashcan versioner stuff)
+	if name == "" || tag == "" {
+		return path, FileVersion{
+			VersionTime: modTime,
 			ModTime:     modTime,
 			Size:        f.Size(),
-		})
-
-		return nil
-	})
+		}, true
+	}
 
+	versionTime, err := time.ParseInLocation(TimeFormat, tag, time.Local)
 	if err != nil {
-		return nil, err
+		// Can't parse it, welp, continue
+		return "", FileVersion{}, false
 	}
 
-	return files, nil
+	return name, FileVersion{
+		VersionTime: versionTime,
+		ModTime:     modTime,
+		Size:        f.Size(),
+	}, true
 }
 
 type fileTagger func(string, string) string
@@ -212,28 +244,7 @@ func restoreFile(src, dst fs.Filesystem, filePath string, versionTime time.Time,
 
 	filePath = osutil.NativeFilename(filePath)
 
-	// Try and find a file that has the correct mtime
-	sourceFile := ""
-	sourceMtime := time.Time{}
-	if info, err := src.Lstat(taggedFilePath); err == nil && info.IsRegular() {
-		sourceFile = taggedFilePath
-		sourceMtime = info.ModTime()
-	} else if err == nil {
-		l.Debugln("restore:", taggedFilePath, "not regular")
-	} else {
-		l.Debugln("restore:", taggedFilePath, err.Error())
-	}
-
-	// Check for untagged file
-	if sourceFile == "" {
-		info, err := src.Lstat(filePath)
-		if err == nil && info.IsRegular() && info.ModTime().Truncate(time.Second).Equal(versionTime) {
-			sourceFile = filePath
-			sourceMtime = info.ModTime()
-		}
-
-	}
-
+	sourceFile, sourceMtime := findVersion(src, taggedFilePath, filePath, versionTime)
 	if sourceFile == "" {
 		return errNotFound
 	}
@@ -252,6 +263,39 @@ func restoreFile(src, dst fs.Filesystem, filePath string, versionTime time.Time,
 	return err
 }
 
+// findVersion returns the path and modification time of the version of
+// filePath archived at versionTime, or an empty path if there is none.
+func findVersion(src fs.Filesystem, taggedFilePath, filePath string, versionTime time.Time) (string, time.Time) {
+	// Try and find a file that has the correct mtime
+	if info, err := src.Lstat(taggedFilePath); err == nil && info.IsRegular() {
+		return taggedFilePath, info.ModTime()
+	} else if err == nil {
+		l.Debugln("restore:", taggedFilePath, "not regular")
+	} else {
+		l.Debugln("restore:", taggedFilePath, err.Error())
+	}
+
+	// Check for untagged file
+	info, err := src.Lstat(filePath)
+	if err == nil && info.IsRegular() && info.ModTime().Truncate(time.Second).Equal(versionTime) {
+		return filePath, info.ModTime()
+	}
+
+	return "", time.Time{}
+}
+
+// openVersion opens the version of filePath archived at versionTime for
+// reading.
+func openVersion(src fs.Filesystem, filePath string, versionTime time.Time, tagger fileTagger) (fs.File, error) {
+	tag := versionTime.In(time.Local).Truncate(time.Second).Format(TimeFormat)
+	filePath = osutil.NativeFilename(filePath)
+	sourceFile, _ := findVersion(src, tagger(filePath, tag), filePath, versionTime)
+	if sourceFile == "" {
+		return nil, errNotFound
+	}
+	return src.Open(sourceFile)
+}
+
 func fsFromParams(folderFs fs.Filesystem, params map[string]string) (versionsFs fs.Filesystem) {
 	if params["fsType"] == "" && params["fsPath"] == "" {
 		versionsFs = fs.NewFilesystem(folderFs.Type(), filepath.Join(folderFs.URI(), ".stversions"))
@@ -20,7 +20,9 @@ import (
 type Versioner interface {
 	Archive(filePath string) error
 	GetVersions() (map[string][]FileVersion, error)
+	GetFileVersions(filePath string) ([]FileVersion, error)
 	Restore(filePath string, versionTime time.Time) error
+	Open(filePath string, versionTime time.Time) (fs.File, error)
 }
 
 type FileVersion struct {
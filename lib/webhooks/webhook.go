@@ -0,0 +1,345 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 5 * time.Minute
+	postTimeout   = time.Minute
+)
+
+type webhook struct {
+	cfg     config.WebhookConfiguration
+	mask    events.EventType
+	folders map[string]struct{}
+	devices map[protocol.DeviceID]struct{}
+	spool   string
+	client  *http.Client
+	full    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mut      sync.Mutex
+	queue    []json.RawMessage
+	inflight int  // events at the start of the queue being delivered
+	dirty    bool // the queue differs from the spool
+	status   Status
+}
+
+func newWebhook(cfg config.WebhookConfiguration, spoolDir string) *webhook {
+	w := &webhook{
+		cfg:   cfg,
+		mask:  DefaultEventMask,
+		spool: filepath.Join(spoolDir, hex.EncodeToString([]byte(cfg.ID))+".json"),
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: dialer.DialContext,
+				Proxy:       http.ProxyFromEnvironment,
+			},
+			Timeout: postTimeout,
+		},
+		full:   make(chan struct{}, 1),
+		mut:    sync.NewMutex(),
+		status: Status{URL: cfg.URL},
+	}
+
+	if len(cfg.Events) > 0 {
+		w.mask = 0
+		for _, name := range cfg.Events {
+			w.mask |= events.UnmarshalEventType(name)
+		}
+	}
+	if len(cfg.Folders) > 0 {
+		w.folders = make(map[string]struct{}, len(cfg.Folders))
+		for _, folder := range cfg.Folders {
+			w.folders[folder] = struct{}{}
+		}
+	}
+	if len(cfg.Devices) > 0 {
+		w.devices = make(map[protocol.DeviceID]struct{}, len(cfg.Devices))
+		for _, device := range cfg.Devices {
+			w.devices[device] = struct{}{}
+		}
+	}
+
+	w.loadSpool()
+	return w
+}
+
+func (w *webhook) start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+	go w.serve(ctx)
+}
+
+// stop stops delivery and waits for the spool to be saved.
+func (w *webhook) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// matches returns whether the event should be delivered to the webhook.
+func (w *webhook) matches(ev events.Event) bool {
+	if ev.Type&w.mask == 0 {
+		return false
+	}
+	if w.folders != nil {
+		if _, ok := w.folders[eventField(ev.Data, "folder")]; !ok {
+			return false
+		}
+	}
+	if w.devices != nil {
+		id, err := protocol.DeviceIDFromString(eventField(ev.Data, "device", "id"))
+		if err != nil {
+			return false
+		}
+		if _, ok := w.devices[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// eventField returns the first of the given fields present in the event
+// data, or the empty string.
+func eventField(data interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch data := data.(type) {
+		case map[string]string:
+			if val, ok := data[key]; ok {
+				return val
+			}
+		case map[string]interface{}:
+			if val, ok := data[key].(string); ok {
+				return val
+			}
+		}
+	}
+	return ""
+}
+
+func (w *webhook) enqueue(msg json.RawMessage) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	w.queue = append(w.queue, msg)
+	w.dirty = true
+	if over := len(w.queue) - w.cfg.SpoolSize(); over > 0 {
+		// Drop the oldest events, except those being delivered.
+		if max := len(w.queue) - w.inflight; over > max {
+			over = max
+		}
+		n := copy(w.queue[w.inflight:], w.queue[w.inflight+over:])
+		w.queue = w.queue[:w.inflight+n]
+		w.status.Dropped += over
+	}
+
+	if len(w.queue) >= w.cfg.BatchSize() {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *webhook) serve(ctx context.Context) {
+	defer close(w.done)
+	defer w.saveSpool()
+
+	interval := w.cfg.BatchInterval()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	var retryDelay time.Duration
+	for {
+		select {
+		case <-timer.C:
+		case <-w.full:
+			if retryDelay > 0 {
+				// Waiting to retry.
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		w.saveSpool()
+		if err := w.deliver(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			retryDelay *= 2
+			if retryDelay < minRetryDelay {
+				retryDelay = minRetryDelay
+			} else if retryDelay > maxRetryDelay {
+				retryDelay = maxRetryDelay
+			}
+			l.Debugf("delivering to webhook %v: %v (retrying in %v)", w.cfg.ID, err, retryDelay)
+			timer.Reset(retryDelay)
+			continue
+		}
+		retryDelay = 0
+		timer.Reset(interval)
+	}
+}
+
+// deliver posts the queued events in batches, until the queue is empty or
+// posting fails.
+func (w *webhook) deliver(ctx context.Context) error {
+	for {
+		w.mut.Lock()
+		n := len(w.queue)
+		if n == 0 {
+			w.mut.Unlock()
+			return nil
+		}
+		if max := w.cfg.BatchSize(); n > max {
+			n = max
+		}
+		batch := w.queue[:n:n]
+		w.inflight = n
+		w.status.LastAttempt = time.Now()
+		w.mut.Unlock()
+
+		err := w.post(ctx, batch)
+
+		w.mut.Lock()
+		w.inflight = 0
+		if err != nil {
+			w.status.Error = events.Error(err)
+			w.mut.Unlock()
+			return err
+		}
+		w.queue = w.queue[n:]
+		w.dirty = true
+		w.status.Delivered += n
+		w.status.LastSuccess = time.Now()
+		w.status.Error = nil
+		w.mut.Unlock()
+	}
+}
+
+func (w *webhook) post(ctx context.Context, batch []json.RawMessage) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if w.cfg.Secret != "" {
+		req.Header.Set("X-Syncthing-Signature", "sha256="+Signature(w.cfg.Secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
+
+// Signature returns the hex encoded HMAC-SHA256 of the body, for the
+// X-Syncthing-Signature header.
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhook) getStatus() Status {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	status := w.status
+	status.Pending = len(w.queue)
+	return status
+}
+
+func (w *webhook) loadSpool() {
+	bs, err := ioutil.ReadFile(w.spool)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		l.Infof("Reading spool of webhook %v: %v", w.cfg.ID, err)
+		return
+	}
+	if err := json.Unmarshal(bs, &w.queue); err != nil {
+		l.Infof("Reading spool of webhook %v: %v", w.cfg.ID, err)
+	}
+}
+
+// saveSpool writes the queue to disk, if it changed.
+func (w *webhook) saveSpool() {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if !w.dirty {
+		return
+	}
+	if err := w.writeSpoolLocked(); err != nil {
+		l.Infof("Writing spool of webhook %v: %v", w.cfg.ID, err)
+		return
+	}
+	w.dirty = false
+}
+
+func (w *webhook) writeSpoolLocked() error {
+	if len(w.queue) == 0 {
+		if err := os.Remove(w.spool); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	bs, err := json.Marshal(w.queue)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.spool), 0700); err != nil {
+		return err
+	}
+	fd, err := osutil.CreateAtomic(w.spool)
+	if err != nil {
+		return err
+	}
+	_, _ = fd.Write(bs) // errors are returned by Close
+	return fd.Close()
+}
@@ -0,0 +1,191 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package webhooks posts events to the configured URLs.
+//
+// Events are posted in batches, as a JSON array in the format of the
+// /rest/events endpoint. With a secret, the HMAC-SHA256 of the body is sent
+// in the X-Syncthing-Signature header as "sha256=<hex>". Undelivered events
+// are retried with backoff and spooled to disk, so they survive restarts.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/thejerf/suture"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+// DefaultEventMask is what is delivered to webhooks not listing any event
+// types, the same as the default of the REST API.
+const DefaultEventMask = events.AllEvents &^ events.LocalChangeDetected &^ events.RemoteChangeDetected
+
+type Service interface {
+	suture.Service
+	config.Committer
+	Status() map[string]Status
+}
+
+// Status is the delivery status of a webhook.
+type Status struct {
+	URL         string    `json:"url"`
+	Pending     int       `json:"pending"`
+	Delivered   int       `json:"delivered"`
+	Dropped     int       `json:"dropped"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	Error       *string   `json:"error"`
+}
+
+type service struct {
+	suture.Service
+	cfg      config.Wrapper
+	evLogger events.Logger
+	spoolDir string
+	changed  chan struct{}
+
+	mut      sync.Mutex
+	webhooks map[string]*webhook
+}
+
+func New(cfg config.Wrapper, evLogger events.Logger, spoolDir string) Service {
+	s := &service{
+		cfg:      cfg,
+		evLogger: evLogger,
+		spoolDir: spoolDir,
+		changed:  make(chan struct{}, 1),
+		mut:      sync.NewMutex(),
+		webhooks: make(map[string]*webhook),
+	}
+	s.Service = util.AsService(s.serve, s.String())
+	return s
+}
+
+func (s *service) String() string {
+	return fmt.Sprintf("webhooks.service@%p", s)
+}
+
+func (s *service) serve(ctx context.Context) {
+	s.cfg.Subscribe(s)
+	defer s.cfg.Unsubscribe(s)
+
+	sub := s.evLogger.Subscribe(events.AllEvents)
+	defer sub.Unsubscribe()
+
+	s.setWebhooks(ctx, s.cfg.RawCopy().Webhooks)
+	defer s.stopWebhooks()
+
+	for {
+		// This loop needs to be fast so we don't miss too many events.
+		select {
+		case ev := <-sub.C():
+			s.handle(ev)
+		case <-s.changed:
+			s.setWebhooks(ctx, s.cfg.RawCopy().Webhooks)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *service) handle(ev events.Event) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var msg []byte
+	for _, w := range s.webhooks {
+		if !w.matches(ev) {
+			continue
+		}
+		if msg == nil {
+			var err error
+			if msg, err = json.Marshal(ev); err != nil {
+				l.Debugln("marshalling event:", err)
+				return
+			}
+		}
+		w.enqueue(msg)
+	}
+}
+
+// setWebhooks starts and stops webhooks to match the configuration. Those
+// with unchanged configuration keep running, and the spool of removed ones
+// is discarded.
+func (s *service) setWebhooks(ctx context.Context, cfgs []config.WebhookConfiguration) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	seen := make(map[string]struct{}, len(cfgs))
+	for _, cfg := range cfgs {
+		seen[cfg.ID] = struct{}{}
+		if w, ok := s.webhooks[cfg.ID]; ok {
+			if reflect.DeepEqual(w.cfg, cfg) {
+				continue
+			}
+			w.stop()
+		}
+		w := newWebhook(cfg, s.spoolDir)
+		w.start(ctx)
+		s.webhooks[cfg.ID] = w
+	}
+
+	for id, w := range s.webhooks {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		w.stop()
+		delete(s.webhooks, id)
+		if err := os.Remove(w.spool); err != nil && !os.IsNotExist(err) {
+			l.Infof("Removing spool of webhook %v: %v", id, err)
+		}
+	}
+}
+
+// stopWebhooks stops all webhooks, keeping their spools for the next start.
+func (s *service) stopWebhooks() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for id, w := range s.webhooks {
+		w.stop()
+		delete(s.webhooks, id)
+	}
+}
+
+// Status returns the delivery status of the webhooks, by ID.
+func (s *service) Status() map[string]Status {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	res := make(map[string]Status, len(s.webhooks))
+	for id, w := range s.webhooks {
+		res[id] = w.getStatus()
+	}
+	return res
+}
+
+func (s *service) VerifyConfiguration(from, to config.Configuration) error {
+	return nil
+}
+
+func (s *service) CommitConfiguration(from, to config.Configuration) bool {
+	if !reflect.DeepEqual(from.Webhooks, to.Webhooks) {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
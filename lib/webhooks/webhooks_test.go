@@ -0,0 +1,163 @@
+// Copyright (C) 2020 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var device1, _ = protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+
+func TestMatches(t *testing.T) {
+	w := newWebhook(config.WebhookConfiguration{
+		ID:      "test",
+		Events:  []string{"ItemFinished", "DeviceConnected"},
+		Folders: []string{"a"},
+	}, "")
+
+	cases := []struct {
+		ev      events.Event
+		matches bool
+	}{
+		{events.Event{Type: events.ItemFinished, Data: map[string]interface{}{"folder": "a"}}, true},
+		{events.Event{Type: events.ItemFinished, Data: map[string]interface{}{"folder": "b"}}, false},
+		{events.Event{Type: events.ItemStarted, Data: map[string]interface{}{"folder": "a"}}, false},
+		{events.Event{Type: events.DeviceConnected, Data: map[string]string{"id": device1.String()}}, false},
+	}
+	for i, tc := range cases {
+		if w.matches(tc.ev) != tc.matches {
+			t.Errorf("%d: expected match %v", i, tc.matches)
+		}
+	}
+
+	w = newWebhook(config.WebhookConfiguration{ID: "test", Devices: []protocol.DeviceID{device1}}, "")
+	if !w.matches(events.Event{Type: events.DeviceConnected, Data: map[string]string{"id": device1.String()}}) {
+		t.Error("device event doesn't match")
+	}
+	if w.matches(events.Event{Type: events.DeviceConnected, Data: map[string]string{"id": protocol.LocalDeviceID.String()}}) {
+		t.Error("other device event matches")
+	}
+	if w.matches(events.Event{Type: events.LocalChangeDetected, Data: map[string]string{"device": device1.String()}}) {
+		t.Error("event not in the default mask matches")
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	spoolDir, err := ioutil.TempDir("", "syncthing-webhooks-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+
+	fail := make(chan bool, 1)
+	fail <- true
+	received := make(chan []events.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if sig := r.Header.Get("X-Syncthing-Signature"); sig != "sha256="+Signature("secret", body) {
+			t.Errorf("bad signature %q", sig)
+		}
+		select {
+		case <-fail:
+			http.Error(w, "failing", http.StatusInternalServerError)
+			return
+		default:
+		}
+		var evs []events.Event
+		if err := json.Unmarshal(body, &evs); err != nil {
+			t.Error(err)
+		}
+		received <- evs
+	}))
+	defer srv.Close()
+
+	evLogger := events.NewLogger()
+	go evLogger.Serve()
+	defer evLogger.Stop()
+
+	cfg := config.Wrap("/dev/null", config.New(protocol.LocalDeviceID), evLogger)
+	raw := cfg.RawCopy()
+	raw.Webhooks = []config.WebhookConfiguration{{
+		ID:             "test",
+		URL:            srv.URL,
+		Secret:         "secret",
+		Events:         []string{"ItemFinished"},
+		MaxBatchSize:   2,
+		BatchIntervalS: 1,
+	}}
+	if _, err := cfg.Replace(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := New(cfg, evLogger, spoolDir).(*service)
+	go svc.Serve()
+	// Let the service subscribe to events.
+	time.Sleep(100 * time.Millisecond)
+
+	for _, item := range []string{"a", "b", "c"} {
+		evLogger.Log(events.ItemFinished, map[string]interface{}{"folder": "default", "item": item})
+	}
+	evLogger.Log(events.ItemStarted, map[string]interface{}{"folder": "default", "item": "d"})
+
+	// The first delivery fails and is retried.
+	var items []string
+	for len(items) < 3 {
+		select {
+		case evs := <-received:
+			if len(evs) > 2 {
+				t.Errorf("batch of %d events", len(evs))
+			}
+			for _, ev := range evs {
+				items = append(items, ev.Data.(map[string]interface{})["item"].(string))
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out, got %v", items)
+		}
+	}
+	if items[0] != "a" || items[1] != "b" || items[2] != "c" {
+		t.Errorf("unexpected items %v", items)
+	}
+	// The status is updated once the response is in.
+	var status Status
+	for i := 0; i < 100; i++ {
+		if status = svc.Status()["test"]; status.Delivered == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Delivered != 3 || status.Pending != 0 || status.Error != nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	// Undelivered events are spooled when stopping, and picked up again.
+	fail <- true
+	evLogger.Log(events.ItemFinished, map[string]interface{}{"folder": "default", "item": "e"})
+	time.Sleep(100 * time.Millisecond)
+	svc.Stop()
+
+	svc = New(cfg, evLogger, spoolDir).(*service)
+	go svc.Serve()
+	defer svc.Stop()
+	select {
+	case evs := <-received:
+		if len(evs) != 1 || evs[0].Data.(map[string]interface{})["item"] != "e" {
+			t.Errorf("unexpected events %v after restart", evs)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("spooled event not delivered")
+	}
+}